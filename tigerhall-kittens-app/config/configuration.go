@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"io/ioutil"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -12,15 +13,127 @@ type Config struct {
 	JWT
 	RabbitMq
 	Server
+	Storage
+	SMTP
+	Notifier
+	RateLimit
+	SightingRules
+	Images
+	Uploads
+	Stats
 }
 
 type Server struct {
 	Port string `yaml:"port"`
 }
 
+// RabbitMq selects the message broker, Driver is either "amqp" or "memory". Without
+// a driver RabbitMQ is used when AmqpURL is set, the in process broker otherwise.
 type RabbitMq struct {
+	Driver    string `yaml:"driver"`
 	AmqpURL   string `yaml:"amqpURL"`
 	QueueName string `yaml:"queueName"`
+	// Failed messages are retried with exponential backoff from RetryDelay up to
+	// MaxRetryDelay, after MaxRetries attempts they go to the dead letter queue
+	MaxRetries    int           `yaml:"maxRetries"`
+	RetryDelay    time.Duration `yaml:"retryDelay"`
+	MaxRetryDelay time.Duration `yaml:"maxRetryDelay"`
+}
+
+// SMTP is the mail server used to send sighting notifications.
+type SMTP struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+// Notifier configures the sighting notification emails, BaseURL is used to link to the API.
+type Notifier struct {
+	BaseURL string `yaml:"base_url"`
+}
+
+// RateLimit configures the token buckets limiting the requests of every client IP and
+// user, Driver is either "memory" or "redis". Limits left at zero are not enforced.
+type RateLimit struct {
+	Driver        string `yaml:"driver"`
+	RedisAddr     string `yaml:"redis_addr"`
+	RedisPassword string `yaml:"redis_password"`
+	RedisDB       int    `yaml:"redis_db"`
+	// TrustForwardedFor takes the client IP from the X-Forwarded-For header, only
+	// enable it behind a proxy that sets the header
+	TrustForwardedFor bool          `yaml:"trust_forwarded_for"`
+	Account           RateLimitRule `yaml:"account"`
+	Public            RateLimitRule `yaml:"public"`
+	User              RateLimitRule `yaml:"user"`
+	// Accounts are locked for LockoutDuration after LockoutMaxFailures failed logins
+	// within LockoutWindow
+	LockoutMaxFailures int           `yaml:"lockout_max_failures"`
+	LockoutWindow      time.Duration `yaml:"lockout_window"`
+	LockoutDuration    time.Duration `yaml:"lockout_duration"`
+}
+
+// RateLimitRule allows RequestsPerMinute requests per minute on average and bursts of Burst requests.
+type RateLimitRule struct {
+	RequestsPerMinute int `yaml:"requests_per_minute"`
+	Burst             int `yaml:"burst"`
+}
+
+// SightingRules configures the plausibility checks of new sightings, checks left at
+// zero are disabled. Sightings before the date of birth of the tiger are always rejected.
+type SightingRules struct {
+	// MaxClockSkew is how far in the future a sighting may be reported
+	MaxClockSkew time.Duration `yaml:"max_clock_skew"`
+	// MaxSpeedKmh is the fastest a tiger may travel between two of its sightings
+	MaxSpeedKmh float64 `yaml:"max_speed_kmh"`
+	// A sighting within DuplicateRadiusKm and DuplicateWindow of another is a duplicate
+	DuplicateRadiusKm float64       `yaml:"duplicate_radius_km"`
+	DuplicateWindow   time.Duration `yaml:"duplicate_window"`
+}
+
+// Images configures the renditions every sighting photo is saved in next to the photo as
+// uploaded, the renditions of utils.DefaultRenditions are used when none are configured.
+type Images struct {
+	Renditions []ImageRendition `yaml:"renditions"`
+}
+
+// ImageRendition resizes photos to fit within Width x Height keeping their aspect ratio,
+// or to fill Width x Height cutting off the edges when Crop is set. Formats are "jpeg" or "png".
+type ImageRendition struct {
+	Name    string   `yaml:"name"`
+	Width   int      `yaml:"width"`
+	Height  int      `yaml:"height"`
+	Crop    bool     `yaml:"crop"`
+	Formats []string `yaml:"formats"`
+}
+
+// Uploads limits the requests reporting sightings, limits left at zero keep their default.
+type Uploads struct {
+	// MaxRequestBytes is the size of the largest request accepted, all photos included
+	MaxRequestBytes int64 `yaml:"max_request_bytes"`
+	// Photos wider or higher than MaxImageDimension pixels, or with more than
+	// MaxImagePixels pixels, are rejected
+	MaxImageDimension int `yaml:"max_image_dimension"`
+	MaxImagePixels    int `yaml:"max_image_pixels"`
+}
+
+// Stats configures the movement and population stats, CacheTTL left at zero keeps
+// service.DefaultStatsCacheTTL.
+type Stats struct {
+	// CacheTTL is how long stats are cached, new sightings invalidate them earlier
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+// Storage selects where sighting images are kept, Driver is either "filesystem" or "s3".
+type Storage struct {
+	Driver    string `yaml:"driver"`
+	Dir       string `yaml:"dir"`
+	Endpoint  string `yaml:"endpoint"`
+	Bucket    string `yaml:"bucket"`
+	Region    string `yaml:"region"`
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
 }
 
 type Database struct {
@@ -33,6 +146,21 @@ type Database struct {
 }
 type JWT struct {
 	SecretKey string `yaml:"secret_key"`
+	// AccessTokenTTL is how long access tokens are valid, refresh tokens are used to get new ones
+	AccessTokenTTL time.Duration `yaml:"access_token_ttl"`
+	// SigningKey is the kid of the key in Keys new tokens are signed with, tokens are
+	// signed with SecretKey (HS256) when it is empty
+	SigningKey string   `yaml:"signing_key"`
+	Keys       []JWTKey `yaml:"keys"`
+	// AcceptHS256Until keeps accepting tokens signed with SecretKey after switching to
+	// a signing key, it should be at least AccessTokenTTL after the switch
+	AcceptHS256Until time.Time `yaml:"accept_hs256_until"`
+}
+
+// JWTKey is a PEM encoded RSA or Ed25519 private key tokens are signed and verified with.
+type JWTKey struct {
+	ID             string `yaml:"kid"`
+	PrivateKeyFile string `yaml:"private_key_file"`
 }
 
 func ReadConfig(filename string) (*Config, error) {
@@ -1,16 +1,202 @@
 package main
 
 import (
+	"fmt"
+	"io/ioutil"
 	"log"
 	conf "tigerhall-kittens-app/config"
 	"tigerhall-kittens-app/pkg/auth"
+	"tigerhall-kittens-app/pkg/handlers"
 	"tigerhall-kittens-app/pkg/messaging"
+	"tigerhall-kittens-app/pkg/middleware"
+	"tigerhall-kittens-app/pkg/notifier"
+	"tigerhall-kittens-app/pkg/ratelimit"
 	"tigerhall-kittens-app/pkg/repository"
 	"tigerhall-kittens-app/pkg/server"
 	"tigerhall-kittens-app/pkg/service"
+	"tigerhall-kittens-app/pkg/storage"
+	"tigerhall-kittens-app/pkg/utils"
+	"time"
 )
 
-func initializeService(config *conf.Config) (service.TigerService, error) {
+// outboxPollInterval is how often the outbox relay looks for messages to publish.
+const outboxPollInterval = time.Second
+
+// webhookPollInterval is how often the webhook dispatcher looks for webhooks to send.
+const webhookPollInterval = time.Second
+
+func initializeImageStore(config conf.Storage) (storage.ImageStore, error) {
+	switch config.Driver {
+	case "", "filesystem":
+		return storage.NewFileSystemStore(config.Dir)
+	case "s3":
+		return storage.NewS3Store(storage.S3Config{
+			Endpoint:  config.Endpoint,
+			Bucket:    config.Bucket,
+			Region:    config.Region,
+			AccessKey: config.AccessKey,
+			SecretKey: config.SecretKey,
+		}, nil), nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", config.Driver)
+	}
+}
+
+// memoryQueueSize is the number of messages the in process broker holds.
+const memoryQueueSize = 1000
+
+func initializeMessageBroker(config conf.RabbitMq) (messaging.Broker, error) {
+	switch config.Driver {
+	case "amqp":
+		return messaging.NewMessageBroker(config.AmqpURL, config.QueueName, retryPolicy(config))
+	case "memory":
+		return messaging.NewMemoryBroker(memoryQueueSize, retryPolicy(config)), nil
+	case "":
+		if config.AmqpURL != "" {
+			return messaging.NewMessageBroker(config.AmqpURL, config.QueueName, retryPolicy(config))
+		}
+		log.Printf("No message broker configured, sighting notifications are queued in process")
+		return messaging.NewMemoryBroker(memoryQueueSize, retryPolicy(config)), nil
+	default:
+		return nil, fmt.Errorf("unknown message broker driver %q", config.Driver)
+	}
+}
+
+// retryPolicy falls back to the default retry policy for the settings left out of the configuration.
+func retryPolicy(config conf.RabbitMq) messaging.RetryPolicy {
+	policy := messaging.DefaultRetryPolicy
+	if config.MaxRetries > 0 {
+		policy.MaxRetries = config.MaxRetries
+	}
+	if config.RetryDelay > 0 {
+		policy.BaseDelay = config.RetryDelay
+	}
+	if config.MaxRetryDelay > 0 {
+		policy.MaxDelay = config.MaxRetryDelay
+	}
+	return policy
+}
+
+func initializeRateLimits(config conf.RateLimit) (server.RateLimits, error) {
+	var store ratelimit.Store
+	switch config.Driver {
+	case "", "memory":
+		store = ratelimit.NewMemoryStore()
+	case "redis":
+		var err error
+		if store, err = ratelimit.NewRedisStore(config.RedisAddr, config.RedisPassword, config.RedisDB); err != nil {
+			return server.RateLimits{}, err
+		}
+	default:
+		return server.RateLimits{}, fmt.Errorf("unknown rate limit driver %q", config.Driver)
+	}
+
+	limits := server.RateLimits{
+		Limiter: &middleware.RateLimiter{Store: store, TrustForwardedFor: config.TrustForwardedFor},
+		Account: ratelimit.PerMinute(config.Account.RequestsPerMinute, config.Account.Burst),
+		Public:  ratelimit.PerMinute(config.Public.RequestsPerMinute, config.Public.Burst),
+		User:    ratelimit.PerMinute(config.User.RequestsPerMinute, config.User.Burst),
+	}
+	if config.LockoutMaxFailures > 0 {
+		limits.Lockout = ratelimit.NewLockout(store, config.LockoutMaxFailures, config.LockoutWindow, config.LockoutDuration)
+	}
+	return limits, nil
+}
+
+func initializeAuth(config conf.JWT) (*auth.Auth, error) {
+	authenticator := auth.NewAuth(config.SecretKey)
+	if config.AccessTokenTTL > 0 {
+		authenticator.AccessTokenTTL = config.AccessTokenTTL
+	}
+	if config.SigningKey == "" {
+		return authenticator, nil
+	}
+
+	var keys []*auth.SigningKey
+	for _, keyConfig := range config.Keys {
+		pemData, err := ioutil.ReadFile(keyConfig.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signing key %s: %v", keyConfig.ID, err)
+		}
+		key, err := auth.ParseSigningKey(keyConfig.ID, pemData)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if err := authenticator.UseSigningKeys(keys, config.SigningKey); err != nil {
+		return nil, err
+	}
+	authenticator.AcceptHS256Until = config.AcceptHS256Until
+	return authenticator, nil
+}
+
+func initializeSightingRules(config conf.SightingRules) []service.SightingRule {
+	return service.DefaultSightingRules(service.SightingRulesConfig{
+		MaxClockSkew:      config.MaxClockSkew,
+		MaxSpeedKmh:       config.MaxSpeedKmh,
+		DuplicateRadiusKm: config.DuplicateRadiusKm,
+		DuplicateWindow:   config.DuplicateWindow,
+	})
+}
+
+// initializeUploadLimits returns the configured upload limits, the limits left at zero
+// keep their default.
+func initializeUploadLimits(config conf.Uploads) handlers.UploadLimits {
+	limits := handlers.DefaultUploadLimits
+	if config.MaxRequestBytes > 0 {
+		limits.MaxRequestBytes = config.MaxRequestBytes
+	}
+	if config.MaxImageDimension > 0 {
+		limits.MaxImageDimension = config.MaxImageDimension
+	}
+	if config.MaxImagePixels > 0 {
+		limits.MaxImagePixels = config.MaxImagePixels
+	}
+	return limits
+}
+
+// initializeStatsCacheTTL returns how long stats are cached, service.DefaultStatsCacheTTL
+// unless configured.
+func initializeStatsCacheTTL(config conf.Stats) time.Duration {
+	if config.CacheTTL > 0 {
+		return config.CacheTTL
+	}
+	return service.DefaultStatsCacheTTL
+}
+
+// initializeRenditions returns the configured renditions of sighting photos, or the
+// default ones when none are configured.
+func initializeRenditions(config conf.Images) ([]utils.Rendition, error) {
+	if len(config.Renditions) == 0 {
+		return utils.DefaultRenditions, nil
+	}
+
+	renditions := make([]utils.Rendition, len(config.Renditions))
+	for i, rendition := range config.Renditions {
+		renditions[i] = utils.Rendition{
+			Name:    rendition.Name,
+			Width:   rendition.Width,
+			Height:  rendition.Height,
+			Crop:    rendition.Crop,
+			Formats: rendition.Formats,
+		}
+	}
+	if err := utils.ValidateRenditions(renditions); err != nil {
+		return nil, fmt.Errorf("invalid image renditions: %v", err)
+	}
+	return renditions, nil
+}
+
+// initializeService also keeps the revoked access tokens of authenticator in the database.
+func initializeService(config *conf.Config, authenticator *auth.Auth) (service.TigerService, error) {
+	// Check the configured renditions before connecting to anything
+	renditions, err := initializeRenditions(config.Images)
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize the database connection
 	dbConnectionString := conf.BuildDBConnectionString(config.Database)
 
@@ -18,18 +204,52 @@ func initializeService(config *conf.Config) (service.TigerService, error) {
 	if err != nil {
 		return nil, err
 	}
+	authenticator.Denylist = store
+
+	// Initialize the store for sighting images
+	imageStore, err := initializeImageStore(config.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// Move the images of older sightings from the database to the image store
+	go func() {
+		moved, err := service.NewImageBackfill(store, imageStore).Run()
+		if err != nil {
+			log.Printf("failed to move sighting images to the image store: %v", err)
+		}
+		if moved > 0 {
+			log.Printf("moved %d sighting images to the image store", moved)
+		}
+	}()
 
-	// Initialize the RabbitMQ message broker
-	messageBroker, err := messaging.NewMessageBroker(config.RabbitMq.AmqpURL, config.RabbitMq.QueueName)
+	// Initialize the message broker
+	messageBroker, err := initializeMessageBroker(config.RabbitMq)
 	if err != nil {
 		return nil, err
 	}
 
-	// Start the message consumer in a separate Goroutine
-	go messageBroker.ConsumeMessages(messaging.ProcessMessage)
+	// Send the sighting notifications in a separate Goroutine
+	mailer := notifier.NewSMTPMailer(config.SMTP.Host, config.SMTP.Port, config.SMTP.Username, config.SMTP.Password, config.SMTP.From)
+	sightingNotifier := notifier.NewNotifier(mailer, store, config.Notifier.BaseURL)
+	go messageBroker.ConsumeMessages(sightingNotifier.ProcessMessage)
+
+	// Send the webhooks of the sighting alerts queued by the notifier
+	webhookDispatcher := notifier.NewWebhookDispatcher(store, notifier.NewHTTPWebhookSender(), notifier.DefaultWebhookWorkers, messaging.DefaultRetryPolicy)
+	go webhookDispatcher.Run(webhookPollInterval, nil)
+
+	// Send the hourly digests of the users in digest mode
+	go sightingNotifier.RunDigests(time.Hour, nil)
+
+	// Publish the messages written to the outbox in a separate Goroutine
+	go service.NewOutboxRelay(store, messageBroker).Run(outboxPollInterval, nil)
 
 	// Initialize the service
-	service := service.NewTigerService(store, messageBroker)
+	service := service.NewTigerServiceWithOptions(store, imageStore, service.Options{
+		SightingRules: initializeSightingRules(config.SightingRules),
+		Renditions:    renditions,
+		StatsCacheTTL: initializeStatsCacheTTL(config.Stats),
+	})
 
 	return service, nil
 }
@@ -41,17 +261,31 @@ func main() {
 		log.Fatalf("Failed to read configuration: %v", err)
 	}
 
+	// Initialize the authentication
+	authenticator, err := initializeAuth(config.JWT)
+	if err != nil {
+		log.Fatalf("Failed to initialize the authentication: %v", err)
+	}
+
 	// Initialize the service
-	service, err := initializeService(config)
+	service, err := initializeService(config, authenticator)
 	if err != nil {
 		log.Fatalf("Failed to initialize the service: %v", err)
 	}
 
+	// Initialize the rate limits
+	rateLimits, err := initializeRateLimits(config.RateLimit)
+	if err != nil {
+		log.Fatalf("Failed to initialize the rate limits: %v", err)
+	}
+
 	// Initialize the server
 	srv := server.NewServer()
+	srv.UseRateLimits(rateLimits)
+	srv.UseUploadLimits(initializeUploadLimits(config.Uploads))
 
 	// Set up the routes and handlers
-	srv.SetupRoutes(service, auth.NewAuth(config.JWT.SecretKey))
+	srv.SetupRoutes(service, authenticator)
 
 	// Start the server
 	err = srv.Start(config.Server.Port)
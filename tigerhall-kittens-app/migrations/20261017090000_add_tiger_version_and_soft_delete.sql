@@ -0,0 +1,16 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- Version is bumped on every update and exposed as the ETag of a tiger,
+-- deleted_at marks retired tigers without removing their sightings
+ALTER TABLE tigers ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE tigers ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_tigers_active_last_seen ON tigers (last_seen DESC) WHERE deleted_at IS NULL;
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+
+DROP INDEX IF EXISTS idx_tigers_active_last_seen;
+ALTER TABLE tigers DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE tigers DROP COLUMN IF EXISTS version;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- Sighting images are kept in the image store, the sighting only references them by key.
+-- The image column is no longer written and only holds images of older sightings.
+ALTER TABLE tiger_sightings ADD COLUMN IF NOT EXISTS image_key VARCHAR(255);
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE tiger_sightings DROP COLUMN IF EXISTS image_key;
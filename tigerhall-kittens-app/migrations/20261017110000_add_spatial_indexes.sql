@@ -0,0 +1,12 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- GiST indexes on the position let bounding box queries (point <@ box) avoid full table scans
+CREATE INDEX IF NOT EXISTS idx_tigers_position ON tigers USING gist (point(long, lat)) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_tiger_sightings_position ON tiger_sightings USING gist (point(long, lat));
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+
+DROP INDEX IF EXISTS idx_tiger_sightings_position;
+DROP INDEX IF EXISTS idx_tigers_position;
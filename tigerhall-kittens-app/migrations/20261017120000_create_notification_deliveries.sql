@@ -0,0 +1,15 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- Every email sent by the notifier, so a redelivered message does not mail a recipient twice.
+CREATE TABLE IF NOT EXISTS notification_deliveries (
+    message_id VARCHAR(64) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    delivered_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (message_id, recipient)
+);
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS notification_deliveries;
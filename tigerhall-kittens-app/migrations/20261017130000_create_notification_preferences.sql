@@ -0,0 +1,44 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- Users are notified about every tiger they reported unless they opt out of it here.
+CREATE TABLE IF NOT EXISTS tiger_subscriptions (
+    email VARCHAR(100) NOT NULL,
+    tiger_id INT NOT NULL REFERENCES tigers(id),
+    subscribed BOOLEAN NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (email, tiger_id)
+);
+
+-- Users in digest mode get one email per hour with all new sightings.
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    email VARCHAR(100) PRIMARY KEY,
+    digest BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Sightings waiting for the next digest email of a recipient.
+CREATE TABLE IF NOT EXISTS notification_digest_items (
+    id SERIAL PRIMARY KEY,
+    message_id VARCHAR(64) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    tiger_id INT NOT NULL,
+    tiger_name VARCHAR(100) NOT NULL,
+    sighting_id INT NOT NULL,
+    lat DOUBLE PRECISION NOT NULL,
+    long DOUBLE PRECISION NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMP,
+    UNIQUE (message_id, recipient)
+);
+
+CREATE INDEX IF NOT EXISTS notification_digest_items_pending_idx
+    ON notification_digest_items (recipient, id) WHERE sent_at IS NULL;
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS notification_digest_items;
+DROP TABLE IF EXISTS notification_preferences;
+DROP TABLE IF EXISTS tiger_subscriptions;
@@ -0,0 +1,31 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- Emails are unique regardless of case, duplicate accounts have to be merged before applying this.
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_key ON users (LOWER(email));
+
+-- Accounts are verified once the user followed the link in the verification email,
+-- accounts created before verification was introduced are considered verified.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP;
+UPDATE users SET email_verified_at = NOW() WHERE email_verified_at IS NULL;
+
+-- Single use tokens mailed to verify the email of a user or reset their password,
+-- stored hashed like the refresh tokens.
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id),
+    purpose VARCHAR(32) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS user_tokens_user_id_idx ON user_tokens (user_id, purpose);
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS user_tokens;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
+DROP INDEX IF EXISTS users_email_key;
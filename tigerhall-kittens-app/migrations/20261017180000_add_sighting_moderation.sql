@@ -0,0 +1,45 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- New sightings wait for a moderator unless their reporter is trusted, only approved
+-- sightings are public. Sightings reported before moderation are approved.
+ALTER TABLE tiger_sightings ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'approved';
+ALTER TABLE tiger_sightings ALTER COLUMN status SET DEFAULT 'pending';
+
+CREATE INDEX IF NOT EXISTS tiger_sightings_status_idx ON tiger_sightings (status, id);
+CREATE INDEX IF NOT EXISTS tiger_sightings_reporter_idx ON tiger_sightings (LOWER(reporter_email), status);
+
+-- Every review of a sighting, so that it is known who approved or rejected what.
+CREATE TABLE IF NOT EXISTS sighting_reviews (
+    id SERIAL PRIMARY KEY,
+    sighting_id INT NOT NULL REFERENCES tiger_sightings(id) ON DELETE CASCADE,
+    reviewer_id INT NOT NULL REFERENCES users(id),
+    status VARCHAR(16) NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS sighting_reviews_sighting_id_idx ON sighting_reviews (sighting_id);
+
+-- Users flag approved sightings they think are wrong, once per sighting. Flags are
+-- resolved by the next review of the sighting.
+CREATE TABLE IF NOT EXISTS sighting_flags (
+    id SERIAL PRIMARY KEY,
+    sighting_id INT NOT NULL REFERENCES tiger_sightings(id) ON DELETE CASCADE,
+    reporter_email VARCHAR(255) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMP,
+    UNIQUE (sighting_id, reporter_email)
+);
+
+CREATE INDEX IF NOT EXISTS sighting_flags_open_idx ON sighting_flags (sighting_id) WHERE resolved_at IS NULL;
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS sighting_flags;
+DROP TABLE IF EXISTS sighting_reviews;
+DROP INDEX IF EXISTS tiger_sightings_reporter_idx;
+DROP INDEX IF EXISTS tiger_sightings_status_idx;
+ALTER TABLE tiger_sightings DROP COLUMN IF EXISTS status;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- Position and capture time from the EXIF data of the photo, for moderators to
+-- cross-check the sighting. photo_mismatch says how they contradict it.
+ALTER TABLE tiger_sightings ADD COLUMN IF NOT EXISTS photo_lat DOUBLE PRECISION;
+ALTER TABLE tiger_sightings ADD COLUMN IF NOT EXISTS photo_long DOUBLE PRECISION;
+ALTER TABLE tiger_sightings ADD COLUMN IF NOT EXISTS photo_timestamp TIMESTAMP;
+ALTER TABLE tiger_sightings ADD COLUMN IF NOT EXISTS photo_mismatch TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE tiger_sightings DROP COLUMN IF EXISTS photo_mismatch;
+ALTER TABLE tiger_sightings DROP COLUMN IF EXISTS photo_timestamp;
+ALTER TABLE tiger_sightings DROP COLUMN IF EXISTS photo_long;
+ALTER TABLE tiger_sightings DROP COLUMN IF EXISTS photo_lat;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- Every photo of a sighting is kept as uploaded, the "original" rendition, and in each
+-- configured rendition and format. tiger_sightings.image_key keeps pointing to the first
+-- JPEG rendition of the first photo, sightings reported before have no rows here.
+CREATE TABLE IF NOT EXISTS sighting_images (
+    id SERIAL PRIMARY KEY,
+    sighting_id INT NOT NULL REFERENCES tiger_sightings(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    rendition VARCHAR(50) NOT NULL,
+    format VARCHAR(10) NOT NULL,
+    image_key TEXT NOT NULL,
+    content_type VARCHAR(50) NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    UNIQUE (sighting_id, position, rendition, format)
+);
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS sighting_images;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- Pages of tigers and of the sightings of a tiger start after the last item of the previous
+-- page, so these indexes match the (last_seen, id) and (timestamp, id) cursors
+CREATE INDEX IF NOT EXISTS idx_tigers_active_last_seen_id ON tigers (last_seen DESC, id DESC) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_tiger_sightings_approved_timestamp_id ON tiger_sightings (tiger_id, timestamp DESC, id DESC) WHERE status = 'approved';
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+
+DROP INDEX IF EXISTS idx_tiger_sightings_approved_timestamp_id;
+DROP INDEX IF EXISTS idx_tigers_active_last_seen_id;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- Trigram indexes serve both the similar name search (name % 'shere kan') and the
+-- prefix search in any case (name ILIKE 'she%')
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS idx_tigers_active_name_trgm ON tigers USING gin (name gin_trgm_ops) WHERE deleted_at IS NULL;
+
+-- Lists sorted by name or date of birth start after the (key, id) of the previous page,
+-- the date of birth index also serves the date of birth range filters
+CREATE INDEX IF NOT EXISTS idx_tigers_active_lower_name_id ON tigers (LOWER(name), id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_tigers_active_date_of_birth_id ON tigers (date_of_birth, id) WHERE deleted_at IS NULL;
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+
+DROP INDEX IF EXISTS idx_tigers_active_date_of_birth_id;
+DROP INDEX IF EXISTS idx_tigers_active_lower_name_id;
+DROP INDEX IF EXISTS idx_tigers_active_name_trgm;
@@ -0,0 +1,31 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- Followers of a tiger are notified about its sightings like its reporters
+CREATE INDEX IF NOT EXISTS idx_tiger_subscriptions_followers ON tiger_subscriptions (tiger_id) WHERE subscribed;
+
+-- Geofence alerts, area is the bounding box of the circle so that the alerts of a
+-- sighting are found through the index before their distance is checked
+CREATE TABLE IF NOT EXISTS sighting_alerts (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(100) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    lat DOUBLE PRECISION NOT NULL,
+    long DOUBLE PRECISION NOT NULL,
+    radius_km DOUBLE PRECISION NOT NULL,
+    area BOX NOT NULL,
+    tiger_id INT REFERENCES tigers(id),
+    channel VARCHAR(10) NOT NULL CHECK (channel IN ('email', 'webhook')),
+    webhook_url TEXT NOT NULL DEFAULT '',
+    webhook_secret VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sighting_alerts_email ON sighting_alerts (email, id);
+CREATE INDEX IF NOT EXISTS idx_sighting_alerts_area ON sighting_alerts USING gist (area);
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS sighting_alerts;
+DROP INDEX IF EXISTS idx_tiger_subscriptions_followers;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- Webhooks of the sighting alerts are queued here by the notifier, one per message and
+-- alert, and sent by the webhook dispatcher so that slow webhooks do not hold up the
+-- notifications. A delivery is due at next_attempt_at until it is delivered or failed.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    message_id VARCHAR(64) NOT NULL,
+    alert_id INT NOT NULL REFERENCES sighting_alerts(id) ON DELETE CASCADE,
+    payload BYTEA NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP,
+    failed_at TIMESTAMP,
+    UNIQUE (message_id, alert_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE delivered_at IS NULL AND failed_at IS NULL;
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS webhook_deliveries;
@@ -2,37 +2,142 @@ package auth
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"golang.org/x/crypto/bcrypt"
+	"sort"
 	"tigerhall-kittens-app/pkg/models"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
+const (
+	// DefaultAccessTokenTTL is how long access tokens are valid unless configured otherwise.
+	DefaultAccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL is how long a refresh token can be used to get a new access token.
+	RefreshTokenTTL = 30 * 24 * time.Hour
+	// EmailVerificationTokenTTL and PasswordResetTokenTTL are how long the tokens mailed to users can be used.
+	EmailVerificationTokenTTL = 24 * time.Hour
+	PasswordResetTokenTTL     = time.Hour
+)
+
+// Denylist keeps the IDs of revoked access tokens until the tokens expire.
+type Denylist interface {
+	RevokeAccessToken(tokenID string, expiresAt time.Time) error
+	IsAccessTokenRevoked(tokenID string) (bool, error)
+}
+
 type Auth struct {
+	// secretKey signs and verifies HS256 tokens
 	secretKey string
+	// keys verify tokens by their kid and signingKey signs new tokens,
+	// tokens are signed with secretKey while there are no keys
+	keys       map[string]*SigningKey
+	signingKey *SigningKey
+	// AcceptHS256Until keeps accepting HS256 tokens after switching to signing keys,
+	// until the tokens signed with the secret key have expired
+	AcceptHS256Until time.Time
+	// AccessTokenTTL is how long the generated access tokens are valid
+	AccessTokenTTL time.Duration
+	// Denylist holds the revoked access tokens, no token is revoked when it is nil
+	Denylist Denylist
+}
+
+// Claims are the claims of a verified access token.
+type Claims struct {
+	// ID is the jti claim, which identifies the token when it is revoked
+	ID        string
+	Username  string
+	Email     string
+	Roles     []string
+	ExpiresAt time.Time
+}
+
+// HasRole reports whether the token has been issued with one of the roles.
+func (c *Claims) HasRole(roles ...string) bool {
+	for _, granted := range c.Roles {
+		for _, role := range roles {
+			if granted == role {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func NewAuth(secretKey string) *Auth {
 	return &Auth{
-		secretKey: secretKey,
+		secretKey:      secretKey,
+		AccessTokenTTL: DefaultAccessTokenTTL,
 	}
 }
 
-func (a *Auth) GenerateToken(username, email string) (string, error) {
+// UseSigningKeys signs new tokens with the key signingKeyID instead of the secret key.
+// Tokens signed with any of the keys are accepted, so keys can be rotated by adding the
+// new key, switching signingKeyID to it and removing the old key once its tokens expired.
+func (a *Auth) UseSigningKeys(keys []*SigningKey, signingKeyID string) error {
+	keysByID := make(map[string]*SigningKey, len(keys))
+	for _, key := range keys {
+		if _, ok := keysByID[key.ID]; ok {
+			return fmt.Errorf("duplicate signing key id %q", key.ID)
+		}
+		keysByID[key.ID] = key
+	}
+
+	signingKey, ok := keysByID[signingKeyID]
+	if !ok {
+		return fmt.Errorf("unknown signing key id %q", signingKeyID)
+	}
+
+	a.keys = keysByID
+	a.signingKey = signingKey
+	return nil
+}
+
+// JWKS returns the public keys tokens are verified with, ordered by key ID.
+func (a *Auth) JWKS() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, key := range a.keys {
+		set.Keys = append(set.Keys, key.JSONWebKey())
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
+
+// GenerateToken issues an access token for the user with the roles granted to the user.
+func (a *Auth) GenerateToken(username, email string, roles ...string) (string, error) {
+	tokenID, err := randomToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %v", err)
+	}
+
 	// Create a new token object, specifying signing method and claims
-	token := jwt.New(jwt.SigningMethodHS256)
+	var token *jwt.Token
+	var key interface{}
+	if a.signingKey != nil {
+		token = jwt.New(a.signingKey.Method)
+		token.Header["kid"] = a.signingKey.ID
+		key = a.signingKey.PrivateKey
+	} else {
+		token = jwt.New(jwt.SigningMethodHS256)
+		key = []byte(a.secretKey)
+	}
 
 	// Create claims for the token (e.g., username, expiration time)
 	claims := token.Claims.(jwt.MapClaims)
+	claims["jti"] = tokenID
 	claims["username"] = username
 	claims["email"] = email
-	claims["exp"] = time.Now().Add(time.Hour * 24).Unix() // Token expires in 24 hours
+	claims["roles"] = roles
+	claims["exp"] = time.Now().Add(a.AccessTokenTTL).Unix()
 
-	// Sign the token with the secret key
-	tokenString, err := token.SignedString([]byte(a.secretKey))
+	// Sign the token with the signing key
+	tokenString, err := token.SignedString(key)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate token: %v", err)
 	}
@@ -41,39 +146,142 @@ func (a *Auth) GenerateToken(username, email string) (string, error) {
 }
 
 func (a *Auth) VerifyToken(tokenString string) (string, string, error) {
+	claims, err := a.ParseToken(tokenString)
+	if err != nil {
+		return "", "", err
+	}
+
+	return claims.Username, claims.Email, nil
+}
+
+// ParseToken verifies the signature and expiry of the token and returns its claims.
+// It does not check whether the token has been revoked, see IsTokenRevoked.
+func (a *Auth) ParseToken(tokenString string) (*Claims, error) {
 	// Parse the token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Check the signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		// Return the secret key for validation
-		return []byte(a.secretKey), nil
-	})
+	token, err := jwt.Parse(tokenString, a.verificationKey)
 
 	if err != nil {
-		return "", "", fmt.Errorf("failed to parse token: %v", err)
+		return nil, fmt.Errorf("failed to parse token: %v", err)
 	}
 
 	// Validate the token and extract claims
-	claims, ok := token.Claims.(jwt.MapClaims)
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
 	if !ok || !token.Valid {
-		return "", "", fmt.Errorf("invalid token")
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	claims := &Claims{}
+
+	// Extract the username from claims
+	claims.Username, ok = mapClaims["username"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid token claims")
 	}
 
-	// Extract and return the username from claims
-	username, ok := claims["username"].(string)
+	// Extract the email from claims
+	claims.Email, ok = mapClaims["email"].(string)
 	if !ok {
-		return "", "", fmt.Errorf("invalid token claims")
+		return nil, fmt.Errorf("invalid token claims")
 	}
 
-	// Extract and return the username from claims
-	email, ok := claims["email"].(string)
+	// Extract the roles from claims, tokens issued before roles were introduced have none
+	if roles, ok := mapClaims["roles"].([]interface{}); ok {
+		for _, role := range roles {
+			if role, ok := role.(string); ok {
+				claims.Roles = append(claims.Roles, role)
+			}
+		}
+	}
+
+	// Tokens issued before token IDs were introduced have no jti and cannot be revoked
+	claims.ID, _ = mapClaims["jti"].(string)
+	if exp, ok := mapClaims["exp"].(float64); ok {
+		claims.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+
+	return claims, nil
+}
+
+// verificationKey returns the key to verify the token with: the signing key named by
+// its kid, or the secret key for HS256 tokens.
+func (a *Auth) verificationKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		if a.signingKey != nil && !time.Now().Before(a.AcceptHS256Until) {
+			return nil, errors.New("HS256 tokens are no longer accepted")
+		}
+		return []byte(a.secretKey), nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := a.keys[kid]
 	if !ok {
-		return "", "", fmt.Errorf("invalid token claims")
+		return nil, fmt.Errorf("unknown signing key id %q", kid)
+	}
+	// The algorithm of the token has to match the key, so that it cannot pick how the key is used
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.PrivateKey.Public(), nil
+}
+
+// IsTokenRevoked reports whether the access token has been revoked.
+func (a *Auth) IsTokenRevoked(claims *Claims) (bool, error) {
+	if a.Denylist == nil || claims.ID == "" {
+		return false, nil
 	}
+	return a.Denylist.IsAccessTokenRevoked(claims.ID)
+}
 
-	return username, email, nil
+// RevokeToken revokes the access token until it expires.
+func (a *Auth) RevokeToken(claims *Claims) error {
+	if a.Denylist == nil {
+		return errors.New("token revocation is not configured")
+	}
+	if claims.ID == "" {
+		return errors.New("token cannot be revoked")
+	}
+	return a.Denylist.RevokeAccessToken(claims.ID, claims.ExpiresAt)
+}
+
+// NewRefreshToken returns a new random refresh token and the hash to store it under.
+func NewRefreshToken() (token, hash string, err error) {
+	token, err = randomToken()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate refresh token: %v", err)
+	}
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken returns the hash a refresh token is stored under, so that the
+// tokens cannot be used by anyone who reads the database.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewUserToken returns a new random token to mail to a user and the hash to store it under.
+func NewUserToken() (token, hash string, err error) {
+	token, err = randomToken()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate user token: %v", err)
+	}
+	return token, HashUserToken(token), nil
+}
+
+// HashUserToken returns the hash a token mailed to a user is stored under.
+func HashUserToken(token string) string {
+	return HashRefreshToken(token)
+}
+
+func randomToken() (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
 }
 
 func GetEmailFromContext(ctx context.Context) (string, bool) {
@@ -83,6 +291,13 @@ func GetEmailFromContext(ctx context.Context) (string, bool) {
 	return email, ok
 }
 
+// GetClaimsFromContext returns the claims of the access token the request was authenticated with.
+func GetClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value("claims").(*Claims)
+
+	return claims, ok
+}
+
 func ValidateUserData(user models.User) error {
 	if user.Username == "" {
 		return errors.New("username is required")
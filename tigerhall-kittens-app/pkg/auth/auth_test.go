@@ -17,7 +17,7 @@ func TestGenerateToken(t *testing.T) {
 
 	username := "testuser"
 	email := "test@example.com"
-	expectedExp := time.Now().Add(time.Hour * 24).Unix()
+	expectedExp := time.Now().Add(DefaultAccessTokenTTL).Unix()
 
 	tokenString, err := auth.GenerateToken(username, email)
 	assert.NoError(t, err)
@@ -35,6 +35,7 @@ func TestGenerateToken(t *testing.T) {
 	assert.Equal(t, username, claims["username"])
 	assert.Equal(t, email, claims["email"])
 	assert.Equal(t, float64(expectedExp), claims["exp"])
+	assert.NotEmpty(t, claims["jti"], "Token should have an ID")
 }
 
 func TestVerifyToken(t *testing.T) {
@@ -54,6 +55,19 @@ func TestVerifyToken(t *testing.T) {
 	assert.Equal(t, email, verifiedEmail)
 }
 
+func TestVerifyToken_Roles(t *testing.T) {
+	auth := NewAuth("test-secret-key")
+
+	tokenString, err := auth.GenerateToken("testuser", "test@example.com", "ranger", "researcher")
+	assert.NoError(t, err)
+
+	claims, err := auth.ParseToken(tokenString)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ranger", "researcher"}, claims.Roles)
+	assert.True(t, claims.HasRole("admin", "ranger"))
+	assert.False(t, claims.HasRole("admin"))
+}
+
 func TestGetEmailFromContext(t *testing.T) {
 	ctx := context.WithValue(context.Background(), "email", "test@example.com")
 
@@ -101,3 +115,49 @@ func TestHashPasswordAndVerifyPassword(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, bcrypt.ErrMismatchedHashAndPassword, err)
 }
+
+// memoryDenylist is an in memory Denylist.
+type memoryDenylist map[string]time.Time
+
+func (m memoryDenylist) RevokeAccessToken(tokenID string, expiresAt time.Time) error {
+	m[tokenID] = expiresAt
+	return nil
+}
+
+func (m memoryDenylist) IsAccessTokenRevoked(tokenID string) (bool, error) {
+	_, ok := m[tokenID]
+	return ok, nil
+}
+
+func TestRevokeToken(t *testing.T) {
+	auth := NewAuth("test-secret-key")
+	auth.Denylist = memoryDenylist{}
+
+	tokenString, err := auth.GenerateToken("testuser", "test@example.com")
+	assert.NoError(t, err)
+	otherTokenString, err := auth.GenerateToken("testuser", "test@example.com")
+	assert.NoError(t, err)
+
+	claims, err := auth.ParseToken(tokenString)
+	assert.NoError(t, err)
+	otherClaims, err := auth.ParseToken(otherTokenString)
+	assert.NoError(t, err)
+	assert.NotEqual(t, claims.ID, otherClaims.ID, "Every token should have its own ID")
+
+	assert.NoError(t, auth.RevokeToken(claims))
+
+	revoked, err := auth.IsTokenRevoked(claims)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = auth.IsTokenRevoked(otherClaims)
+	assert.NoError(t, err)
+	assert.False(t, revoked, "Other tokens of the user should stay valid")
+}
+
+func TestNewRefreshToken(t *testing.T) {
+	token, hash, err := NewRefreshToken()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+	assert.Equal(t, HashRefreshToken(token), hash)
+	assert.NotEqual(t, token, hash, "Refresh tokens should not be stored as is")
+}
@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRSAKey(t *testing.T, id string) *SigningKey {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	key, err := NewSigningKey(id, privateKey)
+	if err != nil {
+		t.Fatalf("failed to create signing key: %v", err)
+	}
+	return key
+}
+
+func newEd25519Key(t *testing.T, id string) *SigningKey {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %v", err)
+	}
+	key, err := NewSigningKey(id, privateKey)
+	if err != nil {
+		t.Fatalf("failed to create signing key: %v", err)
+	}
+	return key
+}
+
+func TestGenerateToken_SigningKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		newKey func(t *testing.T, id string) *SigningKey
+		alg    string
+	}{
+		{"RS256", newRSAKey, "RS256"},
+		{"EdDSA", newEd25519Key, "EdDSA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			auth := NewAuth("test-secret-key")
+			assert.NoError(t, auth.UseSigningKeys([]*SigningKey{tt.newKey(t, "key-1")}, "key-1"))
+
+			// Act
+			tokenString, err := auth.GenerateToken("testuser", "test@example.com")
+			assert.NoError(t, err)
+			username, email, verifyErr := auth.VerifyToken(tokenString)
+
+			// Assert
+			token, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.alg, token.Header["alg"])
+			assert.Equal(t, "key-1", token.Header["kid"])
+			assert.NoError(t, verifyErr)
+			assert.Equal(t, "testuser", username)
+			assert.Equal(t, "test@example.com", email)
+		})
+	}
+}
+
+func TestVerifyToken_KeyRotation(t *testing.T) {
+	// Arrange
+	oldKey := newRSAKey(t, "2026-01")
+	newKey := newEd25519Key(t, "2026-10")
+
+	before := NewAuth("test-secret-key")
+	assert.NoError(t, before.UseSigningKeys([]*SigningKey{oldKey}, "2026-01"))
+	oldToken, err := before.GenerateToken("testuser", "test@example.com")
+	assert.NoError(t, err)
+
+	auth := NewAuth("test-secret-key")
+	assert.NoError(t, auth.UseSigningKeys([]*SigningKey{oldKey, newKey}, "2026-10"))
+
+	// Act
+	newToken, err := auth.GenerateToken("testuser", "test@example.com")
+	assert.NoError(t, err)
+	_, _, oldErr := auth.VerifyToken(oldToken)
+	_, _, newErr := auth.VerifyToken(newToken)
+
+	// Assert
+	assert.NoError(t, oldErr, "tokens of the previous key should still be accepted")
+	assert.NoError(t, newErr)
+
+	// Once the old key is removed its tokens are rejected
+	assert.NoError(t, auth.UseSigningKeys([]*SigningKey{newKey}, "2026-10"))
+	_, _, err = auth.VerifyToken(oldToken)
+	assert.Error(t, err)
+}
+
+func TestVerifyToken_WrongAlgorithmForKey(t *testing.T) {
+	// Arrange
+	key := newRSAKey(t, "key-1")
+	auth := NewAuth("test-secret-key")
+	assert.NoError(t, auth.UseSigningKeys([]*SigningKey{key}, "key-1"))
+
+	// A token claiming to be signed with the key using EdDSA
+	token := jwt.New(SigningMethodEdDSA)
+	token.Header["kid"] = "key-1"
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	tokenString, err := token.SignedString(edKey)
+	assert.NoError(t, err)
+
+	// Act
+	_, _, err = auth.VerifyToken(tokenString)
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func TestVerifyToken_HS256MigrationWindow(t *testing.T) {
+	// Arrange
+	hs256Token, err := NewAuth("test-secret-key").GenerateToken("testuser", "test@example.com")
+	assert.NoError(t, err)
+
+	auth := NewAuth("test-secret-key")
+	assert.NoError(t, auth.UseSigningKeys([]*SigningKey{newEd25519Key(t, "key-1")}, "key-1"))
+
+	// Act
+	auth.AcceptHS256Until = time.Now().Add(time.Hour)
+	_, _, inWindowErr := auth.VerifyToken(hs256Token)
+	auth.AcceptHS256Until = time.Now().Add(-time.Hour)
+	_, _, afterWindowErr := auth.VerifyToken(hs256Token)
+
+	// Assert
+	assert.NoError(t, inWindowErr)
+	assert.Error(t, afterWindowErr)
+}
+
+func TestUseSigningKeys_UnknownSigningKey(t *testing.T) {
+	auth := NewAuth("test-secret-key")
+
+	err := auth.UseSigningKeys([]*SigningKey{newEd25519Key(t, "key-1")}, "key-2")
+
+	assert.Error(t, err)
+}
+
+func TestParseSigningKey(t *testing.T) {
+	// Arrange
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(edKey)
+	assert.NoError(t, err)
+
+	rsaPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	edPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})
+
+	// Act
+	parsedRSA, rsaErr := ParseSigningKey("rsa", rsaPEM)
+	parsedEd, edErr := ParseSigningKey("ed", edPEM)
+	_, invalidErr := ParseSigningKey("invalid", []byte("not a key"))
+
+	// Assert
+	assert.NoError(t, rsaErr)
+	assert.Equal(t, "RS256", parsedRSA.Method.Alg())
+	assert.NoError(t, edErr)
+	assert.Equal(t, "EdDSA", parsedEd.Method.Alg())
+	assert.Error(t, invalidErr)
+}
+
+func TestJWKS(t *testing.T) {
+	// Arrange
+	auth := NewAuth("test-secret-key")
+	edKey := newEd25519Key(t, "b")
+	assert.NoError(t, auth.UseSigningKeys([]*SigningKey{edKey, newRSAKey(t, "a")}, "b"))
+
+	// Act
+	jwks := auth.JWKS()
+
+	// Assert
+	if assert.Len(t, jwks.Keys, 2) {
+		assert.Equal(t, "a", jwks.Keys[0].KeyID)
+		assert.Equal(t, "RSA", jwks.Keys[0].KeyType)
+		assert.Equal(t, "RS256", jwks.Keys[0].Algorithm)
+		assert.Equal(t, "AQAB", jwks.Keys[0].Exponent)
+		assert.NotEmpty(t, jwks.Keys[0].Modulus)
+
+		assert.Equal(t, "b", jwks.Keys[1].KeyID)
+		assert.Equal(t, "OKP", jwks.Keys[1].KeyType)
+		assert.Equal(t, "Ed25519", jwks.Keys[1].Curve)
+		assert.Len(t, jwks.Keys[1].X, 43)
+	}
+}
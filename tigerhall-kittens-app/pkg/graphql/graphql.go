@@ -0,0 +1,135 @@
+// Package graphql serves the tigers, their sightings and the reporters of the sightings
+// over GraphQL, next to the REST routes.
+package graphql
+
+import (
+	"net/http"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"tigerhall-kittens-app/pkg/service"
+)
+
+// MaxRequestBytes is the size of the largest query accepted.
+const MaxRequestBytes = 1 << 20
+
+// MaxDepth is how deeply a query may nest fields, so that a query cannot go back and forth
+// between tigers and sightings without end.
+const MaxDepth = 8
+
+// Schema is the GraphQL schema served by Handler. Only approved sightings are listed.
+const Schema = `
+	schema {
+		query: Query
+		mutation: Mutation
+	}
+
+	scalar Time
+
+	type Query {
+		# The tigers, last seen most recently first
+		tigers(first: Int = 10, after: String): TigerConnection!
+		tiger(id: ID!): Tiger
+	}
+
+	type Mutation {
+		# Needs the ranger or admin role
+		createTiger(input: CreateTigerInput!): Tiger!
+		# Needs authentication, the sighting is reported by the authenticated user
+		reportSighting(input: ReportSightingInput!): Sighting!
+	}
+
+	type Tiger {
+		id: ID!
+		name: String!
+		dateOfBirth: Time!
+		lastSeen: Time!
+		lat: Float!
+		long: Float!
+		# The approved sightings of the tiger, newest first
+		sightings(first: Int = 10, after: String): SightingConnection!
+	}
+
+	type Sighting {
+		id: ID!
+		timestamp: Time!
+		lat: Float!
+		long: Float!
+		status: String!
+		imageURL: String
+		images: [SightingImage!]!
+		tiger: Tiger
+		reporter: User
+	}
+
+	type SightingImage {
+		position: Int!
+		rendition: String!
+		format: String!
+		width: Int!
+		height: Int!
+		url: String!
+	}
+
+	type User {
+		id: ID!
+		username: String!
+		# Only shown to the user and to rangers and admins
+		email: String
+	}
+
+	type PageInfo {
+		hasNextPage: Boolean!
+		endCursor: String
+	}
+
+	type TigerConnection {
+		edges: [TigerEdge!]!
+		pageInfo: PageInfo!
+	}
+
+	type TigerEdge {
+		cursor: String!
+		node: Tiger!
+	}
+
+	type SightingConnection {
+		edges: [SightingEdge!]!
+		pageInfo: PageInfo!
+	}
+
+	type SightingEdge {
+		cursor: String!
+		node: Sighting!
+	}
+
+	input CreateTigerInput {
+		name: String!
+		dateOfBirth: Time!
+		lastSeen: Time!
+		lat: Float!
+		long: Float!
+	}
+
+	input ReportSightingInput {
+		tigerID: ID!
+		timestamp: Time!
+		lat: Float!
+		long: Float!
+	}
+`
+
+// NewHandler returns the handler executing GraphQL queries posted as JSON. It has to be
+// wrapped by middleware.OptionalAuthMiddleware, the mutations take the user from the
+// claims of the request.
+func NewHandler(tigerService service.TigerService) http.Handler {
+	schema := graphql.MustParseSchema(Schema, &resolver{tigerService: tigerService}, graphql.MaxDepth(MaxDepth))
+	relayHandler := &relay.Handler{Schema: schema}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every request batches its own lookups
+		r = r.WithContext(withLoaders(r.Context(), newLoaders(tigerService)))
+		r.Body = http.MaxBytesReader(w, r.Body, MaxRequestBytes)
+		relayHandler.ServeHTTP(w, r)
+	})
+}
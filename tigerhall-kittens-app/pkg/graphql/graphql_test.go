@@ -0,0 +1,470 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"tigerhall-kittens-app/pkg/auth"
+	"tigerhall-kittens-app/pkg/middleware"
+	"tigerhall-kittens-app/pkg/models"
+	"tigerhall-kittens-app/pkg/service"
+	"tigerhall-kittens-app/pkg/utils"
+)
+
+// mockTigerService is a mock implementation of the TigerService interface.
+type mockTigerService struct {
+	signupService                     func(user *models.User) error
+	loginService                      func(credentials models.LoginCredentials) (*models.User, error)
+	createTigerService                func(tiger *models.Tiger) error
+	getTigerService                   func(tigerID int) (*models.Tiger, error)
+	updateTigerService                func(tigerID int, update models.TigerUpdate) (*models.Tiger, error)
+	deleteTigerService                func(tigerID int) error
+	getAllTigersService               func(filter models.TigerFilter, request models.PageRequest) ([]*models.Tiger, *models.PageInfo, error)
+	createTigerSightingService        func(newSighting *models.TigerSighting) error
+	getTigerSightingsByIDService      func(tigerID int, request models.PageRequest) ([]*models.TigerSighting, *models.PageInfo, error)
+	getTigerSightingImageService      func(sightingID int) (io.ReadCloser, error)
+	getTigersNearbyService            func(center models.Coordinates, radiusKm float64) ([]*models.NearbyTiger, error)
+	getSightingsInAreaService         func(box models.BoundingBox, from, to time.Time, limit int) ([]*models.TigerSighting, error)
+	streamTigerTrailService           func(tigerID int, fn func(*models.TigerSighting) error) error
+	exportTigerSightingsService       func(from, to time.Time, fn func(*models.TigerSighting) error) error
+	setTigerSubscriptionService       func(email string, tigerID int, subscribed bool) error
+	setNotificationPreferencesService func(email string, digest bool) error
+	createRefreshTokenService         func(user *models.User) (string, error)
+	refreshTokenService               func(refreshToken string) (*models.User, string, error)
+	logoutService                     func(email, refreshToken string) error
+	grantRoleService                  func(userID int, role string) error
+	revokeRoleService                 func(userID int, role string) error
+	verifyEmailService                func(token string) error
+	resendVerificationService         func(email string) error
+	forgotPasswordService             func(email string) error
+	resetPasswordService              func(token, password string) error
+	getModerationSightingImageService func(sightingID int) (io.ReadCloser, error)
+	getModerationQueueService         func(page, size int) ([]*models.TigerSighting, int, error)
+	reviewTigerSightingService        func(sightingID int, reviewerEmail, status, note string) (*models.SightingReview, error)
+	flagTigerSightingService          func(sightingID int, email, reason string) error
+	getTigerSightingPhotoService      func(sightingID, position int, rendition, format string) (io.ReadCloser, *models.SightingImage, error)
+	getModerationSightingPhotoService func(sightingID, position int, rendition, format string) (io.ReadCloser, *models.SightingImage, error)
+	getTigersAfterService             func(after *models.Cursor, limit int) ([]*models.Tiger, error)
+	getTigersByIDsService             func(tigerIDs []int) ([]*models.Tiger, error)
+	getTigerSightingsAfterService     func(tigerID int, after *models.Cursor, limit int) ([]*models.TigerSighting, error)
+	getLatestTigerSightingsService    func(tigerIDs []int, limit int) ([]*models.TigerSighting, error)
+	getUsersByEmailsService           func(emails []string) ([]*models.User, error)
+	getTigerStatsService              func(tigerID int) (*models.TigerStats, error)
+	getPopulationStatsService         func(days, limit int) (*models.PopulationStats, error)
+	subscribeSightingsService         func(filter models.SightingFeedFilter) *service.SightingSubscription
+	getTigerSubscriptionsService      func(email string) ([]*models.TigerSubscription, error)
+	createSightingAlertService        func(email string, alert *models.SightingAlert) error
+	getSightingAlertsService          func(email string) ([]*models.SightingAlert, error)
+	deleteSightingAlertService        func(email string, alertID int) error
+}
+
+func (m *mockTigerService) SignupService(user *models.User) error {
+	return m.signupService(user)
+}
+
+func (m *mockTigerService) LoginService(credentials models.LoginCredentials) (*models.User, error) {
+	return m.loginService(credentials)
+}
+
+func (m *mockTigerService) CreateTigerService(tiger *models.Tiger) error {
+	return m.createTigerService(tiger)
+}
+
+func (m *mockTigerService) GetTigerService(tigerID int) (*models.Tiger, error) {
+	return m.getTigerService(tigerID)
+}
+
+func (m *mockTigerService) UpdateTigerService(tigerID int, update models.TigerUpdate) (*models.Tiger, error) {
+	return m.updateTigerService(tigerID, update)
+}
+
+func (m *mockTigerService) DeleteTigerService(tigerID int) error {
+	return m.deleteTigerService(tigerID)
+}
+
+func (m *mockTigerService) GetAllTigersService(filter models.TigerFilter, request models.PageRequest) ([]*models.Tiger, *models.PageInfo, error) {
+	return m.getAllTigersService(filter, request)
+}
+
+func (m *mockTigerService) CreateTigerSightingService(newSighting *models.TigerSighting) error {
+	return m.createTigerSightingService(newSighting)
+}
+
+func (m *mockTigerService) GetTigerSightingsByIDService(tigerID int, request models.PageRequest) ([]*models.TigerSighting, *models.PageInfo, error) {
+	return m.getTigerSightingsByIDService(tigerID, request)
+}
+
+func (m *mockTigerService) GetTigerSightingImageService(sightingID int) (io.ReadCloser, error) {
+	return m.getTigerSightingImageService(sightingID)
+}
+
+func (m *mockTigerService) GetTigersNearbyService(center models.Coordinates, radiusKm float64) ([]*models.NearbyTiger, error) {
+	return m.getTigersNearbyService(center, radiusKm)
+}
+
+func (m *mockTigerService) GetTigerSightingsInAreaService(box models.BoundingBox, from, to time.Time, limit int) ([]*models.TigerSighting, error) {
+	return m.getSightingsInAreaService(box, from, to, limit)
+}
+
+func (m *mockTigerService) StreamTigerTrailService(tigerID int, fn func(*models.TigerSighting) error) error {
+	return m.streamTigerTrailService(tigerID, fn)
+}
+
+func (m *mockTigerService) ExportTigerSightingsService(from, to time.Time, fn func(*models.TigerSighting) error) error {
+	return m.exportTigerSightingsService(from, to, fn)
+}
+
+func (m *mockTigerService) SetTigerSubscriptionService(email string, tigerID int, subscribed bool) error {
+	return m.setTigerSubscriptionService(email, tigerID, subscribed)
+}
+
+func (m *mockTigerService) SetNotificationPreferencesService(email string, digest bool) error {
+	return m.setNotificationPreferencesService(email, digest)
+}
+
+func (m *mockTigerService) CreateRefreshTokenService(user *models.User) (string, error) {
+	return m.createRefreshTokenService(user)
+}
+
+func (m *mockTigerService) RefreshTokenService(refreshToken string) (*models.User, string, error) {
+	return m.refreshTokenService(refreshToken)
+}
+
+func (m *mockTigerService) LogoutService(email, refreshToken string) error {
+	return m.logoutService(email, refreshToken)
+}
+
+func (m *mockTigerService) GrantRoleService(userID int, role string) error {
+	return m.grantRoleService(userID, role)
+}
+
+func (m *mockTigerService) RevokeRoleService(userID int, role string) error {
+	return m.revokeRoleService(userID, role)
+}
+
+func (m *mockTigerService) VerifyEmailService(token string) error {
+	return m.verifyEmailService(token)
+}
+
+func (m *mockTigerService) ResendVerificationService(email string) error {
+	return m.resendVerificationService(email)
+}
+
+func (m *mockTigerService) ForgotPasswordService(email string) error {
+	return m.forgotPasswordService(email)
+}
+
+func (m *mockTigerService) ResetPasswordService(token, password string) error {
+	return m.resetPasswordService(token, password)
+}
+
+func (m *mockTigerService) GetModerationSightingImageService(sightingID int) (io.ReadCloser, error) {
+	return m.getModerationSightingImageService(sightingID)
+}
+
+func (m *mockTigerService) GetModerationQueueService(page, size int) ([]*models.TigerSighting, int, error) {
+	return m.getModerationQueueService(page, size)
+}
+
+func (m *mockTigerService) ReviewTigerSightingService(sightingID int, reviewerEmail, status, note string) (*models.SightingReview, error) {
+	return m.reviewTigerSightingService(sightingID, reviewerEmail, status, note)
+}
+
+func (m *mockTigerService) FlagTigerSightingService(sightingID int, email, reason string) error {
+	return m.flagTigerSightingService(sightingID, email, reason)
+}
+
+func (m *mockTigerService) GetTigerSightingPhotoService(sightingID, position int, rendition, format string) (io.ReadCloser, *models.SightingImage, error) {
+	return m.getTigerSightingPhotoService(sightingID, position, rendition, format)
+}
+
+func (m *mockTigerService) GetModerationSightingPhotoService(sightingID, position int, rendition, format string) (io.ReadCloser, *models.SightingImage, error) {
+	return m.getModerationSightingPhotoService(sightingID, position, rendition, format)
+}
+
+func (m *mockTigerService) GetTigersAfterService(after *models.Cursor, limit int) ([]*models.Tiger, error) {
+	return m.getTigersAfterService(after, limit)
+}
+
+func (m *mockTigerService) GetTigersByIDsService(tigerIDs []int) ([]*models.Tiger, error) {
+	return m.getTigersByIDsService(tigerIDs)
+}
+
+func (m *mockTigerService) GetTigerSightingsAfterService(tigerID int, after *models.Cursor, limit int) ([]*models.TigerSighting, error) {
+	return m.getTigerSightingsAfterService(tigerID, after, limit)
+}
+
+func (m *mockTigerService) GetLatestTigerSightingsService(tigerIDs []int, limit int) ([]*models.TigerSighting, error) {
+	return m.getLatestTigerSightingsService(tigerIDs, limit)
+}
+
+func (m *mockTigerService) GetUsersByEmailsService(emails []string) ([]*models.User, error) {
+	return m.getUsersByEmailsService(emails)
+}
+
+func (m *mockTigerService) GetTigerStatsService(tigerID int) (*models.TigerStats, error) {
+	return m.getTigerStatsService(tigerID)
+}
+
+func (m *mockTigerService) GetPopulationStatsService(days, limit int) (*models.PopulationStats, error) {
+	return m.getPopulationStatsService(days, limit)
+}
+
+func (m *mockTigerService) SubscribeSightingsService(filter models.SightingFeedFilter) *service.SightingSubscription {
+	return m.subscribeSightingsService(filter)
+}
+
+func (m *mockTigerService) GetTigerSubscriptionsService(email string) ([]*models.TigerSubscription, error) {
+	return m.getTigerSubscriptionsService(email)
+}
+
+func (m *mockTigerService) CreateSightingAlertService(email string, alert *models.SightingAlert) error {
+	return m.createSightingAlertService(email, alert)
+}
+
+func (m *mockTigerService) GetSightingAlertsService(email string) ([]*models.SightingAlert, error) {
+	return m.getSightingAlertsService(email)
+}
+
+func (m *mockTigerService) DeleteSightingAlertService(email string, alertID int) error {
+	return m.deleteSightingAlertService(email, alertID)
+}
+
+type graphqlResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// execute posts the query to the GraphQL handler, authenticated with the token unless it is empty.
+func execute(t *testing.T, tigerService service.TigerService, authService *auth.Auth, token, query string) graphqlResponse {
+	body, err := json.Marshal(map[string]string{"query": query})
+	assert.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+
+	middleware.OptionalAuthMiddleware(authService, NewHandler(tigerService)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var response graphqlResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	return response
+}
+
+func TestTigers_BatchesSightingsAndReporters(t *testing.T) {
+	// Arrange
+	lastSeen := time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC)
+	tigers := make([]*models.Tiger, 51)
+	for i := range tigers {
+		tigers[i] = &models.Tiger{ID: 100 - i, Name: fmt.Sprintf("Tiger %d", 100-i), LastSeen: lastSeen}
+	}
+
+	var sightingsCalls, usersCalls int32
+	mockService := &mockTigerService{
+		getTigersAfterService: func(after *models.Cursor, limit int) ([]*models.Tiger, error) {
+			assert.Nil(t, after)
+			assert.Equal(t, 51, limit, "One more tiger should be fetched to tell whether there is a next page")
+			return tigers, nil
+		},
+		getLatestTigerSightingsService: func(tigerIDs []int, limit int) ([]*models.TigerSighting, error) {
+			atomic.AddInt32(&sightingsCalls, 1)
+			assert.Len(t, tigerIDs, 50, "Sightings of every tiger of the page should be fetched at once")
+			assert.Equal(t, 2, limit)
+			sightings := []*models.TigerSighting{}
+			for _, tigerID := range tigerIDs {
+				sightings = append(sightings, &models.TigerSighting{ID: tigerID * 10, TigerID: tigerID, Timestamp: lastSeen, ReporterEmail: fmt.Sprintf("reporter%d@example.com", tigerID%3)})
+			}
+			return sightings, nil
+		},
+		getUsersByEmailsService: func(emails []string) ([]*models.User, error) {
+			atomic.AddInt32(&usersCalls, 1)
+			assert.Len(t, emails, 3, "Every reporter should be fetched once")
+			users := []*models.User{}
+			for i, email := range emails {
+				users = append(users, &models.User{ID: i + 1, Username: "reporter", Email: email})
+			}
+			return users, nil
+		},
+	}
+
+	// Act
+	response := execute(t, mockService, auth.NewAuth("test-secret-key"), "", `{
+		tigers(first: 50) {
+			edges { node { id sightings(first: 1) { edges { node { id reporter { username email } tiger { name } } } } } }
+			pageInfo { hasNextPage endCursor }
+		}
+	}`)
+
+	// Assert
+	assert.Empty(t, response.Errors)
+	assert.Equal(t, int32(1), sightingsCalls, "Sightings of 50 tigers should be fetched in a single call")
+	assert.Equal(t, int32(1), usersCalls, "Reporters of the sightings of 50 tigers should be fetched in a single call")
+
+	connection := response.Data["tigers"].(map[string]interface{})
+	assert.Len(t, connection["edges"], 50)
+	pageInfo := connection["pageInfo"].(map[string]interface{})
+	assert.Equal(t, true, pageInfo["hasNextPage"])
+	assert.Equal(t, utils.EncodeCursor(models.Cursor{Time: lastSeen, ID: 51}), pageInfo["endCursor"])
+
+	sighting := connection["edges"].([]interface{})[0].(map[string]interface{})["node"].(map[string]interface{})["sightings"].(map[string]interface{})["edges"].([]interface{})[0].(map[string]interface{})["node"].(map[string]interface{})
+	assert.Equal(t, "1000", sighting["id"])
+	assert.Equal(t, map[string]interface{}{"name": "Tiger 100"}, sighting["tiger"])
+	assert.Equal(t, map[string]interface{}{"username": "reporter", "email": nil}, sighting["reporter"], "Emails of reporters should not be shown to anonymous clients")
+}
+
+func TestTigers_After(t *testing.T) {
+	// Arrange
+	after := models.Cursor{Time: time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC), ID: 3}
+	mockService := &mockTigerService{
+		getTigersAfterService: func(cursor *models.Cursor, limit int) ([]*models.Tiger, error) {
+			assert.Equal(t, &after, cursor)
+			return []*models.Tiger{{ID: 2}, {ID: 1}}, nil
+		},
+	}
+	authService := auth.NewAuth("test-secret-key")
+
+	// Act
+	response := execute(t, mockService, authService, "", fmt.Sprintf(`{ tigers(first: 2, after: %q) { edges { node { id } } pageInfo { hasNextPage } } }`, utils.EncodeCursor(after)))
+	invalid := execute(t, mockService, authService, "", `{ tigers(first: 2, after: "invalid") { edges { cursor } } }`)
+	tooLarge := execute(t, mockService, authService, "", `{ tigers(first: 1000) { edges { cursor } } }`)
+
+	// Assert
+	assert.Empty(t, response.Errors)
+	assert.Equal(t, map[string]interface{}{"hasNextPage": false}, response.Data["tigers"].(map[string]interface{})["pageInfo"])
+	for _, response := range []graphqlResponse{invalid, tooLarge} {
+		if assert.Len(t, response.Errors, 1) {
+			assert.Equal(t, CodeBadRequest, response.Errors[0].Extensions["code"])
+		}
+	}
+}
+
+func TestCreateTiger(t *testing.T) {
+	authService := auth.NewAuth("test-secret-key")
+	rangerToken, _ := authService.GenerateToken("ranger", "ranger@example.com", models.RoleRanger)
+	memberToken, _ := authService.GenerateToken("member", "member@example.com")
+
+	tests := []struct {
+		name  string
+		token string
+		code  string
+	}{
+		{name: "anonymous", code: CodeUnauthenticated},
+		{name: "member", token: memberToken, code: CodeForbidden},
+		{name: "ranger", token: rangerToken},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			mockService := &mockTigerService{
+				createTigerService: func(tiger *models.Tiger) error {
+					assert.Equal(t, "Shere Khan", tiger.Name)
+					tiger.ID = 7
+					return nil
+				},
+			}
+
+			// Act
+			response := execute(t, mockService, authService, tt.token, `mutation {
+				createTiger(input: {name: "Shere Khan", dateOfBirth: "2018-01-15T00:00:00Z", lastSeen: "2023-07-21T12:00:00Z", lat: 12.34, long: 56.78}) { id name }
+			}`)
+
+			// Assert
+			if tt.code != "" {
+				if assert.Len(t, response.Errors, 1) {
+					assert.Equal(t, tt.code, response.Errors[0].Extensions["code"])
+				}
+				return
+			}
+			assert.Empty(t, response.Errors)
+			assert.Equal(t, map[string]interface{}{"id": "7", "name": "Shere Khan"}, response.Data["createTiger"])
+		})
+	}
+}
+
+func TestReportSighting(t *testing.T) {
+	// Arrange
+	authService := auth.NewAuth("test-secret-key")
+	token, _ := authService.GenerateToken("reporter", "reporter@example.com")
+	mockService := &mockTigerService{
+		createTigerSightingService: func(sighting *models.TigerSighting) error {
+			assert.Equal(t, "reporter@example.com", sighting.ReporterEmail, "Sighting should be reported by the authenticated user")
+			if sighting.Lat > 80 {
+				return &service.ValidationError{Code: service.CodeImpossibleSpeed, Message: "too fast"}
+			}
+			sighting.ID = 9
+			sighting.Status = models.SightingPending
+			return nil
+		},
+		getUsersByEmailsService: func(emails []string) ([]*models.User, error) {
+			return []*models.User{{ID: 1, Username: "reporter", Email: "reporter@example.com"}}, nil
+		},
+	}
+	mutation := `mutation {
+		reportSighting(input: {tigerID: "1", timestamp: "2023-07-21T12:00:00Z", lat: %g, long: 56.78}) { id status reporter { email } }
+	}`
+
+	// Act
+	response := execute(t, mockService, authService, token, fmt.Sprintf(mutation, 12.34))
+	rejected := execute(t, mockService, authService, token, fmt.Sprintf(mutation, 85.0))
+	anonymous := execute(t, mockService, authService, "", fmt.Sprintf(mutation, 12.34))
+
+	// Assert
+	assert.Empty(t, response.Errors)
+	assert.Equal(t, map[string]interface{}{"id": "9", "status": models.SightingPending, "reporter": map[string]interface{}{"email": "reporter@example.com"}}, response.Data["reportSighting"])
+	if assert.Len(t, rejected.Errors, 1) {
+		assert.Equal(t, service.CodeImpossibleSpeed, rejected.Errors[0].Extensions["code"], "Sighting rules should tell which rule failed")
+	}
+	if assert.Len(t, anonymous.Errors, 1) {
+		assert.Equal(t, CodeUnauthenticated, anonymous.Errors[0].Extensions["code"])
+	}
+}
+
+func TestLoader(t *testing.T) {
+	// Arrange
+	var calls [][]int
+	l := newLoader(func(keys []int) (map[int]string, error) {
+		calls = append(calls, keys)
+		if keys[0] == 4 {
+			return nil, errors.New("failed")
+		}
+		values := map[int]string{}
+		for _, key := range keys {
+			if key != 3 {
+				values[key] = fmt.Sprint(key)
+			}
+		}
+		return values, nil
+	})
+	l.prime(1, 2, 3, 2)
+
+	// Act
+	two, ok, err := l.load(2)
+	_, missing, _ := l.load(3)
+	_, _, failed := l.load(4)
+	_, _, failedAgain := l.load(4)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "2", two)
+	assert.False(t, missing, "Keys without a value should not be found")
+	assert.Error(t, failed)
+	assert.Error(t, failedAgain, "Errors should be kept for the keys of the failed fetch")
+	assert.Equal(t, [][]int{{2, 1, 3}, {4}}, calls, "Primed keys should be fetched once with the first key loaded")
+}
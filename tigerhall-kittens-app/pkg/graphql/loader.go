@@ -0,0 +1,174 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+
+	"tigerhall-kittens-app/pkg/models"
+	"tigerhall-kittens-app/pkg/service"
+	"tigerhall-kittens-app/pkg/utils"
+)
+
+// loader loads values by key during a single request. The keys of the items of a page are
+// primed when the page is resolved, and loading any of them fetches them all at once, so
+// resolving a field of every item of a page costs a single call to fetch instead of one
+// per item.
+type loader[K comparable, V any] struct {
+	fetch func(keys []K) (map[K]V, error)
+
+	mu     sync.Mutex
+	primed []K
+	values map[K]V
+	errs   map[K]error
+}
+
+func newLoader[K comparable, V any](fetch func(keys []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{fetch: fetch, values: map[K]V{}, errs: map[K]error{}}
+}
+
+// prime queues the keys to be fetched with the next key loaded.
+func (l *loader[K, V]) prime(keys ...K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.primed = append(l.primed, keys...)
+}
+
+// load returns the value of the key, fetching it together with every primed key unless it
+// was fetched before. ok is false when fetch returned no value for the key.
+func (l *loader[K, V]) load(key K) (value V, ok bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err, fetched := l.errs[key]; fetched {
+		value, ok = l.values[key]
+		return value, ok, err
+	}
+
+	keys := []K{key}
+	seen := map[K]bool{key: true}
+	for _, primed := range l.primed {
+		if _, fetched := l.errs[primed]; !fetched && !seen[primed] {
+			keys = append(keys, primed)
+			seen[primed] = true
+		}
+	}
+	l.primed = nil
+
+	values, err := l.fetch(keys)
+	for _, k := range keys {
+		l.errs[k] = err
+		if v, ok := values[k]; ok && err == nil {
+			l.values[k] = v
+		}
+	}
+
+	value, ok = l.values[key]
+	return value, ok, err
+}
+
+// loaders batch the lookups of a request, see loader.
+type loaders struct {
+	tigerService service.TigerService
+	tigers       *loader[int, *models.Tiger]
+	users        *loader[string, *models.User]
+
+	mu sync.Mutex
+	// tigerIDs are the tigers of the pages resolved so far
+	tigerIDs []int
+	// latestSightings are the loaders of the first sightings of tigers by page size
+	latestSightings map[int]*loader[int, []*models.TigerSighting]
+}
+
+type loadersKey struct{}
+
+func newLoaders(tigerService service.TigerService) *loaders {
+	l := &loaders{tigerService: tigerService, latestSightings: map[int]*loader[int, []*models.TigerSighting]{}}
+
+	l.tigers = newLoader(func(tigerIDs []int) (map[int]*models.Tiger, error) {
+		tigers, err := tigerService.GetTigersByIDsService(tigerIDs)
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[int]*models.Tiger, len(tigers))
+		for _, tiger := range tigers {
+			byID[tiger.ID] = tiger
+		}
+		return byID, nil
+	})
+
+	l.users = newLoader(func(emails []string) (map[string]*models.User, error) {
+		users, err := tigerService.GetUsersByEmailsService(emails)
+		if err != nil {
+			return nil, err
+		}
+		byEmail := make(map[string]*models.User, len(users))
+		for _, user := range users {
+			byEmail[utils.NormalizeEmail(user.Email)] = user
+		}
+		return byEmail, nil
+	})
+
+	return l
+}
+
+// sightingsLoader returns the loader of the limit newest sightings of tigers, primed with
+// the tigers of the pages resolved so far.
+func (l *loaders) sightingsLoader(limit int) *loader[int, []*models.TigerSighting] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if sightings, ok := l.latestSightings[limit]; ok {
+		return sightings
+	}
+	sightings := newLoader(func(tigerIDs []int) (map[int][]*models.TigerSighting, error) {
+		tigerSightings, err := l.tigerService.GetLatestTigerSightingsService(tigerIDs, limit)
+		if err != nil {
+			return nil, err
+		}
+		byTiger := make(map[int][]*models.TigerSighting, len(tigerIDs))
+		for _, tigerID := range tigerIDs {
+			byTiger[tigerID] = []*models.TigerSighting{}
+		}
+		for _, sighting := range tigerSightings {
+			byTiger[sighting.TigerID] = append(byTiger[sighting.TigerID], sighting)
+		}
+		// The reporters of the sightings of all the tigers are loaded at once too
+		l.primeReporters(tigerSightings)
+		return byTiger, nil
+	})
+	sightings.prime(l.tigerIDs...)
+	l.latestSightings[limit] = sightings
+	return sightings
+}
+
+// primeTigers queues the tigers of a page for the sightings of every tiger, whatever
+// page size they are asked for with.
+func (l *loaders) primeTigers(tigers []*models.Tiger) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tigerIDs := make([]int, len(tigers))
+	for i, tiger := range tigers {
+		tigerIDs[i] = tiger.ID
+	}
+	l.tigerIDs = append(l.tigerIDs, tigerIDs...)
+	for _, sightings := range l.latestSightings {
+		sightings.prime(tigerIDs...)
+	}
+}
+
+// primeReporters queues the reporters of a page of sightings.
+func (l *loaders) primeReporters(sightings []*models.TigerSighting) {
+	for _, sighting := range sightings {
+		l.users.prime(utils.NormalizeEmail(sighting.ReporterEmail))
+	}
+}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+// loadersFromContext returns the loaders of the request set by Handler.
+func loadersFromContext(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
@@ -0,0 +1,442 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"tigerhall-kittens-app/pkg/auth"
+	"tigerhall-kittens-app/pkg/models"
+	"tigerhall-kittens-app/pkg/service"
+	"tigerhall-kittens-app/pkg/utils"
+)
+
+// MaxPageSize is the largest page of tigers or sightings a query may ask for.
+const MaxPageSize = 100
+
+// Codes of the errors returned to the clients in the extensions of the GraphQL errors,
+// next to the codes of the service.ValidationErrors.
+const (
+	CodeUnauthenticated = "unauthenticated"
+	CodeForbidden       = "forbidden"
+	CodeNotFound        = "not_found"
+	CodeBadRequest      = "bad_request"
+)
+
+// resolverError is an error with a code telling the clients what went wrong.
+type resolverError struct {
+	code    string
+	message string
+}
+
+func (e *resolverError) Error() string {
+	return e.message
+}
+
+func (e *resolverError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
+
+type resolver struct {
+	tigerService service.TigerService
+}
+
+type pageArgs struct {
+	First int32
+	After *string
+}
+
+// page checks the page size and decodes the cursor of the arguments.
+func (args pageArgs) page() (int, *models.Cursor, error) {
+	if args.First < 1 || args.First > MaxPageSize {
+		return 0, nil, &resolverError{code: CodeBadRequest, message: fmt.Sprintf("first must be between 1 and %d", MaxPageSize)}
+	}
+	if args.After == nil {
+		return int(args.First), nil, nil
+	}
+	after, err := utils.DecodeCursor(*args.After)
+	if err != nil {
+		return 0, nil, &resolverError{code: CodeBadRequest, message: err.Error()}
+	}
+	return int(args.First), after, nil
+}
+
+func parseID(id graphql.ID) (int, error) {
+	parsed, err := strconv.Atoi(string(id))
+	if err != nil {
+		return 0, &resolverError{code: CodeBadRequest, message: fmt.Sprintf("invalid id %q", id)}
+	}
+	return parsed, nil
+}
+
+func (r *resolver) Tigers(ctx context.Context, args pageArgs) (*tigerConnection, error) {
+	first, after, err := args.page()
+	if err != nil {
+		return nil, err
+	}
+
+	// One more tiger than asked for tells whether there is a next page
+	tigers, err := r.tigerService.GetTigersAfterService(after, first+1)
+	if err != nil {
+		return nil, err
+	}
+	hasNextPage := len(tigers) > first
+	if hasNextPage {
+		tigers = tigers[:first]
+	}
+
+	loadersFromContext(ctx).primeTigers(tigers)
+	return &tigerConnection{tigerService: r.tigerService, tigers: tigers, hasNextPage: hasNextPage}, nil
+}
+
+func (r *resolver) Tiger(ctx context.Context, args struct{ ID graphql.ID }) (*tigerResolver, error) {
+	tigerID, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	tiger, err := r.tigerService.GetTigerService(tigerID)
+	if errors.Is(err, service.ErrTigerNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &tigerResolver{tigerService: r.tigerService, tiger: tiger}, nil
+}
+
+type createTigerInput struct {
+	Name        string
+	DateOfBirth graphql.Time
+	LastSeen    graphql.Time
+	Lat         float64
+	Long        float64
+}
+
+// CreateTiger creates a tiger, like POST /tiger/create it needs the ranger or admin role.
+func (r *resolver) CreateTiger(ctx context.Context, args struct{ Input createTigerInput }) (*tigerResolver, error) {
+	claims, ok := auth.GetClaimsFromContext(ctx)
+	if !ok {
+		return nil, &resolverError{code: CodeUnauthenticated, message: "authentication required"}
+	}
+	if !claims.HasRole(models.RoleRanger, models.RoleAdmin) {
+		return nil, &resolverError{code: CodeForbidden, message: "the ranger or admin role is required"}
+	}
+
+	tiger := &models.Tiger{
+		Name:        args.Input.Name,
+		DateOfBirth: args.Input.DateOfBirth.Time,
+		LastSeen:    args.Input.LastSeen.Time,
+		Lat:         args.Input.Lat,
+		Long:        args.Input.Long,
+	}
+	if err := r.tigerService.CreateTigerService(tiger); err != nil {
+		return nil, err
+	}
+	return &tigerResolver{tigerService: r.tigerService, tiger: tiger}, nil
+}
+
+type reportSightingInput struct {
+	TigerID   graphql.ID
+	Timestamp graphql.Time
+	Lat       float64
+	Long      float64
+}
+
+// ReportSighting reports a sighting without photos by the authenticated user, like
+// POST /tiger-sighting/create it goes through the sighting rules and moderation.
+func (r *resolver) ReportSighting(ctx context.Context, args struct{ Input reportSightingInput }) (*sightingResolver, error) {
+	reporterEmail, ok := auth.GetEmailFromContext(ctx)
+	if !ok {
+		return nil, &resolverError{code: CodeUnauthenticated, message: "authentication required"}
+	}
+	tigerID, err := parseID(args.Input.TigerID)
+	if err != nil {
+		return nil, err
+	}
+
+	sighting := &models.TigerSighting{
+		TigerID:       tigerID,
+		Timestamp:     args.Input.Timestamp.Time,
+		Lat:           args.Input.Lat,
+		Long:          args.Input.Long,
+		ReporterEmail: reporterEmail,
+	}
+	err = r.tigerService.CreateTigerSightingService(sighting)
+	var validationErr *service.ValidationError
+	if errors.As(err, &validationErr) {
+		return nil, &resolverError{code: validationErr.Code, message: validationErr.Message}
+	} else if errors.Is(err, service.ErrTigerNotFound) {
+		return nil, &resolverError{code: CodeNotFound, message: err.Error()}
+	} else if err != nil {
+		return nil, err
+	}
+	return &sightingResolver{tigerService: r.tigerService, sighting: sighting}, nil
+}
+
+type tigerResolver struct {
+	tigerService service.TigerService
+	tiger        *models.Tiger
+}
+
+func (t *tigerResolver) ID() graphql.ID {
+	return graphql.ID(strconv.Itoa(t.tiger.ID))
+}
+
+func (t *tigerResolver) Name() string {
+	return t.tiger.Name
+}
+
+func (t *tigerResolver) DateOfBirth() graphql.Time {
+	return graphql.Time{Time: t.tiger.DateOfBirth}
+}
+
+func (t *tigerResolver) LastSeen() graphql.Time {
+	return graphql.Time{Time: t.tiger.LastSeen}
+}
+
+func (t *tigerResolver) Lat() float64 {
+	return t.tiger.Lat
+}
+
+func (t *tigerResolver) Long() float64 {
+	return t.tiger.Long
+}
+
+// Sightings returns the approved sightings of the tiger, newest first. The first page of
+// the sightings of every tiger of a page of tigers is loaded at once.
+func (t *tigerResolver) Sightings(ctx context.Context, args pageArgs) (*sightingConnection, error) {
+	first, after, err := args.page()
+	if err != nil {
+		return nil, err
+	}
+
+	var sightings []*models.TigerSighting
+	if after == nil {
+		sightings, _, err = loadersFromContext(ctx).sightingsLoader(first + 1).load(t.tiger.ID)
+	} else {
+		sightings, err = t.tigerService.GetTigerSightingsAfterService(t.tiger.ID, after, first+1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	hasNextPage := len(sightings) > first
+	if hasNextPage {
+		sightings = sightings[:first]
+	}
+
+	loadersFromContext(ctx).primeReporters(sightings)
+	return &sightingConnection{tigerService: t.tigerService, tiger: t.tiger, sightings: sightings, hasNextPage: hasNextPage}, nil
+}
+
+type sightingResolver struct {
+	tigerService service.TigerService
+	sighting     *models.TigerSighting
+	// tiger is set when the sighting was resolved from its tiger
+	tiger *models.Tiger
+}
+
+func (s *sightingResolver) ID() graphql.ID {
+	return graphql.ID(strconv.Itoa(s.sighting.ID))
+}
+
+func (s *sightingResolver) Timestamp() graphql.Time {
+	return graphql.Time{Time: s.sighting.Timestamp}
+}
+
+func (s *sightingResolver) Lat() float64 {
+	return s.sighting.Lat
+}
+
+func (s *sightingResolver) Long() float64 {
+	return s.sighting.Long
+}
+
+func (s *sightingResolver) Status() string {
+	return s.sighting.Status
+}
+
+// ImageURL is the URL of the first photo of the sighting, as served by the REST routes.
+func (s *sightingResolver) ImageURL() *string {
+	if s.sighting.ImageKey == "" {
+		return nil
+	}
+	url := fmt.Sprintf("/sightings/%d/image", s.sighting.ID)
+	return &url
+}
+
+func (s *sightingResolver) Images() []*sightingImageResolver {
+	images := make([]*sightingImageResolver, len(s.sighting.Images))
+	for i, image := range s.sighting.Images {
+		images[i] = &sightingImageResolver{image: image}
+	}
+	return images
+}
+
+func (s *sightingResolver) Tiger(ctx context.Context) (*tigerResolver, error) {
+	if s.tiger != nil {
+		return &tigerResolver{tigerService: s.tigerService, tiger: s.tiger}, nil
+	}
+
+	tiger, ok, err := loadersFromContext(ctx).tigers.load(s.sighting.TigerID)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &tigerResolver{tigerService: s.tigerService, tiger: tiger}, nil
+}
+
+// Reporter is the user who reported the sighting, null when they are no longer registered.
+func (s *sightingResolver) Reporter(ctx context.Context) (*userResolver, error) {
+	user, ok, err := loadersFromContext(ctx).users.load(utils.NormalizeEmail(s.sighting.ReporterEmail))
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &userResolver{user: user}, nil
+}
+
+type sightingImageResolver struct {
+	image *models.SightingImage
+}
+
+func (i *sightingImageResolver) Position() int32 {
+	return int32(i.image.Position)
+}
+
+func (i *sightingImageResolver) Rendition() string {
+	return i.image.Rendition
+}
+
+func (i *sightingImageResolver) Format() string {
+	return i.image.Format
+}
+
+func (i *sightingImageResolver) Width() int32 {
+	return int32(i.image.Width)
+}
+
+func (i *sightingImageResolver) Height() int32 {
+	return int32(i.image.Height)
+}
+
+func (i *sightingImageResolver) URL() string {
+	return fmt.Sprintf("/sightings/%d/images/%d/%s.%s", i.image.SightingID, i.image.Position, i.image.Rendition, i.image.Format)
+}
+
+type userResolver struct {
+	user *models.User
+}
+
+func (u *userResolver) ID() graphql.ID {
+	return graphql.ID(strconv.Itoa(u.user.ID))
+}
+
+func (u *userResolver) Username() string {
+	return u.user.Username
+}
+
+// Email is only shown to the user themselves and to rangers and admins.
+func (u *userResolver) Email(ctx context.Context) *string {
+	claims, ok := auth.GetClaimsFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	if utils.NormalizeEmail(claims.Email) != utils.NormalizeEmail(u.user.Email) && !claims.HasRole(models.RoleRanger, models.RoleAdmin) {
+		return nil
+	}
+	return &u.user.Email
+}
+
+type pageInfo struct {
+	hasNextPage bool
+	endCursor   *string
+}
+
+func (p *pageInfo) HasNextPage() bool {
+	return p.hasNextPage
+}
+
+func (p *pageInfo) EndCursor() *string {
+	return p.endCursor
+}
+
+type tigerConnection struct {
+	tigerService service.TigerService
+	tigers       []*models.Tiger
+	hasNextPage  bool
+}
+
+type tigerEdge struct {
+	cursor string
+	node   *tigerResolver
+}
+
+func (e *tigerEdge) Cursor() string {
+	return e.cursor
+}
+
+func (e *tigerEdge) Node() *tigerResolver {
+	return e.node
+}
+
+func (c *tigerConnection) Edges() []*tigerEdge {
+	edges := make([]*tigerEdge, len(c.tigers))
+	for i, tiger := range c.tigers {
+		edges[i] = &tigerEdge{
+			cursor: utils.EncodeCursor(models.Cursor{Time: tiger.LastSeen, ID: tiger.ID}),
+			node:   &tigerResolver{tigerService: c.tigerService, tiger: tiger},
+		}
+	}
+	return edges
+}
+
+func (c *tigerConnection) PageInfo() *pageInfo {
+	info := &pageInfo{hasNextPage: c.hasNextPage}
+	if len(c.tigers) > 0 {
+		last := c.tigers[len(c.tigers)-1]
+		endCursor := utils.EncodeCursor(models.Cursor{Time: last.LastSeen, ID: last.ID})
+		info.endCursor = &endCursor
+	}
+	return info
+}
+
+type sightingConnection struct {
+	tigerService service.TigerService
+	tiger        *models.Tiger
+	sightings    []*models.TigerSighting
+	hasNextPage  bool
+}
+
+type sightingEdge struct {
+	cursor string
+	node   *sightingResolver
+}
+
+func (e *sightingEdge) Cursor() string {
+	return e.cursor
+}
+
+func (e *sightingEdge) Node() *sightingResolver {
+	return e.node
+}
+
+func (c *sightingConnection) Edges() []*sightingEdge {
+	edges := make([]*sightingEdge, len(c.sightings))
+	for i, sighting := range c.sightings {
+		edges[i] = &sightingEdge{
+			cursor: utils.EncodeCursor(models.Cursor{Time: sighting.Timestamp, ID: sighting.ID}),
+			node:   &sightingResolver{tigerService: c.tigerService, sighting: sighting, tiger: c.tiger},
+		}
+	}
+	return edges
+}
+
+func (c *sightingConnection) PageInfo() *pageInfo {
+	info := &pageInfo{hasNextPage: c.hasNextPage}
+	if len(c.sightings) > 0 {
+		last := c.sightings[len(c.sightings)-1]
+		endCursor := utils.EncodeCursor(models.Cursor{Time: last.Timestamp, ID: last.ID})
+		info.endCursor = &endCursor
+	}
+	return info
+}
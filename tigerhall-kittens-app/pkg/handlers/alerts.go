@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"tigerhall-kittens-app/pkg/auth"
+	"tigerhall-kittens-app/pkg/models"
+	"tigerhall-kittens-app/pkg/service"
+	"tigerhall-kittens-app/pkg/utils"
+)
+
+const (
+	// MaxAlertRadiusKm bounds the radius of a sighting alert
+	MaxAlertRadiusKm = 100.0
+	// MaxAlertNameLength and MaxWebhookURLLength bound the text fields of a sighting alert
+	MaxAlertNameLength  = 100
+	MaxWebhookURLLength = 2048
+)
+
+// GetTigerSubscriptionsHandler lists the tigers the logged in user follows or opted out of.
+func (h *handlers) GetTigerSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	email, ok := auth.GetEmailFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	subscriptions, err := h.TigerService.GetTigerSubscriptionsService(email)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"subscriptions": subscriptions})
+}
+
+// CreateSightingAlertHandler creates a sighting alert of the logged in user. The response
+// holds the secret webhooks are signed with, it cannot be read again.
+func (h *handlers) CreateSightingAlertHandler(w http.ResponseWriter, r *http.Request) {
+	var alert models.SightingAlert
+	if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to parse request body")
+		return
+	}
+	if err := validateSightingAlert(&alert); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	email, ok := auth.GetEmailFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.TigerService.CreateSightingAlertService(email, &alert); err != nil {
+		utils.RespondWithError(w, alertErrorStatus(err), err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, alert)
+}
+
+// GetSightingAlertsHandler lists the sighting alerts of the logged in user.
+func (h *handlers) GetSightingAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	email, ok := auth.GetEmailFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	alerts, err := h.TigerService.GetSightingAlertsService(email)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"alerts": alerts})
+}
+
+// DeleteSightingAlertHandler deletes a sighting alert of the logged in user.
+func (h *handlers) DeleteSightingAlertHandler(w http.ResponseWriter, r *http.Request) {
+	alertID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid alert id")
+		return
+	}
+
+	email, ok := auth.GetEmailFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.TigerService.DeleteSightingAlertService(email, alertID); err != nil {
+		utils.RespondWithError(w, alertErrorStatus(err), err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"message": "success"})
+}
+
+// validateSightingAlert checks the alert sent by a user: a name, a center, a radius up
+// to MaxAlertRadiusKm and a channel. Webhooks must be HTTPS URLs.
+func validateSightingAlert(alert *models.SightingAlert) error {
+	alert.Name = strings.TrimSpace(alert.Name)
+	if alert.Name == "" || len(alert.Name) > MaxAlertNameLength {
+		return fmt.Errorf("name is required and must be at most %d characters", MaxAlertNameLength)
+	}
+	if alert.Lat < -90 || alert.Lat > 90 {
+		return errors.New("invalid lat value")
+	}
+	if alert.Long < -180 || alert.Long > 180 {
+		return errors.New("invalid long value")
+	}
+	if alert.RadiusKm <= 0 || alert.RadiusKm > MaxAlertRadiusKm {
+		return fmt.Errorf("radiusKm must be between 0 and %v", MaxAlertRadiusKm)
+	}
+
+	switch alert.Channel {
+	case models.AlertChannelEmail:
+	case models.AlertChannelWebhook:
+		webhookURL, err := url.Parse(alert.WebhookURL)
+		if err != nil || webhookURL.Scheme != "https" || webhookURL.Host == "" || len(alert.WebhookURL) > MaxWebhookURLLength {
+			return errors.New("webhookURL must be an https URL")
+		}
+	default:
+		return errors.New("channel must be email or webhook")
+	}
+	return nil
+}
+
+// alertErrorStatus maps the errors of the sighting alerts to HTTP status codes.
+func alertErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrAlertNotFound), errors.Is(err, service.ErrTigerNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrTooManyAlerts):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
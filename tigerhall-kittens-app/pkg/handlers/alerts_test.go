@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"tigerhall-kittens-app/pkg/auth"
+	"tigerhall-kittens-app/pkg/models"
+	"tigerhall-kittens-app/pkg/service"
+)
+
+func TestCreateSightingAlertHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		error  string
+	}{
+		{name: "email", body: `{"name": " Camp ", "lat": 12.34, "long": 56.78, "radiusKm": 10, "channel": "email"}`, status: http.StatusCreated},
+		{name: "webhook", body: `{"name": "Camp", "lat": 12.34, "long": 56.78, "radiusKm": 10, "tigerID": 1, "channel": "webhook", "webhookURL": "https://example.com/hook"}`, status: http.StatusCreated},
+		{name: "missing name", body: `{"lat": 12.34, "long": 56.78, "radiusKm": 10, "channel": "email"}`, status: http.StatusBadRequest, error: "name is required"},
+		{name: "invalid lat", body: `{"name": "Camp", "lat": 91, "long": 56.78, "radiusKm": 10, "channel": "email"}`, status: http.StatusBadRequest, error: "invalid lat"},
+		{name: "radius too large", body: `{"name": "Camp", "lat": 12.34, "long": 56.78, "radiusKm": 1000, "channel": "email"}`, status: http.StatusBadRequest, error: "radiusKm"},
+		{name: "unknown channel", body: `{"name": "Camp", "lat": 12.34, "long": 56.78, "radiusKm": 10, "channel": "sms"}`, status: http.StatusBadRequest, error: "channel"},
+		{name: "plain http webhook", body: `{"name": "Camp", "lat": 12.34, "long": 56.78, "radiusKm": 10, "channel": "webhook", "webhookURL": "http://example.com/hook"}`, status: http.StatusBadRequest, error: "webhookURL"},
+		{name: "unknown tiger", body: `{"name": "Camp", "lat": 12.34, "long": 56.78, "radiusKm": 10, "tigerID": 2, "channel": "email"}`, status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			var created *models.SightingAlert
+			mockService := &mockTigerService{
+				createSightingAlertService: func(email string, alert *models.SightingAlert) error {
+					if alert.TigerID != nil && *alert.TigerID != 1 {
+						return service.ErrTigerNotFound
+					}
+					alert.ID = 5
+					if alert.Channel == models.AlertChannelWebhook {
+						alert.WebhookSecret = "secret"
+					}
+					created = alert
+					return nil
+				},
+			}
+			handler := NewHandlers(mockService, log.Default(), auth.NewAuth("test_secret_key"))
+			req := httptest.NewRequest(http.MethodPost, "/alerts", strings.NewReader(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), "email", "ranger@example.com"))
+			rr := httptest.NewRecorder()
+
+			// Act
+			handler.CreateSightingAlertHandler(rr, req)
+
+			// Assert
+			assert.Equal(t, tt.status, rr.Code)
+			var response map[string]interface{}
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+			if tt.status != http.StatusCreated {
+				assert.Contains(t, response["error"], tt.error)
+				return
+			}
+			assert.Equal(t, "Camp", created.Name, "The name should be trimmed")
+			assert.Equal(t, float64(5), response["id"])
+			assert.NotContains(t, response, "email")
+			if created.Channel == models.AlertChannelWebhook {
+				assert.Equal(t, "secret", response["webhookSecret"], "The secret should be returned once")
+			}
+		})
+	}
+}
+
+func TestGetSightingAlertsHandler(t *testing.T) {
+	// Arrange
+	mockService := &mockTigerService{
+		getSightingAlertsService: func(email string) ([]*models.SightingAlert, error) {
+			assert.Equal(t, "ranger@example.com", email)
+			return []*models.SightingAlert{{ID: 5, Name: "Camp", RadiusKm: 10, Channel: models.AlertChannelEmail}}, nil
+		},
+	}
+	handler := NewHandlers(mockService, log.Default(), auth.NewAuth("test_secret_key"))
+	req := httptest.NewRequest(http.MethodGet, "/alerts", nil)
+	req = req.WithContext(context.WithValue(req.Context(), "email", "ranger@example.com"))
+	rr := httptest.NewRecorder()
+
+	// Act
+	handler.GetSightingAlertsHandler(rr, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var response struct {
+		Alerts []*models.SightingAlert `json:"alerts"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Len(t, response.Alerts, 1)
+}
+
+func TestDeleteSightingAlertHandler(t *testing.T) {
+	tests := []struct {
+		id     string
+		status int
+	}{
+		{id: "5", status: http.StatusOK},
+		{id: "6", status: http.StatusNotFound},
+		{id: "camp", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.id, func(t *testing.T) {
+			// Arrange
+			mockService := &mockTigerService{
+				deleteSightingAlertService: func(email string, alertID int) error {
+					if alertID != 5 {
+						return service.ErrAlertNotFound
+					}
+					return nil
+				},
+			}
+			handler := NewHandlers(mockService, log.Default(), auth.NewAuth("test_secret_key"))
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/alerts/"+tt.id, nil), map[string]string{"id": tt.id})
+			req = req.WithContext(context.WithValue(req.Context(), "email", "ranger@example.com"))
+			rr := httptest.NewRecorder()
+
+			// Act
+			handler.DeleteSightingAlertHandler(rr, req)
+
+			// Assert
+			assert.Equal(t, tt.status, rr.Code)
+		})
+	}
+}
+
+func TestGetTigerSubscriptionsHandler(t *testing.T) {
+	// Arrange
+	mockService := &mockTigerService{
+		getTigerSubscriptionsService: func(email string) ([]*models.TigerSubscription, error) {
+			return []*models.TigerSubscription{{TigerID: 1, Subscribed: true}, {TigerID: 2, Subscribed: false}}, nil
+		},
+	}
+	handler := NewHandlers(mockService, log.Default(), auth.NewAuth("test_secret_key"))
+	req := httptest.NewRequest(http.MethodGet, "/notifications/subscriptions", nil)
+	req = req.WithContext(context.WithValue(req.Context(), "email", "ranger@example.com"))
+	rr := httptest.NewRecorder()
+
+	// Act
+	handler.GetTigerSubscriptionsHandler(rr, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var response struct {
+		Subscriptions []*models.TigerSubscription `json:"subscriptions"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Len(t, response.Subscriptions, 2)
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"tigerhall-kittens-app/pkg/models"
+	"tigerhall-kittens-app/pkg/utils"
+)
+
+// csvFlushInterval is the number of rows written between two flushes of the CSV export.
+const csvFlushInterval = 100
+
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// GetTigerTrailHandler writes the sightings of a tiger as a GeoJSON FeatureCollection
+// holding one Point feature per sighting, oldest first, followed by a LineString
+// feature through all of them. Features are written while the sightings are read,
+// only the coordinates of the line are kept in memory.
+func (h *handlers) GetTigerTrailHandler(w http.ResponseWriter, r *http.Request) {
+	tigerID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid tiger id")
+		return
+	}
+
+	tiger, err := h.TigerService.GetTigerService(tigerID)
+	if err != nil {
+		utils.RespondWithError(w, tigerErrorStatus(err), err.Error())
+		return
+	}
+
+	// The document is opened with the first feature, so a failing query can still be reported as an error
+	started := false
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", "application/geo+json")
+		_, err := io.WriteString(w, `{"type":"FeatureCollection","features":[`)
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	line := [][2]float64{}
+	err = h.TigerService.StreamTigerTrailService(tigerID, func(sighting *models.TigerSighting) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		} else if _, err := io.WriteString(w, ","); err != nil {
+			return err
+		}
+		line = append(line, [2]float64{sighting.Long, sighting.Lat})
+
+		return encoder.Encode(geoJSONFeature{
+			Type:     "Feature",
+			Geometry: geoJSONGeometry{Type: "Point", Coordinates: [2]float64{sighting.Long, sighting.Lat}},
+			Properties: map[string]interface{}{
+				"sightingID": sighting.ID,
+				"tigerID":    sighting.TigerID,
+				"timestamp":  sighting.Timestamp,
+			},
+		})
+	})
+	if err != nil && !started {
+		utils.RespondWithError(w, http.StatusInternalServerError, "failed to fetch tiger trail")
+		return
+	} else if err != nil {
+		// The status has already been sent, leaving the document unterminated marks it as broken
+		h.Logger.Printf("Failed to stream trail of tiger %d: %v", tigerID, err)
+		return
+	}
+
+	if !started {
+		start()
+	}
+
+	// A LineString needs at least two positions
+	if len(line) >= 2 {
+		io.WriteString(w, ",")
+		encoder.Encode(geoJSONFeature{
+			Type:     "Feature",
+			Geometry: geoJSONGeometry{Type: "LineString", Coordinates: line},
+			Properties: map[string]interface{}{
+				"tigerID": tiger.ID,
+				"name":    tiger.Name,
+			},
+		})
+	}
+	io.WriteString(w, "]}")
+}
+
+// ExportTigerSightingsCSVHandler writes all sightings between the optional from and to
+// times as CSV, oldest first, flushing the response while the rows are read.
+func (h *handlers) ExportTigerSightingsCSVHandler(w http.ResponseWriter, r *http.Request) {
+	from, err := parseOptionalTime(r.FormValue("from"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid from value")
+		return
+	}
+
+	to, err := parseOptionalTime(r.FormValue("to"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid to value")
+		return
+	}
+
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		utils.RespondWithError(w, http.StatusBadRequest, "from must not be after to")
+		return
+	}
+
+	writer := csv.NewWriter(w)
+	flusher, _ := w.(http.Flusher)
+
+	// The header is written with the first row, so a failing query can still be reported as an error
+	started := false
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="sightings.csv"`)
+		return writer.Write([]string{"id", "tiger_id", "timestamp", "lat", "long", "reporter_email"})
+	}
+
+	rows := 0
+	err = h.TigerService.ExportTigerSightingsService(from, to, func(sighting *models.TigerSighting) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+
+		err := writer.Write([]string{
+			strconv.Itoa(sighting.ID),
+			strconv.Itoa(sighting.TigerID),
+			sighting.Timestamp.Format(time.RFC3339),
+			strconv.FormatFloat(sighting.Lat, 'f', -1, 64),
+			strconv.FormatFloat(sighting.Long, 'f', -1, 64),
+			sighting.ReporterEmail,
+		})
+		if err != nil {
+			return err
+		}
+
+		rows++
+		if rows%csvFlushInterval == 0 {
+			writer.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+			return writer.Error()
+		}
+		return nil
+	})
+	if err != nil && !started {
+		utils.RespondWithError(w, http.StatusInternalServerError, "failed to export tiger sightings")
+		return
+	} else if err != nil {
+		h.Logger.Printf("Failed to export tiger sightings: %v", err)
+		return
+	}
+
+	if !started {
+		start()
+	}
+	writer.Flush()
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"tigerhall-kittens-app/pkg/auth"
+	"tigerhall-kittens-app/pkg/models"
+)
+
+func TestGetTigerTrailHandler_Success(t *testing.T) {
+	// Arrange
+	sightings := []*models.TigerSighting{
+		{ID: 1, TigerID: 1, Timestamp: time.Date(2023, time.July, 20, 12, 0, 0, 0, time.UTC), Lat: 12.34, Long: 56.78},
+		{ID: 2, TigerID: 1, Timestamp: time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC), Lat: 12.45, Long: 56.89},
+	}
+
+	mockService := &mockTigerService{
+		getTigerService: func(tigerID int) (*models.Tiger, error) {
+			return &models.Tiger{ID: tigerID, Name: "Mufasa"}, nil
+		},
+		streamTigerTrailService: func(tigerID int, fn func(*models.TigerSighting) error) error {
+			for _, sighting := range sightings {
+				if err := fn(sighting); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	handler := NewHandlers(mockService, log.Default(), auth.NewAuth("test_secret_key"))
+
+	req, err := http.NewRequest(http.MethodGet, "/tiger/1/trail.geojson", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	// Act
+	handler.GetTigerTrailHandler(rr, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rr.Code, "Status code should be 200")
+	assert.Equal(t, "application/geo+json", rr.Header().Get("Content-Type"), "Content type should be GeoJSON")
+
+	var collection struct {
+		Type     string           `json:"type"`
+		Features []geoJSONFeature `json:"features"`
+	}
+	err = json.Unmarshal(rr.Body.Bytes(), &collection)
+	assert.NoError(t, err, "Response should be valid JSON")
+	assert.Equal(t, "FeatureCollection", collection.Type)
+	assert.Len(t, collection.Features, 3, "Expected two points and one line")
+	assert.Equal(t, "Point", collection.Features[0].Geometry.Type)
+	assert.Equal(t, []interface{}{56.78, 12.34}, collection.Features[0].Geometry.Coordinates, "Coordinates should be long, lat")
+	assert.Equal(t, "LineString", collection.Features[2].Geometry.Type)
+	assert.Equal(t, []interface{}{[]interface{}{56.78, 12.34}, []interface{}{56.89, 12.45}}, collection.Features[2].Geometry.Coordinates)
+	assert.Equal(t, "Mufasa", collection.Features[2].Properties["name"])
+}
+
+func TestGetTigerTrailHandler_NoSightings(t *testing.T) {
+	// Arrange
+	mockService := &mockTigerService{
+		getTigerService: func(tigerID int) (*models.Tiger, error) {
+			return &models.Tiger{ID: tigerID, Name: "Mufasa"}, nil
+		},
+		streamTigerTrailService: func(tigerID int, fn func(*models.TigerSighting) error) error {
+			return nil
+		},
+	}
+
+	handler := NewHandlers(mockService, log.Default(), auth.NewAuth("test_secret_key"))
+
+	req, err := http.NewRequest(http.MethodGet, "/tiger/1/trail.geojson", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	// Act
+	handler.GetTigerTrailHandler(rr, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rr.Code, "Status code should be 200")
+	assert.JSONEq(t, `{"type":"FeatureCollection","features":[]}`, rr.Body.String())
+}
+
+func TestExportTigerSightingsCSVHandler_Success(t *testing.T) {
+	// Arrange
+	var receivedFrom, receivedTo time.Time
+	mockService := &mockTigerService{
+		exportTigerSightingsService: func(from, to time.Time, fn func(*models.TigerSighting) error) error {
+			receivedFrom, receivedTo = from, to
+			return fn(&models.TigerSighting{
+				ID:            1,
+				TigerID:       2,
+				Timestamp:     time.Date(2023, time.July, 20, 12, 0, 0, 0, time.UTC),
+				Lat:           12.34,
+				Long:          56.78,
+				ReporterEmail: "reporter@example.com",
+			})
+		},
+	}
+
+	handler := NewHandlers(mockService, log.Default(), auth.NewAuth("test_secret_key"))
+
+	req, err := http.NewRequest(http.MethodGet, "/export/sightings.csv?from=2023-07-01T00:00:00Z&to=2023-08-01T00:00:00Z", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	// Act
+	handler.ExportTigerSightingsCSVHandler(rr, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rr.Code, "Status code should be 200")
+	assert.Equal(t, "text/csv", rr.Header().Get("Content-Type"), "Content type should be CSV")
+	assert.Equal(t, time.Date(2023, time.July, 1, 0, 0, 0, 0, time.UTC), receivedFrom)
+	assert.Equal(t, time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC), receivedTo)
+	assert.Equal(t, "id,tiger_id,timestamp,lat,long,reporter_email\n1,2,2023-07-20T12:00:00Z,12.34,56.78,reporter@example.com\n", rr.Body.String())
+}
+
+func TestExportTigerSightingsCSVHandler_Failure(t *testing.T) {
+	// Arrange
+	mockService := &mockTigerService{
+		exportTigerSightingsService: func(from, to time.Time, fn func(*models.TigerSighting) error) error {
+			return errors.New("connection reset")
+		},
+	}
+
+	handler := NewHandlers(mockService, log.Default(), auth.NewAuth("test_secret_key"))
+
+	req, err := http.NewRequest(http.MethodGet, "/export/sightings.csv", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	// Act
+	handler.ExportTigerSightingsCSVHandler(rr, req)
+
+	// Assert
+	assert.Equal(t, http.StatusInternalServerError, rr.Code, "Status code should be 500")
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"tigerhall-kittens-app/pkg/models"
+	"tigerhall-kittens-app/pkg/utils"
+)
+
+const (
+	// FeedPingInterval is how often idle feeds are pinged, so that proxies keep them open
+	// and WebSocket clients which went away are noticed
+	FeedPingInterval = 30 * time.Second
+	// feedWriteTimeout bounds the time to write a message to a WebSocket client
+	feedWriteTimeout = 10 * time.Second
+	// MaxFeedTigerIDs is the most tigers a feed may be filtered by
+	MaxFeedTigerIDs = 100
+)
+
+// feedUpgrader upgrades feed requests to WebSocket connections. Requests sent by browsers
+// from another origin are refused.
+var feedUpgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 4096}
+
+// SightingEventsHandler streams the approved sightings as Server-Sent Events, as they are
+// approved. Every sighting is a "sighting" event with the sighting as JSON data and its ID
+// as event ID. The sightings are filtered by parseSightingFeedFilter.
+func (h *handlers) SightingEventsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseSightingFeedFilter(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	subscription := h.TigerService.SubscribeSightingsService(filter)
+	defer subscription.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Keeps nginx from buffering the events
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ping := time.NewTicker(FeedPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ping.C:
+			// Comments are ignored by clients
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case sighting, ok := <-subscription.C:
+			if !ok {
+				// Dropped for falling behind, EventSource clients reconnect by themselves
+				return
+			}
+			data, err := feedMessage(sighting)
+			if err != nil {
+				h.Logger.Printf("failed to encode sighting %d for the feed: %v", sighting.ID, err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: sighting\ndata: %s\n\n", sighting.ID, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// SightingsWebSocketHandler streams the approved sightings over a WebSocket, as they are
+// approved. Every sighting is a text message with the sighting as JSON. The sightings are
+// filtered by parseSightingFeedFilter, messages sent by the client are ignored.
+func (h *handlers) SightingsWebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseSightingFeedFilter(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Upgrade responds with the error itself
+	conn, err := feedUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	subscription := h.TigerService.SubscribeSightingsService(filter)
+	defer subscription.Close()
+
+	// Reading handles the pongs and the close message of the client, the connection is
+	// given up when no pong arrives within two pings
+	closed := make(chan struct{})
+	conn.SetReadLimit(1024)
+	conn.SetReadDeadline(time.Now().Add(2 * FeedPingInterval))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * FeedPingInterval))
+	})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(FeedPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(feedWriteTimeout)); err != nil {
+				return
+			}
+		case sighting, ok := <-subscription.C:
+			if !ok {
+				message := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "fell behind the feed")
+				conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(feedWriteTimeout))
+				return
+			}
+			data, err := feedMessage(sighting)
+			if err != nil {
+				h.Logger.Printf("failed to encode sighting %d for the feed: %v", sighting.ID, err)
+				continue
+			}
+			conn.SetWriteDeadline(time.Now().Add(feedWriteTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// parseSightingFeedFilter reads the filter of a feed from the query string: tigerID, which
+// may be repeated, keeps the sightings of these tigers and bbox those in the area.
+func parseSightingFeedFilter(r *http.Request) (models.SightingFeedFilter, error) {
+	var filter models.SightingFeedFilter
+
+	tigerIDs := r.URL.Query()["tigerID"]
+	if len(tigerIDs) > MaxFeedTigerIDs {
+		return filter, fmt.Errorf("at most %d tigerID values are allowed", MaxFeedTigerIDs)
+	}
+	for _, value := range tigerIDs {
+		tigerID, err := strconv.Atoi(value)
+		if err != nil {
+			return filter, errors.New("invalid tigerID value")
+		}
+		filter.TigerIDs = append(filter.TigerIDs, tigerID)
+	}
+
+	if bbox := r.FormValue("bbox"); bbox != "" {
+		box, err := parseBoundingBox(bbox)
+		if err != nil {
+			return filter, err
+		}
+		filter.Region = &box
+	}
+
+	return filter, nil
+}
+
+// feedMessage encodes the sighting with the URLs of its images. The sighting is shared by
+// every feed, so the URLs are set on a copy.
+func feedMessage(sighting *models.TigerSighting) ([]byte, error) {
+	message := *sighting
+	message.Images = nil
+	for _, image := range sighting.Images {
+		imageCopy := *image
+		message.Images = append(message.Images, &imageCopy)
+	}
+	setSightingImageURLs(&message, "/sightings")
+	return json.Marshal(&message)
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"tigerhall-kittens-app/pkg/auth"
+	"tigerhall-kittens-app/pkg/models"
+	"tigerhall-kittens-app/pkg/service"
+)
+
+// newFeedServer serves the feed handler with a hub the test publishes to. subscribed
+// receives the filter of every feed once it subscribed.
+func newFeedServer(t *testing.T, handler func(*handlers) http.HandlerFunc) (*httptest.Server, *service.SightingHub, chan models.SightingFeedFilter) {
+	hub := service.NewSightingHub()
+	subscribed := make(chan models.SightingFeedFilter, 1)
+	mockService := &mockTigerService{
+		subscribeSightingsService: func(filter models.SightingFeedFilter) *service.SightingSubscription {
+			subscription := hub.Subscribe(filter)
+			subscribed <- filter
+			return subscription
+		},
+	}
+	h := NewHandlers(mockService, log.Default(), auth.NewAuth("test_secret_key"))
+	server := httptest.NewServer(handler(h))
+	t.Cleanup(server.Close)
+	return server, hub, subscribed
+}
+
+func feedSighting() *models.TigerSighting {
+	return &models.TigerSighting{
+		ID:        3,
+		TigerID:   1,
+		Timestamp: time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC),
+		Lat:       12.35,
+		Long:      56.79,
+		Images:    []*models.SightingImage{{Position: 0, Rendition: "thumbnail", Format: "jpeg"}},
+		Status:    models.SightingApproved,
+	}
+}
+
+func TestSightingEventsHandler(t *testing.T) {
+	// Arrange
+	server, hub, subscribed := newFeedServer(t, func(h *handlers) http.HandlerFunc { return h.SightingEventsHandler })
+	resp, err := http.Get(server.URL + "/events/sightings?tigerID=1&tigerID=2")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	filter := <-subscribed
+	sighting := feedSighting()
+
+	// Act
+	hub.Publish(sighting)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Equal(t, []int{1, 2}, filter.TigerIDs)
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		if !assert.NoError(t, err) {
+			return
+		}
+		if line == "\n" {
+			break
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	if !assert.Len(t, lines, 3) {
+		return
+	}
+	assert.Equal(t, "id: 3", lines[0])
+	assert.Equal(t, "event: sighting", lines[1])
+
+	var event models.TigerSighting
+	assert.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &event))
+	assert.Equal(t, 1, event.TigerID)
+	assert.Equal(t, "/sightings/3/images/0/thumbnail.jpeg", event.Images[0].URL)
+	assert.Empty(t, sighting.Images[0].URL, "The published sighting should not be modified")
+}
+
+func TestSightingsWebSocketHandler(t *testing.T) {
+	// Arrange
+	server, hub, subscribed := newFeedServer(t, func(h *handlers) http.HandlerFunc { return h.SightingsWebSocketHandler })
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/sightings?bbox=12,56,13,57"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	filter := <-subscribed
+
+	// Act
+	hub.Publish(&models.TigerSighting{ID: 2, TigerID: 2, Lat: -12.35, Long: 56.79})
+	hub.Publish(feedSighting())
+
+	// Assert
+	assert.Equal(t, &models.BoundingBox{MinLat: 12, MinLong: 56, MaxLat: 13, MaxLong: 57}, filter.Region)
+
+	var event models.TigerSighting
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	assert.NoError(t, conn.ReadJSON(&event))
+	assert.Equal(t, 3, event.ID, "Sightings outside the region should not be sent")
+
+	// Closing the connection ends the subscription
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	assert.Eventually(t, func() bool { return hub.Subscribers() == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSightingFeedHandlers_InvalidFilter(t *testing.T) {
+	handler := NewHandlers(&mockTigerService{}, log.Default(), auth.NewAuth("test_secret_key"))
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		query   string
+	}{
+		{name: "events tiger", handler: handler.SightingEventsHandler, query: "tigerID=shere-khan"},
+		{name: "events region", handler: handler.SightingEventsHandler, query: "bbox=1,2,3"},
+		{name: "websocket tiger", handler: handler.SightingsWebSocketHandler, query: "tigerID=shere-khan"},
+		{name: "websocket region", handler: handler.SightingsWebSocketHandler, query: "bbox=1,2,3"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			rr := httptest.NewRecorder()
+
+			// Act
+			tt.handler(rr, httptest.NewRequest(http.MethodGet, "/sightings?"+tt.query, nil))
+
+			// Assert
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+}
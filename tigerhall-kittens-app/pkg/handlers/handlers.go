@@ -1,35 +1,65 @@
 package handlers
 
 import (
-	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"image"
-	"image/jpeg"
+	"io"
 	"io/ioutil"
 	"log"
 	"math"
 	"mime/multipart"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	"tigerhall-kittens-app/pkg/auth"
 	"tigerhall-kittens-app/pkg/models"
+	"tigerhall-kittens-app/pkg/ratelimit"
 	"tigerhall-kittens-app/pkg/service"
 	"tigerhall-kittens-app/pkg/utils"
 )
 
-const DefaultPageSize = 10
+const (
+	DefaultPageSize = 10
+
+	// MaxNearbyRadiusKm bounds the radius of a nearby tiger search
+	MaxNearbyRadiusKm = 500.0
+	// DefaultSightingsLimit and MaxSightingsLimit bound the sightings returned for an area
+	DefaultSightingsLimit = 100
+	MaxSightingsLimit     = 1000
+	// MaxSightingPhotos is the most image parts a sighting may be reported with
+	MaxSightingPhotos = 5
+)
 
 type pagination map[string]interface{}
 
+// UploadLimits bound the sightings reported with CreateTigerSightingHandler.
+type UploadLimits struct {
+	// MaxRequestBytes is the size of the largest request accepted, all photos included
+	MaxRequestBytes int64
+	// Photos wider or higher than MaxImageDimension, or with more than MaxImagePixels
+	// pixels, are rejected before they are decoded
+	MaxImageDimension int
+	MaxImagePixels    int
+}
+
+// DefaultUploadLimits accept a handful of photos from a recent phone camera.
+var DefaultUploadLimits = UploadLimits{
+	MaxRequestBytes:   32 << 20,
+	MaxImageDimension: 10000,
+	MaxImagePixels:    50000000,
+}
+
 type handlers struct {
 	Auth         *auth.Auth
 	Logger       *log.Logger
 	TigerService service.TigerService
+	// Lockout locks accounts after repeated failed logins, when set
+	Lockout *ratelimit.Lockout
+	Uploads UploadLimits
 }
 
 func NewHandlers(tigerService service.TigerService, logger *log.Logger, auth *auth.Auth) *handlers {
@@ -37,6 +67,7 @@ func NewHandlers(tigerService service.TigerService, logger *log.Logger, auth *au
 		Auth:         auth,
 		Logger:       logger,
 		TigerService: tigerService,
+		Uploads:      DefaultUploadLimits,
 	}
 }
 
@@ -55,7 +86,10 @@ func (h *handlers) SignupHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err := h.TigerService.SignupService(&user)
-	if err != nil {
+	if errors.Is(err, service.ErrEmailTaken) {
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -72,24 +106,215 @@ func (h *handlers) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	account := utils.NormalizeEmail(loginCredentials.Email)
+	if h.Lockout != nil {
+		lockedFor, err := h.Lockout.LockedFor(account)
+		if err != nil {
+			h.Logger.Printf("Failed to check account lock: %v", err)
+		} else if lockedFor > 0 {
+			utils.RespondWithTooManyRequests(w, lockedFor, "Too many failed logins, try again later")
+			return
+		}
+	}
+
 	user, err := h.TigerService.LoginService(loginCredentials)
-	if err != nil {
+	if errors.Is(err, service.ErrEmailNotVerified) {
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+		return
+	} else if err != nil {
+		// Failures are counted for unknown emails too, so that locks do not tell who has an account
+		if h.Lockout != nil {
+			if _, err := h.Lockout.Fail(account); err != nil {
+				h.Logger.Printf("Failed to count failed login: %v", err)
+			}
+		}
 		utils.RespondWithError(w, http.StatusUnauthorized, err.Error())
 		return
 	}
+	if h.Lockout != nil {
+		if err := h.Lockout.Reset(account); err != nil {
+			h.Logger.Printf("Failed to reset failed logins: %v", err)
+		}
+	}
 
 	// Generate JWT token
-	token, err := h.Auth.GenerateToken(user.Username, user.Email)
+	token, err := h.Auth.GenerateToken(user.Username, user.Email, user.Roles...)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	refreshToken, err := h.TigerService.CreateRefreshTokenService(user)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	// Respond with the tokens as JSON
+	response := map[string]string{"token": token, "refresh_token": refreshToken}
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// VerifyEmailHandler verifies the email of a user with the token of the link mailed to them.
+func (h *handlers) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "token is required")
+		return
+	}
+
+	err := h.TigerService.VerifyEmailService(token)
+	if errors.Is(err, service.ErrInvalidUserToken) {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"message": "email verified"})
+}
+
+// ResendVerificationHandler mails a new verification link. The response is the same
+// whether or not there is an unverified user with the email.
+func (h *handlers) ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Email == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "email is required")
+		return
+	}
+
+	if err := h.TigerService.ResendVerificationService(body.Email); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusAccepted, map[string]interface{}{"message": "if the email is awaiting verification, a new link has been sent"})
+}
+
+// ForgotPasswordHandler mails a password reset token. The response is the same whether
+// or not there is a user with the email.
+func (h *handlers) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Email == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "email is required")
+		return
+	}
+
+	if err := h.TigerService.ForgotPasswordService(body.Email); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusAccepted, map[string]interface{}{"message": "if the email is registered, a password reset token has been sent"})
+}
+
+// ResetPasswordHandler sets a new password with a password reset token.
+func (h *handlers) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to parse request body")
+		return
+	}
+	if body.Token == "" || body.Password == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "token and password are required")
+		return
+	}
+
+	err := h.TigerService.ResetPasswordService(body.Token, body.Password)
+	if errors.Is(err, service.ErrInvalidUserToken) {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"message": "password has been reset"})
+}
+
+// RefreshTokenHandler exchanges a refresh token for a new access token and a new refresh token.
+func (h *handlers) RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.RefreshToken == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "refresh_token is required")
+		return
+	}
+
+	user, refreshToken, err := h.TigerService.RefreshTokenService(body.RefreshToken)
+	if errors.Is(err, service.ErrInvalidRefreshToken) {
+		utils.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	} else if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	token, err := h.Auth.GenerateToken(user.Username, user.Email, user.Roles...)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to generate token")
 		return
 	}
 
-	// Respond with the token as JSON
-	response := map[string]string{"token": token}
+	response := map[string]string{"token": token, "refresh_token": refreshToken}
 	utils.RespondWithJSON(w, http.StatusOK, response)
 }
 
+// LogoutHandler revokes the access token of the request and the refresh token in the body, if any.
+func (h *handlers) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Failed to parse request body")
+			return
+		}
+	}
+
+	email, ok := auth.GetEmailFromContext(r.Context())
+	claims, claimsOK := auth.GetClaimsFromContext(r.Context())
+	if !ok || !claimsOK {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if body.RefreshToken != "" {
+		err := h.TigerService.LogoutService(email, body.RefreshToken)
+		if errors.Is(err, service.ErrInvalidRefreshToken) {
+			utils.RespondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		} else if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	if err := h.Auth.RevokeToken(claims); err != nil {
+		h.Logger.Printf("Failed to revoke access token: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke token")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"message": "success"})
+}
+
+// JWKSHandler serves the public keys access tokens are signed with, so that other
+// services can verify the tokens. Clients may cache the keys for a short while.
+func (h *handlers) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	utils.RespondWithJSON(w, http.StatusOK, h.Auth.JWKS())
+}
+
 func (h *handlers) CreateTigerHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse the request body to get tiger data
 	var tiger models.Tiger
@@ -98,7 +323,7 @@ func (h *handlers) CreateTigerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.TigerService.CreateTigerService(tiger)
+	err := h.TigerService.CreateTigerService(&tiger)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -107,123 +332,599 @@ func (h *handlers) CreateTigerHandler(w http.ResponseWriter, r *http.Request) {
 	utils.RespondWithJSON(w, http.StatusCreated, map[string]interface{}{"message": "success"})
 }
 
-func (h *handlers) GetAllTigersHandler(w http.ResponseWriter, r *http.Request) {
-	// Get the pagination parameters from the query string
-	pageStr := r.FormValue("page")
-	pageSizeStr := r.FormValue("pageSize")
+func (h *handlers) GetTigerHandler(w http.ResponseWriter, r *http.Request) {
+	tigerID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid tiger id")
+		return
+	}
+
+	tiger, err := h.TigerService.GetTigerService(tigerID)
+	if err != nil {
+		utils.RespondWithError(w, tigerErrorStatus(err), err.Error())
+		return
+	}
+
+	w.Header().Set("ETag", formatETag(tiger.Version))
+	utils.RespondWithJSON(w, http.StatusOK, tiger)
+}
+
+func (h *handlers) UpdateTigerHandler(w http.ResponseWriter, r *http.Request) {
+	tigerID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid tiger id")
+		return
+	}
+
+	var update models.TigerUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to parse request body")
+		return
+	}
+
+	// The If-Match header takes precedence over the version sent in the body
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		version, err := parseETag(ifMatch)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid If-Match header")
+			return
+		}
+		update.Version = version
+	}
+	if update.Version == 0 {
+		utils.RespondWithError(w, http.StatusPreconditionRequired, "If-Match header or version is required")
+		return
+	}
 
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1
+	if update.Name != nil && strings.TrimSpace(*update.Name) == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "name must not be empty")
+		return
 	}
 
-	pageSize, err := strconv.Atoi(pageSizeStr)
-	if err != nil || pageSize < 1 {
-		pageSize = DefaultPageSize
+	tiger, err := h.TigerService.UpdateTigerService(tigerID, update)
+	if err != nil {
+		utils.RespondWithError(w, tigerErrorStatus(err), err.Error())
+		return
 	}
 
-	tigers, totalCount, err := h.TigerService.GetAllTigersService(page, pageSize)
+	w.Header().Set("ETag", formatETag(tiger.Version))
+	utils.RespondWithJSON(w, http.StatusOK, tiger)
+}
+
+func (h *handlers) DeleteTigerHandler(w http.ResponseWriter, r *http.Request) {
+	tigerID, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid tiger id")
+		return
+	}
+
+	if err := h.TigerService.DeleteTigerService(tigerID); err != nil {
+		utils.RespondWithError(w, tigerErrorStatus(err), err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"message": "success"})
+}
+
+// SetTigerSubscriptionHandler subscribes the logged in user to sightings of the tiger, or opts them out.
+func (h *handlers) SetTigerSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	tigerID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid tiger id")
+		return
+	}
+
+	var body struct {
+		Subscribed *bool `json:"subscribed"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Subscribed == nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "subscribed is required")
+		return
+	}
+
+	email, ok := auth.GetEmailFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.TigerService.SetTigerSubscriptionService(email, tigerID, *body.Subscribed); err != nil {
+		utils.RespondWithError(w, tigerErrorStatus(err), err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"message": "success"})
+}
+
+// SetNotificationPreferencesHandler switches the logged in user between one email per sighting and an hourly digest.
+func (h *handlers) SetNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Digest *bool `json:"digest"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Digest == nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "digest is required")
+		return
+	}
+
+	email, ok := auth.GetEmailFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.TigerService.SetNotificationPreferencesService(email, *body.Digest); err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// Construct pagination response
-	paginationResponse := pagination{
-		"page":       page,
-		"pageSize":   pageSize,
-		"totalCount": totalCount,
-		"totalPages": int(math.Ceil(float64(totalCount) / float64(pageSize))),
-		"tigerList":  tigers,
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"message": "success"})
+}
+
+// GrantRoleHandler grants a role to a user.
+func (h *handlers) GrantRoleHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user id")
+		return
 	}
 
-	// Respond with the tigers as JSON
-	utils.RespondWithJSON(w, http.StatusOK, paginationResponse)
+	if err := h.TigerService.GrantRoleService(userID, mux.Vars(r)["role"]); err != nil {
+		utils.RespondWithError(w, roleErrorStatus(err), err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"message": "success"})
 }
 
-func (h *handlers) CreateTigerSightingHandler(w http.ResponseWriter, r *http.Request) {
-	// Parse the request body to get the tiger sighting data
-	if err := r.ParseMultipartForm(10 << 20); err != nil { // Max memory of 10 MB for file uploads
-		utils.RespondWithError(w, http.StatusBadRequest, "Unable to parse form data")
+// RevokeRoleHandler takes a role away from a user.
+func (h *handlers) RevokeRoleHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user id")
 		return
 	}
 
-	// Get the form values
-	tigerIDStr := r.FormValue("tigerID")
-	timestampStr := r.FormValue("timestamp")
-	latStr := r.FormValue("lat")
-	longStr := r.FormValue("long")
+	if err := h.TigerService.RevokeRoleService(userID, mux.Vars(r)["role"]); err != nil {
+		utils.RespondWithError(w, roleErrorStatus(err), err.Error())
+		return
+	}
 
-	// Convert the form values to appropriate types
-	tigerID, err := strconv.Atoi(tigerIDStr)
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"message": "success"})
+}
+
+func roleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidRole):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// tigerErrorStatus maps the errors of the tiger service to HTTP status codes.
+func tigerErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrTigerNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrTigerVersionConflict):
+		return http.StatusPreconditionFailed
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func formatETag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// parseETag extracts the tiger version from an ETag value such as "3" or W/"3".
+func parseETag(etag string) (int, error) {
+	etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+	return strconv.Atoi(strings.Trim(etag, `"`))
+}
+
+func (h *handlers) GetAllTigersHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the pagination parameters from the query string
+	request, err := parsePageRequest(r)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Invalid tigerID value")
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	timestamp, err := time.Parse(time.RFC3339, timestampStr)
+	filter, err := parseTigerFilter(r)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Invalid timestamp value")
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	lat, err := strconv.ParseFloat(latStr, 64)
+	tigers, info, err := h.TigerService.GetAllTigersService(filter, request)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Invalid lat value")
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	long, err := strconv.ParseFloat(longStr, 64)
+	// Construct pagination response
+	paginationResponse := newPagination(request, info)
+	paginationResponse["tigerList"] = tigers
+
+	// Respond with the tigers as JSON
+	utils.RespondWithJSON(w, http.StatusOK, paginationResponse)
+}
+
+// MaxTigerNameFilterLength is the length of the longest name filter accepted.
+const MaxTigerNameFilterLength = 100
+
+// parseTigerFilter reads the filter and the order of the tiger list from the query string:
+// name is a prefix of the name and q a name with typos, bornFrom and bornTo bound the date
+// of birth as dates, seenFrom and seenTo the last sighting as RFC 3339 times and bbox is
+// the area last seen in. sort is last_seen, name or date_of_birth, and order is asc or
+// desc, names are sorted asc and dates desc by default.
+func parseTigerFilter(r *http.Request) (models.TigerFilter, error) {
+	filter := models.TigerFilter{
+		NamePrefix: strings.TrimSpace(r.FormValue("name")),
+		NameLike:   strings.TrimSpace(r.FormValue("q")),
+	}
+	if len(filter.NamePrefix) > MaxTigerNameFilterLength || len(filter.NameLike) > MaxTigerNameFilterLength {
+		return filter, fmt.Errorf("name filters must be at most %d characters", MaxTigerNameFilterLength)
+	}
+
+	var err error
+	if filter.BornFrom, filter.BornTo, err = parseOptionalRange(r, "bornFrom", "bornTo", "2006-01-02"); err != nil {
+		return filter, err
+	}
+	if filter.SeenFrom, filter.SeenTo, err = parseOptionalRange(r, "seenFrom", "seenTo", time.RFC3339); err != nil {
+		return filter, err
+	}
+
+	if bbox := r.FormValue("bbox"); bbox != "" {
+		box, err := parseBoundingBox(bbox)
+		if err != nil {
+			return filter, err
+		}
+		filter.Region = &box
+	}
+
+	switch filter.Sort = r.FormValue("sort"); filter.Sort {
+	case "", models.TigerSortLastSeen, models.TigerSortDateOfBirth:
+	case models.TigerSortName:
+		filter.Ascending = true
+	default:
+		return filter, errors.New("invalid sort, must be last_seen, name or date_of_birth")
+	}
+
+	switch r.FormValue("order") {
+	case "":
+	case "asc":
+		filter.Ascending = true
+	case "desc":
+		filter.Ascending = false
+	default:
+		return filter, errors.New("invalid order, must be asc or desc")
+	}
+
+	return filter, nil
+}
+
+// parseOptionalRange parses the from and to query parameters in layout, either may be left
+// out. from must not be after to.
+func parseOptionalRange(r *http.Request, fromParam, toParam, layout string) (from, to *time.Time, err error) {
+	if from, err = parseOptionalTimeIn(r.FormValue(fromParam), layout); err != nil {
+		return nil, nil, fmt.Errorf("invalid %s value", fromParam)
+	}
+	if to, err = parseOptionalTimeIn(r.FormValue(toParam), layout); err != nil {
+		return nil, nil, fmt.Errorf("invalid %s value", toParam)
+	}
+
+	if from != nil && to != nil && from.After(*to) {
+		return nil, nil, fmt.Errorf("%s must not be after %s", fromParam, toParam)
+	}
+	return from, to, nil
+}
+
+// parseOptionalTimeIn parses the value in layout, it returns nil for an empty value.
+func parseOptionalTimeIn(value, layout string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(layout, value)
 	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// parsePageRequest reads the page asked for from the query string. A cursor parameter,
+// empty for the first page, selects cursor mode, otherwise pages are numbered by page.
+// The total is counted exactly in page mode and not at all in cursor mode, unless count
+// tells otherwise.
+func parsePageRequest(r *http.Request) (models.PageRequest, error) {
+	request := models.PageRequest{Page: 1, PageSize: DefaultPageSize, Count: models.CountExact}
+
+	if page, err := strconv.Atoi(r.FormValue("page")); err == nil && page > 0 {
+		request.Page = page
+	}
+	if pageSize, err := strconv.Atoi(r.FormValue("pageSize")); err == nil && pageSize > 0 {
+		request.PageSize = pageSize
+	}
+
+	if cursor, ok := r.URL.Query()["cursor"]; ok {
+		request.CursorMode = true
+		request.Count = models.CountNone
+		if cursor[0] != "" {
+			after, err := utils.DecodeCursor(cursor[0])
+			if err != nil {
+				return request, errors.New("invalid cursor")
+			}
+			request.After = after
+		}
+	}
+
+	switch count := r.FormValue("count"); count {
+	case "":
+	case models.CountExact, models.CountEstimate, models.CountNone:
+		request.Count = count
+	default:
+		return request, errors.New("invalid count, must be exact, estimate or none")
+	}
+
+	return request, nil
+}
+
+// newPagination returns the pagination fields of the response with the page. next_cursor
+// is null on the last page, totalCount and totalPages are only set when counted.
+func newPagination(request models.PageRequest, info *models.PageInfo) pagination {
+	response := pagination{
+		"pageSize":    request.PageSize,
+		"next_cursor": nil,
+	}
+	if !request.CursorMode {
+		response["page"] = request.Page
+	}
+	if info.NextCursor != nil {
+		response["next_cursor"] = utils.EncodeCursor(*info.NextCursor)
+	}
+	if info.TotalCount != nil {
+		response["count"] = request.Count
+		response["totalCount"] = *info.TotalCount
+		if !request.CursorMode {
+			response["totalPages"] = int(math.Ceil(float64(*info.TotalCount) / float64(request.PageSize)))
+		}
+	}
+	return response
+}
+
+func (h *handlers) GetTigersNearbyHandler(w http.ResponseWriter, r *http.Request) {
+	lat, err := strconv.ParseFloat(r.FormValue("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid lat value")
+		return
+	}
+
+	long, err := strconv.ParseFloat(r.FormValue("long"), 64)
+	if err != nil || long < -180 || long > 180 {
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid long value")
 		return
 	}
 
-	reporterEmail, ok := auth.GetEmailFromContext(r.Context())
-	if !ok {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve previous sighting")
+	radiusKm, err := strconv.ParseFloat(r.FormValue("radius_km"), 64)
+	if err != nil || radiusKm <= 0 || radiusKm > MaxNearbyRadiusKm {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("radius_km must be between 0 and %v", MaxNearbyRadiusKm))
+		return
+	}
+
+	tigers, err := h.TigerService.GetTigersNearbyService(models.Coordinates{Lat: lat, Long: long}, radiusKm)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	newSighting := models.TigerSighting{TigerID: tigerID, Timestamp: timestamp, Lat: lat, Long: long, ReporterEmail: reporterEmail}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"tigerList": tigers})
+}
 
-	imageFile, _, err := r.FormFile("image")
+func (h *handlers) GetTigerSightingsInAreaHandler(w http.ResponseWriter, r *http.Request) {
+	box, err := parseBoundingBox(r.FormValue("bbox"))
 	if err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Failed to get image file")
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	defer imageFile.Close()
 
-	resizedImage, err := getProcessedImage(imageFile)
+	from, err := parseOptionalTime(r.FormValue("from"))
 	if err != nil {
-		h.Logger.Printf("Got Error Resizing Image: %v", err)
-		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid from value")
 		return
 	}
 
-	newSighting.Image = resizedImage
-	err = h.TigerService.CreateTigerSightingService(&newSighting)
+	to, err := parseOptionalTime(r.FormValue("to"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid to value")
+		return
+	}
+
+	limit, err := strconv.Atoi(r.FormValue("limit"))
+	if err != nil || limit < 1 {
+		limit = DefaultSightingsLimit
+	} else if limit > MaxSightingsLimit {
+		limit = MaxSightingsLimit
+	}
+
+	tigerSightings, err := h.TigerService.GetTigerSightingsInAreaService(box, from, to, limit)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	utils.RespondWithJSON(w, http.StatusCreated, map[string]interface{}{"message": "success"})
+	for _, t := range tigerSightings {
+		setSightingImageURLs(t, "/sightings")
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{"tigerSightings": tigerSightings})
 }
 
-func getProcessedImage(imageFile multipart.File) ([]byte, error) {
-	// Read the image data into a byte slice
-	imageData, err := ioutil.ReadAll(imageFile)
+// parseBoundingBox parses a bounding box given as minLat,minLong,maxLat,maxLong.
+func parseBoundingBox(value string) (models.BoundingBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return models.BoundingBox{}, errors.New("bbox must be minLat,minLong,maxLat,maxLong")
+	}
+
+	values := make([]float64, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return models.BoundingBox{}, errors.New("bbox must be minLat,minLong,maxLat,maxLong")
+		}
+		values[i] = v
+	}
+
+	box := models.BoundingBox{MinLat: values[0], MinLong: values[1], MaxLat: values[2], MaxLong: values[3]}
+	if box.MinLat < -90 || box.MaxLat > 90 || box.MinLong < -180 || box.MaxLong > 180 ||
+		box.MinLat > box.MaxLat || box.MinLong > box.MaxLong {
+		return models.BoundingBox{}, errors.New("bbox is out of range")
+	}
+	return box, nil
+}
+
+// parseOptionalTime parses an RFC3339 time, an empty value gives the zero time.
+func parseOptionalTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// CreateTigerSightingHandler reports a sighting with up to MaxSightingPhotos photos, each
+// in an image part. The lat, long and timestamp form values may be left out when the
+// first photo with EXIF data has them. Requests and photos larger than the Uploads limits
+// are rejected, as are photos which are not JPEG, PNG or WebP whatever their file name.
+func (h *handlers) CreateTigerSightingHandler(w http.ResponseWriter, r *http.Request) {
+	if r.ContentLength > h.Uploads.MaxRequestBytes {
+		utils.RespondWithError(w, http.StatusRequestEntityTooLarge, "Request is too large")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, h.Uploads.MaxRequestBytes)
+
+	// Parse the request body to get the tiger sighting data
+	if err := r.ParseMultipartForm(10 << 20); isRequestTooLarge(err) { // Max memory of 10 MB for file uploads
+		utils.RespondWithError(w, http.StatusRequestEntityTooLarge, "Request is too large")
+		return
+	} else if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Unable to parse form data")
+		return
+	}
+
+	// Convert the form values to appropriate types
+	tigerID, err := strconv.Atoi(r.FormValue("tigerID"))
 	if err != nil {
-		return nil, err
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid tigerID value")
+		return
+	}
+
+	reporterEmail, ok := auth.GetEmailFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve previous sighting")
+		return
+	}
+
+	imageFiles := r.MultipartForm.File["image"]
+	if len(imageFiles) == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to get image file")
+		return
+	}
+	if len(imageFiles) > MaxSightingPhotos {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("At most %d images may be uploaded", MaxSightingPhotos))
+		return
+	}
+
+	newSighting := models.TigerSighting{TigerID: tigerID, ReporterEmail: reporterEmail}
+	var photo utils.PhotoMetadata
+	for _, imageFile := range imageFiles {
+		imageData, err := readFormFile(imageFile)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Failed to read image file")
+			return
+		}
+		if _, err := utils.CheckImage(imageData, h.Uploads.MaxImageDimension, h.Uploads.MaxImagePixels); errors.Is(err, utils.ErrUnsupportedImage) {
+			utils.RespondWithError(w, http.StatusUnsupportedMediaType, "Images must be JPEG, PNG or WebP")
+			return
+		} else if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Images must be at most %d pixels wide and high and %d pixels in total", h.Uploads.MaxImageDimension, h.Uploads.MaxImagePixels))
+			return
+		}
+		newSighting.Photos = append(newSighting.Photos, imageData)
+
+		if !photo.HasLocation && photo.Timestamp.IsZero() {
+			photo = utils.ReadPhotoMetadata(imageData)
+		}
+	}
+
+	if photo.HasLocation {
+		newSighting.PhotoLat, newSighting.PhotoLong = &photo.Lat, &photo.Long
 	}
+	if !photo.Timestamp.IsZero() {
+		newSighting.PhotoTimestamp = &photo.Timestamp
+	}
+
+	if timestampStr := r.FormValue("timestamp"); timestampStr != "" || photo.Timestamp.IsZero() {
+		if newSighting.Timestamp, err = time.Parse(time.RFC3339, timestampStr); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid timestamp value")
+			return
+		}
+	} else {
+		newSighting.Timestamp = photo.Timestamp
+	}
+
+	latStr, longStr := r.FormValue("lat"), r.FormValue("long")
+	if latStr != "" || longStr != "" || !photo.HasLocation {
+		if newSighting.Lat, err = strconv.ParseFloat(latStr, 64); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid lat value")
+			return
+		}
+		if newSighting.Long, err = strconv.ParseFloat(longStr, 64); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid long value")
+			return
+		}
+	} else {
+		newSighting.Lat, newSighting.Long = photo.Lat, photo.Long
+	}
+
+	err = h.TigerService.CreateTigerSightingService(&newSighting)
+	var validationErr *service.ValidationError
+	if errors.As(err, &validationErr) {
+		utils.RespondWithErrorCode(w, http.StatusUnprocessableEntity, validationErr.Code, validationErr.Message)
+		return
+	} else if errors.Is(err, service.ErrInvalidImage) {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		utils.RespondWithError(w, tigerErrorStatus(err), err.Error())
+		return
+	}
+
+	// Sightings of reporters who are not trusted yet wait for a moderator
+	response := map[string]interface{}{"message": "success", "status": newSighting.Status}
+	if newSighting.PhotoMismatch != "" {
+		response["photoMismatch"] = newSighting.PhotoMismatch
+	}
+	utils.RespondWithJSON(w, http.StatusCreated, response)
+}
 
-	// Resize the image to 250x200
-	resizedImage, err := utils.ResizeImage(imageData, 250, 200)
+// isRequestTooLarge tells whether err comes from reading past http.MaxBytesReader, which
+// has no error type of its own before Go 1.19.
+func isRequestTooLarge(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "http: request body too large")
+}
+
+// readFormFile reads an uploaded file of a multipart form.
+func readFormFile(fileHeader *multipart.FileHeader) ([]byte, error) {
+	file, err := fileHeader.Open()
 	if err != nil {
 		return nil, err
 	}
-	return resizedImage, nil
+	defer file.Close()
+
+	return ioutil.ReadAll(file)
 }
 
 func (h *handlers) GetTigerSightingsByIDHandler(w http.ResponseWriter, r *http.Request) {
@@ -242,60 +943,98 @@ func (h *handlers) GetTigerSightingsByIDHandler(w http.ResponseWriter, r *http.R
 	}
 
 	// Get the pagination parameters from the query string
-	pageStr := r.FormValue("page")
-	pageSizeStr := r.FormValue("pageSize")
+	request, err := parsePageRequest(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1
+	tigerSightings, info, err := h.TigerService.GetTigerSightingsByIDService(tigerIDInt, request)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
-	pageSize, err := strconv.Atoi(pageSizeStr)
-	if err != nil || pageSize < 1 {
-		pageSize = DefaultPageSize
+	// Images are served by their own endpoint instead of being embedded in the list
+	for _, t := range tigerSightings {
+		setSightingImageURLs(t, "/sightings")
 	}
 
-	tigerSightings, totalCount, err := h.TigerService.GetTigerSightingsByIDService(tigerIDInt, page, pageSize)
+	// Construct pagination response
+	paginationResponse := newPagination(request, info)
+	paginationResponse["tigerSightings"] = tigerSightings
+
+	// Respond with the tiger sightings as JSON
+	utils.RespondWithJSON(w, http.StatusOK, paginationResponse)
+}
+
+func (h *handlers) GetTigerSightingImageHandler(w http.ResponseWriter, r *http.Request) {
+	sightingID, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid sighting id")
 		return
 	}
 
-	for _, t := range tigerSightings {
-		img, _, err := image.Decode(bytes.NewReader(t.Image))
-		if err != nil {
-			fmt.Errorf("Error decoding image data: %v", err)
-		}
+	image, err := h.TigerService.GetTigerSightingImageService(sightingID)
+	// Image keys are never reused, so the image of a sighting never changes
+	h.writeSightingImage(w, image, "image/jpeg", err, "public, max-age=31536000, immutable")
+}
 
-		// Save the image to a new file
-		fileName := fmt.Sprintf("%v_%v_%v_%v.jpeg", t.TigerID, t.Lat, t.Long, t.ReporterEmail)
-		outputFile, err := os.Create(fileName) // we could have store it in S3 bucket, for simplicity storing it here.
-		if err != nil {
-			fmt.Errorf("Error creating output file: %v", err)
-		}
-		defer outputFile.Close()
+// GetTigerSightingPhotoHandler serves a photo of an approved sighting in a rendition,
+// the name of the image is the rendition followed by the format, e.g. medium.jpeg.
+func (h *handlers) GetTigerSightingPhotoHandler(w http.ResponseWriter, r *http.Request) {
+	sightingID, position, rendition, format, err := parseSightingPhotoVars(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-		// Save the image in JPEG format
-		if img != nil {
-			err = jpeg.Encode(outputFile, img, nil)
-			if err != nil {
-				fmt.Errorf("Error encoding image data to file: %v", err)
-			}
-		}
+	image, sightingImage, err := h.TigerService.GetTigerSightingPhotoService(sightingID, position, rendition, format)
+	if err != nil {
+		utils.RespondWithError(w, sightingErrorStatus(err), err.Error())
+		return
+	}
+	h.writeSightingImage(w, image, sightingImage.ContentType, nil, "public, max-age=31536000, immutable")
+}
+
+// parseSightingPhotoVars parses the id, position and name route variables of a sighting photo.
+func parseSightingPhotoVars(r *http.Request) (sightingID, position int, rendition, format string, err error) {
+	vars := mux.Vars(r)
+	if sightingID, err = strconv.Atoi(vars["id"]); err != nil {
+		return 0, 0, "", "", errors.New("invalid sighting id")
+	}
+	if position, err = strconv.Atoi(vars["position"]); err != nil || position < 0 {
+		return 0, 0, "", "", errors.New("invalid image position")
+	}
+	rendition, format, ok := strings.Cut(vars["name"], ".")
+	if !ok || rendition == "" || format == "" {
+		return 0, 0, "", "", errors.New("invalid image name")
+	}
+	return sightingID, position, rendition, format, nil
+}
 
-		t.ImageFile = fileName
-		t.Image = nil
+// setSightingImageURLs sets the URLs the images of the sighting are served at under prefix.
+func setSightingImageURLs(sighting *models.TigerSighting, prefix string) {
+	if sighting.ImageKey != "" {
+		sighting.ImageURL = fmt.Sprintf("%s/%d/image", prefix, sighting.ID)
+	}
+	for _, image := range sighting.Images {
+		image.URL = fmt.Sprintf("%s/%d/images/%d/%s.%s", prefix, sighting.ID, image.Position, image.Rendition, image.Format)
 	}
+}
 
-	// Construct pagination response
-	paginationResponse := pagination{
-		"page":           page,
-		"pageSize":       pageSize,
-		"totalCount":     totalCount,
-		"totalPages":     int(math.Ceil(float64(totalCount) / float64(pageSize))),
-		"tigerSightings": tigerSightings,
+// writeSightingImage writes the image returned by the service, or its error.
+func (h *handlers) writeSightingImage(w http.ResponseWriter, image io.ReadCloser, contentType string, err error, cacheControl string) {
+	if err != nil {
+		utils.RespondWithError(w, sightingErrorStatus(err), err.Error())
+		return
 	}
+	defer image.Close()
 
-	// Respond with the tiger sightings as JSON
-	utils.RespondWithJSON(w, http.StatusOK, paginationResponse)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", cacheControl)
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, image); err != nil {
+		h.Logger.Printf("Failed to write tiger sighting image: %v", err)
+	}
 }
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"tigerhall-kittens-app/pkg/auth"
+	"tigerhall-kittens-app/pkg/service"
+	"tigerhall-kittens-app/pkg/utils"
+)
+
+// GetModerationQueueHandler lists the sightings waiting for a moderator, oldest first.
+func (h *handlers) GetModerationQueueHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.Atoi(r.FormValue("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(r.FormValue("pageSize"))
+	if err != nil || pageSize < 1 {
+		pageSize = DefaultPageSize
+	}
+
+	sightings, totalCount, err := h.TigerService.GetModerationQueueService(page, pageSize)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// The public image endpoint only serves approved sightings
+	for _, sighting := range sightings {
+		setSightingImageURLs(sighting, "/moderation/sightings")
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, pagination{
+		"page":           page,
+		"pageSize":       pageSize,
+		"totalCount":     totalCount,
+		"totalPages":     int(math.Ceil(float64(totalCount) / float64(pageSize))),
+		"tigerSightings": sightings,
+	})
+}
+
+// GetModerationSightingImageHandler serves the image of a sighting whatever its status.
+func (h *handlers) GetModerationSightingImageHandler(w http.ResponseWriter, r *http.Request) {
+	sightingID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid sighting id")
+		return
+	}
+
+	image, err := h.TigerService.GetModerationSightingImageService(sightingID)
+	// Unlike public images these must not be kept by shared caches
+	h.writeSightingImage(w, image, "image/jpeg", err, "private, max-age=300")
+}
+
+// GetModerationSightingPhotoHandler serves a photo of a sighting in a rendition whatever
+// the status of the sighting.
+func (h *handlers) GetModerationSightingPhotoHandler(w http.ResponseWriter, r *http.Request) {
+	sightingID, position, rendition, format, err := parseSightingPhotoVars(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	image, sightingImage, err := h.TigerService.GetModerationSightingPhotoService(sightingID, position, rendition, format)
+	if err != nil {
+		utils.RespondWithError(w, sightingErrorStatus(err), err.Error())
+		return
+	}
+	h.writeSightingImage(w, image, sightingImage.ContentType, nil, "private, max-age=300")
+}
+
+// ReviewTigerSightingHandler approves or rejects a sighting on behalf of the logged in moderator.
+func (h *handlers) ReviewTigerSightingHandler(w http.ResponseWriter, r *http.Request) {
+	sightingID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid sighting id")
+		return
+	}
+
+	var body struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	email, ok := auth.GetEmailFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	review, err := h.TigerService.ReviewTigerSightingService(sightingID, email, body.Status, body.Note)
+	if err != nil {
+		utils.RespondWithError(w, sightingErrorStatus(err), err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, review)
+}
+
+// FlagTigerSightingHandler reports an approved sighting as wrong on behalf of the logged in user.
+func (h *handlers) FlagTigerSightingHandler(w http.ResponseWriter, r *http.Request) {
+	sightingID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid sighting id")
+		return
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	email, ok := auth.GetEmailFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.TigerService.FlagTigerSightingService(sightingID, email, body.Reason); err != nil {
+		utils.RespondWithError(w, sightingErrorStatus(err), err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusAccepted, map[string]interface{}{"message": "success"})
+}
+
+func sightingErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrSightingNotFound), errors.Is(err, service.ErrTigerNotFound), errors.Is(err, service.ErrImageNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidReviewStatus):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrUserNotFound):
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"tigerhall-kittens-app/pkg/auth"
+	"tigerhall-kittens-app/pkg/models"
+	"tigerhall-kittens-app/pkg/service"
+)
+
+func TestGetModerationQueueHandler(t *testing.T) {
+	// Arrange
+	var gotPage, gotSize int
+	mockService := &mockTigerService{
+		getModerationQueueService: func(page, size int) ([]*models.TigerSighting, int, error) {
+			gotPage, gotSize = page, size
+			return []*models.TigerSighting{
+				{ID: 3, TigerID: 1, ImageKey: "sightings/1/image.jpeg", Status: models.SightingPending, Images: []*models.SightingImage{{SightingID: 3, Position: 0, Rendition: "original", Format: "jpeg"}}},
+				{ID: 4, TigerID: 1, Status: models.SightingApproved, OpenFlags: 2},
+			}, 12, nil
+		},
+	}
+
+	handler := NewHandlers(mockService, log.Default(), auth.NewAuth("test_secret_key"))
+
+	req, err := http.NewRequest(http.MethodGet, "/moderation/sightings?page=2&pageSize=5", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	// Act
+	handler.GetModerationQueueHandler(rr, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rr.Code, "Status code should be 200")
+	assert.Equal(t, 2, gotPage)
+	assert.Equal(t, 5, gotSize)
+
+	var response struct {
+		TotalPages     int                     `json:"totalPages"`
+		TigerSightings []*models.TigerSighting `json:"tigerSightings"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, 3, response.TotalPages)
+	if assert.Len(t, response.TigerSightings, 2) {
+		assert.Equal(t, "/moderation/sightings/3/image", response.TigerSightings[0].ImageURL, "Moderators should get the moderation image URL")
+		if assert.Len(t, response.TigerSightings[0].Images, 1) {
+			assert.Equal(t, "/moderation/sightings/3/images/0/original.jpeg", response.TigerSightings[0].Images[0].URL)
+		}
+		assert.Equal(t, 2, response.TigerSightings[1].OpenFlags)
+	}
+}
+
+func TestGetModerationSightingImageHandler(t *testing.T) {
+	// Arrange
+	mockService := &mockTigerService{
+		getModerationSightingImageService: func(sightingID int) (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader("image data")), nil
+		},
+	}
+
+	handler := NewHandlers(mockService, log.Default(), auth.NewAuth("test_secret_key"))
+
+	req, err := http.NewRequest(http.MethodGet, "/moderation/sightings/3/image", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	rr := httptest.NewRecorder()
+
+	// Act
+	handler.GetModerationSightingImageHandler(rr, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rr.Code, "Status code should be 200")
+	assert.Equal(t, "image data", rr.Body.String())
+	assert.Equal(t, "private, max-age=300", rr.Header().Get("Cache-Control"), "Images of unreviewed sightings should not be cached publicly")
+}
+
+func TestGetModerationSightingPhotoHandler(t *testing.T) {
+	// Arrange
+	mockService := &mockTigerService{
+		getModerationSightingPhotoService: func(sightingID, position int, rendition, format string) (io.ReadCloser, *models.SightingImage, error) {
+			return ioutil.NopCloser(strings.NewReader("image data")), &models.SightingImage{ContentType: "image/jpeg"}, nil
+		},
+	}
+
+	handler := NewHandlers(mockService, log.Default(), auth.NewAuth("test_secret_key"))
+
+	req, err := http.NewRequest(http.MethodGet, "/moderation/sightings/3/images/0/original.jpeg", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": "3", "position": "0", "name": "original.jpeg"})
+	rr := httptest.NewRecorder()
+
+	// Act
+	handler.GetModerationSightingPhotoHandler(rr, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rr.Code, "Status code should be 200")
+	assert.Equal(t, "image data", rr.Body.String())
+	assert.Equal(t, "private, max-age=300", rr.Header().Get("Cache-Control"), "Images of unreviewed sightings should not be cached publicly")
+}
+
+func TestReviewTigerSightingHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		err            error
+		expectedStatus int
+	}{
+		{name: "approved", body: `{"status": "approved", "note": "clear photo"}`, expectedStatus: http.StatusOK},
+		{name: "invalid status", body: `{"status": "maybe"}`, err: service.ErrInvalidReviewStatus, expectedStatus: http.StatusBadRequest},
+		{name: "unknown sighting", body: `{"status": "rejected"}`, err: service.ErrSightingNotFound, expectedStatus: http.StatusNotFound},
+		{name: "deleted tiger", body: `{"status": "approved"}`, err: service.ErrTigerNotFound, expectedStatus: http.StatusNotFound},
+		{name: "invalid payload", body: `{`, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			var gotEmail, gotStatus, gotNote string
+			mockService := &mockTigerService{
+				reviewTigerSightingService: func(sightingID int, reviewerEmail, status, note string) (*models.SightingReview, error) {
+					gotEmail, gotStatus, gotNote = reviewerEmail, status, note
+					if tt.err != nil {
+						return nil, tt.err
+					}
+					return &models.SightingReview{ID: 1, SightingID: sightingID, ReviewerID: 7, Status: status, Note: note}, nil
+				},
+			}
+
+			handler := NewHandlers(mockService, log.Default(), auth.NewAuth("test_secret_key"))
+
+			req, err := http.NewRequest(http.MethodPost, "/moderation/sightings/3/review", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = mux.SetURLVars(req, map[string]string{"id": "3"})
+			req = req.WithContext(context.WithValue(req.Context(), "email", "ranger@example.com"))
+			rr := httptest.NewRecorder()
+
+			// Act
+			handler.ReviewTigerSightingHandler(rr, req)
+
+			// Assert
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, "ranger@example.com", gotEmail, "The review should be recorded for the logged in moderator")
+				assert.Equal(t, models.SightingApproved, gotStatus)
+				assert.Equal(t, "clear photo", gotNote)
+
+				var review models.SightingReview
+				assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &review))
+				assert.Equal(t, 7, review.ReviewerID)
+			}
+		})
+	}
+}
+
+func TestFlagTigerSightingHandler(t *testing.T) {
+	// Arrange
+	var gotID int
+	var gotEmail, gotReason string
+	mockService := &mockTigerService{
+		flagTigerSightingService: func(sightingID int, email, reason string) error {
+			gotID, gotEmail, gotReason = sightingID, email, reason
+			return nil
+		},
+	}
+
+	handler := NewHandlers(mockService, log.Default(), auth.NewAuth("test_secret_key"))
+
+	req, err := http.NewRequest(http.MethodPost, "/sightings/3/flag", strings.NewReader(`{"reason": "this is a leopard"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	req = req.WithContext(context.WithValue(req.Context(), "email", "rajnish.kumar@gmail.com"))
+	rr := httptest.NewRecorder()
+
+	// Act
+	handler.FlagTigerSightingHandler(rr, req)
+
+	// Assert
+	assert.Equal(t, http.StatusAccepted, rr.Code, "Status code should be 202")
+	assert.Equal(t, 3, gotID)
+	assert.Equal(t, "rajnish.kumar@gmail.com", gotEmail)
+	assert.Equal(t, "this is a leopard", gotReason)
+}
+
+func TestFlagTigerSightingHandler_NotFound(t *testing.T) {
+	// Arrange
+	mockService := &mockTigerService{
+		flagTigerSightingService: func(sightingID int, email, reason string) error {
+			return service.ErrSightingNotFound
+		},
+	}
+
+	handler := NewHandlers(mockService, log.Default(), auth.NewAuth("test_secret_key"))
+
+	req, err := http.NewRequest(http.MethodPost, "/sightings/3/flag", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	req = req.WithContext(context.WithValue(req.Context(), "email", "rajnish.kumar@gmail.com"))
+	rr := httptest.NewRecorder()
+
+	// Act
+	handler.FlagTigerSightingHandler(rr, req)
+
+	// Assert
+	assert.Equal(t, http.StatusNotFound, rr.Code, "Status code should be 404")
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"tigerhall-kittens-app/pkg/utils"
+)
+
+const (
+	// DefaultStatsDays and MaxStatsDays bound the days the population stats cover
+	DefaultStatsDays = 30
+	MaxStatsDays     = 365
+	// DefaultActiveTigersLimit and MaxActiveTigersLimit bound the most active tigers listed
+	DefaultActiveTigersLimit = 10
+	MaxActiveTigersLimit     = 100
+)
+
+// GetTigerStatsHandler responds with the movement stats of a tiger: the distance between
+// its consecutive sightings, its average speed, its home range, when it was first and last
+// seen and by how many reporters.
+func (h *handlers) GetTigerStatsHandler(w http.ResponseWriter, r *http.Request) {
+	tigerID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid tiger id")
+		return
+	}
+
+	stats, err := h.TigerService.GetTigerStatsService(tigerID)
+	if err != nil {
+		utils.RespondWithError(w, tigerErrorStatus(err), err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, stats)
+}
+
+// GetPopulationStatsHandler responds with the sightings per day of the last days days and
+// the limit tigers with the most sightings over these days.
+func (h *handlers) GetPopulationStatsHandler(w http.ResponseWriter, r *http.Request) {
+	days := DefaultStatsDays
+	if value := r.FormValue("days"); value != "" {
+		if days, _ = strconv.Atoi(value); days < 1 || days > MaxStatsDays {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid days value, must be between 1 and "+strconv.Itoa(MaxStatsDays))
+			return
+		}
+	}
+
+	limit, err := strconv.Atoi(r.FormValue("limit"))
+	if err != nil || limit < 1 {
+		limit = DefaultActiveTigersLimit
+	} else if limit > MaxActiveTigersLimit {
+		limit = MaxActiveTigersLimit
+	}
+
+	stats, err := h.TigerService.GetPopulationStatsService(days, limit)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, stats)
+}
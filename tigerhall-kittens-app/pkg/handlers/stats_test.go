@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"tigerhall-kittens-app/pkg/auth"
+	"tigerhall-kittens-app/pkg/models"
+	"tigerhall-kittens-app/pkg/service"
+)
+
+func TestGetTigerStatsHandler(t *testing.T) {
+	// Arrange
+	firstSeen := time.Date(2023, time.July, 20, 12, 0, 0, 0, time.UTC)
+	mockService := &mockTigerService{
+		getTigerStatsService: func(tigerID int) (*models.TigerStats, error) {
+			if tigerID != 1 {
+				return nil, service.ErrTigerNotFound
+			}
+			return &models.TigerStats{TigerID: 1, Sightings: 2, DistanceKm: 12.5, FirstSeen: &firstSeen, LastSeen: &firstSeen, Reporters: 1}, nil
+		},
+	}
+	handler := NewHandlers(mockService, log.Default(), auth.NewAuth("test_secret_key"))
+
+	tests := []struct {
+		id     string
+		status int
+	}{
+		{id: "1", status: http.StatusOK},
+		{id: "2", status: http.StatusNotFound},
+		{id: "tiger", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.id, func(t *testing.T) {
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/tiger/"+tt.id+"/stats", nil), map[string]string{"id": tt.id})
+			rr := httptest.NewRecorder()
+
+			// Act
+			handler.GetTigerStatsHandler(rr, req)
+
+			// Assert
+			assert.Equal(t, tt.status, rr.Code)
+			if tt.status == http.StatusOK {
+				var stats map[string]interface{}
+				assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &stats))
+				assert.Equal(t, 12.5, stats["distance_km"])
+				assert.Equal(t, "2023-07-20T12:00:00Z", stats["first_seen"])
+			}
+		})
+	}
+}
+
+func TestGetPopulationStatsHandler(t *testing.T) {
+	tests := []struct {
+		query  string
+		status int
+		days   int
+		limit  int
+	}{
+		{query: "", status: http.StatusOK, days: DefaultStatsDays, limit: DefaultActiveTigersLimit},
+		{query: "days=7&limit=3", status: http.StatusOK, days: 7, limit: 3},
+		{query: "limit=1000", status: http.StatusOK, days: DefaultStatsDays, limit: MaxActiveTigersLimit},
+		{query: "days=0", status: http.StatusBadRequest},
+		{query: "days=366", status: http.StatusBadRequest},
+		{query: "days=week", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.query, func(t *testing.T) {
+			// Arrange
+			var gotDays, gotLimit int
+			mockService := &mockTigerService{
+				getPopulationStatsService: func(days, limit int) (*models.PopulationStats, error) {
+					gotDays, gotLimit = days, limit
+					return &models.PopulationStats{SightingsPerDay: []*models.DailySightings{}, MostActiveTigers: []*models.ActiveTiger{}}, nil
+				},
+			}
+			handler := NewHandlers(mockService, log.Default(), auth.NewAuth("test_secret_key"))
+			rr := httptest.NewRecorder()
+
+			// Act
+			handler.GetPopulationStatsHandler(rr, httptest.NewRequest(http.MethodGet, "/stats?"+tt.query, nil))
+
+			// Assert
+			assert.Equal(t, tt.status, rr.Code)
+			assert.Equal(t, tt.days, gotDays)
+			assert.Equal(t, tt.limit, gotLimit)
+		})
+	}
+}
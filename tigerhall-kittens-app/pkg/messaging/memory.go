@@ -0,0 +1,122 @@
+package messaging
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrQueueFull is returned when a message is published to a MemoryBroker whose queue is full.
+	ErrQueueFull = errors.New("message queue is full")
+	// ErrBrokerClosed is returned when a message is published to a closed MemoryBroker.
+	ErrBrokerClosed = errors.New("message broker is closed")
+)
+
+// MemoryBroker is a Broker keeping its queue in process, for tests and local development.
+// Messages are lost when the process stops.
+type MemoryBroker struct {
+	messages chan Message
+	retry    RetryPolicy
+	closed   chan struct{}
+	once     sync.Once
+
+	mu   sync.Mutex
+	dead []Message
+}
+
+// NewMemoryBroker creates a MemoryBroker holding up to size messages.
+func NewMemoryBroker(size int, retry RetryPolicy) *MemoryBroker {
+	return &MemoryBroker{
+		messages: make(chan Message, size),
+		retry:    retry,
+		closed:   make(chan struct{}),
+	}
+}
+
+// PublishMessage publishes a message to the queue under a new message ID.
+func (mb *MemoryBroker) PublishMessage(message []byte) error {
+	id, err := newMessageID()
+	if err != nil {
+		return err
+	}
+	return mb.PublishMessageWithID(id, message)
+}
+
+// PublishMessageWithID publishes a message to the queue under the given message ID.
+// It fails with ErrQueueFull instead of waiting when the queue is full.
+func (mb *MemoryBroker) PublishMessageWithID(id string, message []byte) error {
+	body := make([]byte, len(message))
+	copy(body, message)
+	return mb.enqueue(Message{ID: id, Body: body})
+}
+
+func (mb *MemoryBroker) enqueue(message Message) error {
+	select {
+	case <-mb.closed:
+		return ErrBrokerClosed
+	default:
+	}
+
+	select {
+	case mb.messages <- message:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// ConsumeMessages processes the messages of the queue until the broker is closed.
+func (mb *MemoryBroker) ConsumeMessages(processMessage func(Message) error) {
+	for {
+		select {
+		case message := <-mb.messages:
+			err := processMessage(message)
+			if err == nil {
+				continue
+			}
+
+			log.Printf("failed to process message %s (attempt %d): %v", message.ID, message.Retries+1, err)
+			mb.reschedule(message, err)
+		case <-mb.closed:
+			return
+		}
+	}
+}
+
+// reschedule publishes a failed message again once its backoff expires, or moves it
+// to the dead letters once it cannot or should not be retried any more.
+func (mb *MemoryBroker) reschedule(message Message, processErr error) {
+	if IsPermanent(processErr) || message.Retries >= mb.retry.MaxRetries {
+		mb.mu.Lock()
+		mb.dead = append(mb.dead, message)
+		mb.mu.Unlock()
+		return
+	}
+
+	delay := mb.retry.Delay(message.Retries)
+	message.Retries++
+	time.AfterFunc(delay, func() {
+		if err := mb.enqueue(message); err != nil && err != ErrBrokerClosed {
+			log.Printf("failed to retry message %s: %v", message.ID, err)
+			mb.mu.Lock()
+			mb.dead = append(mb.dead, message)
+			mb.mu.Unlock()
+		}
+	})
+}
+
+// DeadLetters returns the messages that could not be processed.
+func (mb *MemoryBroker) DeadLetters() []Message {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	return append([]Message(nil), mb.dead...)
+}
+
+// Close stops consuming messages, messages still in the queue are dropped.
+func (mb *MemoryBroker) Close() {
+	mb.once.Do(func() {
+		close(mb.closed)
+	})
+}
@@ -0,0 +1,114 @@
+package messaging
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Both brokers can be used wherever a Broker is expected
+var (
+	_ Broker = (*MessageBroker)(nil)
+	_ Broker = (*MemoryBroker)(nil)
+)
+
+var testRetryPolicy = RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+// consume runs the consumer of the broker until the test ends.
+func consume(t *testing.T, broker *MemoryBroker, processMessage func(Message) error) {
+	done := make(chan struct{})
+	go func() {
+		broker.ConsumeMessages(processMessage)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		broker.Close()
+		<-done
+	})
+}
+
+func TestMemoryBroker_PublishAndConsume(t *testing.T) {
+	// Arrange
+	broker := NewMemoryBroker(10, testRetryPolicy)
+	received := make(chan Message, 1)
+	consume(t, broker, func(message Message) error {
+		received <- message
+		return nil
+	})
+
+	// Act
+	err := broker.PublishMessageWithID("message-1", []byte("Test Message"))
+
+	// Assert
+	assert.NoError(t, err)
+	select {
+	case message := <-received:
+		assert.Equal(t, Message{ID: "message-1", Body: []byte("Test Message")}, message)
+	case <-time.After(time.Second):
+		t.Fatal("message was not consumed")
+	}
+}
+
+func TestMemoryBroker_RetriesFailedMessages(t *testing.T) {
+	// Arrange
+	broker := NewMemoryBroker(10, testRetryPolicy)
+	var mu sync.Mutex
+	var attempts []int
+	consume(t, broker, func(message Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts = append(attempts, message.Retries)
+		if message.Retries < 1 {
+			return errors.New("temporary failure")
+		}
+		return nil
+	})
+
+	// Act
+	assert.NoError(t, broker.PublishMessage([]byte("Test Message")))
+
+	// Assert
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(attempts) == 2
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []int{0, 1}, attempts)
+	assert.Empty(t, broker.DeadLetters())
+}
+
+func TestMemoryBroker_DeadLetters(t *testing.T) {
+	// Arrange
+	broker := NewMemoryBroker(10, testRetryPolicy)
+	consume(t, broker, func(message Message) error {
+		if string(message.Body) == "poison" {
+			return Permanent(errors.New("invalid message"))
+		}
+		return errors.New("temporary failure")
+	})
+
+	// Act
+	assert.NoError(t, broker.PublishMessageWithID("poison", []byte("poison")))
+	assert.NoError(t, broker.PublishMessageWithID("failing", []byte("failing")))
+
+	// Assert
+	assert.Eventually(t, func() bool { return len(broker.DeadLetters()) == 2 }, time.Second, time.Millisecond)
+	dead := broker.DeadLetters()
+	assert.Equal(t, "poison", dead[0].ID, "Permanent failures should be dead-lettered at once")
+	assert.Equal(t, 0, dead[0].Retries)
+	assert.Equal(t, "failing", dead[1].ID)
+	assert.Equal(t, testRetryPolicy.MaxRetries, dead[1].Retries)
+}
+
+func TestMemoryBroker_QueueFull(t *testing.T) {
+	broker := NewMemoryBroker(1, testRetryPolicy)
+
+	assert.NoError(t, broker.PublishMessage([]byte("first")))
+	assert.ErrorIs(t, broker.PublishMessage([]byte("second")), ErrQueueFull)
+
+	broker.Close()
+	assert.ErrorIs(t, broker.PublishMessage([]byte("third")), ErrBrokerClosed)
+}
@@ -1,21 +1,124 @@
 package messaging
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/streadway/amqp"
 )
 
-// MessageBroker represents the messaging service using RabbitMQ.
+// Publisher publishes messages to the queue.
+type Publisher interface {
+	// PublishMessage publishes a message under a new message ID.
+	PublishMessage(message []byte) error
+	// PublishMessageWithID publishes a message under the given message ID, consumers
+	// can use the ID to detect a message that has been published more than once. It
+	// returns once the broker has taken the message.
+	PublishMessageWithID(id string, message []byte) error
+}
+
+// Consumer delivers the messages of the queue to a processing function. Messages for
+// which processMessage fails are retried with backoff, messages failing with a permanent
+// error or too many times are dead-lettered.
+type Consumer interface {
+	ConsumeMessages(processMessage func(Message) error)
+}
+
+// Broker is a message queue, MessageBroker implements it with RabbitMQ and MemoryBroker in process.
+type Broker interface {
+	Publisher
+	Consumer
+	Close()
+}
+
+// retriesHeader counts how many times a message has been sent back for another attempt.
+const retriesHeader = "x-retries"
+
+// Message is a message received from the queue.
+type Message struct {
+	ID   string
+	Body []byte
+	// Retries is the number of earlier failed attempts to process the message
+	Retries int
+}
+
+// RetryPolicy controls how failed messages are retried before they are dead-lettered.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// DefaultRetryPolicy retries a message five times, waiting from 5 seconds up to 5 minutes.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 5,
+	BaseDelay:  5 * time.Second,
+	MaxDelay:   5 * time.Minute,
+}
+
+// Delay returns the backoff before the next attempt of a message that already failed retries times.
+func (p RetryPolicy) Delay(retries int) time.Duration {
+	delay := p.BaseDelay
+	for i := 0; i < retries && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error as one that retrying cannot fix, such as a message that
+// cannot be decoded. Messages failing with a permanent error are dead-lettered at once.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err has been marked with Permanent.
+func IsPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
+
+// publishConfirmTimeout is how long publishing waits for RabbitMQ to confirm a message.
+const publishConfirmTimeout = 10 * time.Second
+
+// MessageBroker represents the messaging service using RabbitMQ. The channel is in
+// confirm mode, publishing returns once RabbitMQ has confirmed the message.
 type MessageBroker struct {
 	conn    *amqp.Connection
 	channel *amqp.Channel
 	queue   amqp.Queue
+	// retryQueues holds the delay queue of every retry, indexed by the number of earlier
+	// retries. Messages wait in it until its TTL expires, then are dead-lettered back to
+	// queue. All messages of a delay queue wait as long, so they expire in order.
+	retryQueues []string
+	// deadQueue keeps messages that could not be processed for inspection
+	deadQueue amqp.Queue
+	retry     RetryPolicy
+
+	// mu serializes publishing, so the confirmation received is the one of the message
+	// published last, whose delivery tag is published
+	mu        sync.Mutex
+	confirms  chan amqp.Confirmation
+	published uint64
 }
 
 // NewMessageBroker creates a new MessageBroker instance.
-func NewMessageBroker(amqpURL, queueName string) (*MessageBroker, error) {
+func NewMessageBroker(amqpURL, queueName string, retry RetryPolicy) (*MessageBroker, error) {
 	conn, err := amqp.Dial(amqpURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to RabbitMQ: %v", err)
@@ -27,6 +130,15 @@ func NewMessageBroker(amqpURL, queueName string) (*MessageBroker, error) {
 		return nil, fmt.Errorf("failed to open RabbitMQ channel: %v", err)
 	}
 
+	if err := channel.Confirm(false); err != nil {
+		channel.Close()
+		conn.Close()
+		return nil, fmt.Errorf("failed to put RabbitMQ channel in confirm mode: %v", err)
+	}
+	// One confirmation is buffered, the late one of a message whose wait timed out, so
+	// that it does not hold up the connection until the next message is published
+	confirms := channel.NotifyPublish(make(chan amqp.Confirmation, 1))
+
 	queue, err := channel.QueueDeclare(
 		queueName,
 		true,  // durable
@@ -41,35 +153,126 @@ func NewMessageBroker(amqpURL, queueName string) (*MessageBroker, error) {
 		return nil, fmt.Errorf("failed to declare RabbitMQ queue: %v", err)
 	}
 
+	retryQueues := make([]string, retry.MaxRetries)
+	for i := range retryQueues {
+		delay := retry.Delay(i)
+		retryQueue, err := channel.QueueDeclare(
+			retryQueueName(queueName, delay),
+			true,  // durable
+			false, // autoDelete
+			false, // exclusive
+			false, // noWait
+			amqp.Table{
+				"x-message-ttl": delay.Milliseconds(),
+				// Expired messages go back to the main queue through the default exchange
+				"x-dead-letter-exchange":    "",
+				"x-dead-letter-routing-key": queueName,
+			},
+		)
+		if err != nil {
+			channel.Close()
+			conn.Close()
+			return nil, fmt.Errorf("failed to declare RabbitMQ retry queue: %v", err)
+		}
+		retryQueues[i] = retryQueue.Name
+	}
+
+	deadQueue, err := channel.QueueDeclare(
+		queueName+".dead",
+		true,  // durable
+		false, // autoDelete
+		false, // exclusive
+		false, // noWait
+		nil,
+	)
+	if err != nil {
+		channel.Close()
+		conn.Close()
+		return nil, fmt.Errorf("failed to declare RabbitMQ dead letter queue: %v", err)
+	}
+
 	return &MessageBroker{
-		conn:    conn,
-		channel: channel,
-		queue:   queue,
+		conn:        conn,
+		channel:     channel,
+		queue:       queue,
+		retryQueues: retryQueues,
+		deadQueue:   deadQueue,
+		retry:       retry,
+		confirms:    confirms,
 	}, nil
 }
 
-// PublishMessage publishes a message to the RabbitMQ queue.
+// PublishMessage publishes a message to the RabbitMQ queue under a new message ID.
 func (mb *MessageBroker) PublishMessage(message []byte) error {
+	id, err := newMessageID()
+	if err != nil {
+		return err
+	}
+	return mb.PublishMessageWithID(id, message)
+}
+
+// PublishMessageWithID publishes a message to the RabbitMQ queue under the given message ID
+// and waits for RabbitMQ to confirm it. Consumers can use the ID to detect a message that
+// has been published more than once.
+func (mb *MessageBroker) PublishMessageWithID(id string, message []byte) error {
+	return mb.publish(mb.queue.Name, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    id,
+		Body:         message,
+	})
+}
+
+// publish publishes a message to the queue and waits for RabbitMQ to confirm it.
+func (mb *MessageBroker) publish(queueName string, publishing amqp.Publishing) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
 	err := mb.channel.Publish(
-		"",            // exchange
-		mb.queue.Name, // routing key
-		false,         // mandatory
-		false,         // immediate
-		amqp.Publishing{
-			ContentType: "text/plain",
-			Body:        message,
-		},
+		"",        // exchange
+		queueName, // routing key
+		false,     // mandatory
+		false,     // immediate
+		publishing,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to publish message to RabbitMQ: %v", err)
 	}
+	mb.published++
 
-	return nil
+	return waitForConfirm(mb.confirms, mb.published, publishConfirmTimeout)
+}
+
+// waitForConfirm waits for the confirmation of the message with the given delivery tag.
+// Late confirmations of earlier messages are skipped.
+func waitForConfirm(confirms <-chan amqp.Confirmation, deliveryTag uint64, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case confirm, ok := <-confirms:
+			if !ok {
+				return errors.New("RabbitMQ channel closed before the message was confirmed")
+			}
+			if confirm.DeliveryTag < deliveryTag {
+				continue
+			}
+			if !confirm.Ack {
+				return errors.New("RabbitMQ rejected the message")
+			}
+			return nil
+		case <-timer.C:
+			return errors.New("timed out waiting for RabbitMQ to confirm the message")
+		}
+	}
 }
 
 // ConsumeMessages starts consuming messages from the RabbitMQ queue.
 // It takes a message processing function as an argument to handle each message received.
-func (mb *MessageBroker) ConsumeMessages(processMessage func([]byte) error) {
+// Failed messages are retried with exponential backoff, messages failing with a
+// permanent error or too many times are moved to the dead letter queue.
+func (mb *MessageBroker) ConsumeMessages(processMessage func(Message) error) {
 	msgs, err := mb.channel.Consume(
 		mb.queue.Name, // queue
 		"",            // consumer
@@ -84,25 +287,80 @@ func (mb *MessageBroker) ConsumeMessages(processMessage func([]byte) error) {
 	}
 
 	for msg := range msgs {
-		err := processMessage(msg.Body)
-		if err != nil {
-			log.Printf("failed to process message: %v", err)
-			// Requeue the message to be processed later
-			msg.Nack(false, true)
-		} else {
+		message := Message{ID: msg.MessageId, Body: msg.Body, Retries: retriesOf(msg.Headers)}
+
+		err := processMessage(message)
+		if err == nil {
 			// Acknowledge the successful processing of the message
 			msg.Ack(false)
+			continue
+		}
+
+		log.Printf("failed to process message %s (attempt %d): %v", message.ID, message.Retries+1, err)
+		if err := mb.reschedule(msg, message.Retries, err); err != nil {
+			// Fall back to requeueing so the message is not lost
+			log.Printf("failed to reschedule message %s: %v", message.ID, err)
+			msg.Nack(false, true)
+			continue
 		}
+		msg.Ack(false)
 	}
 }
 
+// reschedule sends a failed message to the delay queue of its retry, or to the dead letter queue
+// once it cannot or should not be retried any more.
+func (mb *MessageBroker) reschedule(msg amqp.Delivery, retries int, processErr error) error {
+	headers := amqp.Table{}
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+	headers[retriesHeader] = int64(retries + 1)
+
+	publishing := amqp.Publishing{
+		ContentType:  msg.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    msg.MessageId,
+		Headers:      headers,
+		Body:         msg.Body,
+	}
+
+	if IsPermanent(processErr) || retries >= mb.retry.MaxRetries {
+		headers["x-error"] = processErr.Error()
+		return mb.publish(mb.deadQueue.Name, publishing)
+	}
+
+	return mb.publish(mb.retryQueues[retries], publishing)
+}
+
+// retryQueueName names the delay queue of queueName holding messages for delay. Queues
+// are named by their delay, as the TTL of a queue cannot change once it is declared.
+func retryQueueName(queueName string, delay time.Duration) string {
+	return queueName + ".retry." + strconv.FormatInt(delay.Milliseconds(), 10) + "ms"
+}
+
 // Close closes the connection and channel to the RabbitMQ broker.
 func (mb *MessageBroker) Close() {
 	mb.channel.Close()
 	mb.conn.Close()
 }
 
-func ProcessMessage(message []byte) error {
-	log.Printf("Following list of email are sent: %s\n", message)
-	return nil
+func retriesOf(headers amqp.Table) int {
+	switch retries := headers[retriesHeader].(type) {
+	case int:
+		return retries
+	case int32:
+		return int(retries)
+	case int64:
+		return int(retries)
+	default:
+		return 0
+	}
+}
+
+func newMessageID() (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate message id: %v", err)
+	}
+	return hex.EncodeToString(random), nil
 }
@@ -1,15 +1,19 @@
 package messaging
 
 import (
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/streadway/amqp"
 	"github.com/stretchr/testify/assert"
 )
 
 // mockMessageBroker is a mock implementation of the MessageBroker interface.
 type mockMessageBroker struct {
 	publishedMessage []byte
-	consumeFunc      func([]byte) error
+	consumeFunc      func(Message) error
 }
 
 func (m *mockMessageBroker) PublishMessage(message []byte) error {
@@ -17,7 +21,7 @@ func (m *mockMessageBroker) PublishMessage(message []byte) error {
 	return nil
 }
 
-func (m *mockMessageBroker) ConsumeMessages(processMessage func([]byte) error) {
+func (m *mockMessageBroker) ConsumeMessages(processMessage func(Message) error) {
 	m.consumeFunc = processMessage
 }
 
@@ -33,3 +37,52 @@ func TestMessageBroker_PublishMessage(t *testing.T) {
 	// Assert that the message was published correctly
 	assert.Equal(t, message, mockBroker.publishedMessage)
 }
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 5, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	assert.Equal(t, time.Second, policy.Delay(0))
+	assert.Equal(t, 2*time.Second, policy.Delay(1))
+	assert.Equal(t, 8*time.Second, policy.Delay(3))
+	assert.Equal(t, 10*time.Second, policy.Delay(4))
+	assert.Equal(t, 10*time.Second, policy.Delay(50))
+}
+
+func TestIsPermanent(t *testing.T) {
+	err := errors.New("invalid message")
+
+	assert.True(t, IsPermanent(Permanent(err)))
+	assert.True(t, IsPermanent(fmt.Errorf("failed to process: %w", Permanent(err))))
+	assert.False(t, IsPermanent(err))
+	assert.ErrorIs(t, Permanent(err), err)
+}
+
+func TestWaitForConfirm(t *testing.T) {
+	confirm := func(confirmations ...amqp.Confirmation) chan amqp.Confirmation {
+		confirms := make(chan amqp.Confirmation, len(confirmations))
+		for _, confirmation := range confirmations {
+			confirms <- confirmation
+		}
+		return confirms
+	}
+
+	assert.NoError(t, waitForConfirm(confirm(amqp.Confirmation{DeliveryTag: 2, Ack: true}), 2, time.Second))
+	assert.NoError(t, waitForConfirm(confirm(amqp.Confirmation{DeliveryTag: 1, Ack: false}, amqp.Confirmation{DeliveryTag: 2, Ack: true}), 2, time.Second), "Late confirmations should be skipped")
+	assert.Error(t, waitForConfirm(confirm(amqp.Confirmation{DeliveryTag: 2, Ack: false}), 2, time.Second), "Rejected messages should fail")
+	assert.Error(t, waitForConfirm(confirm(amqp.Confirmation{DeliveryTag: 1, Ack: true}), 2, 10*time.Millisecond), "Unconfirmed messages should time out")
+
+	closed := confirm()
+	close(closed)
+	assert.Error(t, waitForConfirm(closed, 2, time.Second))
+}
+
+func TestRetryQueueName(t *testing.T) {
+	assert.Equal(t, "sightings.retry.5000ms", retryQueueName("sightings", 5*time.Second))
+	assert.Equal(t, "sightings.retry.300000ms", retryQueueName("sightings", 5*time.Minute))
+}
+
+func TestRetriesOf(t *testing.T) {
+	assert.Equal(t, 0, retriesOf(nil))
+	assert.Equal(t, 2, retriesOf(amqp.Table{retriesHeader: int64(2)}))
+	assert.Equal(t, 3, retriesOf(amqp.Table{retriesHeader: int32(3)}))
+}
@@ -8,9 +8,24 @@ import (
 )
 
 func AuthMiddleware(auth *auth.Auth, next http.Handler) http.Handler {
+	return authenticate(auth, next, false)
+}
+
+// OptionalAuthMiddleware lets requests without an Authorization header through to next
+// anonymously, with no claims in their context. Requests with a token are verified like
+// by AuthMiddleware and rejected when the token is invalid.
+func OptionalAuthMiddleware(auth *auth.Auth, next http.Handler) http.Handler {
+	return authenticate(auth, next, true)
+}
+
+func authenticate(auth *auth.Auth, next http.Handler, optional bool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Extract the token from the Authorization header
 		authorizationHeader := r.Header.Get("Authorization")
+		if authorizationHeader == "" && optional {
+			next.ServeHTTP(w, r)
+			return
+		}
 		if authorizationHeader == "" {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
@@ -26,22 +41,56 @@ func AuthMiddleware(auth *auth.Auth, next http.Handler) http.Handler {
 		token := tokenParts[1]
 
 		// Verify the token
-		username, email, err := auth.VerifyToken(token)
+		claims, err := auth.ParseToken(token)
 		if err != nil {
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
 
+		// Reject tokens revoked before they expired, e.g. on logout
+		revoked, err := auth.IsTokenRevoked(claims)
+		if err != nil {
+			http.Error(w, "Failed to verify token", http.StatusInternalServerError)
+			return
+		}
+		if revoked {
+			http.Error(w, "Token has been revoked", http.StatusUnauthorized)
+			return
+		}
+
 		// Add the username to the request context for use in the handlers
 		ctx := r.Context()
-		ctx = context.WithValue(ctx, "username", username)
+		ctx = context.WithValue(ctx, "username", claims.Username)
 		r = r.WithContext(ctx)
 
 		// Add the email to the request context for use in the handlers
-		ctx = context.WithValue(ctx, "email", email)
+		ctx = context.WithValue(ctx, "email", claims.Email)
+		r = r.WithContext(ctx)
+
+		// Add the claims to the request context so the token can be revoked
+		ctx = context.WithValue(ctx, "claims", claims)
 		r = r.WithContext(ctx)
 
 		// Call the next handler in the chain
 		next.ServeHTTP(w, r)
 	})
 }
+
+// RequireRole only lets requests through to next whose access token has one of the roles.
+// It has to be wrapped by AuthMiddleware, which verifies the token.
+func RequireRole(next http.Handler, roles ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := auth.GetClaimsFromContext(r.Context())
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if !claims.HasRole(roles...) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
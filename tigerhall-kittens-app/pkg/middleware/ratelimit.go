@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"tigerhall-kittens-app/pkg/auth"
+	"tigerhall-kittens-app/pkg/ratelimit"
+	"tigerhall-kittens-app/pkg/utils"
+)
+
+// RateLimiter limits the requests of every client IP, and of every user once they are
+// authenticated, with the token buckets kept in Store.
+type RateLimiter struct {
+	Store ratelimit.Store
+	// TrustForwardedFor takes the client IP from the X-Forwarded-For header, see ratelimit.ClientIP
+	TrustForwardedFor bool
+}
+
+// Limit takes a token for every request to next from the bucket of the client IP and, when
+// the request is authenticated, from the bucket of the user. The buckets of different
+// names are separate. Requests are rejected with 429 once a bucket is empty. Requests are
+// let through when the store fails, so that the API stays up without Redis. A nil
+// RateLimiter or a zero limit does not limit anything.
+func (rl *RateLimiter) Limit(name string, limit ratelimit.Limit, next http.Handler) http.Handler {
+	if rl == nil || !limit.Enabled() {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys := []string{name + ":ip:" + ratelimit.ClientIP(r, rl.TrustForwardedFor)}
+		if email, ok := auth.GetEmailFromContext(r.Context()); ok {
+			keys = append(keys, name+":user:"+utils.NormalizeEmail(email))
+		}
+
+		for _, key := range keys {
+			allowed, retryAfter, err := rl.Store.Take(key, limit)
+			if err != nil {
+				log.Printf("failed to apply rate limit: %v", err)
+				continue
+			}
+			if !allowed {
+				utils.RespondWithTooManyRequests(w, retryAfter, "Too many requests")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
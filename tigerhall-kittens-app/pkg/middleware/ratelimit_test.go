@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"tigerhall-kittens-app/pkg/ratelimit"
+)
+
+func TestRateLimiter_Limit(t *testing.T) {
+	// Arrange
+	limiter := &RateLimiter{Store: ratelimit.NewMemoryStore()}
+	handler := limiter.Limit("public", ratelimit.PerMinute(60, 2), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/tigers", nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Act
+	first := request("1.2.3.4:1000")
+	second := request("1.2.3.4:1001")
+	limited := request("1.2.3.4:1002")
+	otherClient := request("5.6.7.8:1000")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code, "Status code should be 429 once the burst is used up")
+	assert.Equal(t, "1", limited.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, otherClient.Code, "Other clients should not be limited")
+}
+
+func TestRateLimiter_LimitPerUser(t *testing.T) {
+	// Arrange
+	limiter := &RateLimiter{Store: ratelimit.NewMemoryStore()}
+	handler := limiter.Limit("user", ratelimit.PerMinute(60, 1), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(remoteAddr, email string) int {
+		req := httptest.NewRequest(http.MethodPost, "/tiger-sighting/create", nil)
+		req.RemoteAddr = remoteAddr
+		req = req.WithContext(context.WithValue(req.Context(), "email", email))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// Act & Assert
+	assert.Equal(t, http.StatusOK, request("1.2.3.4:1000", "ranger@example.com"))
+	// The same user from another IP shares the bucket of the user
+	assert.Equal(t, http.StatusTooManyRequests, request("5.6.7.8:1000", "Ranger@example.com"))
+}
+
+func TestRateLimiter_Disabled(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	var limiter *RateLimiter
+	assert.NotNil(t, limiter.Limit("public", ratelimit.PerMinute(60, 1), next))
+
+	limiter = &RateLimiter{Store: ratelimit.NewMemoryStore()}
+	handler := limiter.Limit("public", ratelimit.Limit{}, next)
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/tigers", nil))
+		assert.Equal(t, http.StatusOK, rr.Code, "A zero limit should not be enforced")
+	}
+}
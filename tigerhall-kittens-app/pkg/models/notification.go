@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+)
+
+// NotificationPreference is how a user wants to hear about new sightings of a tiger.
+type NotificationPreference struct {
+	// Subscribed is false once the user opted out of the tiger
+	Subscribed bool `json:"subscribed"`
+	// Digest batches the sightings into one email per hour instead of one email per sighting
+	Digest bool `json:"digest"`
+}
+
+// DigestItem is a sighting waiting to be sent with the next digest email of the recipient.
+type DigestItem struct {
+	ID         int       `json:"id"`
+	Recipient  string    `json:"recipient"`
+	TigerID    int       `json:"tigerID"`
+	TigerName  string    `json:"tigerName"`
+	SightingID int       `json:"sightingID"`
+	Lat        float64   `json:"lat"`
+	Long       float64   `json:"long"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// TigerSubscription is a tiger a user follows, or opted out of when Subscribed is false.
+type TigerSubscription struct {
+	TigerID    int       `json:"tigerID"`
+	Subscribed bool      `json:"subscribed"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// Delivery channels of a sighting alert.
+const (
+	AlertChannelEmail   = "email"
+	AlertChannelWebhook = "webhook"
+)
+
+// SightingAlert notifies its owner of the approved sightings within RadiusKm of a point,
+// of every tiger or only of TigerID, through its own channel.
+type SightingAlert struct {
+	ID       int     `json:"id"`
+	Email    string  `json:"-"`
+	Name     string  `json:"name"`
+	Lat      float64 `json:"lat"`
+	Long     float64 `json:"long"`
+	RadiusKm float64 `json:"radiusKm"`
+	TigerID  *int    `json:"tigerID,omitempty"`
+	Channel  string  `json:"channel"`
+	// WebhookURL receives the sightings of webhook alerts, signed with WebhookSecret
+	WebhookURL string `json:"webhookURL,omitempty"`
+	// WebhookSecret is only returned when the alert is created
+	WebhookSecret string    `json:"webhookSecret,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// WebhookDelivery is a webhook of a sighting alert waiting to be sent. Attempts counts
+// the attempts so far, including the one it was claimed for.
+type WebhookDelivery struct {
+	ID       int64  `json:"id"`
+	AlertID  int    `json:"alertID"`
+	URL      string `json:"url"`
+	Secret   string `json:"-"`
+	Payload  []byte `json:"payload"`
+	Attempts int    `json:"attempts"`
+}
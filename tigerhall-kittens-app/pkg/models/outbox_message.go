@@ -0,0 +1,12 @@
+package models
+
+import (
+	"time"
+)
+
+// OutboxMessage is a message waiting to be published to the message broker.
+type OutboxMessage struct {
+	ID        int64     `json:"id"`
+	Payload   []byte    `json:"payload"`
+	CreatedAt time.Time `json:"created_at"`
+}
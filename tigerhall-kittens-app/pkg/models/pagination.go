@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Cursor is the sort key of the last item of a page, the next page starts after it.
+// Sightings are sorted by Timestamp, newest first, tigers by the field the list is sorted
+// by, see TigerFilter.Cursor, and ID breaks the ties.
+type Cursor struct {
+	Time time.Time
+	// Text is the key of lists sorted by a text field
+	Text string
+	ID   int
+}
+
+// Ways the total of a paginated list is counted.
+const (
+	// CountExact counts every item, which costs a scan of the whole list
+	CountExact = "exact"
+	// CountEstimate takes the estimate of the query planner, cheap but approximate
+	CountEstimate = "estimate"
+	// CountNone leaves the total out
+	CountNone = "none"
+)
+
+// PageRequest selects a page of a list. In cursor mode the page starts after the cursor
+// After, or at the first item when After is nil, otherwise Page numbers the pages from 1.
+type PageRequest struct {
+	Page       int
+	PageSize   int
+	CursorMode bool
+	After      *Cursor
+	// Count is how the total is counted, one of CountExact, CountEstimate and CountNone
+	Count string
+}
+
+// PageInfo describes a page of a list.
+type PageInfo struct {
+	// NextCursor is the cursor of the last item of the page, nil on the last page
+	NextCursor *Cursor
+	// TotalCount is nil when the total was not counted
+	TotalCount *int
+}
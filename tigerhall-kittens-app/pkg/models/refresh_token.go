@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// RefreshToken is a refresh token of a user, only the hash of the token is stored.
+type RefreshToken struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	ExpiresAt time.Time
+	// RevokedAt is set once the token has been used or the user logged out
+	RevokedAt *time.Time
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// TigerStats sums up the movements of a tiger from its approved sightings.
+type TigerStats struct {
+	TigerID   int `json:"tiger_id"`
+	Sightings int `json:"sightings"`
+	// DistanceKm is the sum of the distances between consecutive sightings
+	DistanceKm float64 `json:"distance_km"`
+	// AverageSpeedKmh is DistanceKm over the time between the first and the last sighting
+	AverageSpeedKmh float64 `json:"average_speed_kmh"`
+	// HomeRangeKm2 is the area of the convex hull of the sightings
+	HomeRangeKm2 float64 `json:"home_range_km2"`
+	// FirstSeen and LastSeen are nil when the tiger has no sightings
+	FirstSeen *time.Time `json:"first_seen"`
+	LastSeen  *time.Time `json:"last_seen"`
+	// Reporters is the number of distinct reporters of the sightings
+	Reporters int `json:"reporters"`
+}
+
+// PopulationStats sums up the approved sightings of every tiger from From up to To.
+type PopulationStats struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// SightingsPerDay lists the days with sightings, oldest first, days are UTC
+	SightingsPerDay  []*DailySightings `json:"sightings_per_day"`
+	MostActiveTigers []*ActiveTiger    `json:"most_active_tigers"`
+}
+
+// DailySightings is the number of sightings of a day.
+type DailySightings struct {
+	Day       time.Time `json:"day"`
+	Sightings int       `json:"sightings"`
+}
+
+// ActiveTiger is a tiger with its number of sightings.
+type ActiveTiger struct {
+	TigerID   int    `json:"tiger_id"`
+	Name      string `json:"name"`
+	Sightings int    `json:"sightings"`
+}
@@ -9,9 +9,76 @@ type Tiger struct {
 	LastSeen    time.Time `json:"last_seen"`
 	Lat         float64   `json:"lat"`
 	Long        float64   `json:"long"`
+	Version     int       `json:"version"`
+}
+
+// TigerUpdate holds the tiger fields that can be changed after creation.
+// Nil fields are left untouched. Version must match the stored version of the tiger.
+type TigerUpdate struct {
+	Name        *string    `json:"name"`
+	DateOfBirth *time.Time `json:"date_of_birth"`
+	Version     int        `json:"version"`
+}
+
+// NearbyTiger is a tiger together with its distance to the point of a nearby search.
+type NearbyTiger struct {
+	*Tiger
+	DistanceKm float64 `json:"distance_km"`
 }
 
 type Coordinates struct {
 	Lat  float64 `json:"lat"`
 	Long float64 `json:"long"`
 }
+
+// BoundingBox is the area between two latitudes and two longitudes.
+type BoundingBox struct {
+	MinLat  float64 `json:"min_lat"`
+	MinLong float64 `json:"min_long"`
+	MaxLat  float64 `json:"max_lat"`
+	MaxLong float64 `json:"max_long"`
+}
+
+func (b BoundingBox) Contains(coordinates Coordinates) bool {
+	return coordinates.Lat >= b.MinLat && coordinates.Lat <= b.MaxLat &&
+		coordinates.Long >= b.MinLong && coordinates.Long <= b.MaxLong
+}
+
+// Fields the tigers can be sorted by.
+const (
+	TigerSortLastSeen    = "last_seen"
+	TigerSortName        = "name"
+	TigerSortDateOfBirth = "date_of_birth"
+)
+
+// TigerFilter selects the tigers of a list and their order. The zero value keeps every
+// tiger, last seen most recently first.
+type TigerFilter struct {
+	// NamePrefix keeps the tigers whose name starts with it, in any case
+	NamePrefix string
+	// NameLike keeps the tigers whose name is similar to it, forgiving typos
+	NameLike string
+	// BornFrom and BornTo bound the date of birth, both included, nil is unbounded
+	BornFrom *time.Time
+	BornTo   *time.Time
+	// SeenFrom and SeenTo bound when the tiger was last seen, both included
+	SeenFrom *time.Time
+	SeenTo   *time.Time
+	// Region keeps the tigers last seen inside it
+	Region *BoundingBox
+	// Sort is one of the TigerSort fields, TigerSortLastSeen when empty
+	Sort      string
+	Ascending bool
+}
+
+// Cursor returns the cursor of the tiger in the list sorted by the filter.
+func (f TigerFilter) Cursor(tiger *Tiger) Cursor {
+	switch f.Sort {
+	case TigerSortName:
+		return Cursor{Text: tiger.Name, ID: tiger.ID}
+	case TigerSortDateOfBirth:
+		return Cursor{Time: tiger.DateOfBirth, ID: tiger.ID}
+	default:
+		return Cursor{Time: tiger.LastSeen, ID: tiger.ID}
+	}
+}
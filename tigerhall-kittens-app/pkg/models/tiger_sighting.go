@@ -4,13 +4,92 @@ import (
 	"time"
 )
 
+// Statuses of a sighting, only approved sightings are public.
+const (
+	SightingPending  = "pending"
+	SightingApproved = "approved"
+	SightingRejected = "rejected"
+)
+
 type TigerSighting struct {
+	ID        int       `json:"id"`
+	TigerID   int       `json:"tigerID"`
+	Timestamp time.Time `json:"timestamp"`
+	Lat       float64   `json:"lat"`
+	Long      float64   `json:"long"`
+	// Photos holds the uploaded photos until they are saved to the image store
+	Photos [][]byte `json:"-"`
+	// ImageKey is the first JPEG rendition of the first photo, served as ImageURL
+	ImageKey string `json:"-"`
+	ImageURL string `json:"imageURL,omitempty"`
+	// Images are the renditions of every photo, in the order the photos were uploaded
+	Images        []*SightingImage `json:"images,omitempty"`
+	ReporterEmail string           `json:"reporterEmail"`
+	Status        string           `json:"status"`
+	// OpenFlags is the number of unresolved flags, only set in the moderation queue
+	OpenFlags int `json:"openFlags,omitempty"`
+	// The position and capture time found in the EXIF data of the photo, only set
+	// in the moderation queue
+	PhotoLat       *float64   `json:"photoLat,omitempty"`
+	PhotoLong      *float64   `json:"photoLong,omitempty"`
+	PhotoTimestamp *time.Time `json:"photoTimestamp,omitempty"`
+	// PhotoMismatch tells moderators how the photo contradicts the sighting
+	PhotoMismatch string `json:"photoMismatch,omitempty"`
+}
+
+// SightingImage is a photo of a sighting in one rendition and format, the photo as
+// uploaded is the "original" rendition. Position is the index of the photo in the upload.
+type SightingImage struct {
+	SightingID  int    `json:"-"`
+	Position    int    `json:"position"`
+	Rendition   string `json:"rendition"`
+	Format      string `json:"format"`
+	ImageKey    string `json:"-"`
+	ContentType string `json:"contentType"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	URL         string `json:"url,omitempty"`
+}
+
+// SightingReview records a moderator approving or rejecting a sighting.
+type SightingReview struct {
+	ID         int       `json:"id"`
+	SightingID int       `json:"sightingID"`
+	ReviewerID int       `json:"reviewerID"`
+	Status     string    `json:"status"`
+	Note       string    `json:"note"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// SightingFlag is a user reporting an approved sighting as wrong.
+type SightingFlag struct {
 	ID            int       `json:"id"`
-	TigerID       int       `json:"tigerID"`
-	Timestamp     time.Time `json:"timestamp"`
-	Lat           float64   `json:"lat"`
-	Long          float64   `json:"long"`
-	Image         []byte    `json:"image,omitempty"`
-	ImageFile     string    `json:"imageFile,omitempty"`
+	SightingID    int       `json:"sightingID"`
 	ReporterEmail string    `json:"reporterEmail"`
+	Reason        string    `json:"reason"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// SightingFeedFilter selects the sightings sent to a live feed, zero fields keep every sighting.
+type SightingFeedFilter struct {
+	// TigerIDs keeps the sightings of these tigers
+	TigerIDs []int
+	// Region keeps the sightings inside it
+	Region *BoundingBox
+}
+
+// Matches reports whether the filter keeps the sighting.
+func (f SightingFeedFilter) Matches(sighting *TigerSighting) bool {
+	if f.Region != nil && !f.Region.Contains(Coordinates{Lat: sighting.Lat, Long: sighting.Long}) {
+		return false
+	}
+	if len(f.TigerIDs) == 0 {
+		return true
+	}
+	for _, tigerID := range f.TigerIDs {
+		if tigerID == sighting.TigerID {
+			return true
+		}
+	}
+	return false
 }
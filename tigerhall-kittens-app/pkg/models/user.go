@@ -1,8 +1,28 @@
 package models
 
+// Roles a user can be granted, users without a role are members of the public.
+const (
+	RoleRanger     = "ranger"
+	RoleResearcher = "researcher"
+	RoleAdmin      = "admin"
+)
+
 type User struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	ID       int      `json:"id"`
+	Username string   `json:"username"`
+	Email    string   `json:"email"`
+	Password string   `json:"password"`
+	Roles    []string `json:"roles,omitempty"`
+	// EmailVerified is set once the user verified their email, users cannot log in before
+	EmailVerified bool `json:"-"`
+}
+
+// IsValidRole reports whether role is one of the known roles.
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleRanger, RoleResearcher, RoleAdmin:
+		return true
+	default:
+		return false
+	}
 }
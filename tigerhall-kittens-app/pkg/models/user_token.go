@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// Purposes of the single use tokens mailed to users.
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+)
+
+// UserToken is a single use token mailed to a user, only the hash of the token is stored.
+type UserToken struct {
+	ID        int
+	UserID    int
+	Purpose   string
+	TokenHash string
+	ExpiresAt time.Time
+	// UsedAt is set once the token has been used or a newer token was used instead
+	UsedAt *time.Time
+}
@@ -0,0 +1,118 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"text/template"
+	"time"
+
+	"tigerhall-kittens-app/pkg/messaging"
+	"tigerhall-kittens-app/pkg/models"
+	"tigerhall-kittens-app/pkg/utils"
+)
+
+var alertSubjectTemplate = template.Must(template.New("alertSubject").Parse(
+	`{{.Name}} has been spotted near {{.AlertName}}`))
+
+var alertBodyTemplate = template.Must(template.New("alertBody").Parse(`Hello,
+
+{{.Name}} has been spotted {{printf "%.1f" .DistanceKm}} km from {{.AlertName}}, at latitude {{.Lat}}, longitude {{.Long}}{{if not .Timestamp.IsZero}} on {{.Timestamp.Format "02 Jan 2006 15:04 MST"}}{{end}}.
+
+You are receiving this email because of your alert {{.AlertName}} for sightings within {{.RadiusKm}} km.
+See all sightings: {{.SightingsURL}}
+
+Tigerhall Kittens
+`))
+
+// alertMailData is passed to the alert email templates.
+type alertMailData struct {
+	mailData
+	AlertName  string
+	RadiusKm   float64
+	DistanceKm float64
+}
+
+// AlertPayload is the body of the webhooks of the sighting alerts.
+type AlertPayload struct {
+	AlertID      int       `json:"alertID"`
+	AlertName    string    `json:"alertName"`
+	TigerID      int       `json:"tigerID"`
+	TigerName    string    `json:"tigerName"`
+	SightingID   int       `json:"sightingID"`
+	Lat          float64   `json:"lat"`
+	Long         float64   `json:"long"`
+	Timestamp    time.Time `json:"timestamp"`
+	DistanceKm   float64   `json:"distanceKm"`
+	SightingsURL string    `json:"sightingsURL"`
+}
+
+// processAlerts notifies the sighting to the alerts whose circle holds it, once per alert.
+// Webhooks are only queued, see WebhookDispatcher. Emails which cannot be rendered are
+// given up on so that they do not hold up the others.
+func (n *Notifier) processAlerts(messageID string, notification utils.SightingNotification) error {
+	position := models.Coordinates{Lat: notification.Lat, Long: notification.Long}
+	alerts, err := n.store.GetSightingAlertsAt(position, notification.TigerID)
+	if err != nil {
+		return err
+	}
+
+	for _, alert := range alerts {
+		alert := alert
+		distanceKm := utils.CalculateDistance(models.Coordinates{Lat: alert.Lat, Long: alert.Long}, position)
+		if distanceKm > alert.RadiusKm {
+			continue
+		}
+
+		err := n.deliverOnce(messageID, fmt.Sprintf("alert:%d", alert.ID), func() error {
+			err := n.sendAlert(messageID, alert, notification, distanceKm)
+			if messaging.IsPermanent(err) {
+				log.Printf("failed to notify sighting alert %d: %v", alert.ID, err)
+				return nil
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (n *Notifier) sendAlert(messageID string, alert *models.SightingAlert, notification utils.SightingNotification, distanceKm float64) error {
+	data := n.mailData(notification.TigerID, notification.TigerName, notification.Lat, notification.Long, notification.Timestamp)
+
+	switch alert.Channel {
+	case models.AlertChannelEmail:
+		mail, err := n.render(alert.Email, alertSubjectTemplate, alertBodyTemplate, alertMailData{
+			mailData:   data,
+			AlertName:  alert.Name,
+			RadiusKm:   alert.RadiusKm,
+			DistanceKm: distanceKm,
+		})
+		if err != nil {
+			return messaging.Permanent(err)
+		}
+		return n.mailer.Send(mail)
+	case models.AlertChannelWebhook:
+		payload, err := json.Marshal(AlertPayload{
+			AlertID:      alert.ID,
+			AlertName:    alert.Name,
+			TigerID:      notification.TigerID,
+			TigerName:    data.Name,
+			SightingID:   notification.SightingID,
+			Lat:          notification.Lat,
+			Long:         notification.Long,
+			Timestamp:    notification.Timestamp,
+			DistanceKm:   distanceKm,
+			SightingsURL: data.SightingsURL,
+		})
+		if err != nil {
+			return messaging.Permanent(err)
+		}
+		return n.store.QueueWebhookDelivery(messageID, alert.ID, payload)
+	default:
+		return messaging.Permanent(fmt.Errorf("unknown alert channel %q", alert.Channel))
+	}
+}
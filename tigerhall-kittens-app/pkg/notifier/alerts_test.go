@@ -0,0 +1,51 @@
+package notifier
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"tigerhall-kittens-app/pkg/messaging"
+	"tigerhall-kittens-app/pkg/models"
+)
+
+func TestNotifier_ProcessMessage_Alerts(t *testing.T) {
+	// Arrange
+	server := newFakeSMTPServer(t)
+	host, port := server.hostPort()
+	otherTiger := 2
+	store := newMemoryStore()
+	store.alerts = []*models.SightingAlert{
+		{ID: 1, Email: "camp@example.com", Name: "Camp", Lat: 12.4, Long: 56.78, RadiusKm: 10, Channel: models.AlertChannelEmail},
+		{ID: 2, Name: "Hook", Lat: 12.34, Long: 56.8, RadiusKm: 5, Channel: models.AlertChannelWebhook, WebhookURL: "https://example.com/hook", WebhookSecret: "secret"},
+		{ID: 3, Email: "far@example.com", Name: "Far", Lat: 13, Long: 56.78, RadiusKm: 10, Channel: models.AlertChannelEmail},
+		{ID: 4, Email: "other@example.com", Name: "Other", Lat: 12.34, Long: 56.78, RadiusKm: 10, TigerID: &otherTiger, Channel: models.AlertChannelEmail},
+	}
+	n := NewNotifier(NewSMTPMailer(host, port, "", "", "alerts@example.com"), store, "http://localhost:8080")
+	message := messaging.Message{ID: "message-1", Body: []byte(sightingMessage)}
+
+	// Act
+	assert.NoError(t, n.ProcessMessage(message))
+	assert.NoError(t, n.ProcessMessage(message))
+
+	// Assert
+	mails := server.received()
+	if assert.Len(t, mails, 2, "The recipient and the camp alert should be mailed once") {
+		assert.Contains(t, mails[1], "To: camp@example.com\r\n")
+		assert.Contains(t, mails[1], "Subject: Mufasa has been spotted near Camp\r\n")
+		assert.Contains(t, mails[1], "Mufasa has been spotted 6.7 km from Camp")
+	}
+
+	if assert.Len(t, store.webhooks, 1, "The webhook should be queued once") {
+		assert.Equal(t, 2, store.webhooks[0].AlertID)
+
+		var payload AlertPayload
+		assert.NoError(t, json.Unmarshal(store.webhooks[0].Payload, &payload))
+		assert.Equal(t, 2, payload.AlertID)
+		assert.Equal(t, 7, payload.SightingID)
+		assert.Equal(t, "Mufasa", payload.TigerName)
+		assert.InDelta(t, 2.2, payload.DistanceKm, 0.1)
+		assert.Equal(t, "http://localhost:8080/tiger/1/sightings", payload.SightingsURL)
+	}
+}
@@ -0,0 +1,126 @@
+package notifier
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"tigerhall-kittens-app/pkg/messaging"
+	"tigerhall-kittens-app/pkg/models"
+)
+
+// DefaultWebhookWorkers is the number of webhooks a WebhookDispatcher sends at once.
+const DefaultWebhookWorkers = 8
+
+// webhookLease is how long a claimed webhook delivery is not due again. It is much longer
+// than a delivery may wait for a worker and take to send, see webhookTimeout.
+const webhookLease = 5 * time.Minute
+
+// WebhookStore keeps the webhooks queued by the Notifier until they are sent.
+type WebhookStore interface {
+	ClaimWebhookDeliveries(limit int, lease time.Duration) ([]*models.WebhookDelivery, error)
+	MarkWebhookDelivered(id int64) error
+	RetryWebhookDelivery(id int64, delay time.Duration, lastError string) error
+	FailWebhookDelivery(id int64, lastError string) error
+}
+
+// WebhookDispatcher sends the queued webhooks of the sighting alerts, apart from the
+// notifications so that slow webhooks only hold up each other. Failed webhooks are
+// retried with the backoff of the retry policy, and given up on after its retries or
+// when they will never be accepted, such as a webhook answering 404.
+type WebhookDispatcher struct {
+	store   WebhookStore
+	sender  WebhookSender
+	workers int
+	retry   messaging.RetryPolicy
+}
+
+// NewWebhookDispatcher creates a WebhookDispatcher sending up to workers webhooks at once.
+func NewWebhookDispatcher(store WebhookStore, sender WebhookSender, workers int, retry messaging.RetryPolicy) *WebhookDispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	return &WebhookDispatcher{
+		store:   store,
+		sender:  sender,
+		workers: workers,
+		retry:   retry,
+	}
+}
+
+// DispatchPending sends the due webhooks until none is left, or claiming them fails.
+// Deliveries are claimed as workers are free, so that they do not wait past their lease.
+func (d *WebhookDispatcher) DispatchPending() error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	free := make(chan struct{}, d.workers)
+	for i := 0; i < d.workers; i++ {
+		free <- struct{}{}
+	}
+
+	for {
+		// Wait for a free worker, then claim as many deliveries as there are free workers
+		<-free
+		limit := 1
+		for drained := false; !drained; {
+			select {
+			case <-free:
+				limit++
+			default:
+				drained = true
+			}
+		}
+
+		deliveries, err := d.store.ClaimWebhookDeliveries(limit, webhookLease)
+		if err != nil {
+			return err
+		}
+		for _, delivery := range deliveries {
+			delivery := delivery
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d.deliver(delivery)
+				free <- struct{}{}
+			}()
+		}
+		if len(deliveries) < limit {
+			return nil
+		}
+	}
+}
+
+// deliver sends the webhook and records the outcome of the attempt.
+func (d *WebhookDispatcher) deliver(delivery *models.WebhookDelivery) {
+	err := d.sender.Send(Webhook{URL: delivery.URL, Secret: delivery.Secret, Payload: delivery.Payload})
+	switch {
+	case err == nil:
+		err = d.store.MarkWebhookDelivered(delivery.ID)
+	case messaging.IsPermanent(err) || delivery.Attempts > d.retry.MaxRetries:
+		log.Printf("giving up on webhook of sighting alert %d: %v", delivery.AlertID, err)
+		err = d.store.FailWebhookDelivery(delivery.ID, err.Error())
+	default:
+		err = d.store.RetryWebhookDelivery(delivery.ID, d.retry.Delay(delivery.Attempts-1), err.Error())
+	}
+	if err != nil {
+		log.Printf("failed to record webhook delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// Run sends the due webhooks every interval until stop is closed.
+func (d *WebhookDispatcher) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.DispatchPending(); err != nil {
+			log.Printf("failed to dispatch webhooks: %v", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
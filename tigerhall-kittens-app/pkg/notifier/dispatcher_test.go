@@ -0,0 +1,90 @@
+package notifier
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"tigerhall-kittens-app/pkg/messaging"
+	"tigerhall-kittens-app/pkg/models"
+)
+
+// recordingWebhookSender keeps the webhooks it sends, or fails with err.
+type recordingWebhookSender struct {
+	mu       sync.Mutex
+	webhooks []Webhook
+	err      error
+}
+
+func (r *recordingWebhookSender) Send(webhook Webhook) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.webhooks = append(r.webhooks, webhook)
+	return nil
+}
+
+func TestWebhookDispatcher_DispatchPending(t *testing.T) {
+	// Arrange
+	store := newMemoryStore()
+	store.alerts = []*models.SightingAlert{
+		{ID: 2, Channel: models.AlertChannelWebhook, WebhookURL: "https://example.com/hook", WebhookSecret: "secret"},
+	}
+	for _, messageID := range []string{"message-1", "message-2", "message-3"} {
+		assert.NoError(t, store.QueueWebhookDelivery(messageID, 2, []byte(`{"alertID":2}`)))
+	}
+	webhooks := &recordingWebhookSender{}
+	dispatcher := NewWebhookDispatcher(store, webhooks, 2, messaging.DefaultRetryPolicy)
+
+	// Act
+	err := dispatcher.DispatchPending()
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.Len(t, webhooks.webhooks, 3) {
+		assert.Equal(t, Webhook{URL: "https://example.com/hook", Secret: "secret", Payload: []byte(`{"alertID":2}`)}, webhooks.webhooks[0])
+	}
+	for _, webhook := range store.webhooks {
+		assert.Equal(t, "delivered", webhook.status)
+	}
+}
+
+func TestWebhookDispatcher_Failures(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		attempts int
+		status   string
+	}{
+		{name: "retried", err: errors.New("connection refused"), status: "retry"},
+		{name: "given up", err: messaging.Permanent(errors.New("webhook responded with 404")), status: "failed"},
+		{name: "out of retries", err: errors.New("connection refused"), attempts: messaging.DefaultRetryPolicy.MaxRetries, status: "failed"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			store := newMemoryStore()
+			store.alerts = []*models.SightingAlert{{ID: 2, Channel: models.AlertChannelWebhook, WebhookURL: "https://example.com/hook"}}
+			assert.NoError(t, store.QueueWebhookDelivery("message-1", 2, []byte(`{}`)))
+			store.webhooks[0].Attempts = tt.attempts
+			dispatcher := NewWebhookDispatcher(store, &recordingWebhookSender{err: tt.err}, 2, messaging.DefaultRetryPolicy)
+
+			// Act
+			err := dispatcher.DispatchPending()
+
+			// Assert
+			assert.NoError(t, err, "Failed webhooks should not stop the dispatcher")
+			assert.Equal(t, tt.status, store.webhooks[0].status)
+			assert.Equal(t, tt.err.Error(), store.webhooks[0].lastError)
+			if tt.status == "retry" {
+				assert.Equal(t, messaging.DefaultRetryPolicy.BaseDelay, store.webhooks[0].delay)
+			}
+		})
+	}
+}
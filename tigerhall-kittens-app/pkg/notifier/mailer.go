@@ -0,0 +1,63 @@
+package notifier
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+// Mail is a plain text email to a single recipient.
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails.
+type Mailer interface {
+	Send(mail Mail) error
+}
+
+// smtpMailer sends emails through an SMTP server.
+type smtpMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer creates a Mailer sending through the SMTP server at host:port.
+// Authentication is skipped when username is empty.
+func NewSMTPMailer(host string, port int, username, password, from string) Mailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &smtpMailer{
+		addr: fmt.Sprintf("%s:%d", host, port),
+		from: from,
+		auth: auth,
+	}
+}
+
+func (m *smtpMailer) Send(mail Mail) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", headerValue(m.from))
+	fmt.Fprintf(&msg, "To: %s\r\n", headerValue(mail.To))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", headerValue(mail.Subject))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+
+	err := smtp.SendMail(m.addr, m.auth, m.from, []string{mail.To}, []byte(msg.String()))
+	if err != nil {
+		return fmt.Errorf("failed to send mail to %s: %v", mail.To, err)
+	}
+
+	return nil
+}
+
+// headerValue strips line breaks so values cannot inject additional headers.
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
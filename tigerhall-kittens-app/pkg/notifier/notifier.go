@@ -0,0 +1,375 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"tigerhall-kittens-app/pkg/auth"
+	"tigerhall-kittens-app/pkg/messaging"
+	"tigerhall-kittens-app/pkg/models"
+	"tigerhall-kittens-app/pkg/utils"
+)
+
+var subjectTemplate = template.Must(template.New("subject").Parse(
+	`{{.Name}} has been spotted again`))
+
+var bodyTemplate = template.Must(template.New("body").Parse(`Hello,
+
+{{.Name}} has been spotted again at latitude {{.Lat}}, longitude {{.Long}}{{if not .Timestamp.IsZero}} on {{.Timestamp.Format "02 Jan 2006 15:04 MST"}}{{end}}.
+
+You are receiving this email because you follow {{.Name}} or reported an earlier sighting of it.
+See all sightings: {{.SightingsURL}}
+
+Tigerhall Kittens
+`))
+
+var digestSubjectTemplate = template.Must(template.New("digestSubject").Parse(
+	`{{len .}} new tiger sighting{{if gt (len .) 1}}s{{end}}`))
+
+var digestBodyTemplate = template.Must(template.New("digestBody").Parse(`Hello,
+
+These tigers have been spotted again:
+{{range .}}
+- {{.Name}} at latitude {{.Lat}}, longitude {{.Long}}{{if not .Timestamp.IsZero}} on {{.Timestamp.Format "02 Jan 2006 15:04 MST"}}{{end}}
+  {{.SightingsURL}}
+{{end}}
+You are receiving this email because you follow these tigers or reported an earlier sighting of them.
+
+Tigerhall Kittens
+`))
+
+var verificationSubjectTemplate = template.Must(template.New("verificationSubject").Parse(
+	`Verify your email address`))
+
+var verificationBodyTemplate = template.Must(template.New("verificationBody").Parse(`Hello {{.Username}},
+
+Please verify your email address to start using your Tigerhall Kittens account:
+{{.URL}}
+
+The link expires on {{.ExpiresAt.Format "02 Jan 2006 15:04 MST"}}. If you did not sign up, you can ignore this email.
+
+Tigerhall Kittens
+`))
+
+var passwordResetSubjectTemplate = template.Must(template.New("passwordResetSubject").Parse(
+	`Reset your password`))
+
+var passwordResetBodyTemplate = template.Must(template.New("passwordResetBody").Parse(`Hello {{.Username}},
+
+Use this token to reset the password of your Tigerhall Kittens account:
+{{.Token}}
+
+Send it with your new password to {{.URL}}. The token expires on {{.ExpiresAt.Format "02 Jan 2006 15:04 MST"}}.
+If you did not ask to reset your password, you can ignore this email.
+
+Tigerhall Kittens
+`))
+
+// Store keeps the notification preferences of the users and the state of the
+// notifications, so that a message processed more than once does not mail the
+// same recipient twice.
+type Store interface {
+	IsNotificationDelivered(messageID, recipient string) (bool, error)
+	MarkNotificationDelivered(messageID, recipient string) error
+	GetNotificationPreference(email string, tigerID int) (*models.NotificationPreference, error)
+	GetSightingAlertsAt(coordinates models.Coordinates, tigerID int) ([]*models.SightingAlert, error)
+	RenewUserToken(tokenID int, tokenHash string) (bool, error)
+	QueueWebhookDelivery(messageID string, alertID int, payload []byte) error
+	AddDigestItem(messageID string, item *models.DigestItem) error
+	GetPendingDigestItems() ([]*models.DigestItem, error)
+	MarkDigestItemsSent(recipient string, lastID int) error
+}
+
+// Notifier sends the emails of the sighting messages published by the service, and
+// notifies the sighting alerts of the sightings through their email or webhook. Webhooks
+// are queued for the WebhookDispatcher.
+type Notifier struct {
+	mailer  Mailer
+	store   Store
+	baseURL string
+}
+
+// NewNotifier creates a Notifier, baseURL is used to link to the sightings of a tiger.
+func NewNotifier(mailer Mailer, store Store, baseURL string) *Notifier {
+	return &Notifier{
+		mailer:  mailer,
+		store:   store,
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}
+}
+
+// mailData is passed to the email templates.
+type mailData struct {
+	Name         string
+	Lat          float64
+	Long         float64
+	Timestamp    time.Time
+	SightingsURL string
+}
+
+// ProcessMessage notifies every recipient of the message who has not been notified yet.
+// Recipients who opted out of the tiger are skipped, recipients in digest mode get the
+// sighting with their next digest. The sighting is then notified to the sighting alerts
+// around it, see processAlerts. Account emails, see utils.AccountEmail, are sent to their
+// recipient. A message that cannot be decoded fails with a permanent error.
+func (n *Notifier) ProcessMessage(message messaging.Message) error {
+	messageID := message.ID
+	if messageID == "" {
+		// Messages published without an ID are identified by their content
+		sum := sha256.Sum256(message.Body)
+		messageID = hex.EncodeToString(sum[:])
+	}
+
+	if body := bytes.TrimSpace(message.Body); len(body) > 0 && body[0] == '[' {
+		return n.processEmails(messageID, body)
+	}
+
+	var notification struct {
+		utils.SightingNotification
+		// Type is only set for account emails
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(message.Body, &notification); err != nil {
+		return messaging.Permanent(fmt.Errorf("failed to decode sighting notification: %v", err))
+	}
+	if notification.Type != "" {
+		return n.processAccountEmail(messageID, message.Body)
+	}
+
+	for _, recipient := range notification.Recipients {
+		err := n.deliverOnce(messageID, recipient, func() error {
+			return n.notify(messageID, recipient, notification.SightingNotification)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return n.processAlerts(messageID, notification.SightingNotification)
+}
+
+func (n *Notifier) notify(messageID, recipient string, notification utils.SightingNotification) error {
+	preference, err := n.store.GetNotificationPreference(recipient, notification.TigerID)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !preference.Subscribed:
+		return nil
+	case preference.Digest:
+		return n.store.AddDigestItem(messageID, &models.DigestItem{
+			Recipient:  recipient,
+			TigerID:    notification.TigerID,
+			TigerName:  notification.TigerName,
+			SightingID: notification.SightingID,
+			Lat:        notification.Lat,
+			Long:       notification.Long,
+			Timestamp:  notification.Timestamp,
+		})
+	default:
+		mail, err := n.render(recipient, subjectTemplate, bodyTemplate, n.mailData(notification.TigerID, notification.TigerName, notification.Lat, notification.Long, notification.Timestamp))
+		if err != nil {
+			return messaging.Permanent(err)
+		}
+		return n.mailer.Send(mail)
+	}
+}
+
+// processEmails sends the emails of a message published in the EmailTemplate format.
+func (n *Notifier) processEmails(messageID string, body []byte) error {
+	var emails []utils.EmailTemplate
+	if err := json.Unmarshal(body, &emails); err != nil {
+		return messaging.Permanent(fmt.Errorf("failed to decode emails: %v", err))
+	}
+
+	for _, email := range emails {
+		email := email
+		err := n.deliverOnce(messageID, email.Recipient, func() error {
+			return n.mailer.Send(Mail{To: email.Recipient, Subject: email.Sub, Body: email.Body})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// accountMailData is passed to the account email templates.
+type accountMailData struct {
+	Username  string
+	Token     string
+	URL       string
+	ExpiresAt time.Time
+}
+
+// processAccountEmail mails the token of an account email. The token is minted here and
+// only its hash is stored, a token mailed by an earlier attempt stops working. Tokens
+// used or expired before the email is sent are not mailed. Account emails are sent
+// regardless of the notification preferences of the recipient.
+func (n *Notifier) processAccountEmail(messageID string, body []byte) error {
+	var email utils.AccountEmail
+	if err := json.Unmarshal(body, &email); err != nil {
+		return messaging.Permanent(fmt.Errorf("failed to decode account email: %v", err))
+	}
+
+	var subject, text *template.Template
+	switch email.Type {
+	case models.TokenPurposeEmailVerification:
+		subject, text = verificationSubjectTemplate, verificationBodyTemplate
+	case models.TokenPurposePasswordReset:
+		subject, text = passwordResetSubjectTemplate, passwordResetBodyTemplate
+	default:
+		return messaging.Permanent(fmt.Errorf("unknown account email type %q", email.Type))
+	}
+
+	return n.deliverOnce(messageID, email.Recipient, func() error {
+		token, hash, err := auth.NewUserToken()
+		if err != nil {
+			return err
+		}
+		renewed, err := n.store.RenewUserToken(email.TokenID, hash)
+		if err != nil {
+			return err
+		} else if !renewed {
+			log.Printf("not mailing %s token %d, it has been used or has expired", email.Type, email.TokenID)
+			return nil
+		}
+
+		data := accountMailData{Username: email.Username, Token: token, ExpiresAt: email.ExpiresAt}
+		if email.Type == models.TokenPurposeEmailVerification {
+			data.URL = fmt.Sprintf("%s/email/verify?token=%s", n.baseURL, url.QueryEscape(token))
+		} else {
+			data.URL = n.baseURL + "/password/reset"
+		}
+		mail, err := n.render(email.Recipient, subject, text, data)
+		if err != nil {
+			return messaging.Permanent(err)
+		}
+		return n.mailer.Send(mail)
+	})
+}
+
+// deliverOnce runs deliver unless the message has already been delivered to the recipient.
+func (n *Notifier) deliverOnce(messageID, recipient string, deliver func() error) error {
+	if recipient == "" {
+		return nil
+	}
+
+	delivered, err := n.store.IsNotificationDelivered(messageID, recipient)
+	if err != nil {
+		return err
+	}
+	if delivered {
+		return nil
+	}
+
+	if err := deliver(); err != nil {
+		return err
+	}
+
+	return n.store.MarkNotificationDelivered(messageID, recipient)
+}
+
+// SendDigests sends every recipient with pending digest items one email listing them.
+// A failure for one recipient does not stop the others, the first error is returned.
+func (n *Notifier) SendDigests() error {
+	items, err := n.store.GetPendingDigestItems()
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	for start := 0; start < len(items); {
+		// Items are grouped by recipient
+		end := start + 1
+		for end < len(items) && items[end].Recipient == items[start].Recipient {
+			end++
+		}
+
+		if err := n.sendDigest(items[start:end]); err != nil {
+			log.Printf("failed to send digest to %s: %v", items[start].Recipient, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		start = end
+	}
+
+	return firstErr
+}
+
+func (n *Notifier) sendDigest(items []*models.DigestItem) error {
+	recipient := items[0].Recipient
+	lastID := 0
+
+	var sightings []mailData
+	for _, item := range items {
+		sightings = append(sightings, n.mailData(item.TigerID, item.TigerName, item.Lat, item.Long, item.Timestamp))
+		if item.ID > lastID {
+			lastID = item.ID
+		}
+	}
+
+	mail, err := n.render(recipient, digestSubjectTemplate, digestBodyTemplate, sightings)
+	if err != nil {
+		return err
+	}
+	if err := n.mailer.Send(mail); err != nil {
+		return err
+	}
+
+	return n.store.MarkDigestItemsSent(recipient, lastID)
+}
+
+// RunDigests sends the pending digests every interval until stop is closed.
+func (n *Notifier) RunDigests(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := n.SendDigests(); err != nil {
+				log.Printf("failed to send digests: %v", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (n *Notifier) mailData(tigerID int, tigerName string, lat, long float64, timestamp time.Time) mailData {
+	data := mailData{
+		Name:         tigerName,
+		Lat:          lat,
+		Long:         long,
+		Timestamp:    timestamp,
+		SightingsURL: fmt.Sprintf("%s/tiger/%d/sightings", n.baseURL, tigerID),
+	}
+	if data.Name == "" {
+		data.Name = fmt.Sprintf("Tiger %d", tigerID)
+	}
+	return data
+}
+
+func (n *Notifier) render(recipient string, subjectTemplate, bodyTemplate *template.Template, data interface{}) (Mail, error) {
+	var subject, body strings.Builder
+	if err := subjectTemplate.Execute(&subject, data); err != nil {
+		return Mail{}, fmt.Errorf("failed to render email subject: %v", err)
+	}
+	if err := bodyTemplate.Execute(&body, data); err != nil {
+		return Mail{}, fmt.Errorf("failed to render email body: %v", err)
+	}
+
+	return Mail{To: recipient, Subject: subject.String(), Body: body.String()}, nil
+}
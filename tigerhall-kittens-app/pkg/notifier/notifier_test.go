@@ -0,0 +1,486 @@
+package notifier
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"tigerhall-kittens-app/pkg/auth"
+	"tigerhall-kittens-app/pkg/messaging"
+	"tigerhall-kittens-app/pkg/models"
+)
+
+// fakeSMTPServer is a minimal SMTP server keeping the data of every received mail.
+type fakeSMTPServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	mails    []string
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start fake SMTP server: %v", err)
+	}
+	server := &fakeSMTPServer{listener: listener}
+	go server.serve()
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+func (s *fakeSMTPServer) hostPort() (string, int) {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+func (s *fakeSMTPServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.mails...)
+}
+
+func (s *fakeSMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "DATA"):
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			s.mu.Lock()
+			s.mails = append(s.mails, data.String())
+			s.mu.Unlock()
+			reply("250 OK")
+		case strings.HasPrefix(command, "QUIT"):
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// memoryStore is an in memory Store and WebhookStore.
+type memoryStore struct {
+	delivered   map[string]bool
+	preferences map[string]models.NotificationPreference
+	digestItems []*models.DigestItem
+	alerts      []*models.SightingAlert
+	// tokens holds the hashes of the user tokens that are neither used nor expired
+	tokens map[int]string
+
+	// webhooks are updated by the workers of the dispatcher
+	mu       sync.Mutex
+	webhooks []*memoryWebhookDelivery
+}
+
+// memoryWebhookDelivery is a webhook queued in a memoryStore, status is empty while it
+// is due.
+type memoryWebhookDelivery struct {
+	models.WebhookDelivery
+	messageID string
+	status    string
+	delay     time.Duration
+	lastError string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		delivered:   map[string]bool{},
+		preferences: map[string]models.NotificationPreference{},
+		tokens:      map[int]string{},
+	}
+}
+
+func (m *memoryStore) IsNotificationDelivered(messageID, recipient string) (bool, error) {
+	return m.delivered[messageID+"/"+recipient], nil
+}
+
+func (m *memoryStore) MarkNotificationDelivered(messageID, recipient string) error {
+	m.delivered[messageID+"/"+recipient] = true
+	return nil
+}
+
+func (m *memoryStore) GetNotificationPreference(email string, tigerID int) (*models.NotificationPreference, error) {
+	preference, ok := m.preferences[email]
+	if !ok {
+		preference = models.NotificationPreference{Subscribed: true}
+	}
+	return &preference, nil
+}
+
+// GetSightingAlertsAt returns the alerts for every tiger or the tiger, wherever they are.
+func (m *memoryStore) GetSightingAlertsAt(coordinates models.Coordinates, tigerID int) ([]*models.SightingAlert, error) {
+	var alerts []*models.SightingAlert
+	for _, alert := range m.alerts {
+		if alert.TigerID == nil || *alert.TigerID == tigerID {
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts, nil
+}
+
+func (m *memoryStore) AddDigestItem(messageID string, item *models.DigestItem) error {
+	item.ID = len(m.digestItems) + 1
+	m.digestItems = append(m.digestItems, item)
+	return nil
+}
+
+func (m *memoryStore) GetPendingDigestItems() ([]*models.DigestItem, error) {
+	return m.digestItems, nil
+}
+
+func (m *memoryStore) MarkDigestItemsSent(recipient string, lastID int) error {
+	var pending []*models.DigestItem
+	for _, item := range m.digestItems {
+		if item.Recipient != recipient || item.ID > lastID {
+			pending = append(pending, item)
+		}
+	}
+	m.digestItems = pending
+	return nil
+}
+
+func (m *memoryStore) RenewUserToken(tokenID int, tokenHash string) (bool, error) {
+	if _, ok := m.tokens[tokenID]; !ok {
+		return false, nil
+	}
+	m.tokens[tokenID] = tokenHash
+	return true, nil
+}
+
+func (m *memoryStore) QueueWebhookDelivery(messageID string, alertID int, payload []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, webhook := range m.webhooks {
+		if webhook.messageID == messageID && webhook.AlertID == alertID {
+			return nil
+		}
+	}
+	m.webhooks = append(m.webhooks, &memoryWebhookDelivery{
+		WebhookDelivery: models.WebhookDelivery{ID: int64(len(m.webhooks) + 1), AlertID: alertID, Payload: payload},
+		messageID:       messageID,
+	})
+	return nil
+}
+
+func (m *memoryStore) ClaimWebhookDeliveries(limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var deliveries []*models.WebhookDelivery
+	for _, webhook := range m.webhooks {
+		if webhook.status != "" || len(deliveries) == limit {
+			continue
+		}
+		for _, alert := range m.alerts {
+			if alert.ID == webhook.AlertID {
+				webhook.URL, webhook.Secret = alert.WebhookURL, alert.WebhookSecret
+			}
+		}
+		webhook.Attempts++
+		webhook.status = "claimed"
+		delivery := webhook.WebhookDelivery
+		deliveries = append(deliveries, &delivery)
+	}
+	return deliveries, nil
+}
+
+func (m *memoryStore) setWebhookStatus(id int64, status string, delay time.Duration, lastError string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, webhook := range m.webhooks {
+		if webhook.ID == id {
+			webhook.status, webhook.delay, webhook.lastError = status, delay, lastError
+		}
+	}
+	return nil
+}
+
+func (m *memoryStore) MarkWebhookDelivered(id int64) error {
+	return m.setWebhookStatus(id, "delivered", 0, "")
+}
+
+func (m *memoryStore) RetryWebhookDelivery(id int64, delay time.Duration, lastError string) error {
+	return m.setWebhookStatus(id, "retry", delay, lastError)
+}
+
+func (m *memoryStore) FailWebhookDelivery(id int64, lastError string) error {
+	return m.setWebhookStatus(id, "failed", 0, lastError)
+}
+
+// failingMailer fails to send every mail.
+type failingMailer struct{}
+
+func (failingMailer) Send(mail Mail) error {
+	return errors.New("connection refused")
+}
+
+const sightingMessage = `{
+	"tigerID": 1,
+	"tigerName": "Mufasa",
+	"sightingID": 7,
+	"lat": 12.34,
+	"long": 56.78,
+	"timestamp": "2023-07-21T12:00:00Z",
+	"recipients": ["ranger@example.com"]
+}`
+
+func TestNotifier_ProcessMessage(t *testing.T) {
+	// Arrange
+	server := newFakeSMTPServer(t)
+	host, port := server.hostPort()
+	n := NewNotifier(NewSMTPMailer(host, port, "", "", "alerts@example.com"), newMemoryStore(), "http://localhost:8080/")
+	message := messaging.Message{ID: "message-1", Body: []byte(sightingMessage)}
+
+	// Act
+	err := n.ProcessMessage(message)
+
+	// Assert
+	assert.NoError(t, err)
+	mails := server.received()
+	if assert.Len(t, mails, 1) {
+		assert.Contains(t, mails[0], "To: ranger@example.com\r\n")
+		assert.Contains(t, mails[0], "Subject: Mufasa has been spotted again\r\n")
+		assert.Contains(t, mails[0], "latitude 12.34, longitude 56.78 on 21 Jul 2023 12:00 UTC")
+		assert.Contains(t, mails[0], "http://localhost:8080/tiger/1/sightings")
+	}
+}
+
+func TestNotifier_ProcessMessage_Idempotent(t *testing.T) {
+	// Arrange
+	server := newFakeSMTPServer(t)
+	host, port := server.hostPort()
+	n := NewNotifier(NewSMTPMailer(host, port, "", "", "alerts@example.com"), newMemoryStore(), "http://localhost:8080")
+	message := messaging.Message{ID: "message-1", Body: []byte(sightingMessage)}
+
+	// Act
+	assert.NoError(t, n.ProcessMessage(message))
+	message.Retries = 1
+	assert.NoError(t, n.ProcessMessage(message))
+
+	// Assert
+	assert.Len(t, server.received(), 1)
+}
+
+func TestNotifier_ProcessMessage_InvalidMessage(t *testing.T) {
+	// Arrange
+	n := NewNotifier(failingMailer{}, newMemoryStore(), "http://localhost:8080")
+
+	// Act
+	err := n.ProcessMessage(messaging.Message{ID: "message-1", Body: []byte("not json")})
+
+	// Assert
+	assert.Error(t, err)
+	assert.True(t, messaging.IsPermanent(err))
+}
+
+func TestNotifier_ProcessMessage_SendFailure(t *testing.T) {
+	// Arrange
+	store := newMemoryStore()
+	n := NewNotifier(failingMailer{}, store, "http://localhost:8080")
+
+	// Act
+	err := n.ProcessMessage(messaging.Message{ID: "message-1", Body: []byte(sightingMessage)})
+
+	// Assert
+	assert.Error(t, err)
+	assert.False(t, messaging.IsPermanent(err), "send failures should be retried")
+	assert.Empty(t, store.delivered)
+}
+
+func TestNotifier_ProcessMessage_WithoutMessageID(t *testing.T) {
+	// Arrange
+	store := newMemoryStore()
+	server := newFakeSMTPServer(t)
+	host, port := server.hostPort()
+	n := NewNotifier(NewSMTPMailer(host, port, "", "", "alerts@example.com"), store, "http://localhost:8080")
+
+	// Act
+	assert.NoError(t, n.ProcessMessage(messaging.Message{Body: []byte(sightingMessage)}))
+	assert.NoError(t, n.ProcessMessage(messaging.Message{Body: []byte(sightingMessage)}))
+
+	// Assert
+	assert.Len(t, server.received(), 1)
+	assert.Len(t, store.delivered, 1)
+}
+
+func TestNotifier_ProcessMessage_LegacyEmails(t *testing.T) {
+	// Arrange
+	server := newFakeSMTPServer(t)
+	host, port := server.hostPort()
+	n := NewNotifier(NewSMTPMailer(host, port, "", "", "alerts@example.com"), newMemoryStore(), "http://localhost:8080")
+	body := `[{"subject": "Tiger Sights", "body": "Tiger_1 is found at {Lat: 12.34,Long: 56.78}", "recipient": "ranger@example.com"}]`
+
+	// Act
+	err := n.ProcessMessage(messaging.Message{ID: "message-1", Body: []byte(body)})
+
+	// Assert
+	assert.NoError(t, err)
+	mails := server.received()
+	if assert.Len(t, mails, 1) {
+		assert.Contains(t, mails[0], "Subject: Tiger Sights\r\n")
+		assert.Contains(t, mails[0], "Tiger_1 is found at {Lat: 12.34,Long: 56.78}")
+	}
+}
+
+func TestNotifier_ProcessMessage_OptedOut(t *testing.T) {
+	// Arrange
+	server := newFakeSMTPServer(t)
+	host, port := server.hostPort()
+	store := newMemoryStore()
+	store.preferences["ranger@example.com"] = models.NotificationPreference{Subscribed: false}
+	n := NewNotifier(NewSMTPMailer(host, port, "", "", "alerts@example.com"), store, "http://localhost:8080")
+
+	// Act
+	err := n.ProcessMessage(messaging.Message{ID: "message-1", Body: []byte(sightingMessage)})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Empty(t, server.received())
+	assert.Empty(t, store.digestItems)
+}
+
+func TestNotifier_Digest(t *testing.T) {
+	// Arrange
+	server := newFakeSMTPServer(t)
+	host, port := server.hostPort()
+	store := newMemoryStore()
+	store.preferences["ranger@example.com"] = models.NotificationPreference{Subscribed: true, Digest: true}
+	n := NewNotifier(NewSMTPMailer(host, port, "", "", "alerts@example.com"), store, "http://localhost:8080")
+
+	// Act
+	assert.NoError(t, n.ProcessMessage(messaging.Message{ID: "message-1", Body: []byte(sightingMessage)}))
+	assert.NoError(t, n.ProcessMessage(messaging.Message{ID: "message-2", Body: []byte(sightingMessage)}))
+	assert.Empty(t, server.received(), "digest recipients should not be mailed per sighting")
+	err := n.SendDigests()
+
+	// Assert
+	assert.NoError(t, err)
+	mails := server.received()
+	if assert.Len(t, mails, 1) {
+		assert.Contains(t, mails[0], "Subject: 2 new tiger sightings\r\n")
+		assert.Equal(t, 2, strings.Count(mails[0], "- Mufasa at latitude 12.34, longitude 56.78"))
+	}
+	assert.Empty(t, store.digestItems)
+
+	// Nothing is pending any more
+	assert.NoError(t, n.SendDigests())
+	assert.Len(t, server.received(), 1)
+}
+
+func TestNotifier_ProcessMessage_AccountEmails(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		subject  string
+		contains string
+	}{
+		{
+			name:     "email verification",
+			body:     `{"type": "email_verification", "recipient": "ranger@example.com", "username": "ranger", "tokenID": 3, "expiresAt": "2023-07-22T12:00:00Z"}`,
+			subject:  "Verify your email address",
+			contains: "http://localhost:8080/email/verify?token=",
+		},
+		{
+			name:     "password reset",
+			body:     `{"type": "password_reset", "recipient": "ranger@example.com", "username": "ranger", "tokenID": 3, "expiresAt": "2023-07-22T12:00:00Z"}`,
+			subject:  "Reset your password",
+			contains: "http://localhost:8080/password/reset",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			server := newFakeSMTPServer(t)
+			host, port := server.hostPort()
+			store := newMemoryStore()
+			store.tokens[3] = "hash of a token nobody knows"
+			// Account emails are sent even to users who opted out of notifications
+			store.preferences["ranger@example.com"] = models.NotificationPreference{Subscribed: false}
+			n := NewNotifier(NewSMTPMailer(host, port, "", "", "alerts@example.com"), store, "http://localhost:8080")
+
+			// Act
+			err := n.ProcessMessage(messaging.Message{ID: "message-1", Body: []byte(tt.body)})
+
+			// Assert
+			assert.NoError(t, err)
+			mails := server.received()
+			if !assert.Len(t, mails, 1) {
+				return
+			}
+			assert.Contains(t, mails[0], "To: ranger@example.com\r\n")
+			assert.Contains(t, mails[0], "Subject: "+tt.subject+"\r\n")
+			assert.Contains(t, mails[0], "Hello ranger,")
+			assert.Contains(t, mails[0], tt.contains)
+			assert.Contains(t, mails[0], "22 Jul 2023 12:00 UTC")
+			token := regexp.MustCompile(`[A-Za-z0-9_-]{43}`).FindString(mails[0])
+			assert.Equal(t, auth.HashUserToken(token), store.tokens[3], "The mailed token should be the one stored")
+		})
+	}
+}
+
+func TestNotifier_ProcessMessage_UsedAccountToken(t *testing.T) {
+	// Arrange
+	n := NewNotifier(failingMailer{}, newMemoryStore(), "http://localhost:8080")
+
+	// Act
+	err := n.ProcessMessage(messaging.Message{ID: "message-1", Body: []byte(`{"type": "password_reset", "recipient": "ranger@example.com", "tokenID": 3}`)})
+
+	// Assert
+	assert.NoError(t, err, "Tokens used or expired before the email is sent should not be mailed")
+}
+
+func TestNotifier_ProcessMessage_UnknownAccountEmail(t *testing.T) {
+	// Arrange
+	n := NewNotifier(failingMailer{}, newMemoryStore(), "http://localhost:8080")
+
+	// Act
+	err := n.ProcessMessage(messaging.Message{ID: "message-1", Body: []byte(`{"type": "welcome", "recipient": "ranger@example.com"}`)})
+
+	// Assert
+	assert.Error(t, err)
+	assert.True(t, messaging.IsPermanent(err))
+}
+
+func TestHeaderValue(t *testing.T) {
+	assert.Equal(t, "HelloBcc: someone@example.com", headerValue("Hello\r\nBcc: someone@example.com"))
+}
@@ -0,0 +1,165 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+
+	"tigerhall-kittens-app/pkg/messaging"
+)
+
+const (
+	// WebhookTimestampHeader holds the Unix time the webhook was sent at
+	WebhookTimestampHeader = "X-Tigerhall-Timestamp"
+	// WebhookSignatureHeader holds "sha256=" and the hex HMAC-SHA256 of the timestamp, a
+	// dot and the body, keyed with the secret of the alert, see SignWebhook
+	WebhookSignatureHeader = "X-Tigerhall-Signature"
+
+	webhookTimeout = 10 * time.Second
+)
+
+// errAddressNotPublic is returned when a webhook resolves to an address of a private network.
+var errAddressNotPublic = errors.New("webhook address is not public")
+
+// nonPublicPrefixes are the special purpose ranges of the IANA registries webhooks may not
+// be sent to. IPv4 addresses mapped to IPv6 are checked as IPv4.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // this network
+	netip.MustParsePrefix("10.0.0.0/8"),      // private
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier grade NAT
+	netip.MustParsePrefix("127.0.0.0/8"),     // loopback
+	netip.MustParsePrefix("169.254.0.0/16"),  // link local, cloud metadata services
+	netip.MustParsePrefix("172.16.0.0/12"),   // private
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("192.88.99.0/24"),  // 6to4 relay anycast
+	netip.MustParsePrefix("192.168.0.0/16"),  // private
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("224.0.0.0/4"),     // multicast
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved and broadcast
+	netip.MustParsePrefix("::/128"),          // unspecified
+	netip.MustParsePrefix("::1/128"),         // loopback
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, embeds any IPv4 address
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local NAT64
+	netip.MustParsePrefix("100::/64"),        // discard
+	netip.MustParsePrefix("2001::/23"),       // IETF protocol assignments, Teredo
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4, embeds any IPv4 address
+	netip.MustParsePrefix("fc00::/7"),        // unique local
+	netip.MustParsePrefix("fe80::/10"),       // link local
+	netip.MustParsePrefix("ff00::/8"),        // multicast
+}
+
+// Webhook is a JSON payload posted to URL, signed with Secret.
+type Webhook struct {
+	URL     string
+	Secret  string
+	Payload []byte
+}
+
+// WebhookSender sends webhooks. Webhooks which will never be accepted fail with a
+// permanent error, see messaging.Permanent.
+type WebhookSender interface {
+	Send(webhook Webhook) error
+}
+
+// httpWebhookSender posts webhooks over HTTP.
+type httpWebhookSender struct {
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewHTTPWebhookSender creates a WebhookSender posting to public addresses only, so that
+// webhooks cannot reach the services in the network of the notifier. Redirects are not
+// followed.
+func NewHTTPWebhookSender() WebhookSender {
+	dialer := &net.Dialer{Timeout: webhookTimeout, Control: dialPublicOnly}
+	return newHTTPWebhookSender(&http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: webhookTimeout,
+		},
+	})
+}
+
+func newHTTPWebhookSender(client *http.Client) *httpWebhookSender {
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &httpWebhookSender{client: client, now: time.Now}
+}
+
+func (s *httpWebhookSender) Send(webhook Webhook) error {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(webhook.Payload))
+	if err != nil {
+		return messaging.Permanent(fmt.Errorf("invalid webhook URL: %v", err))
+	}
+
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(webhook.Secret, timestamp, webhook.Payload))
+
+	resp, err := s.client.Do(req)
+	if errors.Is(err, errAddressNotPublic) {
+		return messaging.Permanent(fmt.Errorf("failed to send webhook to %s: %v", req.URL.Host, err))
+	} else if err != nil {
+		return fmt.Errorf("failed to send webhook to %s: %v", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("webhook %s responded with %d", req.URL.Host, resp.StatusCode)
+	default:
+		return messaging.Permanent(fmt.Errorf("webhook %s responded with %d", req.URL.Host, resp.StatusCode))
+	}
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of the timestamp, a dot and the payload, keyed
+// with the secret. Receivers compute it to check that a webhook comes from the notifier,
+// and reject old timestamps so that a webhook cannot be replayed.
+func SignWebhook(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// dialPublicOnly refuses to connect to the addresses in nonPublicPrefixes. It runs once
+// the host has been resolved, so it also applies to host names.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return errAddressNotPublic
+	}
+	// Prefixes never contain zoned addresses
+	ip = ip.Unmap().WithZone("")
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return errAddressNotPublic
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package notifier
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"tigerhall-kittens-app/pkg/messaging"
+)
+
+func TestHTTPWebhookSender_Send(t *testing.T) {
+	// Arrange
+	var header http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sender := newHTTPWebhookSender(server.Client())
+	sender.now = func() time.Time { return time.Unix(1690000000, 0) }
+	payload := []byte(`{"alertID":2}`)
+
+	// Act
+	err := sender.Send(Webhook{URL: server.URL, Secret: "secret", Payload: payload})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, payload, body)
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, "1690000000", header.Get(WebhookTimestampHeader))
+	assert.Equal(t, "sha256="+SignWebhook("secret", "1690000000", payload), header.Get(WebhookSignatureHeader))
+	assert.NotEqual(t, SignWebhook("other", "1690000000", payload), SignWebhook("secret", "1690000000", payload))
+}
+
+func TestHTTPWebhookSender_Status(t *testing.T) {
+	tests := []struct {
+		status    int
+		permanent bool
+	}{
+		{status: http.StatusFound, permanent: true},
+		{status: http.StatusNotFound, permanent: true},
+		{status: http.StatusTooManyRequests},
+		{status: http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			// Arrange
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Redirects are not followed
+				w.Header().Set("Location", "/elsewhere")
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			// Act
+			err := newHTTPWebhookSender(server.Client()).Send(Webhook{URL: server.URL, Payload: []byte(`{}`)})
+
+			// Assert
+			assert.Error(t, err)
+			assert.Equal(t, tt.permanent, messaging.IsPermanent(err))
+		})
+	}
+}
+
+func TestHTTPWebhookSender_PrivateAddress(t *testing.T) {
+	// Arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("private addresses should not be reached")
+	}))
+	defer server.Close()
+
+	// Act
+	err := NewHTTPWebhookSender().Send(Webhook{URL: server.URL, Payload: []byte(`{}`)})
+
+	// Assert
+	assert.Error(t, err)
+	assert.True(t, messaging.IsPermanent(err), "Webhooks to private addresses should not be retried")
+}
+
+func TestDialPublicOnly(t *testing.T) {
+	tests := []struct {
+		address string
+		public  bool
+	}{
+		{address: "93.184.216.34", public: true},
+		{address: "2606:2800:220:1:248:1893:25c8:1946", public: true},
+		{address: "127.0.0.1"},
+		{address: "10.1.2.3"},
+		{address: "172.16.0.1"},
+		{address: "192.168.1.1"},
+		{address: "169.254.169.254"},
+		{address: "100.64.0.1"},
+		{address: "0.1.2.3"},
+		{address: "198.18.0.1"},
+		{address: "255.255.255.255"},
+		{address: "::1"},
+		{address: "::"},
+		{address: "fd00::1"},
+		{address: "fe80::1"},
+		{address: "fe80::1%eth0"},
+		{address: "::ffff:127.0.0.1"},
+		{address: "64:ff9b::a9fe:a9fe"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.address, func(t *testing.T) {
+			// Act
+			err := dialPublicOnly("tcp", net.JoinHostPort(tt.address, "443"), nil)
+
+			// Assert
+			if tt.public {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, errAddressNotPublic)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is how many Take calls the memory store handles between removing the
+// buckets and failures that expired.
+const sweepInterval = 1000
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// expires is when the bucket is full again and can be forgotten
+	expires time.Time
+}
+
+type failures struct {
+	count   int
+	expires time.Time
+}
+
+// memoryStore keeps the buckets in the memory of a single server.
+type memoryStore struct {
+	mu       sync.Mutex
+	buckets  map[string]*bucket
+	failures map[string]*failures
+	locks    map[string]time.Time
+	takes    int
+	now      func() time.Time
+}
+
+// NewMemoryStore creates a Store keeping the buckets in memory, every instance of the
+// server limits its own clients.
+func NewMemoryStore() Store {
+	return newMemoryStore(time.Now)
+}
+
+func newMemoryStore(now func() time.Time) *memoryStore {
+	return &memoryStore{
+		buckets:  map[string]*bucket{},
+		failures: map[string]*failures{},
+		locks:    map[string]time.Time{},
+		now:      now,
+	}
+}
+
+func (m *memoryStore) Take(key string, limit Limit) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.takes++
+	if m.takes%sweepInterval == 0 {
+		m.sweep(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		m.buckets[key] = b
+	}
+
+	tokens, allowed, retryAfter := limit.take(b.tokens, now.Sub(b.updated))
+	b.tokens = tokens
+	b.updated = now
+	b.expires = now.Add(limit.refillTime())
+	return allowed, retryAfter, nil
+}
+
+func (m *memoryStore) AddFailure(key string, window time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	f, ok := m.failures[key]
+	if !ok || !now.Before(f.expires) {
+		f = &failures{expires: now.Add(window)}
+		m.failures[key] = f
+	}
+	f.count++
+	return f.count, nil
+}
+
+func (m *memoryStore) ResetFailures(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.failures, key)
+	return nil
+}
+
+func (m *memoryStore) Lock(key string, d time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.locks[key] = m.now().Add(d)
+	return nil
+}
+
+func (m *memoryStore) LockedFor(key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expires, ok := m.locks[key]
+	if !ok {
+		return 0, nil
+	}
+	lockedFor := expires.Sub(m.now())
+	if lockedFor <= 0 {
+		delete(m.locks, key)
+		return 0, nil
+	}
+	return lockedFor, nil
+}
+
+// sweep removes what expired, so that the store does not grow with every client ever seen.
+func (m *memoryStore) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if !now.Before(b.expires) {
+			delete(m.buckets, key)
+		}
+	}
+	for key, f := range m.failures {
+		if !now.Before(f.expires) {
+			delete(m.failures, key)
+		}
+	}
+	for key, expires := range m.locks {
+		if !now.Before(expires) {
+			delete(m.locks, key)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Limit is a token bucket: it holds up to Burst tokens and is refilled with Rate tokens
+// per second. Every request takes one token.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute returns the Limit allowing requests per minute on average and bursts of burst requests.
+func PerMinute(requests, burst int) Limit {
+	return Limit{Rate: float64(requests) / 60, Burst: burst}
+}
+
+// Enabled reports whether the limit allows any requests, a zero Limit is not enforced.
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// refillTime is how long an empty bucket takes to fill up.
+func (l Limit) refillTime() time.Duration {
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+// take takes a token from a bucket holding tokens elapsed ago and returns the tokens left.
+// When there is no token it returns false and how long until there is one.
+func (l Limit) take(tokens float64, elapsed time.Duration) (float64, bool, time.Duration) {
+	tokens = math.Min(float64(l.Burst), tokens+elapsed.Seconds()*l.Rate)
+	if tokens < 1 {
+		return tokens, false, time.Duration((1 - tokens) / l.Rate * float64(time.Second))
+	}
+	return tokens - 1, true, 0
+}
+
+// Store keeps the token buckets and the failed logins. With the Redis store they are
+// shared by every instance of the server.
+type Store interface {
+	// Take takes a token from the bucket of key. When the bucket is empty it returns
+	// false and how long until a token is available.
+	Take(key string, limit Limit) (bool, time.Duration, error)
+	// AddFailure counts a failure for key and returns the failures counted since the
+	// first one, failures are forgotten window after the first one.
+	AddFailure(key string, window time.Duration) (int, error)
+	ResetFailures(key string) error
+	// Lock locks key for d, LockedFor returns how long key is still locked.
+	Lock(key string, d time.Duration) error
+	LockedFor(key string) (time.Duration, error)
+}
+
+// Lockout locks an account after repeated failed logins. The lock expires on its own.
+type Lockout struct {
+	store       Store
+	maxFailures int
+	window      time.Duration
+	duration    time.Duration
+}
+
+// NewLockout creates a Lockout locking an account for duration once maxFailures logins
+// failed within window.
+func NewLockout(store Store, maxFailures int, window, duration time.Duration) *Lockout {
+	return &Lockout{
+		store:       store,
+		maxFailures: maxFailures,
+		window:      window,
+		duration:    duration,
+	}
+}
+
+// LockedFor returns how long the account is still locked.
+func (l *Lockout) LockedFor(account string) (time.Duration, error) {
+	return l.store.LockedFor("lockout:locked:" + account)
+}
+
+// Fail counts a failed login of the account and locks it when there were too many.
+// It returns how long the account has been locked for, if it has been.
+func (l *Lockout) Fail(account string) (time.Duration, error) {
+	failures, err := l.store.AddFailure("lockout:failures:"+account, l.window)
+	if err != nil {
+		return 0, err
+	}
+	if failures < l.maxFailures {
+		return 0, nil
+	}
+
+	if err := l.store.Lock("lockout:locked:"+account, l.duration); err != nil {
+		return 0, err
+	}
+	// The account gets maxFailures attempts again once the lock expired
+	if err := l.store.ResetFailures("lockout:failures:" + account); err != nil {
+		return 0, err
+	}
+	return l.duration, nil
+}
+
+// Reset forgets the failed logins of the account after a successful login.
+func (l *Lockout) Reset(account string) error {
+	return l.store.ResetFailures("lockout:failures:" + account)
+}
+
+// ClientIP returns the IP address of the client of the request. The X-Forwarded-For
+// header is only used with trustForwardedFor, since clients can set it to anything
+// unless a proxy in front of the server overwrites it.
+func ClientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+			// The last address is the one added by the proxy
+			addresses := strings.Split(forwardedFor, ",")
+			return strings.TrimSpace(addresses[len(addresses)-1])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,164 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is a clock for the memory store that only moves when told to.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newRedisTestStore(t *testing.T) Store {
+	server := miniredis.RunT(t)
+	store, err := NewRedisStore(server.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("failed to connect to redis: %v", err)
+	}
+	return store
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	// Arrange
+	clock := &fakeClock{now: time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC)}
+	store := newMemoryStore(clock.Now)
+	limit := PerMinute(60, 2)
+
+	// Act & Assert
+	allowed, _, _ := store.Take("ip:1.2.3.4", limit)
+	assert.True(t, allowed)
+	allowed, _, _ = store.Take("ip:1.2.3.4", limit)
+	assert.True(t, allowed)
+
+	// The bucket is empty after the burst
+	allowed, retryAfter, err := store.Take("ip:1.2.3.4", limit)
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, time.Second, retryAfter)
+
+	// Other keys have their own bucket
+	allowed, _, _ = store.Take("ip:5.6.7.8", limit)
+	assert.True(t, allowed)
+
+	// A token is added every second
+	clock.now = clock.now.Add(time.Second)
+	allowed, _, _ = store.Take("ip:1.2.3.4", limit)
+	assert.True(t, allowed)
+}
+
+func TestMemoryStore_Sweep(t *testing.T) {
+	// Arrange
+	clock := &fakeClock{now: time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC)}
+	store := newMemoryStore(clock.Now)
+	store.Take("ip:1.2.3.4", PerMinute(60, 2))
+
+	// Act
+	clock.now = clock.now.Add(time.Minute)
+	store.sweep(clock.now)
+
+	// Assert
+	assert.Empty(t, store.buckets, "full buckets should be forgotten")
+}
+
+func TestRedisStore_Take(t *testing.T) {
+	// Arrange
+	store := newRedisTestStore(t)
+	limit := PerMinute(60, 2)
+
+	// Act & Assert
+	allowed, _, err := store.Take("ip:1.2.3.4", limit)
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+	allowed, _, _ = store.Take("ip:1.2.3.4", limit)
+	assert.True(t, allowed)
+
+	allowed, retryAfter, err := store.Take("ip:1.2.3.4", limit)
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+	assert.True(t, retryAfter > 0 && retryAfter <= time.Second, "retry after %v", retryAfter)
+
+	allowed, _, _ = store.Take("ip:5.6.7.8", limit)
+	assert.True(t, allowed)
+}
+
+func TestLockout(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store { return NewMemoryStore() },
+		"redis":  newRedisTestStore,
+	}
+
+	for name, newStore := range stores {
+		newStore := newStore
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			lockout := NewLockout(newStore(t), 3, time.Hour, 15*time.Minute)
+
+			// Act & Assert
+			for i := 0; i < 2; i++ {
+				lockedFor, err := lockout.Fail("ranger@example.com")
+				assert.NoError(t, err)
+				assert.Zero(t, lockedFor)
+			}
+			lockedFor, _ := lockout.LockedFor("ranger@example.com")
+			assert.Zero(t, lockedFor, "the account should not be locked before the last attempt")
+
+			lockedFor, err := lockout.Fail("ranger@example.com")
+			assert.NoError(t, err)
+			assert.Equal(t, 15*time.Minute, lockedFor)
+
+			lockedFor, err = lockout.LockedFor("ranger@example.com")
+			assert.NoError(t, err)
+			assert.True(t, lockedFor > 14*time.Minute && lockedFor <= 15*time.Minute, "locked for %v", lockedFor)
+
+			// Other accounts are not affected
+			lockedFor, _ = lockout.LockedFor("researcher@example.com")
+			assert.Zero(t, lockedFor)
+		})
+	}
+}
+
+func TestLockout_Reset(t *testing.T) {
+	// Arrange
+	lockout := NewLockout(NewMemoryStore(), 2, time.Hour, 15*time.Minute)
+
+	// Act
+	lockout.Fail("ranger@example.com")
+	assert.NoError(t, lockout.Reset("ranger@example.com"))
+	lockedFor, _ := lockout.Fail("ranger@example.com")
+
+	// Assert
+	assert.Zero(t, lockedFor, "failures before a successful login should be forgotten")
+}
+
+func TestLockout_Expires(t *testing.T) {
+	// Arrange
+	clock := &fakeClock{now: time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC)}
+	lockout := NewLockout(newMemoryStore(clock.Now), 1, time.Hour, 15*time.Minute)
+	lockout.Fail("ranger@example.com")
+
+	// Act
+	clock.now = clock.now.Add(15 * time.Minute)
+	lockedFor, err := lockout.LockedFor("ranger@example.com")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Zero(t, lockedFor)
+}
+
+func TestClientIP(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "/tigers", nil)
+	r.RemoteAddr = "10.0.0.1:54321"
+	r.Header.Set("X-Forwarded-For", "1.2.3.4, 5.6.7.8")
+
+	assert.Equal(t, "10.0.0.1", ClientIP(r, false))
+	assert.Equal(t, "5.6.7.8", ClientIP(r, true))
+}
@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// keyPrefix namespaces the keys of the rate limits in Redis.
+const keyPrefix = "ratelimit:"
+
+// takeScript takes a token from the bucket in KEYS[1], a hash of the tokens left and
+// when they were counted. ARGV are the rate per second, the burst, the current time and
+// the time the bucket takes to fill up, both in milliseconds. It returns whether a token
+// was taken and otherwise the milliseconds until one is available.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(bucket[1])
+local updated = tonumber(bucket[2])
+if tokens == nil or updated == nil then
+	tokens = burst
+	updated = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - updated) / 1000 * rate)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000)
+end
+
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "updated", tostring(now))
+redis.call("PEXPIRE", KEYS[1], ARGV[4])
+return {allowed, wait}
+`)
+
+// addFailureScript counts a failure in KEYS[1], which expires ARGV[1] milliseconds
+// after the first failure.
+var addFailureScript = redis.NewScript(`
+local failures = redis.call("INCR", KEYS[1])
+if failures == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return failures
+`)
+
+// redisStore keeps the buckets in Redis, or any server speaking its protocol.
+type redisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore creates a Store keeping the buckets in the Redis server at addr, so that
+// the limits apply across every instance of the server.
+func NewRedisStore(addr, password string, db int) (Store, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %v", err)
+	}
+
+	return &redisStore{client: client}, nil
+}
+
+func (s *redisStore) Take(key string, limit Limit) (bool, time.Duration, error) {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	result, err := takeScript.Run(context.Background(), s.client, []string{keyPrefix + key},
+		limit.Rate, limit.Burst, now, limit.refillTime().Milliseconds()+1).Int64Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to take token: %v", err)
+	}
+
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
+
+func (s *redisStore) AddFailure(key string, window time.Duration) (int, error) {
+	failures, err := addFailureScript.Run(context.Background(), s.client, []string{keyPrefix + key}, window.Milliseconds()).Int()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count failure: %v", err)
+	}
+
+	return failures, nil
+}
+
+func (s *redisStore) ResetFailures(key string) error {
+	if err := s.client.Del(context.Background(), keyPrefix+key).Err(); err != nil {
+		return fmt.Errorf("failed to reset failures: %v", err)
+	}
+
+	return nil
+}
+
+func (s *redisStore) Lock(key string, d time.Duration) error {
+	if err := s.client.Set(context.Background(), keyPrefix+key, 1, d).Err(); err != nil {
+		return fmt.Errorf("failed to lock: %v", err)
+	}
+
+	return nil
+}
+
+func (s *redisStore) LockedFor(key string) (time.Duration, error) {
+	ttl, err := s.client.PTTL(context.Background(), keyPrefix+key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check lock: %v", err)
+	}
+	// PTTL is negative when the key does not exist
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
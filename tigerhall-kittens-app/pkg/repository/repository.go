@@ -1,22 +1,124 @@
 package repository
 
 import (
+	"time"
+
 	"tigerhall-kittens-app/pkg/models"
 	"tigerhall-kittens-app/pkg/repository/store"
 )
 
+var (
+	ErrNotFound        = store.ErrNotFound
+	ErrVersionConflict = store.ErrVersionConflict
+	ErrAlreadyExists   = store.ErrAlreadyExists
+)
+
 type TigerRepository interface {
 	CreateUser(user *models.User) error
 	GetUserByEmail(email string) (*models.User, error)
+	GetUsersByEmails(emails []string) ([]*models.User, error)
+	GetUserByID(userID int) (*models.User, error)
+	MarkEmailVerified(userID int) error
+	UpdateUserPassword(userID int, hashedPassword string) error
+	CreateUserToken(token *models.UserToken) error
+	LockUserToken(purpose, tokenHash string) (*models.UserToken, error)
+	UseUserTokens(userID int, purpose string) error
+	RenewUserToken(tokenID int, tokenHash string) (bool, error)
+	GetUserRoles(userID int) ([]string, error)
+	GrantUserRole(userID int, role string) error
+	RevokeUserRole(userID int, role string) error
+	CreateRefreshToken(token *models.RefreshToken) error
+	LockRefreshToken(tokenHash string) (*models.RefreshToken, error)
+	RevokeRefreshToken(tokenID int) error
+	RevokeUserRefreshTokens(userID int) error
+	RevokeAccessToken(tokenID string, expiresAt time.Time) error
+	IsAccessTokenRevoked(tokenID string) (bool, error)
 	CreateTiger(tiger *models.Tiger) error
-	GetAllTigersWithPagination(page, pageSize int) ([]*models.Tiger, int, error)
+	GetTigerByID(tigerID int) (*models.Tiger, error)
+	UpdateTiger(tiger *models.Tiger) error
+	DeleteTiger(tigerID int) error
+	LockTiger(tigerID int) (*models.Tiger, error)
+	UpdateTigerLastSeen(tigerSighting *models.TigerSighting) error
+	GetAllTigersWithPagination(filter models.TigerFilter, page, pageSize int) ([]*models.Tiger, error)
+	GetTotalTigerCount(filter models.TigerFilter, estimate bool) (int, error)
+	GetTigersInBoundingBox(box models.BoundingBox) ([]*models.Tiger, error)
+	GetTigersAfter(filter models.TigerFilter, after *models.Cursor, limit int) ([]*models.Tiger, error)
+	GetTigersByIDs(tigerIDs []int) ([]*models.Tiger, error)
 	CreateTigerSighting(tigerSighting *models.TigerSighting) error
+	GetTigerSightingByID(sightingID int) (*models.TigerSighting, error)
+	GetLegacySightingImages(limit int) ([]*models.TigerSighting, error)
+	MoveLegacySightingImage(sightingID int, imageKey string) error
 	GetTigerSightingsByID(tigerID int) ([]*models.TigerSighting, error)
-	GetPreviousTigerSighting(tigerID int) (*models.TigerSighting, error)
-	GetTigerSightingsByIDWithPagination(tigerID, page, pageSize int) ([]*models.TigerSighting, int, error)
+	GetAdjacentTigerSightings(tigerID int, reporterEmail string, timestamp time.Time) (before, after *models.TigerSighting, err error)
+	GetTigerSightingsBetween(tigerID int, reporterEmail string, from, to time.Time) ([]*models.TigerSighting, error)
+	StreamTigerSightings(tigerID int, fn func(*models.TigerSighting) error) error
+	StreamTigerSightingsBetween(from, to time.Time, fn func(*models.TigerSighting) error) error
+	GetDailySightingCounts(from, to time.Time) ([]*models.DailySightings, error)
+	GetMostActiveTigers(from, to time.Time, limit int) ([]*models.ActiveTiger, error)
+	GetTigerSightingsInBoundingBox(box models.BoundingBox, from, to time.Time, limit int) ([]*models.TigerSighting, error)
+	GetTigerSightingsByIDWithPagination(tigerID, page, pageSize int) ([]*models.TigerSighting, error)
+	GetTigerSightingsCountByID(tigerID int, estimate bool) (int, error)
+	GetTigerSightingsAfter(tigerID int, after *models.Cursor, limit int) ([]*models.TigerSighting, error)
+	GetLatestTigerSightings(tigerIDs []int, limit int) ([]*models.TigerSighting, error)
+	LockTigerSighting(sightingID int) (*models.TigerSighting, error)
+	UpdateTigerSightingStatus(sightingID int, status string) error
+	RefreshTigerLastSeen(tigerID int) error
+	GetModerationQueue(page, pageSize int) ([]*models.TigerSighting, int, error)
+	CreateSightingReview(review *models.SightingReview) error
+	CreateSightingFlag(flag *models.SightingFlag) error
+	ResolveSightingFlags(sightingID int) error
+	CountReporterSightings(email string) (approved, rejected int, err error)
+	CreateSightingImages(images []*models.SightingImage) error
+	GetSightingImages(sightingIDs []int) ([]*models.SightingImage, error)
+	GetSightingImage(sightingID, position int, rendition, format string) (*models.SightingImage, error)
+	IsNotificationDelivered(messageID, recipient string) (bool, error)
+	MarkNotificationDelivered(messageID, recipient string) error
+	GetNotificationPreference(email string, tigerID int) (*models.NotificationPreference, error)
+	SetTigerSubscription(email string, tigerID int, subscribed bool) error
+	GetTigerFollowers(tigerID int) ([]string, error)
+	GetTigerSubscriptions(email string) ([]*models.TigerSubscription, error)
+	SetDigestMode(email string, digest bool) error
+	AddDigestItem(messageID string, item *models.DigestItem) error
+	GetPendingDigestItems() ([]*models.DigestItem, error)
+	MarkDigestItemsSent(recipient string, lastID int) error
+	CreateSightingAlert(alert *models.SightingAlert, area models.BoundingBox) error
+	GetSightingAlerts(email string) ([]*models.SightingAlert, error)
+	GetSightingAlertsAt(coordinates models.Coordinates, tigerID int) ([]*models.SightingAlert, error)
+	DeleteSightingAlert(alertID int, email string) error
+	QueueWebhookDelivery(messageID string, alertID int, payload []byte) error
+	ClaimWebhookDeliveries(limit int, lease time.Duration) ([]*models.WebhookDelivery, error)
+	MarkWebhookDelivered(id int64) error
+	RetryWebhookDelivery(id int64, delay time.Duration, lastError string) error
+	FailWebhookDelivery(id int64, lastError string) error
+	CreateOutboxMessage(payload []byte) error
+	GetPendingOutboxMessages(limit int) ([]*models.OutboxMessage, error)
+	MarkOutboxMessageSent(id int64) error
+	// WithTx runs fn against a repository bound to a single transaction,
+	// committing when fn returns nil and rolling back otherwise.
+	WithTx(fn func(tx TigerRepository) error) error
+	// WithSnapshot runs fn against a repository bound to a read only transaction,
+	// so that every query of fn sees the same state of the database.
+	WithSnapshot(fn func(tx TigerRepository) error) error
+}
+
+// postgresRepository adapts the transaction API of the store to TigerRepository.
+type postgresRepository struct {
+	*store.PostgresRepository
+}
+
+func (p postgresRepository) WithTx(fn func(tx TigerRepository) error) error {
+	return p.PostgresRepository.WithTx(func(tx *store.PostgresRepository) error {
+		return fn(postgresRepository{tx})
+	})
+}
+
+func (p postgresRepository) WithSnapshot(fn func(tx TigerRepository) error) error {
+	return p.PostgresRepository.WithSnapshot(func(tx *store.PostgresRepository) error {
+		return fn(postgresRepository{tx})
+	})
 }
 
 func NewPostgresRepository(connection string) (TigerRepository, error) {
 	db, err := store.NewPostgresDB(connection)
-	return store.NewPostgresRepository(db), err
+	return postgresRepository{store.NewPostgresRepository(db)}, err
 }
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// selectQuery composes a SELECT statement from fixed SQL fragments. Values never end up in
+// the SQL, they are passed as arguments and referred to by numbered placeholders.
+type selectQuery struct {
+	columns    string
+	from       string
+	conditions []string
+	order      []string
+	limit      string
+	offset     string
+	args       []interface{}
+}
+
+func newSelectQuery(columns, from string) *selectQuery {
+	return &selectQuery{columns: columns, from: from}
+}
+
+// arg adds the value to the arguments and returns its placeholder.
+func (q *selectQuery) arg(value interface{}) string {
+	q.args = append(q.args, value)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+// where adds a condition. Its %s verbs are replaced by the placeholders of the values, in
+// order, so a literal % has to be written %%.
+func (q *selectQuery) where(condition string, values ...interface{}) *selectQuery {
+	placeholders := make([]interface{}, len(values))
+	for i, value := range values {
+		placeholders[i] = q.arg(value)
+	}
+	q.conditions = append(q.conditions, fmt.Sprintf(condition, placeholders...))
+	return q
+}
+
+// orderBy adds terms to the ORDER BY clause.
+func (q *selectQuery) orderBy(terms ...string) *selectQuery {
+	q.order = append(q.order, terms...)
+	return q
+}
+
+func (q *selectQuery) limitTo(limit int) *selectQuery {
+	q.limit = q.arg(limit)
+	return q
+}
+
+func (q *selectQuery) offsetBy(offset int) *selectQuery {
+	q.offset = q.arg(offset)
+	return q
+}
+
+// build returns the SQL of the query and its arguments.
+func (q *selectQuery) build() (string, []interface{}) {
+	var sql strings.Builder
+	sql.WriteString("SELECT " + q.columns + " FROM " + q.from)
+	if len(q.conditions) > 0 {
+		sql.WriteString(" WHERE " + strings.Join(q.conditions, " AND "))
+	}
+	if len(q.order) > 0 {
+		sql.WriteString(" ORDER BY " + strings.Join(q.order, ", "))
+	}
+	if q.limit != "" {
+		sql.WriteString(" LIMIT " + q.limit)
+	}
+	if q.offset != "" {
+		sql.WriteString(" OFFSET " + q.offset)
+	}
+	return sql.String(), q.args
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern, so that the value only matches itself.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -1,38 +1,130 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"tigerhall-kittens-app/pkg/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrNotFound is returned when the requested record does not exist or has been deleted.
+	ErrNotFound = errors.New("record not found")
+	// ErrVersionConflict is returned when an update is based on a stale version of a record.
+	ErrVersionConflict = errors.New("record has been modified")
+	// ErrAlreadyExists is returned when a record violates a unique constraint.
+	ErrAlreadyExists = errors.New("record already exists")
 )
 
-type postgresRepository struct {
-	db *sql.DB
+// isUniqueViolation reports whether err is a unique constraint violation.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// dbtx is implemented by both *sql.DB and *sql.Tx, so the same queries
+// can run inside and outside of a transaction.
+type dbtx interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type PostgresRepository struct {
+	db dbtx
+	// conn is nil when the repository is bound to a transaction
+	conn *sql.DB
+}
+
+func NewPostgresRepository(db *sql.DB) *PostgresRepository {
+	return &PostgresRepository{db: db, conn: db}
+}
+
+// WithTx runs fn with a repository bound to a single database transaction.
+// The transaction is committed when fn returns nil and rolled back otherwise,
+// in which case the error of fn is returned as is. Calling WithTx on a
+// repository that is already bound to a transaction reuses that transaction.
+func (p *PostgresRepository) WithTx(fn func(tx *PostgresRepository) error) error {
+	if p.conn == nil {
+		return fn(p)
+	}
+
+	tx, err := p.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	if err := fn(&PostgresRepository{db: tx}); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("failed to rollback transaction: %v", rollbackErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
 }
 
-func NewPostgresRepository(db *sql.DB) *postgresRepository {
-	return &postgresRepository{db: db}
+// WithSnapshot runs fn with a repository bound to a read only transaction seeing the
+// database as it was when the transaction started, so that a page of a list and its total
+// are consistent with each other. Calling WithSnapshot on a repository that is already
+// bound to a transaction reuses that transaction.
+func (p *PostgresRepository) WithSnapshot(fn func(tx *PostgresRepository) error) error {
+	if p.conn == nil {
+		return fn(p)
+	}
+
+	tx, err := p.conn.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	// Nothing has been written, the transaction only has to be ended
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			log.Printf("failed to end read only transaction: %v", err)
+		}
+	}()
+
+	return fn(&PostgresRepository{db: tx})
 }
 
-func (p *postgresRepository) CreateUser(user *models.User) error {
+// CreateUser saves the unverified user and sets its ID. ErrAlreadyExists is returned
+// when another user has the same email.
+func (p *PostgresRepository) CreateUser(user *models.User) error {
 	query := `
 		INSERT INTO users (username, email, password)
 		VALUES ($1, $2, $3)
+		RETURNING id
 	`
-	_, err := p.db.Exec(query, user.Username, user.Email, user.Password)
+	err := p.db.QueryRow(query, user.Username, user.Email, user.Password).Scan(&user.ID)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrAlreadyExists
+		}
 		return err
 	}
 
 	return nil
 }
 
-func (p *postgresRepository) CreateTiger(tiger *models.Tiger) error {
+// CreateTiger saves the tiger and sets its ID and version.
+func (p *PostgresRepository) CreateTiger(tiger *models.Tiger) error {
 	query := `
 		INSERT INTO tigers (name, date_of_birth, last_seen, lat, long)
 		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, version
 	`
-	_, err := p.db.Exec(query, tiger.Name, tiger.DateOfBirth, tiger.LastSeen, tiger.Lat, tiger.Long)
+	err := p.db.QueryRow(query, tiger.Name, tiger.DateOfBirth, tiger.LastSeen, tiger.Lat, tiger.Long).Scan(&tiger.ID, &tiger.Version)
 	if err != nil {
 		return err
 	}
@@ -40,67 +132,391 @@ func (p *postgresRepository) CreateTiger(tiger *models.Tiger) error {
 	return nil
 }
 
-func (p *postgresRepository) GetAllTigersWithPagination(page, pageSize int) ([]*models.Tiger, int, error) {
+// GetAllTigersWithPagination returns the page of the tigers kept by the filter numbered
+// from 1, in the order of the filter. Use GetTotalTigerCount for the total.
+func (p *PostgresRepository) GetAllTigersWithPagination(filter models.TigerFilter, page, pageSize int) ([]*models.Tiger, error) {
+	q := filterTigers(tigerColumns, filter)
+	if err := sortTigers(q, filter, nil); err != nil {
+		return nil, err
+	}
+
+	offset := (page - 1) * pageSize
+
+	query, args := q.limitTo(pageSize).offsetBy(offset).build()
+	return p.queryTigers(query, args...)
+}
+
+// GetTotalTigerCount counts the tigers kept by the filter, or asks the query planner for
+// an estimate when estimate is set.
+func (p *PostgresRepository) GetTotalTigerCount(filter models.TigerFilter, estimate bool) (int, error) {
+	if estimate {
+		query, args := filterTigers("id", filter).build()
+		return p.estimateRows(query, args...)
+	}
+
+	query, args := filterTigers("COUNT(*)", filter).build()
+
+	var totalCount int
+	err := p.db.QueryRow(query, args...).Scan(&totalCount)
+	if err != nil {
+		return 0, err
+	}
+
+	return totalCount, nil
+}
+
+// tigerColumns are the columns scanned by queryTigers.
+const tigerColumns = "id, name, date_of_birth, last_seen, lat, long, version"
+
+// tigerSortColumns are the columns of the fields tigers are sorted by, names are sorted in
+// any case. Only these columns end up in ORDER BY clauses.
+var tigerSortColumns = map[string]string{
+	models.TigerSortLastSeen:    "last_seen",
+	models.TigerSortName:        "LOWER(name)",
+	models.TigerSortDateOfBirth: "date_of_birth",
+}
+
+// filterTigers returns the query of the columns of the tigers kept by the filter which
+// have not been deleted.
+func filterTigers(columns string, filter models.TigerFilter) *selectQuery {
+	q := newSelectQuery(columns, "tigers").where("deleted_at IS NULL")
+	if filter.NamePrefix != "" {
+		q.where("name ILIKE %s", escapeLike(filter.NamePrefix)+"%")
+	}
+	if filter.NameLike != "" {
+		// The pg_trgm similarity operator, names sharing enough trigrams match
+		q.where("name %% %s", filter.NameLike)
+	}
+	if filter.BornFrom != nil {
+		q.where("date_of_birth >= %s", *filter.BornFrom)
+	}
+	if filter.BornTo != nil {
+		q.where("date_of_birth <= %s", *filter.BornTo)
+	}
+	if filter.SeenFrom != nil {
+		q.where("last_seen >= %s", *filter.SeenFrom)
+	}
+	if filter.SeenTo != nil {
+		q.where("last_seen <= %s", *filter.SeenTo)
+	}
+	if box := filter.Region; box != nil {
+		q.where("point(long, lat) <@ box(point(%s, %s), point(%s, %s))", box.MinLong, box.MinLat, box.MaxLong, box.MaxLat)
+	}
+	return q
+}
+
+// sortTigers orders the tigers by the sort field of the filter, the ID breaking the ties,
+// and keeps those following the cursor unless it is nil.
+func sortTigers(q *selectQuery, filter models.TigerFilter, after *models.Cursor) error {
+	sort := filter.Sort
+	if sort == "" {
+		sort = models.TigerSortLastSeen
+	}
+	column, ok := tigerSortColumns[sort]
+	if !ok {
+		return fmt.Errorf("unknown tiger sort %q", filter.Sort)
+	}
+
+	direction, comparison := "DESC", "<"
+	if filter.Ascending {
+		direction, comparison = "ASC", ">"
+	}
+
+	if after != nil {
+		if sort == models.TigerSortName {
+			q.where("("+column+", id) "+comparison+" (LOWER(%s), %s)", after.Text, after.ID)
+		} else {
+			q.where("("+column+", id) "+comparison+" (%s, %s)", after.Time, after.ID)
+		}
+	}
+	q.orderBy(column+" "+direction, "id "+direction)
+	return nil
+}
+
+// estimateRows returns the number of rows the query planner expects the query to return,
+// from its statistics and without running the query.
+func (p *PostgresRepository) estimateRows(query string, args ...interface{}) (int, error) {
+	var plan []byte
+	if err := p.db.QueryRow("EXPLAIN (FORMAT JSON) "+query, args...).Scan(&plan); err != nil {
+		return 0, fmt.Errorf("failed to explain query: %v", err)
+	}
+
+	var plans []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal(plan, &plans); err != nil || len(plans) == 0 {
+		return 0, fmt.Errorf("failed to read query plan: %v", err)
+	}
+
+	return int(plans[0].Plan.Rows), nil
+}
+
+func (p *PostgresRepository) GetTigerByID(tigerID int) (*models.Tiger, error) {
 	query := `
-		SELECT id, name, date_of_birth, last_seen, lat, long
+		SELECT id, name, date_of_birth, last_seen, lat, long, version
 		FROM tigers
-		ORDER BY last_seen DESC
-		LIMIT $1 OFFSET $2
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 
-	offset := (page - 1) * pageSize
+	tiger := &models.Tiger{}
+	err := p.db.QueryRow(query, tigerID).Scan(&tiger.ID, &tiger.Name, &tiger.DateOfBirth, &tiger.LastSeen, &tiger.Lat, &tiger.Long, &tiger.Version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get tiger: %v", err)
+	}
+
+	return tiger, nil
+}
+
+// LockTiger returns the tiger and holds a row lock on it until the surrounding
+// transaction ends, serialising writes that depend on the state of the tiger.
+func (p *PostgresRepository) LockTiger(tigerID int) (*models.Tiger, error) {
+	query := `
+		SELECT id, name, date_of_birth, last_seen, lat, long, version
+		FROM tigers
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`
+
+	tiger := &models.Tiger{}
+	err := p.db.QueryRow(query, tigerID).Scan(&tiger.ID, &tiger.Name, &tiger.DateOfBirth, &tiger.LastSeen, &tiger.Lat, &tiger.Long, &tiger.Version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to lock tiger: %v", err)
+	}
+
+	return tiger, nil
+}
+
+// UpdateTigerLastSeen moves the last seen time and position of the tiger to the
+// sighting, unless the tiger has already been seen after it.
+func (p *PostgresRepository) UpdateTigerLastSeen(tigerSighting *models.TigerSighting) error {
+	query := `
+		UPDATE tigers
+		SET last_seen = $2, lat = $3, long = $4
+		WHERE id = $1 AND last_seen < $2
+	`
+
+	_, err := p.db.Exec(query, tigerSighting.TigerID, tigerSighting.Timestamp, tigerSighting.Lat, tigerSighting.Long)
+	if err != nil {
+		return fmt.Errorf("failed to update tiger last seen: %v", err)
+	}
+
+	return nil
+}
+
+// UpdateTiger saves the name and date of birth of the tiger only if its stored version
+// still equals tiger.Version. On success tiger.Version is set to the new version.
+func (p *PostgresRepository) UpdateTiger(tiger *models.Tiger) error {
+	query := `
+		UPDATE tigers
+		SET name = $1, date_of_birth = $2, version = version + 1
+		WHERE id = $3 AND version = $4 AND deleted_at IS NULL
+		RETURNING version
+	`
+
+	err := p.db.QueryRow(query, tiger.Name, tiger.DateOfBirth, tiger.ID, tiger.Version).Scan(&tiger.Version)
+	if err == sql.ErrNoRows {
+		// Either the tiger is gone or somebody else updated it first
+		if _, err := p.GetTigerByID(tiger.ID); err != nil {
+			return err
+		}
+		return ErrVersionConflict
+	} else if err != nil {
+		return fmt.Errorf("failed to update tiger: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteTiger soft deletes the tiger, its sightings are kept.
+func (p *PostgresRepository) DeleteTiger(tigerID int) error {
+	query := `
+		UPDATE tigers
+		SET deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	result, err := p.db.Exec(query, tigerID)
+	if err != nil {
+		return fmt.Errorf("failed to delete tiger: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete tiger: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
 
-	rows, err := p.db.Query(query, pageSize, offset)
+// GetTigersInBoundingBox returns every tiger last seen inside the box.
+func (p *PostgresRepository) GetTigersInBoundingBox(box models.BoundingBox) ([]*models.Tiger, error) {
+	query := `
+		SELECT id, name, date_of_birth, last_seen, lat, long, version
+		FROM tigers
+		WHERE point(long, lat) <@ box(point($1, $2), point($3, $4)) AND deleted_at IS NULL
+	`
+
+	return p.queryTigers(query, box.MinLong, box.MinLat, box.MaxLong, box.MaxLat)
+}
+
+// GetTigersAfter returns at most limit of the tigers kept by the filter following the
+// cursor, in the order of the filter. A nil cursor starts from the first tiger.
+func (p *PostgresRepository) GetTigersAfter(filter models.TigerFilter, after *models.Cursor, limit int) ([]*models.Tiger, error) {
+	q := filterTigers(tigerColumns, filter)
+	if err := sortTigers(q, filter, after); err != nil {
+		return nil, err
+	}
+
+	query, args := q.limitTo(limit).build()
+	return p.queryTigers(query, args...)
+}
+
+// GetTigersByIDs returns the tigers with the IDs which have not been deleted, in no particular order.
+func (p *PostgresRepository) GetTigersByIDs(tigerIDs []int) ([]*models.Tiger, error) {
+	query := `
+		SELECT id, name, date_of_birth, last_seen, lat, long, version
+		FROM tigers
+		WHERE id = ANY($1) AND deleted_at IS NULL
+	`
+
+	return p.queryTigers(query, pq.Array(tigerIDs))
+}
+
+func (p *PostgresRepository) queryTigers(query string, args ...interface{}) ([]*models.Tiger, error) {
+	rows, err := p.db.Query(query, args...)
 	if err != nil {
-		return nil, 0, err
+		return nil, fmt.Errorf("failed to get tigers: %v", err)
 	}
 	defer rows.Close()
 
 	tigers := []*models.Tiger{}
 	for rows.Next() {
 		tiger := &models.Tiger{}
-		err := rows.Scan(&tiger.ID, &tiger.Name, &tiger.DateOfBirth, &tiger.LastSeen, &tiger.Lat, &tiger.Long)
+		err := rows.Scan(&tiger.ID, &tiger.Name, &tiger.DateOfBirth, &tiger.LastSeen, &tiger.Lat, &tiger.Long, &tiger.Version)
 		if err != nil {
-			return nil, 0, err
+			return nil, fmt.Errorf("failed to scan tiger: %v", err)
 		}
 		tigers = append(tigers, tiger)
 	}
 
-	// Get total count of tigers (without pagination)
-	totalCount, err := p.GetTotalTigerCount()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error processing tigers rows: %v", err)
+	}
+
+	return tigers, nil
+}
+
+// GetLegacySightingImages returns up to limit sightings reported before images moved to
+// the image store, with the image of their image column as their only photo.
+func (p *PostgresRepository) GetLegacySightingImages(limit int) ([]*models.TigerSighting, error) {
+	query := `
+		SELECT id, tiger_id, image
+		FROM tiger_sightings
+		WHERE image_key IS NULL AND image IS NOT NULL
+		ORDER BY id
+		LIMIT $1
+	`
+
+	rows, err := p.db.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get legacy sighting images: %v", err)
+	}
+	defer rows.Close()
+
+	var sightings []*models.TigerSighting
+	for rows.Next() {
+		var sighting models.TigerSighting
+		var image []byte
+		if err := rows.Scan(&sighting.ID, &sighting.TigerID, &image); err != nil {
+			return nil, fmt.Errorf("failed to scan legacy sighting image: %v", err)
+		}
+		sighting.Photos = [][]byte{image}
+		sightings = append(sightings, &sighting)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error processing legacy sighting image rows: %v", err)
+	}
+
+	return sightings, nil
+}
+
+// MoveLegacySightingImage points the sighting to its image saved under imageKey and
+// clears its image column.
+func (p *PostgresRepository) MoveLegacySightingImage(sightingID int, imageKey string) error {
+	query := `
+		UPDATE tiger_sightings
+		SET image_key = $2, image = NULL
+		WHERE id = $1 AND image_key IS NULL
+	`
+
+	_, err := p.db.Exec(query, sightingID, imageKey)
 	if err != nil {
-		return nil, 0, err
+		return fmt.Errorf("failed to move legacy sighting image: %v", err)
 	}
 
-	return tigers, totalCount, nil
+	return nil
 }
 
-func (p *postgresRepository) GetTotalTigerCount() (int, error) {
+// GetUsersByEmails returns the users with the emails in any case, in no particular order.
+func (p *PostgresRepository) GetUsersByEmails(emails []string) ([]*models.User, error) {
+	lowerEmails := make([]string, len(emails))
+	for i, email := range emails {
+		lowerEmails[i] = strings.ToLower(email)
+	}
+
 	query := `
-		SELECT COUNT(*) FROM tigers
+		SELECT id, username, email, email_verified_at IS NOT NULL
+		FROM users
+		WHERE LOWER(email) = ANY($1)
 	`
 
-	var totalCount int
-	err := p.db.QueryRow(query).Scan(&totalCount)
+	rows, err := p.db.Query(query, pq.Array(lowerEmails))
 	if err != nil {
-		return 0, err
+		return nil, fmt.Errorf("failed to get users: %v", err)
 	}
+	defer rows.Close()
 
-	return totalCount, nil
+	users := []*models.User{}
+	for rows.Next() {
+		user := &models.User{}
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.EmailVerified); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %v", err)
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error processing users rows: %v", err)
+	}
+
+	return users, nil
 }
 
-func (p *postgresRepository) GetUserByEmail(email string) (*models.User, error) {
+// GetUserByEmail returns the user with the email in any case, or ErrNotFound when there is no such user.
+func (p *PostgresRepository) GetUserByEmail(email string) (*models.User, error) {
 	query := `
-        SELECT id, username, email, password
+        SELECT id, username, email, password, email_verified_at IS NOT NULL
         FROM users
-        WHERE email = $1
+        WHERE LOWER(email) = LOWER($1)
     `
 
 	user := &models.User{}
-	err := p.db.QueryRow(query, email).Scan(&user.ID, &user.Username, &user.Email, &user.Password)
+	err := p.db.QueryRow(query, email).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.EmailVerified)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+			return nil, ErrNotFound
 		}
 		return nil, err
 	}
@@ -108,13 +524,14 @@ func (p *postgresRepository) GetUserByEmail(email string) (*models.User, error)
 	return user, nil
 }
 
-func (p *postgresRepository) CreateTigerSighting(tigerSighting *models.TigerSighting) error {
+func (p *PostgresRepository) CreateTigerSighting(tigerSighting *models.TigerSighting) error {
 	query := `
-       INSERT INTO tiger_sightings (tiger_id, timestamp, lat, long, image, reporter_Email)
-       VALUES ($1, $2, $3, $4, $5,$6)
+       INSERT INTO tiger_sightings (tiger_id, timestamp, lat, long, image_key, reporter_Email, status, photo_lat, photo_long, photo_timestamp, photo_mismatch)
+       VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9, $10, $11)
        RETURNING id
    `
-	err := p.db.QueryRow(query, tigerSighting.TigerID, tigerSighting.Timestamp, tigerSighting.Lat, tigerSighting.Long, tigerSighting.Image, tigerSighting.ReporterEmail).Scan(&tigerSighting.ID)
+	err := p.db.QueryRow(query, tigerSighting.TigerID, tigerSighting.Timestamp, tigerSighting.Lat, tigerSighting.Long, tigerSighting.ImageKey, tigerSighting.ReporterEmail, tigerSighting.Status,
+		tigerSighting.PhotoLat, tigerSighting.PhotoLong, tigerSighting.PhotoTimestamp, tigerSighting.PhotoMismatch).Scan(&tigerSighting.ID)
 	if err != nil {
 		return fmt.Errorf("failed to create tiger sighting: %v", err)
 	}
@@ -122,8 +539,9 @@ func (p *postgresRepository) CreateTigerSighting(tigerSighting *models.TigerSigh
 	return nil
 }
 
-func (p *postgresRepository) GetTigerSightingsByID(tigerID int) ([]*models.TigerSighting, error) {
-	query := "SELECT id, tiger_id, timestamp, lat, long, image, reporter_Email FROM tiger_sightings WHERE tiger_id = $1 ORDER BY timestamp DESC"
+// GetTigerSightingsByID returns the approved sightings of the tiger, newest first.
+func (p *PostgresRepository) GetTigerSightingsByID(tigerID int) ([]*models.TigerSighting, error) {
+	query := "SELECT id, tiger_id, timestamp, lat, long, COALESCE(image_key, ''), reporter_Email, status FROM tiger_sightings WHERE tiger_id = $1 AND status = 'approved' ORDER BY timestamp DESC"
 
 	rows, err := p.db.Query(query, tigerID)
 	if err != nil {
@@ -134,7 +552,7 @@ func (p *postgresRepository) GetTigerSightingsByID(tigerID int) ([]*models.Tiger
 	sightings := []*models.TigerSighting{}
 	for rows.Next() {
 		var sighting models.TigerSighting
-		err := rows.Scan(&sighting.ID, &sighting.TigerID, &sighting.Timestamp, &sighting.Lat, &sighting.Long, &sighting.Image, &sighting.ReporterEmail)
+		err := rows.Scan(&sighting.ID, &sighting.TigerID, &sighting.Timestamp, &sighting.Lat, &sighting.Long, &sighting.ImageKey, &sighting.ReporterEmail, &sighting.Status)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan tiger sighting: %v", err)
 		}
@@ -148,50 +566,145 @@ func (p *postgresRepository) GetTigerSightingsByID(tigerID int) ([]*models.Tiger
 	return sightings, nil
 }
 
-func (p *postgresRepository) GetTigerSightingsByIDWithPagination(tigerID, page, pageSize int) ([]*models.TigerSighting, int, error) {
+func (p *PostgresRepository) GetTigerSightingByID(sightingID int) (*models.TigerSighting, error) {
 	query := `
-		SELECT id, tiger_id, timestamp, lat, long, image, reporter_Email
+		SELECT id, tiger_id, timestamp, lat, long, COALESCE(image_key, ''), reporter_Email, status
 		FROM tiger_sightings
-		WHERE tiger_id = $1
-		ORDER BY timestamp DESC
-		OFFSET $2
-		LIMIT $3
+		WHERE id = $1
 	`
 
-	offset := (page - 1) * pageSize
+	var sighting models.TigerSighting
+	err := p.db.QueryRow(query, sightingID).Scan(&sighting.ID, &sighting.TigerID, &sighting.Timestamp, &sighting.Lat, &sighting.Long, &sighting.ImageKey, &sighting.ReporterEmail, &sighting.Status)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get tiger sighting: %v", err)
+	}
+
+	return &sighting, nil
+}
 
-	rows, err := p.db.Query(query, tigerID, offset, pageSize)
+// GetTigerSightingsInBoundingBox returns the newest approved sightings inside the box, at most limit of them.
+// A zero from or to leaves that end of the time range open.
+func (p *PostgresRepository) GetTigerSightingsInBoundingBox(box models.BoundingBox, from, to time.Time, limit int) ([]*models.TigerSighting, error) {
+	query := `
+		SELECT s.id, s.tiger_id, s.timestamp, s.lat, s.long, COALESCE(s.image_key, ''), s.reporter_Email, s.status
+		FROM tiger_sightings s
+		JOIN tigers t ON t.id = s.tiger_id AND t.deleted_at IS NULL
+		WHERE s.status = 'approved'
+			AND point(s.long, s.lat) <@ box(point($1, $2), point($3, $4))
+			AND ($5::timestamp IS NULL OR s.timestamp >= $5)
+			AND ($6::timestamp IS NULL OR s.timestamp <= $6)
+		ORDER BY s.timestamp DESC
+		LIMIT $7
+	`
+
+	rows, err := p.db.Query(query, box.MinLong, box.MinLat, box.MaxLong, box.MaxLat, nullTime(from), nullTime(to), limit)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to get tiger sightings: %v", err)
+		return nil, fmt.Errorf("failed to get tiger sightings: %v", err)
 	}
 	defer rows.Close()
 
 	sightings := []*models.TigerSighting{}
 	for rows.Next() {
 		var sighting models.TigerSighting
-		err := rows.Scan(&sighting.ID, &sighting.TigerID, &sighting.Timestamp, &sighting.Lat, &sighting.Long, &sighting.Image, &sighting.ReporterEmail)
+		err := rows.Scan(&sighting.ID, &sighting.TigerID, &sighting.Timestamp, &sighting.Lat, &sighting.Long, &sighting.ImageKey, &sighting.ReporterEmail, &sighting.Status)
 		if err != nil {
-			return nil, 0, fmt.Errorf("failed to scan tiger sighting: %v", err)
+			return nil, fmt.Errorf("failed to scan tiger sighting: %v", err)
 		}
 		sightings = append(sightings, &sighting)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, 0, fmt.Errorf("error processing tiger sightings rows: %v", err)
+		return nil, fmt.Errorf("error processing tiger sightings rows: %v", err)
 	}
 
-	// Get total count of tiger sightings (without pagination)
-	totalCount, err := p.GetTigerSightingsCountByID(tigerID)
+	return sightings, nil
+}
+
+// StreamTigerSightings calls fn for every approved sighting of the tiger, oldest first,
+// while reading the rows from the database.
+func (p *PostgresRepository) StreamTigerSightings(tigerID int, fn func(*models.TigerSighting) error) error {
+	query := `
+		SELECT id, tiger_id, timestamp, lat, long, COALESCE(image_key, ''), reporter_Email, status
+		FROM tiger_sightings
+		WHERE tiger_id = $1 AND status = 'approved'
+		ORDER BY timestamp ASC, id ASC
+	`
+
+	return p.streamTigerSightings(fn, query, tigerID)
+}
+
+// StreamTigerSightingsBetween calls fn for every approved sighting in the time range, oldest first,
+// while reading the rows from the database. A zero from or to leaves that end of the range open.
+func (p *PostgresRepository) StreamTigerSightingsBetween(from, to time.Time, fn func(*models.TigerSighting) error) error {
+	query := `
+		SELECT id, tiger_id, timestamp, lat, long, COALESCE(image_key, ''), reporter_Email, status
+		FROM tiger_sightings
+		WHERE status = 'approved'
+			AND ($1::timestamp IS NULL OR timestamp >= $1)
+			AND ($2::timestamp IS NULL OR timestamp <= $2)
+		ORDER BY timestamp ASC, id ASC
+	`
+
+	return p.streamTigerSightings(fn, query, nullTime(from), nullTime(to))
+}
+
+func (p *PostgresRepository) streamTigerSightings(fn func(*models.TigerSighting) error, query string, args ...interface{}) error {
+	rows, err := p.db.Query(query, args...)
 	if err != nil {
-		return nil, 0, err
+		return fmt.Errorf("failed to get tiger sightings: %v", err)
 	}
+	defer rows.Close()
 
-	return sightings, totalCount, nil
+	for rows.Next() {
+		var sighting models.TigerSighting
+		err := rows.Scan(&sighting.ID, &sighting.TigerID, &sighting.Timestamp, &sighting.Lat, &sighting.Long, &sighting.ImageKey, &sighting.ReporterEmail, &sighting.Status)
+		if err != nil {
+			return fmt.Errorf("failed to scan tiger sighting: %v", err)
+		}
+		if err := fn(&sighting); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error processing tiger sightings rows: %v", err)
+	}
+
+	return nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// GetTigerSightingsByIDWithPagination returns the page of approved sightings of the tiger
+// numbered from 1, newest first. Use GetTigerSightingsCountByID for the total.
+func (p *PostgresRepository) GetTigerSightingsByIDWithPagination(tigerID, page, pageSize int) ([]*models.TigerSighting, error) {
+	query := `
+		SELECT id, tiger_id, timestamp, lat, long, COALESCE(image_key, ''), reporter_Email, status
+		FROM tiger_sightings
+		WHERE tiger_id = $1 AND status = 'approved'
+		ORDER BY timestamp DESC, id DESC
+		OFFSET $2
+		LIMIT $3
+	`
+
+	offset := (page - 1) * pageSize
+
+	return p.queryTigerSightings(query, tigerID, offset, pageSize)
 }
 
-func (p *postgresRepository) GetTigerSightingsCountByID(tigerID int) (int, error) {
+// GetTigerSightingsCountByID counts the approved sightings of the tiger, or asks the query
+// planner for an estimate when estimate is set.
+func (p *PostgresRepository) GetTigerSightingsCountByID(tigerID int, estimate bool) (int, error) {
+	if estimate {
+		return p.estimateRows(`SELECT id FROM tiger_sightings WHERE tiger_id = $1 AND status = 'approved'`, tigerID)
+	}
+
 	query := `
-		SELECT COUNT(*) FROM tiger_sightings WHERE tiger_id = $1
+		SELECT COUNT(*) FROM tiger_sightings WHERE tiger_id = $1 AND status = 'approved'
 	`
 
 	var totalCount int
@@ -203,34 +716,1203 @@ func (p *postgresRepository) GetTigerSightingsCountByID(tigerID int) (int, error
 	return totalCount, nil
 }
 
-func (p *postgresRepository) GetPreviousTigerSighting(tigerID int) (*models.TigerSighting, error) {
-	// Query the database to get the previous tiger sighting based on tigerID
+// GetAdjacentTigerSightings returns the sightings of the tiger right before and right
+// after timestamp that are approved or still pending from reporterEmail, the pending
+// sightings of other reporters may be wrong. A sighting at timestamp counts as before,
+// before or after is nil when there is no such sighting.
+func (p *PostgresRepository) GetAdjacentTigerSightings(tigerID int, reporterEmail string, timestamp time.Time) (before, after *models.TigerSighting, err error) {
 	query := `
-		SELECT id, tiger_id, timestamp, lat, long, image, reporter_Email
+		(SELECT id, tiger_id, timestamp, lat, long, COALESCE(image_key, ''), reporter_Email, status
 		FROM tiger_sightings
-		WHERE tiger_id = $1
+		WHERE tiger_id = $1 AND (status = 'approved' OR (status = 'pending' AND reporter_Email = $2)) AND timestamp <= $3
 		ORDER BY timestamp DESC
-		LIMIT 1;
+		LIMIT 1)
+		UNION ALL
+		(SELECT id, tiger_id, timestamp, lat, long, COALESCE(image_key, ''), reporter_Email, status
+		FROM tiger_sightings
+		WHERE tiger_id = $1 AND (status = 'approved' OR (status = 'pending' AND reporter_Email = $2)) AND timestamp > $3
+		ORDER BY timestamp ASC
+		LIMIT 1)
 	`
 
-	var previousSighting models.TigerSighting
-	err := p.db.QueryRow(query, tigerID).Scan(
-		&previousSighting.ID,
-		&previousSighting.TigerID,
-		&previousSighting.Timestamp,
-		&previousSighting.Lat,
-		&previousSighting.Long,
-		&previousSighting.Image,
-		&previousSighting.ReporterEmail,
-	)
+	sightings, err := p.queryTigerSightings(query, tigerID, reporterEmail, timestamp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, sighting := range sightings {
+		if sighting.Timestamp.After(timestamp) {
+			after = sighting
+		} else {
+			before = sighting
+		}
+	}
 
-	if err == sql.ErrNoRows {
-		// No previous sighting found for the given tigerID
-		return nil, nil
-	} else if err != nil {
-		// Some other error occurred during the query
-		return nil, err
+	return before, after, nil
+}
+
+// GetTigerSightingsBetween returns the sightings of the tiger from from to to that are
+// approved or still pending from reporterEmail, oldest first.
+func (p *PostgresRepository) GetTigerSightingsBetween(tigerID int, reporterEmail string, from, to time.Time) ([]*models.TigerSighting, error) {
+	query := `
+		SELECT id, tiger_id, timestamp, lat, long, COALESCE(image_key, ''), reporter_Email, status
+		FROM tiger_sightings
+		WHERE tiger_id = $1 AND (status = 'approved' OR (status = 'pending' AND reporter_Email = $2)) AND timestamp BETWEEN $3 AND $4
+		ORDER BY timestamp ASC
+	`
+
+	return p.queryTigerSightings(query, tigerID, reporterEmail, from, to)
+}
+
+// GetTigerSightingsAfter returns at most limit approved sightings of the tiger following
+// the cursor, newest first. A nil cursor starts from the newest sighting.
+func (p *PostgresRepository) GetTigerSightingsAfter(tigerID int, after *models.Cursor, limit int) ([]*models.TigerSighting, error) {
+	args := []interface{}{tigerID, limit}
+	condition := ""
+	if after != nil {
+		condition = "AND (timestamp, id) < ($3, $4)"
+		args = append(args, after.Time, after.ID)
+	}
+
+	query := `
+		SELECT id, tiger_id, timestamp, lat, long, COALESCE(image_key, ''), reporter_Email, status
+		FROM tiger_sightings
+		WHERE tiger_id = $1 AND status = 'approved' ` + condition + `
+		ORDER BY timestamp DESC, id DESC
+		LIMIT $2
+	`
+
+	return p.queryTigerSightings(query, args...)
+}
+
+// GetLatestTigerSightings returns the limit newest approved sightings of each of the
+// tigers, ordered by tiger and newest first, in a single query.
+func (p *PostgresRepository) GetLatestTigerSightings(tigerIDs []int, limit int) ([]*models.TigerSighting, error) {
+	query := `
+		SELECT id, tiger_id, timestamp, lat, long, COALESCE(image_key, ''), reporter_Email, status
+		FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY tiger_id ORDER BY timestamp DESC, id DESC) AS rank
+			FROM tiger_sightings
+			WHERE tiger_id = ANY($1) AND status = 'approved'
+		) ranked
+		WHERE rank <= $2
+		ORDER BY tiger_id, timestamp DESC, id DESC
+	`
+
+	return p.queryTigerSightings(query, pq.Array(tigerIDs), limit)
+}
+
+func (p *PostgresRepository) queryTigerSightings(query string, args ...interface{}) ([]*models.TigerSighting, error) {
+	rows, err := p.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tiger sightings: %v", err)
+	}
+	defer rows.Close()
+
+	sightings := []*models.TigerSighting{}
+	for rows.Next() {
+		var sighting models.TigerSighting
+		err := rows.Scan(&sighting.ID, &sighting.TigerID, &sighting.Timestamp, &sighting.Lat, &sighting.Long, &sighting.ImageKey, &sighting.ReporterEmail, &sighting.Status)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tiger sighting: %v", err)
+		}
+		sightings = append(sightings, &sighting)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error processing tiger sightings rows: %v", err)
+	}
+
+	return sightings, nil
+}
+
+// IsNotificationDelivered reports whether the email of the message has already been sent to the recipient.
+func (p *PostgresRepository) IsNotificationDelivered(messageID, recipient string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM notification_deliveries
+			WHERE message_id = $1 AND recipient = $2
+		)
+	`
+
+	var delivered bool
+	err := p.db.QueryRow(query, messageID, recipient).Scan(&delivered)
+	if err != nil {
+		return false, fmt.Errorf("failed to check notification delivery: %v", err)
+	}
+
+	return delivered, nil
+}
+
+// MarkNotificationDelivered records that the email of the message has been sent to the recipient.
+func (p *PostgresRepository) MarkNotificationDelivered(messageID, recipient string) error {
+	query := `
+		INSERT INTO notification_deliveries (message_id, recipient)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`
+
+	_, err := p.db.Exec(query, messageID, recipient)
+	if err != nil {
+		return fmt.Errorf("failed to record notification delivery: %v", err)
+	}
+
+	return nil
+}
+
+// GetNotificationPreference returns how the user wants to hear about sightings of the tiger.
+// Users are subscribed to every tiger and get one email per sighting unless they changed it.
+func (p *PostgresRepository) GetNotificationPreference(email string, tigerID int) (*models.NotificationPreference, error) {
+	query := `
+		SELECT
+			COALESCE((SELECT subscribed FROM tiger_subscriptions WHERE email = $1 AND tiger_id = $2), TRUE),
+			COALESCE((SELECT digest FROM notification_preferences WHERE email = $1), FALSE)
+	`
+
+	var preference models.NotificationPreference
+	err := p.db.QueryRow(query, email, tigerID).Scan(&preference.Subscribed, &preference.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification preference: %v", err)
+	}
+
+	return &preference, nil
+}
+
+// SetTigerSubscription subscribes the user to sightings of the tiger or opts them out.
+func (p *PostgresRepository) SetTigerSubscription(email string, tigerID int, subscribed bool) error {
+	query := `
+		INSERT INTO tiger_subscriptions (email, tiger_id, subscribed)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (email, tiger_id) DO UPDATE SET subscribed = EXCLUDED.subscribed, updated_at = NOW()
+	`
+
+	_, err := p.db.Exec(query, email, tigerID, subscribed)
+	if err != nil {
+		return fmt.Errorf("failed to set tiger subscription: %v", err)
+	}
+
+	return nil
+}
+
+// GetTigerFollowers returns the emails of the users who follow the tiger.
+func (p *PostgresRepository) GetTigerFollowers(tigerID int) ([]string, error) {
+	query := `
+		SELECT email
+		FROM tiger_subscriptions
+		WHERE tiger_id = $1 AND subscribed
+		ORDER BY email
+	`
+
+	rows, err := p.db.Query(query, tigerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tiger followers: %v", err)
+	}
+	defer rows.Close()
+
+	var followers []string
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, fmt.Errorf("failed to scan tiger follower row: %v", err)
+		}
+		followers = append(followers, email)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error occurred while iterating over tiger follower rows: %v", err)
+	}
+
+	return followers, nil
+}
+
+// GetTigerSubscriptions returns the tigers the user follows or opted out of, most recently changed first.
+func (p *PostgresRepository) GetTigerSubscriptions(email string) ([]*models.TigerSubscription, error) {
+	query := `
+		SELECT tiger_id, subscribed, updated_at
+		FROM tiger_subscriptions
+		WHERE email = $1
+		ORDER BY updated_at DESC, tiger_id
+	`
+
+	rows, err := p.db.Query(query, email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tiger subscriptions: %v", err)
+	}
+	defer rows.Close()
+
+	subscriptions := []*models.TigerSubscription{}
+	for rows.Next() {
+		var subscription models.TigerSubscription
+		if err := rows.Scan(&subscription.TigerID, &subscription.Subscribed, &subscription.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tiger subscription row: %v", err)
+		}
+		subscriptions = append(subscriptions, &subscription)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error occurred while iterating over tiger subscription rows: %v", err)
+	}
+
+	return subscriptions, nil
+}
+
+// SetDigestMode switches the user between one email per sighting and an hourly digest.
+func (p *PostgresRepository) SetDigestMode(email string, digest bool) error {
+	query := `
+		INSERT INTO notification_preferences (email, digest)
+		VALUES ($1, $2)
+		ON CONFLICT (email) DO UPDATE SET digest = EXCLUDED.digest, updated_at = NOW()
+	`
+
+	_, err := p.db.Exec(query, email, digest)
+	if err != nil {
+		return fmt.Errorf("failed to set digest mode: %v", err)
+	}
+
+	return nil
+}
+
+// AddDigestItem queues the sighting for the next digest email of item.Recipient.
+// Adding the same message for the same recipient again is a no-op.
+func (p *PostgresRepository) AddDigestItem(messageID string, item *models.DigestItem) error {
+	query := `
+		INSERT INTO notification_digest_items (message_id, recipient, tiger_id, tiger_name, sighting_id, lat, long, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (message_id, recipient) DO NOTHING
+	`
+
+	_, err := p.db.Exec(query, messageID, item.Recipient, item.TigerID, item.TigerName, item.SightingID, item.Lat, item.Long, item.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to add digest item: %v", err)
+	}
+
+	return nil
+}
+
+// GetPendingDigestItems returns the digest items not sent yet, grouped by recipient in the order they were added.
+func (p *PostgresRepository) GetPendingDigestItems() ([]*models.DigestItem, error) {
+	query := `
+		SELECT id, recipient, tiger_id, tiger_name, sighting_id, lat, long, timestamp
+		FROM notification_digest_items
+		WHERE sent_at IS NULL
+		ORDER BY recipient, id
+	`
+
+	rows, err := p.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending digest items: %v", err)
+	}
+	defer rows.Close()
+
+	var items []*models.DigestItem
+	for rows.Next() {
+		var item models.DigestItem
+		err := rows.Scan(&item.ID, &item.Recipient, &item.TigerID, &item.TigerName, &item.SightingID, &item.Lat, &item.Long, &item.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan digest item row: %v", err)
+		}
+		items = append(items, &item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error occurred while iterating over digest item rows: %v", err)
+	}
+
+	return items, nil
+}
+
+// MarkDigestItemsSent marks the pending digest items of the recipient up to lastID as sent.
+func (p *PostgresRepository) MarkDigestItemsSent(recipient string, lastID int) error {
+	query := `
+		UPDATE notification_digest_items
+		SET sent_at = NOW()
+		WHERE recipient = $1 AND id <= $2 AND sent_at IS NULL
+	`
+
+	_, err := p.db.Exec(query, recipient, lastID)
+	if err != nil {
+		return fmt.Errorf("failed to mark digest items sent: %v", err)
+	}
+
+	return nil
+}
+
+// CreateSightingAlert saves the alert, area is the bounding box of its circle.
+func (p *PostgresRepository) CreateSightingAlert(alert *models.SightingAlert, area models.BoundingBox) error {
+	query := `
+		INSERT INTO sighting_alerts (email, name, lat, long, radius_km, area, tiger_id, channel, webhook_url, webhook_secret)
+		VALUES ($1, $2, $3, $4, $5, box(point($6, $7), point($8, $9)), $10, $11, $12, $13)
+		RETURNING id, created_at
+	`
+
+	err := p.db.QueryRow(query, alert.Email, alert.Name, alert.Lat, alert.Long, alert.RadiusKm,
+		area.MinLong, area.MinLat, area.MaxLong, area.MaxLat,
+		alert.TigerID, alert.Channel, alert.WebhookURL, alert.WebhookSecret).Scan(&alert.ID, &alert.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create sighting alert: %v", err)
+	}
+
+	return nil
+}
+
+// GetSightingAlerts returns the alerts of the user, oldest first.
+func (p *PostgresRepository) GetSightingAlerts(email string) ([]*models.SightingAlert, error) {
+	query := `
+		SELECT id, email, name, lat, long, radius_km, tiger_id, channel, webhook_url, webhook_secret, created_at
+		FROM sighting_alerts
+		WHERE email = $1
+		ORDER BY id
+	`
+
+	return p.querySightingAlerts(query, email)
+}
+
+// GetSightingAlertsAt returns the alerts whose area holds the coordinates, for every
+// tiger or for the tiger. The caller checks the distance to the center of the alerts.
+func (p *PostgresRepository) GetSightingAlertsAt(coordinates models.Coordinates, tigerID int) ([]*models.SightingAlert, error) {
+	query := `
+		SELECT id, email, name, lat, long, radius_km, tiger_id, channel, webhook_url, webhook_secret, created_at
+		FROM sighting_alerts
+		WHERE area @> point($1, $2) AND (tiger_id IS NULL OR tiger_id = $3)
+		ORDER BY id
+	`
+
+	return p.querySightingAlerts(query, coordinates.Long, coordinates.Lat, tigerID)
+}
+
+// DeleteSightingAlert deletes the alert of the user, ErrNotFound is returned when the user has no such alert.
+func (p *PostgresRepository) DeleteSightingAlert(alertID int, email string) error {
+	query := `
+		DELETE FROM sighting_alerts
+		WHERE id = $1 AND email = $2
+	`
+
+	result, err := p.db.Exec(query, alertID, email)
+	if err != nil {
+		return fmt.Errorf("failed to delete sighting alert: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete sighting alert: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *PostgresRepository) querySightingAlerts(query string, args ...interface{}) ([]*models.SightingAlert, error) {
+	rows, err := p.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sighting alerts: %v", err)
+	}
+	defer rows.Close()
+
+	alerts := []*models.SightingAlert{}
+	for rows.Next() {
+		var alert models.SightingAlert
+		var tigerID sql.NullInt64
+		err := rows.Scan(&alert.ID, &alert.Email, &alert.Name, &alert.Lat, &alert.Long, &alert.RadiusKm, &tigerID,
+			&alert.Channel, &alert.WebhookURL, &alert.WebhookSecret, &alert.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sighting alert row: %v", err)
+		}
+		if tigerID.Valid {
+			id := int(tigerID.Int64)
+			alert.TigerID = &id
+		}
+		alerts = append(alerts, &alert)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error occurred while iterating over sighting alert rows: %v", err)
+	}
+
+	return alerts, nil
+}
+
+// QueueWebhookDelivery queues the webhook of the alert for the message, unless it has
+// already been queued.
+func (p *PostgresRepository) QueueWebhookDelivery(messageID string, alertID int, payload []byte) error {
+	query := `
+		INSERT INTO webhook_deliveries (message_id, alert_id, payload)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (message_id, alert_id) DO NOTHING
+	`
+
+	_, err := p.db.Exec(query, messageID, alertID, payload)
+	if err != nil {
+		return fmt.Errorf("failed to queue webhook delivery: %v", err)
+	}
+
+	return nil
+}
+
+// ClaimWebhookDeliveries returns up to limit due webhook deliveries, oldest first, with
+// the URL and secret of their alert. Their attempts are counted and they are not due
+// again for lease, so that another dispatcher does not send them meanwhile.
+func (p *PostgresRepository) ClaimWebhookDeliveries(limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	query := `
+		WITH claimed AS (
+			UPDATE webhook_deliveries
+			SET attempts = attempts + 1, next_attempt_at = NOW() + $2 * INTERVAL '1 second'
+			WHERE id IN (
+				SELECT id
+				FROM webhook_deliveries
+				WHERE delivered_at IS NULL AND failed_at IS NULL AND next_attempt_at <= NOW()
+				ORDER BY next_attempt_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, alert_id, payload, attempts
+		)
+		SELECT c.id, c.alert_id, a.webhook_url, a.webhook_secret, c.payload, c.attempts
+		FROM claimed c
+		JOIN sighting_alerts a ON a.id = c.alert_id
+		ORDER BY c.id
+	`
+
+	rows, err := p.db.Query(query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		if err := rows.Scan(&delivery.ID, &delivery.AlertID, &delivery.URL, &delivery.Secret, &delivery.Payload, &delivery.Attempts); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery row: %v", err)
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error occurred while iterating over webhook delivery rows: %v", err)
+	}
+
+	return deliveries, nil
+}
+
+// MarkWebhookDelivered marks the webhook delivery as delivered.
+func (p *PostgresRepository) MarkWebhookDelivered(id int64) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET delivered_at = NOW(), last_error = ''
+		WHERE id = $1
+	`
+
+	_, err := p.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark webhook delivered: %v", err)
+	}
+
+	return nil
+}
+
+// RetryWebhookDelivery makes the webhook delivery due again after delay.
+func (p *PostgresRepository) RetryWebhookDelivery(id int64, delay time.Duration, lastError string) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET next_attempt_at = NOW() + $2 * INTERVAL '1 second', last_error = $3
+		WHERE id = $1
+	`
+
+	_, err := p.db.Exec(query, id, delay.Seconds(), lastError)
+	if err != nil {
+		return fmt.Errorf("failed to retry webhook delivery: %v", err)
+	}
+
+	return nil
+}
+
+// FailWebhookDelivery gives up on the webhook delivery.
+func (p *PostgresRepository) FailWebhookDelivery(id int64, lastError string) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET failed_at = NOW(), last_error = $2
+		WHERE id = $1
+	`
+
+	_, err := p.db.Exec(query, id, lastError)
+	if err != nil {
+		return fmt.Errorf("failed to mark webhook delivery failed: %v", err)
+	}
+
+	return nil
+}
+
+// CreateOutboxMessage adds a message to the outbox, to be published by the outbox relay.
+func (p *PostgresRepository) CreateOutboxMessage(payload []byte) error {
+	query := `
+		INSERT INTO outbox_messages (payload)
+		VALUES ($1)
+	`
+
+	_, err := p.db.Exec(query, payload)
+	if err != nil {
+		return fmt.Errorf("failed to create outbox message: %v", err)
+	}
+
+	return nil
+}
+
+// GetPendingOutboxMessages returns up to limit unpublished messages, oldest first.
+// Inside a transaction the messages stay locked until it ends, messages locked by
+// another transaction are skipped.
+func (p *PostgresRepository) GetPendingOutboxMessages(limit int) ([]*models.OutboxMessage, error) {
+	query := `
+		SELECT id, payload, created_at
+		FROM outbox_messages
+		WHERE sent_at IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`
+
+	rows, err := p.db.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending outbox messages: %v", err)
+	}
+	defer rows.Close()
+
+	var messages []*models.OutboxMessage
+	for rows.Next() {
+		var message models.OutboxMessage
+		if err := rows.Scan(&message.ID, &message.Payload, &message.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox message row: %v", err)
+		}
+		messages = append(messages, &message)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error occurred while iterating over outbox message rows: %v", err)
+	}
+
+	return messages, nil
+}
+
+// MarkOutboxMessageSent marks the message as published.
+func (p *PostgresRepository) MarkOutboxMessageSent(id int64) error {
+	query := `
+		UPDATE outbox_messages
+		SET sent_at = NOW()
+		WHERE id = $1
+	`
+
+	_, err := p.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox message sent: %v", err)
+	}
+
+	return nil
+}
+
+// GetUserByID returns the user, or ErrNotFound when there is no such user.
+func (p *PostgresRepository) GetUserByID(userID int) (*models.User, error) {
+	query := `
+		SELECT id, username, email, password, email_verified_at IS NOT NULL
+		FROM users
+		WHERE id = $1
+	`
+
+	user := &models.User{}
+	err := p.db.QueryRow(query, userID).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.EmailVerified)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %v", err)
+	}
+
+	return user, nil
+}
+
+// CreateRefreshToken saves the refresh token and sets its ID.
+func (p *PostgresRepository) CreateRefreshToken(token *models.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`
+
+	err := p.db.QueryRow(query, token.UserID, token.TokenHash, token.ExpiresAt).Scan(&token.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %v", err)
+	}
+
+	return nil
+}
+
+// LockRefreshToken returns the refresh token stored under the hash and locks it until
+// the end of the transaction, so that it can only be rotated once.
+func (p *PostgresRepository) LockRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	query := `
+		SELECT id, user_id, token_hash, expires_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`
+
+	token := &models.RefreshToken{}
+	var revokedAt sql.NullTime
+	err := p.db.QueryRow(query, tokenHash).Scan(&token.ID, &token.UserID, &token.TokenHash, &token.ExpiresAt, &revokedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to lock refresh token: %v", err)
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+
+	return token, nil
+}
+
+// RevokeRefreshToken revokes the refresh token, revoking it again is a no-op.
+func (p *PostgresRepository) RevokeRefreshToken(tokenID int) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE id = $1 AND revoked_at IS NULL
+	`
+
+	_, err := p.db.Exec(query, tokenID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %v", err)
+	}
+
+	return nil
+}
+
+// RevokeUserRefreshTokens revokes every refresh token of the user.
+func (p *PostgresRepository) RevokeUserRefreshTokens(userID int) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`
+
+	_, err := p.db.Exec(query, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %v", err)
+	}
+
+	return nil
+}
+
+// RevokeAccessToken adds the access token to the denylist until it expires.
+func (p *PostgresRepository) RevokeAccessToken(tokenID string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_access_tokens (token_id, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (token_id) DO NOTHING
+	`
+
+	_, err := p.db.Exec(query, tokenID, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to revoke access token: %v", err)
+	}
+
+	return nil
+}
+
+// IsAccessTokenRevoked reports whether the access token is on the denylist.
+func (p *PostgresRepository) IsAccessTokenRevoked(tokenID string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM revoked_access_tokens
+			WHERE token_id = $1 AND expires_at > NOW()
+		)
+	`
+
+	var revoked bool
+	err := p.db.QueryRow(query, tokenID).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("failed to check access token: %v", err)
+	}
+
+	return revoked, nil
+}
+
+// GetUserRoles returns the roles granted to the user, in alphabetical order.
+func (p *PostgresRepository) GetUserRoles(userID int) ([]string, error) {
+	query := `
+		SELECT role
+		FROM user_roles
+		WHERE user_id = $1
+		ORDER BY role
+	`
+
+	rows, err := p.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user roles: %v", err)
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("failed to scan user role: %v", err)
+		}
+		roles = append(roles, role)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error processing user role rows: %v", err)
+	}
+
+	return roles, nil
+}
+
+// GrantUserRole grants the role to the user, granting it again is a no-op.
+func (p *PostgresRepository) GrantUserRole(userID int, role string) error {
+	query := `
+		INSERT INTO user_roles (user_id, role)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, role) DO NOTHING
+	`
+
+	_, err := p.db.Exec(query, userID, role)
+	if err != nil {
+		return fmt.Errorf("failed to grant user role: %v", err)
+	}
+
+	return nil
+}
+
+// RevokeUserRole takes the role away from the user.
+func (p *PostgresRepository) RevokeUserRole(userID int, role string) error {
+	query := `
+		DELETE FROM user_roles
+		WHERE user_id = $1 AND role = $2
+	`
+
+	_, err := p.db.Exec(query, userID, role)
+	if err != nil {
+		return fmt.Errorf("failed to revoke user role: %v", err)
+	}
+
+	return nil
+}
+
+// MarkEmailVerified marks the email of the user as verified, verifying it again is a no-op.
+func (p *PostgresRepository) MarkEmailVerified(userID int) error {
+	query := `
+		UPDATE users
+		SET email_verified_at = NOW()
+		WHERE id = $1 AND email_verified_at IS NULL
+	`
+
+	_, err := p.db.Exec(query, userID)
+	if err != nil {
+		return fmt.Errorf("failed to mark email verified: %v", err)
+	}
+
+	return nil
+}
+
+// UpdateUserPassword replaces the hashed password of the user.
+func (p *PostgresRepository) UpdateUserPassword(userID int, hashedPassword string) error {
+	query := `
+		UPDATE users
+		SET password = $2
+		WHERE id = $1
+	`
+
+	_, err := p.db.Exec(query, userID, hashedPassword)
+	if err != nil {
+		return fmt.Errorf("failed to update user password: %v", err)
+	}
+
+	return nil
+}
+
+// CreateUserToken saves the single use token and sets its ID.
+func (p *PostgresRepository) CreateUserToken(token *models.UserToken) error {
+	query := `
+		INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+
+	err := p.db.QueryRow(query, token.UserID, token.Purpose, token.TokenHash, token.ExpiresAt).Scan(&token.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create user token: %v", err)
+	}
+
+	return nil
+}
+
+// LockUserToken returns the token for the purpose stored under the hash and locks it
+// until the end of the transaction, so that it can only be used once.
+func (p *PostgresRepository) LockUserToken(purpose, tokenHash string) (*models.UserToken, error) {
+	query := `
+		SELECT id, user_id, purpose, token_hash, expires_at, used_at
+		FROM user_tokens
+		WHERE purpose = $1 AND token_hash = $2
+		FOR UPDATE
+	`
+
+	token := &models.UserToken{}
+	var usedAt sql.NullTime
+	err := p.db.QueryRow(query, purpose, tokenHash).Scan(&token.ID, &token.UserID, &token.Purpose, &token.TokenHash, &token.ExpiresAt, &usedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to lock user token: %v", err)
+	}
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+
+	return token, nil
+}
+
+// RenewUserToken stores the token under a new hash, unless it has been used or has
+// expired, and reports whether it did.
+func (p *PostgresRepository) RenewUserToken(tokenID int, tokenHash string) (bool, error) {
+	query := `
+		UPDATE user_tokens
+		SET token_hash = $2
+		WHERE id = $1 AND used_at IS NULL AND expires_at > NOW()
+	`
+
+	result, err := p.db.Exec(query, tokenID, tokenHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to renew user token: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to renew user token: %v", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// UseUserTokens marks every unused token of the user for the purpose as used.
+func (p *PostgresRepository) UseUserTokens(userID int, purpose string) error {
+	query := `
+		UPDATE user_tokens
+		SET used_at = NOW()
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+	`
+
+	_, err := p.db.Exec(query, userID, purpose)
+	if err != nil {
+		return fmt.Errorf("failed to use user tokens: %v", err)
+	}
+
+	return nil
+}
+
+// LockTigerSighting returns the sighting and locks it until the end of the transaction,
+// so that concurrent reviews of it wait for each other.
+func (p *PostgresRepository) LockTigerSighting(sightingID int) (*models.TigerSighting, error) {
+	query := `
+		SELECT id, tiger_id, timestamp, lat, long, COALESCE(image_key, ''), reporter_Email, status
+		FROM tiger_sightings
+		WHERE id = $1
+		FOR UPDATE
+	`
+
+	var sighting models.TigerSighting
+	err := p.db.QueryRow(query, sightingID).Scan(&sighting.ID, &sighting.TigerID, &sighting.Timestamp, &sighting.Lat, &sighting.Long, &sighting.ImageKey, &sighting.ReporterEmail, &sighting.Status)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to lock tiger sighting: %v", err)
+	}
+
+	return &sighting, nil
+}
+
+// UpdateTigerSightingStatus sets the status of the sighting.
+func (p *PostgresRepository) UpdateTigerSightingStatus(sightingID int, status string) error {
+	query := `
+		UPDATE tiger_sightings
+		SET status = $2
+		WHERE id = $1
+	`
+
+	_, err := p.db.Exec(query, sightingID, status)
+	if err != nil {
+		return fmt.Errorf("failed to update tiger sighting status: %v", err)
+	}
+
+	return nil
+}
+
+// RefreshTigerLastSeen moves the last seen time and position of the tiger to its newest
+// approved sighting, it is left as it is when the tiger has no approved sighting.
+func (p *PostgresRepository) RefreshTigerLastSeen(tigerID int) error {
+	query := `
+		UPDATE tigers t
+		SET last_seen = s.timestamp, lat = s.lat, long = s.long
+		FROM (
+			SELECT timestamp, lat, long
+			FROM tiger_sightings
+			WHERE tiger_id = $1 AND status = 'approved'
+			ORDER BY timestamp DESC
+			LIMIT 1
+		) s
+		WHERE t.id = $1
+	`
+
+	_, err := p.db.Exec(query, tigerID)
+	if err != nil {
+		return fmt.Errorf("failed to refresh tiger last seen: %v", err)
+	}
+
+	return nil
+}
+
+// GetModerationQueue returns the sightings waiting for a moderator, oldest first: the
+// pending sightings and the approved sightings with unresolved flags. The sightings of
+// deleted tigers are left out.
+func (p *PostgresRepository) GetModerationQueue(page, pageSize int) ([]*models.TigerSighting, int, error) {
+	query := `
+		SELECT s.id, s.tiger_id, s.timestamp, s.lat, s.long, COALESCE(s.image_key, ''), s.reporter_Email, s.status,
+			s.photo_lat, s.photo_long, s.photo_timestamp, s.photo_mismatch, COUNT(f.id), COUNT(*) OVER ()
+		FROM tiger_sightings s
+		JOIN tigers t ON t.id = s.tiger_id AND t.deleted_at IS NULL
+		LEFT JOIN sighting_flags f ON f.sighting_id = s.id AND f.resolved_at IS NULL
+		WHERE s.status = 'pending' OR (s.status = 'approved' AND f.id IS NOT NULL)
+		GROUP BY s.id
+		ORDER BY s.id ASC
+		OFFSET $1
+		LIMIT $2
+	`
+
+	rows, err := p.db.Query(query, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get moderation queue: %v", err)
+	}
+	defer rows.Close()
+
+	totalCount := 0
+	sightings := []*models.TigerSighting{}
+	for rows.Next() {
+		var sighting models.TigerSighting
+		err := rows.Scan(&sighting.ID, &sighting.TigerID, &sighting.Timestamp, &sighting.Lat, &sighting.Long, &sighting.ImageKey, &sighting.ReporterEmail, &sighting.Status,
+			&sighting.PhotoLat, &sighting.PhotoLong, &sighting.PhotoTimestamp, &sighting.PhotoMismatch, &sighting.OpenFlags, &totalCount)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan tiger sighting: %v", err)
+		}
+		sightings = append(sightings, &sighting)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error processing tiger sightings rows: %v", err)
+	}
+
+	return sightings, totalCount, nil
+}
+
+// CreateSightingReview saves the review and sets its ID and creation time.
+func (p *PostgresRepository) CreateSightingReview(review *models.SightingReview) error {
+	query := `
+		INSERT INTO sighting_reviews (sighting_id, reviewer_id, status, note)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	err := p.db.QueryRow(query, review.SightingID, review.ReviewerID, review.Status, review.Note).Scan(&review.ID, &review.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create sighting review: %v", err)
+	}
+
+	return nil
+}
+
+// CreateSightingFlag saves the flag, flagging a sighting again is a no-op.
+func (p *PostgresRepository) CreateSightingFlag(flag *models.SightingFlag) error {
+	query := `
+		INSERT INTO sighting_flags (sighting_id, reporter_email, reason)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (sighting_id, reporter_email) DO NOTHING
+	`
+
+	_, err := p.db.Exec(query, flag.SightingID, flag.ReporterEmail, flag.Reason)
+	if err != nil {
+		return fmt.Errorf("failed to create sighting flag: %v", err)
+	}
+
+	return nil
+}
+
+// ResolveSightingFlags resolves the open flags of the sighting.
+func (p *PostgresRepository) ResolveSightingFlags(sightingID int) error {
+	query := `
+		UPDATE sighting_flags
+		SET resolved_at = NOW()
+		WHERE sighting_id = $1 AND resolved_at IS NULL
+	`
+
+	_, err := p.db.Exec(query, sightingID)
+	if err != nil {
+		return fmt.Errorf("failed to resolve sighting flags: %v", err)
+	}
+
+	return nil
+}
+
+// CountReporterSightings returns how many sightings of the reporter have been approved
+// and how many rejected.
+func (p *PostgresRepository) CountReporterSightings(email string) (approved, rejected int, err error) {
+	query := `
+		SELECT COUNT(*) FILTER (WHERE status = 'approved'), COUNT(*) FILTER (WHERE status = 'rejected')
+		FROM tiger_sightings
+		WHERE LOWER(reporter_email) = LOWER($1)
+	`
+
+	err = p.db.QueryRow(query, email).Scan(&approved, &rejected)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count reporter sightings: %v", err)
+	}
+
+	return approved, rejected, nil
+}
+
+// CreateSightingImages saves the renditions of the photos of a sighting.
+func (p *PostgresRepository) CreateSightingImages(images []*models.SightingImage) error {
+	query := `
+		INSERT INTO sighting_images (sighting_id, position, rendition, format, image_key, content_type, width, height)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	for _, image := range images {
+		_, err := p.db.Exec(query, image.SightingID, image.Position, image.Rendition, image.Format, image.ImageKey, image.ContentType, image.Width, image.Height)
+		if err != nil {
+			return fmt.Errorf("failed to create sighting image: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// GetSightingImages returns the renditions of the photos of the sightings, ordered by
+// sighting and photo.
+func (p *PostgresRepository) GetSightingImages(sightingIDs []int) ([]*models.SightingImage, error) {
+	query := `
+		SELECT sighting_id, position, rendition, format, image_key, content_type, width, height
+		FROM sighting_images
+		WHERE sighting_id = ANY($1)
+		ORDER BY sighting_id, position, id
+	`
+
+	rows, err := p.db.Query(query, pq.Array(sightingIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sighting images: %v", err)
+	}
+	defer rows.Close()
+
+	images := []*models.SightingImage{}
+	for rows.Next() {
+		var image models.SightingImage
+		err := rows.Scan(&image.SightingID, &image.Position, &image.Rendition, &image.Format, &image.ImageKey, &image.ContentType, &image.Width, &image.Height)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sighting image: %v", err)
+		}
+		images = append(images, &image)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error processing sighting images rows: %v", err)
+	}
+
+	return images, nil
+}
+
+// GetSightingImage returns the photo at position of the sighting in the rendition and format.
+func (p *PostgresRepository) GetSightingImage(sightingID, position int, rendition, format string) (*models.SightingImage, error) {
+	query := `
+		SELECT sighting_id, position, rendition, format, image_key, content_type, width, height
+		FROM sighting_images
+		WHERE sighting_id = $1 AND position = $2 AND rendition = $3 AND format = $4
+	`
+
+	var image models.SightingImage
+	err := p.db.QueryRow(query, sightingID, position, rendition, format).
+		Scan(&image.SightingID, &image.Position, &image.Rendition, &image.Format, &image.ImageKey, &image.ContentType, &image.Width, &image.Height)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get sighting image: %v", err)
+	}
+
+	return &image, nil
+}
+
+// GetDailySightingCounts returns the number of approved sightings of every day from from
+// up to to with sightings, oldest first. Days are cut at midnight UTC.
+func (p *PostgresRepository) GetDailySightingCounts(from, to time.Time) ([]*models.DailySightings, error) {
+	query := `
+		SELECT date_trunc('day', timestamp) AS day, COUNT(*)
+		FROM tiger_sightings
+		WHERE status = 'approved' AND timestamp >= $1 AND timestamp < $2
+		GROUP BY day
+		ORDER BY day ASC
+	`
+
+	rows, err := p.db.Query(query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily sighting counts: %v", err)
+	}
+	defer rows.Close()
+
+	days := []*models.DailySightings{}
+	for rows.Next() {
+		day := &models.DailySightings{}
+		if err := rows.Scan(&day.Day, &day.Sightings); err != nil {
+			return nil, fmt.Errorf("failed to scan daily sighting count: %v", err)
+		}
+		days = append(days, day)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error processing daily sighting count rows: %v", err)
+	}
+
+	return days, nil
+}
+
+// GetMostActiveTigers returns the limit tigers with the most approved sightings from from
+// up to to, most sightings first. Deleted tigers are left out.
+func (p *PostgresRepository) GetMostActiveTigers(from, to time.Time, limit int) ([]*models.ActiveTiger, error) {
+	query := `
+		SELECT t.id, t.name, COUNT(*) AS sightings
+		FROM tiger_sightings s
+		JOIN tigers t ON t.id = s.tiger_id AND t.deleted_at IS NULL
+		WHERE s.status = 'approved' AND s.timestamp >= $1 AND s.timestamp < $2
+		GROUP BY t.id
+		ORDER BY sightings DESC, t.id ASC
+		LIMIT $3
+	`
+
+	rows, err := p.db.Query(query, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get most active tigers: %v", err)
+	}
+	defer rows.Close()
+
+	tigers := []*models.ActiveTiger{}
+	for rows.Next() {
+		tiger := &models.ActiveTiger{}
+		if err := rows.Scan(&tiger.TigerID, &tiger.Name, &tiger.Sightings); err != nil {
+			return nil, fmt.Errorf("failed to scan active tiger: %v", err)
+		}
+		tigers = append(tigers, tiger)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error processing active tiger rows: %v", err)
 	}
 
-	return &previousSighting, nil
+	return tigers, nil
 }
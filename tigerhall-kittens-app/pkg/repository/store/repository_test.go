@@ -1,10 +1,12 @@
 package store
 
 import (
+	"regexp"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"tigerhall-kittens-app/pkg/models"
 )
@@ -29,12 +31,13 @@ func TestPostgresRepository_CreateUser(t *testing.T) {
 	}
 
 	// Expect the INSERT query to be executed
-	mock.ExpectExec("INSERT INTO users").
+	mock.ExpectQuery("INSERT INTO users (.+) RETURNING id").
 		WithArgs(user.Username, user.Email, user.Password).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
 	err = repo.CreateUser(user)
 	assert.NoError(t, err)
+	assert.Equal(t, 1, user.ID)
 
 	// Check if all expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -54,17 +57,18 @@ func TestPostgresRepository_GetUserByEmail(t *testing.T) {
 	// Test case data
 	email := "testuser@example.com"
 	user := &models.User{
-		ID:       1,
-		Username: "testuser",
-		Email:    email,
-		Password: "testpassword",
+		ID:            1,
+		Username:      "testuser",
+		Email:         email,
+		Password:      "testpassword",
+		EmailVerified: true,
 	}
 
 	// Mock the SELECT query to return the test case data
 	mock.ExpectQuery("SELECT id, username, email, password").
 		WithArgs(email).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "password"}).
-			AddRow(user.ID, user.Username, user.Email, user.Password))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "password", "email_verified"}).
+			AddRow(user.ID, user.Username, user.Email, user.Password, true))
 
 	resultUser, err := repo.GetUserByEmail(email)
 	assert.NoError(t, err)
@@ -76,6 +80,94 @@ func TestPostgresRepository_GetUserByEmail(t *testing.T) {
 	}
 }
 
+func TestPostgresRepository_CreateUser_DuplicateEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// Mock the INSERT query to violate the unique email index
+	mock.ExpectQuery("INSERT INTO users").
+		WithArgs("testuser", "testuser@example.com", "testpassword").
+		WillReturnError(&pq.Error{Code: "23505"})
+
+	err = repo.CreateUser(&models.User{Username: "testuser", Email: "testuser@example.com", Password: "testpassword"})
+	assert.Equal(t, ErrAlreadyExists, err)
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_LockUserToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// Mock the query to return a used token
+	expiresAt := time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC)
+	usedAt := expiresAt.Add(-time.Minute)
+	mock.ExpectQuery("SELECT id, user_id, purpose, token_hash, expires_at, used_at FROM user_tokens (.+) FOR UPDATE").
+		WithArgs(models.TokenPurposePasswordReset, "hash").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "purpose", "token_hash", "expires_at", "used_at"}).
+			AddRow(3, 1, models.TokenPurposePasswordReset, "hash", expiresAt, usedAt))
+	mock.ExpectQuery("SELECT (.+) FROM user_tokens").
+		WithArgs(models.TokenPurposeEmailVerification, "hash").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "purpose", "token_hash", "expires_at", "used_at"}))
+
+	token, err := repo.LockUserToken(models.TokenPurposePasswordReset, "hash")
+	assert.NoError(t, err)
+	assert.Equal(t, &models.UserToken{ID: 3, UserID: 1, Purpose: models.TokenPurposePasswordReset, TokenHash: "hash", ExpiresAt: expiresAt, UsedAt: &usedAt}, token)
+
+	// Tokens are only found for their purpose
+	_, err = repo.LockUserToken(models.TokenPurposeEmailVerification, "hash")
+	assert.Equal(t, ErrNotFound, err)
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_RenewUserToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// Expect a usable token to be renewed, and a used one to be left alone
+	mock.ExpectExec("UPDATE user_tokens SET token_hash = \\$2 WHERE id = \\$1 AND used_at IS NULL AND expires_at > NOW\\(\\)").
+		WithArgs(3, "new hash").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE user_tokens").
+		WithArgs(4, "new hash").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	renewed, err := repo.RenewUserToken(3, "new hash")
+	assert.NoError(t, err)
+	assert.True(t, renewed)
+
+	renewed, err = repo.RenewUserToken(4, "new hash")
+	assert.NoError(t, err)
+	assert.False(t, renewed, "Used or expired tokens should not be renewed")
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
 func TestPostgresRepository_CreateTiger(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -94,13 +186,15 @@ func TestPostgresRepository_CreateTiger(t *testing.T) {
 		Long:        78.91011,
 	}
 
-	// Mock the INSERT query to return success
-	mock.ExpectExec("INSERT INTO tigers").
+	// Mock the INSERT query to return the new ID and version
+	mock.ExpectQuery("INSERT INTO tigers (.+) RETURNING id, version").
 		WithArgs(tiger.Name, tiger.DateOfBirth, tiger.LastSeen, tiger.Lat, tiger.Long).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "version"}).AddRow(7, 1))
 
 	err = repo.CreateTiger(tiger)
 	assert.NoError(t, err)
+	assert.Equal(t, 7, tiger.ID)
+	assert.Equal(t, 1, tiger.Version)
 
 	// Check if all expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -123,13 +217,15 @@ func TestPostgresRepository_CreateTigerSighting(t *testing.T) {
 		Timestamp:     time.Now(),
 		Lat:           12.3456,
 		Long:          78.91011,
-		Image:         []byte("test image data"),
+		ImageKey:      "sightings/1/image.jpeg",
 		ReporterEmail: "testuser@example.com",
+		Status:        models.SightingPending,
 	}
 
 	// Mock the INSERT query to return the test case data
 	mock.ExpectQuery("INSERT INTO tiger_sightings").
-		WithArgs(tigerSighting.TigerID, tigerSighting.Timestamp, tigerSighting.Lat, tigerSighting.Long, tigerSighting.Image, tigerSighting.ReporterEmail).
+		WithArgs(tigerSighting.TigerID, tigerSighting.Timestamp, tigerSighting.Lat, tigerSighting.Long, tigerSighting.ImageKey, tigerSighting.ReporterEmail, tigerSighting.Status,
+			tigerSighting.PhotoLat, tigerSighting.PhotoLong, tigerSighting.PhotoTimestamp, tigerSighting.PhotoMismatch).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
 	err = repo.CreateTigerSighting(tigerSighting)
@@ -141,7 +237,7 @@ func TestPostgresRepository_CreateTigerSighting(t *testing.T) {
 	}
 }
 
-func TestPostgresRepository_GetPreviousTigerSighting(t *testing.T) {
+func TestPostgresRepository_GetAdjacentTigerSightings(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("failed to create mock database connection: %v", err)
@@ -152,35 +248,949 @@ func TestPostgresRepository_GetPreviousTigerSighting(t *testing.T) {
 
 	// Test case data
 	tigerID := 1
-	tigerSighting := &models.TigerSighting{
+	timestamp := time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC)
+	earlier := &models.TigerSighting{
 		ID:            1,
 		TigerID:       tigerID,
-		Timestamp:     time.Now(),
+		Timestamp:     timestamp.Add(-time.Hour),
 		Lat:           12.3456,
 		Long:          78.91011,
-		Image:         []byte("sample-image"),
+		ImageKey:      "sightings/1/image.jpeg",
 		ReporterEmail: "reporter@example.com",
+		Status:        models.SightingPending,
+	}
+	later := &models.TigerSighting{
+		ID:            2,
+		TigerID:       tigerID,
+		Timestamp:     timestamp.Add(time.Hour),
+		Lat:           12.4456,
+		Long:          78.91011,
+		ReporterEmail: "ranger@example.com",
+		Status:        models.SightingApproved,
 	}
 
-	// Mock the query to return a single row result
-	mock.ExpectQuery("SELECT id, tiger_id, timestamp, lat, long, image, reporter_Email FROM tiger_sightings").
-		WithArgs(tigerID).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "tiger_id", "timestamp", "lat", "long", "image", "reporter_Email"}).
-			AddRow(tigerSighting.ID, tigerSighting.TigerID, tigerSighting.Timestamp, tigerSighting.Lat, tigerSighting.Long, tigerSighting.Image, tigerSighting.ReporterEmail))
+	// Mock the query to return the sighting on either side, only the pending sightings of
+	// the reporter are kept
+	mock.ExpectQuery("SELECT id, tiger_id, timestamp, lat, long, (.+), reporter_Email, status FROM tiger_sightings WHERE tiger_id = \\$1 AND \\(status = 'approved' OR \\(status = 'pending' AND reporter_Email = \\$2\\)\\) AND timestamp <= \\$3 (.+) UNION ALL").
+		WithArgs(tigerID, "reporter@example.com", timestamp).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tiger_id", "timestamp", "lat", "long", "image_key", "reporter_Email", "status"}).
+			AddRow(earlier.ID, earlier.TigerID, earlier.Timestamp, earlier.Lat, earlier.Long, earlier.ImageKey, earlier.ReporterEmail, earlier.Status).
+			AddRow(later.ID, later.TigerID, later.Timestamp, later.Lat, later.Long, later.ImageKey, later.ReporterEmail, later.Status))
 
 	// Call the function
-	previousSighting, err := repo.GetPreviousTigerSighting(tigerID)
+	before, after, err := repo.GetAdjacentTigerSightings(tigerID, "reporter@example.com", timestamp)
 
 	// Check the result
 	assert.NoError(t, err)
-	assert.NotNil(t, previousSighting)
-	assert.Equal(t, tigerSighting.ID, previousSighting.ID)
-	assert.Equal(t, tigerSighting.TigerID, previousSighting.TigerID)
-	assert.Equal(t, tigerSighting.Timestamp, previousSighting.Timestamp)
-	assert.Equal(t, tigerSighting.Lat, previousSighting.Lat)
-	assert.Equal(t, tigerSighting.Long, previousSighting.Long)
-	assert.Equal(t, tigerSighting.Image, previousSighting.Image)
-	assert.Equal(t, tigerSighting.ReporterEmail, previousSighting.ReporterEmail)
+	assert.Equal(t, earlier, before)
+	assert.Equal(t, later, after)
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_GetTigerSightingsBetween(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	from := time.Date(2023, time.July, 21, 11, 0, 0, 0, time.UTC)
+	to := from.Add(2 * time.Hour)
+	// The pending sightings of other reporters are left out
+	mock.ExpectQuery("SELECT (.+) FROM tiger_sightings WHERE tiger_id = \\$1 AND \\(status = 'approved' OR \\(status = 'pending' AND reporter_Email = \\$2\\)\\) AND timestamp BETWEEN \\$3 AND \\$4").
+		WithArgs(1, "reporter@example.com", from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tiger_id", "timestamp", "lat", "long", "image_key", "reporter_Email", "status"}).
+			AddRow(1, 1, from.Add(time.Hour), 12.34, 56.78, "", "reporter@example.com", models.SightingApproved))
+
+	sightings, err := repo.GetTigerSightingsBetween(1, "reporter@example.com", from, to)
+	assert.NoError(t, err)
+	if assert.Len(t, sightings, 1) {
+		assert.Equal(t, from.Add(time.Hour), sightings[0].Timestamp)
+	}
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_LegacySightingImages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// Expect the legacy image to be read and moved once
+	mock.ExpectQuery("SELECT id, tiger_id, image FROM tiger_sightings WHERE image_key IS NULL AND image IS NOT NULL").
+		WithArgs(50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tiger_id", "image"}).AddRow(3, 1, []byte("image data")))
+	mock.ExpectExec("UPDATE tiger_sightings SET image_key = \\$2, image = NULL WHERE id = \\$1 AND image_key IS NULL").
+		WithArgs(3, "sightings/1/image.jpeg").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	sightings, err := repo.GetLegacySightingImages(50)
+	assert.NoError(t, err)
+	assert.Equal(t, []*models.TigerSighting{{ID: 3, TigerID: 1, Photos: [][]byte{[]byte("image data")}}}, sightings)
+	assert.NoError(t, repo.MoveLegacySightingImage(3, "sightings/1/image.jpeg"))
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_GetTigerByID_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// Mock the SELECT query to return no rows, as for a deleted tiger
+	mock.ExpectQuery("SELECT id, name, date_of_birth, last_seen, lat, long, version FROM tigers").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "date_of_birth", "last_seen", "lat", "long", "version"}))
+
+	tiger, err := repo.GetTigerByID(1)
+	assert.Nil(t, tiger)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_UpdateTiger(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// Test case data
+	tiger := &models.Tiger{
+		ID:          1,
+		Name:        "Tiger 1",
+		DateOfBirth: time.Date(2018, 1, 15, 0, 0, 0, 0, time.UTC),
+		Version:     2,
+	}
+
+	// Mock the UPDATE query to return the bumped version
+	mock.ExpectQuery("UPDATE tigers").
+		WithArgs(tiger.Name, tiger.DateOfBirth, tiger.ID, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+
+	err = repo.UpdateTiger(tiger)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, tiger.Version)
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_UpdateTiger_VersionConflict(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// Test case data
+	tiger := &models.Tiger{
+		ID:          1,
+		Name:        "Tiger 1",
+		DateOfBirth: time.Date(2018, 1, 15, 0, 0, 0, 0, time.UTC),
+		Version:     2,
+	}
+
+	// The UPDATE matches no row because the version has moved on, while the tiger still exists
+	mock.ExpectQuery("UPDATE tigers").
+		WithArgs(tiger.Name, tiger.DateOfBirth, tiger.ID, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectQuery("SELECT id, name, date_of_birth, last_seen, lat, long, version FROM tigers").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "date_of_birth", "last_seen", "lat", "long", "version"}).
+			AddRow(1, "Tiger 1", tiger.DateOfBirth, time.Now(), 12.3456, 78.91011, 3))
+
+	err = repo.UpdateTiger(tiger)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_DeleteTiger(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// Expect a soft delete, the second call finds no active tiger
+	mock.ExpectExec("UPDATE tigers SET deleted_at = NOW()").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE tigers SET deleted_at = NOW()").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, repo.DeleteTiger(1))
+	assert.ErrorIs(t, repo.DeleteTiger(1), ErrNotFound)
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_WithTx_Commit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// Test case data
+	tigerSighting := &models.TigerSighting{
+		TigerID:   1,
+		Timestamp: time.Now(),
+		Lat:       12.3456,
+		Long:      78.91011,
+	}
+
+	// Expect the lock and the last seen update to run in one transaction
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM tigers WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "date_of_birth", "last_seen", "lat", "long", "version"}).
+			AddRow(1, "Tiger 1", time.Now(), time.Now(), 12.3456, 78.91011, 1))
+	mock.ExpectExec("UPDATE tigers SET last_seen").
+		WithArgs(tigerSighting.TigerID, tigerSighting.Timestamp, tigerSighting.Lat, tigerSighting.Long).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.WithTx(func(tx *PostgresRepository) error {
+		if _, err := tx.LockTiger(1); err != nil {
+			return err
+		}
+		return tx.UpdateTigerLastSeen(tigerSighting)
+	})
+	assert.NoError(t, err)
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_WithTx_Rollback(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// A missing tiger aborts the transaction
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM tigers WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "date_of_birth", "last_seen", "lat", "long", "version"}))
+	mock.ExpectRollback()
+
+	err = repo.WithTx(func(tx *PostgresRepository) error {
+		_, err := tx.LockTiger(1)
+		return err
+	})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_GetTigersInBoundingBox(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// Test case data
+	box := models.BoundingBox{MinLat: 12, MinLong: 56, MaxLat: 13, MaxLong: 57}
+
+	// Expect the box to be passed as (long, lat) corners
+	mock.ExpectQuery("SELECT (.+) FROM tigers WHERE point\\(long, lat\\) <@ box").
+		WithArgs(box.MinLong, box.MinLat, box.MaxLong, box.MaxLat).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "date_of_birth", "last_seen", "lat", "long", "version"}).
+			AddRow(1, "Tiger 1", time.Now(), time.Now(), 12.3456, 56.91011, 1))
+
+	tigers, err := repo.GetTigersInBoundingBox(box)
+	assert.NoError(t, err)
+	assert.Len(t, tigers, 1)
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_StreamTigerSightings(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// Mock the query to return two sightings, oldest first
+	first := time.Date(2023, time.July, 20, 12, 0, 0, 0, time.UTC)
+	second := time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT (.+) FROM tiger_sightings WHERE tiger_id = \\$1 AND status = 'approved' ORDER BY timestamp ASC").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tiger_id", "timestamp", "lat", "long", "image_key", "reporter_Email", "status"}).
+			AddRow(1, 1, first, 12.34, 56.78, "", "reporter@example.com", models.SightingApproved).
+			AddRow(2, 1, second, 12.45, 56.89, "", "reporter@example.com", models.SightingApproved))
+
+	var timestamps []time.Time
+	err = repo.StreamTigerSightings(1, func(sighting *models.TigerSighting) error {
+		timestamps = append(timestamps, sighting.Timestamp)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{first, second}, timestamps)
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_NotificationDeliveries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// Expect the delivery to be recorded and found afterwards
+	mock.ExpectExec("INSERT INTO notification_deliveries").
+		WithArgs("message-1", "test@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT EXISTS (.+) FROM notification_deliveries").
+		WithArgs("message-1", "test@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	assert.NoError(t, repo.MarkNotificationDelivered("message-1", "test@example.com"))
+	delivered, err := repo.IsNotificationDelivered("message-1", "test@example.com")
+	assert.NoError(t, err)
+	assert.True(t, delivered)
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_GetNotificationPreference(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// Mock the query for a user who opted out of the tiger
+	mock.ExpectQuery("SELECT (.+) FROM tiger_subscriptions (.+) FROM notification_preferences").
+		WithArgs("test@example.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"subscribed", "digest"}).AddRow(false, true))
+
+	preference, err := repo.GetNotificationPreference("test@example.com", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, &models.NotificationPreference{Subscribed: false, Digest: true}, preference)
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_GetTigerFollowers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// Mock the query for the users subscribed to the tiger
+	mock.ExpectQuery("SELECT email FROM tiger_subscriptions WHERE tiger_id = (.+) AND subscribed").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("follower@example.com"))
+
+	followers, err := repo.GetTigerFollowers(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"follower@example.com"}, followers)
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_SightingAlerts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+	tigerID := 1
+	createdAt := time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC)
+	alert := &models.SightingAlert{Email: "test@example.com", Name: "Camp", Lat: 12.34, Long: 56.78, RadiusKm: 10, TigerID: &tigerID, Channel: models.AlertChannelWebhook, WebhookURL: "https://example.com/hook", WebhookSecret: "secret"}
+	area := models.BoundingBox{MinLat: 12, MinLong: 56, MaxLat: 13, MaxLong: 57}
+	columns := []string{"id", "email", "name", "lat", "long", "radius_km", "tiger_id", "channel", "webhook_url", "webhook_secret", "created_at"}
+
+	// Mock the insert of the alert with the box of its circle
+	mock.ExpectQuery("INSERT INTO sighting_alerts (.+) RETURNING id, created_at").
+		WithArgs("test@example.com", "Camp", 12.34, 56.78, 10.0, 56.0, 12.0, 57.0, 13.0, &tigerID, models.AlertChannelWebhook, "https://example.com/hook", "secret").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(5, createdAt))
+
+	// Mock the query for the alerts around a sighting, the box takes the longitude first
+	mock.ExpectQuery("SELECT (.+) FROM sighting_alerts WHERE area @> point(.+) AND (.+)tiger_id = ").
+		WithArgs(56.78, 12.34, 1).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(5, "test@example.com", "Camp", 12.34, 56.78, 10.0, 1, models.AlertChannelWebhook, "https://example.com/hook", "secret", createdAt).
+			AddRow(6, "test@example.com", "River", 12.3, 56.7, 5.0, nil, models.AlertChannelEmail, "", "", createdAt))
+
+	// Mock deleting an alert of another user
+	mock.ExpectExec("DELETE FROM sighting_alerts WHERE id = (.+) AND email = ").
+		WithArgs(5, "other@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, repo.CreateSightingAlert(alert, area))
+	assert.Equal(t, 5, alert.ID)
+	assert.Equal(t, createdAt, alert.CreatedAt)
+
+	alerts, err := repo.GetSightingAlertsAt(models.Coordinates{Lat: 12.34, Long: 56.78}, 1)
+	assert.NoError(t, err)
+	if assert.Len(t, alerts, 2) {
+		assert.Equal(t, alert, alerts[0])
+		assert.Nil(t, alerts[1].TigerID, "Alerts for every tiger should have no tiger")
+	}
+
+	assert.ErrorIs(t, repo.DeleteSightingAlert(5, "other@example.com"), ErrNotFound)
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_MarkDigestItemsSent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	mock.ExpectExec("UPDATE notification_digest_items SET sent_at = NOW()").
+		WithArgs("test@example.com", 7).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	assert.NoError(t, repo.MarkDigestItemsSent("test@example.com", 7))
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_WebhookDeliveries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// Expect the delivery to be queued once, claimed with the webhook of its alert and retried
+	mock.ExpectExec("INSERT INTO webhook_deliveries (.+) ON CONFLICT \\(message_id, alert_id\\) DO NOTHING").
+		WithArgs("message-1", 2, []byte(`{}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE webhook_deliveries SET attempts = attempts \\+ 1, (.+) FOR UPDATE SKIP LOCKED (.+) JOIN sighting_alerts").
+		WithArgs(8, float64(300)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "alert_id", "webhook_url", "webhook_secret", "payload", "attempts"}).
+			AddRow(int64(1), 2, "https://example.com/hook", "secret", []byte(`{}`), 1))
+	mock.ExpectExec("UPDATE webhook_deliveries SET next_attempt_at = NOW\\(\\) \\+ \\$2 \\* INTERVAL '1 second', last_error = \\$3").
+		WithArgs(int64(1), float64(5), "webhook responded with 502").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, repo.QueueWebhookDelivery("message-1", 2, []byte(`{}`)))
+	deliveries, err := repo.ClaimWebhookDeliveries(8, 5*time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, []*models.WebhookDelivery{{ID: 1, AlertID: 2, URL: "https://example.com/hook", Secret: "secret", Payload: []byte(`{}`), Attempts: 1}}, deliveries)
+	assert.NoError(t, repo.RetryWebhookDelivery(1, 5*time.Second, "webhook responded with 502"))
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_GetPendingOutboxMessages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// Mock the query to return one pending message
+	createdAt := time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT id, payload, created_at FROM outbox_messages WHERE sent_at IS NULL (.+) FOR UPDATE SKIP LOCKED").
+		WithArgs(100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "payload", "created_at"}).AddRow(int64(1), []byte(`{}`), createdAt))
+
+	messages, err := repo.GetPendingOutboxMessages(100)
+	assert.NoError(t, err)
+	assert.Equal(t, []*models.OutboxMessage{{ID: 1, Payload: []byte(`{}`), CreatedAt: createdAt}}, messages)
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_GetModerationQueue(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// Mock the query to return a pending and a flagged sighting
+	timestamp := time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT (.+) FROM tiger_sightings s JOIN tigers t ON t.id = s.tiger_id AND t.deleted_at IS NULL LEFT JOIN sighting_flags f (.+) WHERE s.status = 'pending' OR").
+		WithArgs(10, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tiger_id", "timestamp", "lat", "long", "image_key", "reporter_Email", "status", "photo_lat", "photo_long", "photo_timestamp", "photo_mismatch", "open_flags", "total_count"}).
+			AddRow(3, 1, timestamp, 12.34, 56.78, "", "reporter@example.com", models.SightingPending, 12.84, 56.78, timestamp, "photo was taken 55.6 km away", 0, 12).
+			AddRow(4, 1, timestamp, 12.45, 56.89, "", "reporter@example.com", models.SightingApproved, nil, nil, nil, "", 2, 12))
+
+	sightings, totalCount, err := repo.GetModerationQueue(2, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 12, totalCount)
+	if assert.Len(t, sightings, 2) {
+		assert.Equal(t, models.SightingPending, sightings[0].Status)
+		if assert.NotNil(t, sightings[0].PhotoLat) {
+			assert.Equal(t, 12.84, *sightings[0].PhotoLat)
+		}
+		assert.Equal(t, "photo was taken 55.6 km away", sightings[0].PhotoMismatch)
+		assert.Nil(t, sightings[1].PhotoTimestamp)
+		assert.Equal(t, 2, sightings[1].OpenFlags)
+	}
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_CountReporterSightings(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM tiger_sightings WHERE LOWER\\(reporter_email\\) = LOWER\\(\\$1\\)").
+		WithArgs("reporter@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"approved", "rejected"}).AddRow(7, 1))
+
+	approved, rejected, err := repo.CountReporterSightings("reporter@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, 7, approved)
+	assert.Equal(t, 1, rejected)
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_CreateSightingImages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	images := []*models.SightingImage{
+		{SightingID: 3, Position: 0, Rendition: "original", Format: "jpeg", ImageKey: "sightings/1/a.jpeg", ContentType: "image/jpeg", Width: 800, Height: 600},
+		{SightingID: 3, Position: 0, Rendition: "thumbnail", Format: "png", ImageKey: "sightings/1/b.png", ContentType: "image/png", Width: 200, Height: 200},
+	}
+	for _, image := range images {
+		mock.ExpectExec("INSERT INTO sighting_images").
+			WithArgs(image.SightingID, image.Position, image.Rendition, image.Format, image.ImageKey, image.ContentType, image.Width, image.Height).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+
+	err = repo.CreateSightingImages(images)
+	assert.NoError(t, err)
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_GetSightingImages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	columns := []string{"sighting_id", "position", "rendition", "format", "image_key", "content_type", "width", "height"}
+	mock.ExpectQuery("SELECT (.+) FROM sighting_images WHERE sighting_id = ANY\\(\\$1\\)").
+		WithArgs(pq.Array([]int{3, 4})).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(3, 0, "original", "jpeg", "sightings/1/a.jpeg", "image/jpeg", 800, 600).
+			AddRow(3, 1, "original", "png", "sightings/1/b.png", "image/png", 400, 300))
+
+	images, err := repo.GetSightingImages([]int{3, 4})
+	assert.NoError(t, err)
+	if assert.Len(t, images, 2) {
+		assert.Equal(t, &models.SightingImage{SightingID: 3, Position: 1, Rendition: "original", Format: "png", ImageKey: "sightings/1/b.png", ContentType: "image/png", Width: 400, Height: 300}, images[1])
+	}
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_GetSightingImage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	columns := []string{"sighting_id", "position", "rendition", "format", "image_key", "content_type", "width", "height"}
+	mock.ExpectQuery("SELECT (.+) FROM sighting_images WHERE sighting_id = \\$1 AND position = \\$2 AND rendition = \\$3 AND format = \\$4").
+		WithArgs(3, 0, "medium", "jpeg").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(3, 0, "medium", "jpeg", "sightings/1/c.jpeg", "image/jpeg", 1024, 768))
+	mock.ExpectQuery("SELECT (.+) FROM sighting_images").
+		WithArgs(3, 1, "medium", "jpeg").
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	image, err := repo.GetSightingImage(3, 0, "medium", "jpeg")
+	assert.NoError(t, err)
+	assert.Equal(t, "sightings/1/c.jpeg", image.ImageKey)
+
+	_, err = repo.GetSightingImage(3, 1, "medium", "jpeg")
+	assert.ErrorIs(t, err, ErrNotFound, "Missing image should not be found")
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_GetTigersAfter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	columns := []string{"id", "name", "date_of_birth", "last_seen", "lat", "long", "version"}
+	lastSeen := time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC)
+
+	// The first page has no cursor condition
+	mock.ExpectQuery("SELECT (.+) FROM tigers WHERE deleted_at IS NULL ORDER BY last_seen DESC, id DESC LIMIT \\$1").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(3, "Tiger 3", time.Now(), lastSeen, 12.34, 56.78, 1).
+			AddRow(2, "Tiger 2", time.Now(), lastSeen, 12.34, 56.78, 1))
+	// The next page starts after the last tiger of the first
+	mock.ExpectQuery("SELECT (.+) FROM tigers WHERE deleted_at IS NULL AND \\(last_seen, id\\) < \\(\\$1, \\$2\\) ORDER BY last_seen DESC, id DESC LIMIT \\$3").
+		WithArgs(lastSeen, 2, 2).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "Tiger 1", time.Now(), lastSeen, 12.34, 56.78, 1))
+
+	tigers, err := repo.GetTigersAfter(models.TigerFilter{}, nil, 2)
+	assert.NoError(t, err)
+	assert.Len(t, tigers, 2)
+
+	tigers, err = repo.GetTigersAfter(models.TigerFilter{}, &models.Cursor{Time: lastSeen, ID: 2}, 2)
+	assert.NoError(t, err)
+	if assert.Len(t, tigers, 1) {
+		assert.Equal(t, 1, tigers[0].ID)
+	}
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_GetLatestTigerSightings(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// A single query returns the latest sightings of every tiger
+	timestamp := time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT (.+) FROM \\(.+PARTITION BY tiger_id.+WHERE tiger_id = ANY\\(\\$1\\) AND status = 'approved'.+WHERE rank <= \\$2").
+		WithArgs(pq.Array([]int{1, 2}), 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tiger_id", "timestamp", "lat", "long", "image_key", "reporter_Email", "status"}).
+			AddRow(5, 1, timestamp, 12.34, 56.78, "", "reporter@example.com", models.SightingApproved).
+			AddRow(4, 2, timestamp, 12.45, 56.89, "", "reporter@example.com", models.SightingApproved))
+
+	sightings, err := repo.GetLatestTigerSightings([]int{1, 2}, 3)
+	assert.NoError(t, err)
+	if assert.Len(t, sightings, 2) {
+		assert.Equal(t, 2, sightings[1].TigerID)
+	}
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_GetUsersByEmails(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// Emails are matched in any case
+	mock.ExpectQuery("SELECT (.+) FROM users WHERE LOWER\\(email\\) = ANY\\(\\$1\\)").
+		WithArgs(pq.Array([]string{"ranger@example.com"})).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "verified"}).
+			AddRow(1, "ranger", "ranger@example.com", true))
+
+	users, err := repo.GetUsersByEmails([]string{"Ranger@Example.com"})
+	assert.NoError(t, err)
+	if assert.Len(t, users, 1) {
+		assert.Equal(t, "ranger", users[0].Username)
+	}
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_WithSnapshot(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// The page and the total are read in the same transaction, which is ended afterwards
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM tigers WHERE deleted_at IS NULL ORDER BY last_seen DESC, id DESC LIMIT \\$1 OFFSET \\$2").
+		WithArgs(10, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "date_of_birth", "last_seen", "lat", "long", "version"}).
+			AddRow(1, "Tiger 1", time.Now(), time.Now(), 12.34, 56.78, 1))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM tigers WHERE deleted_at IS NULL").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(11))
+	mock.ExpectRollback()
+
+	var tigers []*models.Tiger
+	var total int
+	err = repo.WithSnapshot(func(tx *PostgresRepository) error {
+		if tigers, err = tx.GetAllTigersWithPagination(models.TigerFilter{}, 2, 10); err != nil {
+			return err
+		}
+		total, err = tx.GetTotalTigerCount(models.TigerFilter{}, false)
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Len(t, tigers, 1)
+	assert.Equal(t, 11, total)
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_EstimateCounts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	// Estimates are read from the plan of the query instead of counting
+	mock.ExpectQuery("EXPLAIN \\(FORMAT JSON\\) SELECT id FROM tigers WHERE deleted_at IS NULL").
+		WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow(`[{"Plan": {"Node Type": "Seq Scan", "Plan Rows": 1200}}]`))
+	mock.ExpectQuery("EXPLAIN \\(FORMAT JSON\\) SELECT id FROM tiger_sightings WHERE tiger_id = \\$1 AND status = 'approved'").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow(`[{"Plan": {"Node Type": "Index Scan", "Plan Rows": 35}}]`))
+
+	tigers, err := repo.GetTotalTigerCount(models.TigerFilter{}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1200, tigers)
+
+	sightings, err := repo.GetTigerSightingsCountByID(7, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 35, sightings)
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_FilterTigers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	bornFrom := time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC)
+	seenTo := time.Date(2023, time.July, 21, 0, 0, 0, 0, time.UTC)
+	filter := models.TigerFilter{
+		NamePrefix: "50%_off",
+		NameLike:   "shere kan",
+		BornFrom:   &bornFrom,
+		SeenTo:     &seenTo,
+		Region:     &models.BoundingBox{MinLat: 10, MinLong: 20, MaxLat: 30, MaxLong: 40},
+		Sort:       models.TigerSortName,
+		Ascending:  true,
+	}
+
+	// Every value is passed as an argument, the wildcards of the prefix are escaped
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, name, date_of_birth, last_seen, lat, long, version FROM tigers "+
+		"WHERE deleted_at IS NULL AND name ILIKE $1 AND name % $2 AND date_of_birth >= $3 AND last_seen <= $4 "+
+		"AND point(long, lat) <@ box(point($5, $6), point($7, $8)) AND (LOWER(name), id) > (LOWER($9), $10) "+
+		"ORDER BY LOWER(name) ASC, id ASC LIMIT $11")).
+		WithArgs(`50\%\_off%`, "shere kan", bornFrom, seenTo, 20.0, 10.0, 40.0, 30.0, "Raja", 4, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "date_of_birth", "last_seen", "lat", "long", "version"}).
+			AddRow(2, "Shere Khan", bornFrom, seenTo, 12.34, 56.78, 1))
+	// Counts are filtered the same way, without the order
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM tigers WHERE deleted_at IS NULL AND date_of_birth >= $1")).
+		WithArgs(bornFrom).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	tigers, err := repo.GetTigersAfter(filter, &models.Cursor{Text: "Raja", ID: 4}, 5)
+	assert.NoError(t, err)
+	assert.Len(t, tigers, 1)
+
+	total, err := repo.GetTotalTigerCount(models.TigerFilter{BornFrom: &bornFrom, Sort: models.TigerSortDateOfBirth}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+
+	_, err = repo.GetAllTigersWithPagination(models.TigerFilter{Sort: "weight"}, 1, 10)
+	assert.Error(t, err, "Unknown sort fields should be rejected")
+
+	// Check if all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("failed to meet expectations: %v", err)
+	}
+}
+
+func TestPostgresRepository_PopulationStats(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepository(db)
+
+	from := time.Date(2023, time.July, 15, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, time.July, 22, 0, 0, 0, 0, time.UTC)
+
+	// Only approved sightings are counted, per day and per tiger
+	mock.ExpectQuery("SELECT date_trunc\\('day', timestamp\\) AS day, COUNT\\(\\*\\) FROM tiger_sightings WHERE status = 'approved' AND timestamp >= \\$1 AND timestamp < \\$2 GROUP BY day").
+		WithArgs(from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"day", "count"}).
+			AddRow(time.Date(2023, time.July, 16, 0, 0, 0, 0, time.UTC), 3).
+			AddRow(time.Date(2023, time.July, 18, 0, 0, 0, 0, time.UTC), 1))
+	mock.ExpectQuery("SELECT t.id, t.name, COUNT\\(\\*\\) AS sightings FROM tiger_sightings s JOIN tigers t ON t.id = s.tiger_id AND t.deleted_at IS NULL WHERE s.status = 'approved'.+LIMIT \\$3").
+		WithArgs(from, to, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "sightings"}).AddRow(1, "Raja", 4))
+
+	days, err := repo.GetDailySightingCounts(from, to)
+	assert.NoError(t, err)
+	if assert.Len(t, days, 2) {
+		assert.Equal(t, 3, days[0].Sightings)
+	}
+
+	tigers, err := repo.GetMostActiveTigers(from, to, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []*models.ActiveTiger{{TigerID: 1, Name: "Raja", Sightings: 4}}, tigers)
 
 	// Check if all expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
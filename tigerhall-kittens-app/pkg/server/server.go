@@ -7,36 +7,127 @@ import (
 
 	"github.com/gorilla/mux"
 	"tigerhall-kittens-app/pkg/auth"
+	"tigerhall-kittens-app/pkg/graphql"
 	"tigerhall-kittens-app/pkg/handlers"
 	"tigerhall-kittens-app/pkg/middleware"
+	"tigerhall-kittens-app/pkg/models"
+	"tigerhall-kittens-app/pkg/ratelimit"
 	"tigerhall-kittens-app/pkg/service"
 )
 
 type server struct {
-	router *mux.Router
-	logger *log.Logger
+	router       *mux.Router
+	logger       *log.Logger
+	rateLimits   RateLimits
+	uploadLimits handlers.UploadLimits
+}
+
+// RateLimits are the limits of the routes, nothing is limited without a Limiter.
+type RateLimits struct {
+	Limiter *middleware.RateLimiter
+	// Account limits signup, login and the other account routes
+	Account ratelimit.Limit
+	// Public limits the other routes that do not need authentication
+	Public ratelimit.Limit
+	// User limits the routes that need authentication, per client IP and per user
+	User ratelimit.Limit
+	// Lockout locks accounts after repeated failed logins, when set
+	Lockout *ratelimit.Lockout
 }
 
 func NewServer() *server {
 	return &server{
-		router: mux.NewRouter(),
-		logger: log.New(os.Stdout, "[Tigerhall Kittens] ", log.LstdFlags),
+		router:       mux.NewRouter(),
+		logger:       log.New(os.Stdout, "[Tigerhall Kittens] ", log.LstdFlags),
+		uploadLimits: handlers.DefaultUploadLimits,
 	}
 }
 
+// UseRateLimits limits the routes set up afterwards with SetupRoutes.
+func (s *server) UseRateLimits(limits RateLimits) {
+	s.rateLimits = limits
+}
+
+// UseUploadLimits bounds the sighting uploads of the routes set up afterwards with SetupRoutes.
+func (s *server) UseUploadLimits(limits handlers.UploadLimits) {
+	s.uploadLimits = limits
+}
+
 func (s *server) SetupRoutes(tigerService service.TigerService, auth *auth.Auth) {
 	handlers := handlers.NewHandlers(tigerService, s.logger, auth)
+	handlers.Lockout = s.rateLimits.Lockout
+	handlers.Uploads = s.uploadLimits
+
+	limiter := s.rateLimits.Limiter
+	account := func(handler http.HandlerFunc) http.Handler {
+		return limiter.Limit("account", s.rateLimits.Account, handler)
+	}
+	public := func(handler http.HandlerFunc) http.Handler {
+		return limiter.Limit("public", s.rateLimits.Public, handler)
+	}
+	// Authenticated routes are limited after authentication, so that the user is known
+	authenticated := func(handler http.Handler) http.Handler {
+		return middleware.AuthMiddleware(auth, limiter.Limit("user", s.rateLimits.User, handler))
+	}
+
+	// Account routes
+	s.router.Handle("/signup", account(handlers.SignupHandler)).Methods("POST")
+	s.router.Handle("/login", account(handlers.LoginHandler)).Methods("POST")
+	s.router.Handle("/token/refresh", account(handlers.RefreshTokenHandler)).Methods("POST")
+	s.router.Handle("/email/verify", account(handlers.VerifyEmailHandler)).Methods("GET")
+	s.router.Handle("/email/verify/resend", account(handlers.ResendVerificationHandler)).Methods("POST")
+	s.router.Handle("/password/forgot", account(handlers.ForgotPasswordHandler)).Methods("POST")
+	s.router.Handle("/password/reset", account(handlers.ResetPasswordHandler)).Methods("POST")
 
 	// Public routes
-	s.router.HandleFunc("/signup", handlers.SignupHandler).Methods("POST")
-	s.router.HandleFunc("/login", handlers.LoginHandler).Methods("POST")
+	s.router.HandleFunc("/.well-known/jwks.json", handlers.JWKSHandler).Methods("GET")
+	s.router.Handle("/tigers", public(handlers.GetAllTigersHandler)).Methods("GET")
+	s.router.Handle("/tigers/nearby", public(handlers.GetTigersNearbyHandler)).Methods("GET")
+	s.router.Handle("/tiger/{id}", public(handlers.GetTigerHandler)).Methods("GET")
+	s.router.Handle("/tiger/{id}/sightings", public(handlers.GetTigerSightingsByIDHandler)).Methods("GET")
+	s.router.Handle("/tiger/{id}/stats", public(handlers.GetTigerStatsHandler)).Methods("GET")
+	s.router.Handle("/stats", public(handlers.GetPopulationStatsHandler)).Methods("GET")
+
+	// Live feeds of the approved sightings, the rate limit applies to opening them
+	s.router.Handle("/ws/sightings", public(handlers.SightingsWebSocketHandler)).Methods("GET")
+	s.router.Handle("/events/sightings", public(handlers.SightingEventsHandler)).Methods("GET")
+	s.router.Handle("/sightings", public(handlers.GetTigerSightingsInAreaHandler)).Methods("GET")
+	s.router.Handle("/sightings/{id}/image", public(handlers.GetTigerSightingImageHandler)).Methods("GET")
+	s.router.Handle("/sightings/{id}/images/{position}/{name}", public(handlers.GetTigerSightingPhotoHandler)).Methods("GET")
 
-	s.router.HandleFunc("/tigers", handlers.GetAllTigersHandler).Methods("GET")
-	s.router.HandleFunc("/tiger/{id}/sightings", handlers.GetTigerSightingsByIDHandler).Methods("GET")
+	// GraphQL, anonymous requests may query and the mutations check the claims themselves
+	s.router.Handle("/graphql", middleware.OptionalAuthMiddleware(auth, public(graphql.NewHandler(tigerService).ServeHTTP))).Methods("POST")
 
 	// Protected routes (require authentication)
-	s.router.Handle("/tiger/create", middleware.AuthMiddleware(auth, http.HandlerFunc(handlers.CreateTigerHandler))).Methods("POST")
-	s.router.Handle("/tiger-sighting/create", middleware.AuthMiddleware(auth, http.HandlerFunc(handlers.CreateTigerSightingHandler))).Methods("POST")
+	s.router.Handle("/logout", authenticated(http.HandlerFunc(handlers.LogoutHandler))).Methods("POST")
+	s.router.Handle("/tiger/{id}/subscription", authenticated(http.HandlerFunc(handlers.SetTigerSubscriptionHandler))).Methods("PUT")
+	s.router.Handle("/notifications/preferences", authenticated(http.HandlerFunc(handlers.SetNotificationPreferencesHandler))).Methods("PUT")
+	s.router.Handle("/notifications/subscriptions", authenticated(http.HandlerFunc(handlers.GetTigerSubscriptionsHandler))).Methods("GET")
+	s.router.Handle("/alerts", authenticated(http.HandlerFunc(handlers.CreateSightingAlertHandler))).Methods("POST")
+	s.router.Handle("/alerts", authenticated(http.HandlerFunc(handlers.GetSightingAlertsHandler))).Methods("GET")
+	s.router.Handle("/alerts/{id}", authenticated(http.HandlerFunc(handlers.DeleteSightingAlertHandler))).Methods("DELETE")
+	s.router.Handle("/tiger-sighting/create", authenticated(http.HandlerFunc(handlers.CreateTigerSightingHandler))).Methods("POST")
+	s.router.Handle("/sightings/{id}/flag", authenticated(http.HandlerFunc(handlers.FlagTigerSightingHandler))).Methods("POST")
+
+	// Ranger routes (require the ranger or admin role)
+	s.router.Handle("/tiger/create", authenticated(middleware.RequireRole(http.HandlerFunc(handlers.CreateTigerHandler), models.RoleRanger, models.RoleAdmin))).Methods("POST")
+	s.router.Handle("/tiger/{id}", authenticated(middleware.RequireRole(http.HandlerFunc(handlers.UpdateTigerHandler), models.RoleRanger, models.RoleAdmin))).Methods("PATCH")
+	s.router.Handle("/tiger/{id}", authenticated(middleware.RequireRole(http.HandlerFunc(handlers.DeleteTigerHandler), models.RoleRanger, models.RoleAdmin))).Methods("DELETE")
+	s.router.Handle("/tiger/{id}/trail.geojson", authenticated(middleware.RequireRole(http.HandlerFunc(handlers.GetTigerTrailHandler), models.RoleRanger, models.RoleAdmin))).Methods("GET")
+	s.router.Handle("/export/sightings.csv", authenticated(middleware.RequireRole(http.HandlerFunc(handlers.ExportTigerSightingsCSVHandler), models.RoleRanger, models.RoleAdmin))).Methods("GET")
+	s.router.Handle("/moderation/sightings", authenticated(middleware.RequireRole(http.HandlerFunc(handlers.GetModerationQueueHandler), models.RoleRanger, models.RoleAdmin))).Methods("GET")
+	s.router.Handle("/moderation/sightings/{id}/image", authenticated(middleware.RequireRole(http.HandlerFunc(handlers.GetModerationSightingImageHandler), models.RoleRanger, models.RoleAdmin))).Methods("GET")
+	s.router.Handle("/moderation/sightings/{id}/images/{position}/{name}", authenticated(middleware.RequireRole(http.HandlerFunc(handlers.GetModerationSightingPhotoHandler), models.RoleRanger, models.RoleAdmin))).Methods("GET")
+	s.router.Handle("/moderation/sightings/{id}/review", authenticated(middleware.RequireRole(http.HandlerFunc(handlers.ReviewTigerSightingHandler), models.RoleRanger, models.RoleAdmin))).Methods("POST")
+
+	// Admin routes (require the admin role)
+	s.router.Handle("/admin/users/{id}/roles/{role}", authenticated(middleware.RequireRole(http.HandlerFunc(handlers.GrantRoleHandler), models.RoleAdmin))).Methods("PUT")
+	s.router.Handle("/admin/users/{id}/roles/{role}", authenticated(middleware.RequireRole(http.HandlerFunc(handlers.RevokeRoleHandler), models.RoleAdmin))).Methods("DELETE")
+}
+
+// ServeHTTP serves the routes set up with SetupRoutes.
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
 }
 
 func (s *server) Start(port string) error {
@@ -1,24 +1,66 @@
 package server_test
 
 import (
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"tigerhall-kittens-app/pkg/auth"
 	"tigerhall-kittens-app/pkg/models"
 	"tigerhall-kittens-app/pkg/server"
+	"tigerhall-kittens-app/pkg/service"
 )
 
 // mockTigerService is a mock implementation of the TigerService interface.
 type mockTigerService struct {
-	signupService               func(user *models.User) error
-	loginService                func(credentials models.LoginCredentials) (*models.User, error)
-	createTigerService          func(tiger models.Tiger) error
-	getAllTigersService         func() ([]*models.Tiger, error)
-	createTigerSightingService  func(newSighting *models.TigerSighting) error
-	getAllTigerSightingsService func(tigerID int) ([]*models.TigerSighting, error)
+	signupService                     func(user *models.User) error
+	loginService                      func(credentials models.LoginCredentials) (*models.User, error)
+	createTigerService                func(tiger *models.Tiger) error
+	getTigerService                   func(tigerID int) (*models.Tiger, error)
+	updateTigerService                func(tigerID int, update models.TigerUpdate) (*models.Tiger, error)
+	deleteTigerService                func(tigerID int) error
+	getAllTigersService               func(filter models.TigerFilter, request models.PageRequest) ([]*models.Tiger, *models.PageInfo, error)
+	createTigerSightingService        func(newSighting *models.TigerSighting) error
+	getTigerSightingsByIDService      func(tigerID int, request models.PageRequest) ([]*models.TigerSighting, *models.PageInfo, error)
+	getTigerSightingImageService      func(sightingID int) (io.ReadCloser, error)
+	getTigersNearbyService            func(center models.Coordinates, radiusKm float64) ([]*models.NearbyTiger, error)
+	getSightingsInAreaService         func(box models.BoundingBox, from, to time.Time, limit int) ([]*models.TigerSighting, error)
+	streamTigerTrailService           func(tigerID int, fn func(*models.TigerSighting) error) error
+	exportTigerSightingsService       func(from, to time.Time, fn func(*models.TigerSighting) error) error
+	setTigerSubscriptionService       func(email string, tigerID int, subscribed bool) error
+	setNotificationPreferencesService func(email string, digest bool) error
+	createRefreshTokenService         func(user *models.User) (string, error)
+	refreshTokenService               func(refreshToken string) (*models.User, string, error)
+	logoutService                     func(email, refreshToken string) error
+	grantRoleService                  func(userID int, role string) error
+	revokeRoleService                 func(userID int, role string) error
+	verifyEmailService                func(token string) error
+	resendVerificationService         func(email string) error
+	forgotPasswordService             func(email string) error
+	resetPasswordService              func(token, password string) error
+	getModerationSightingImageService func(sightingID int) (io.ReadCloser, error)
+	getModerationQueueService         func(page, size int) ([]*models.TigerSighting, int, error)
+	reviewTigerSightingService        func(sightingID int, reviewerEmail, status, note string) (*models.SightingReview, error)
+	flagTigerSightingService          func(sightingID int, email, reason string) error
+	getTigerSightingPhotoService      func(sightingID, position int, rendition, format string) (io.ReadCloser, *models.SightingImage, error)
+	getModerationSightingPhotoService func(sightingID, position int, rendition, format string) (io.ReadCloser, *models.SightingImage, error)
+	getTigersAfterService             func(after *models.Cursor, limit int) ([]*models.Tiger, error)
+	getTigersByIDsService             func(tigerIDs []int) ([]*models.Tiger, error)
+	getTigerSightingsAfterService     func(tigerID int, after *models.Cursor, limit int) ([]*models.TigerSighting, error)
+	getLatestTigerSightingsService    func(tigerIDs []int, limit int) ([]*models.TigerSighting, error)
+	getUsersByEmailsService           func(emails []string) ([]*models.User, error)
+	getTigerStatsService              func(tigerID int) (*models.TigerStats, error)
+	getPopulationStatsService         func(days, limit int) (*models.PopulationStats, error)
+	subscribeSightingsService         func(filter models.SightingFeedFilter) *service.SightingSubscription
+	getTigerSubscriptionsService      func(email string) ([]*models.TigerSubscription, error)
+	createSightingAlertService        func(email string, alert *models.SightingAlert) error
+	getSightingAlertsService          func(email string) ([]*models.SightingAlert, error)
+	deleteSightingAlertService        func(email string, alertID int) error
 }
 
 func (m *mockTigerService) SignupService(user *models.User) error {
@@ -29,20 +71,168 @@ func (m *mockTigerService) LoginService(credentials models.LoginCredentials) (*m
 	return m.loginService(credentials)
 }
 
-func (m *mockTigerService) CreateTigerService(tiger models.Tiger) error {
+func (m *mockTigerService) CreateTigerService(tiger *models.Tiger) error {
 	return m.createTigerService(tiger)
 }
 
-func (m *mockTigerService) GetAllTigersService() ([]*models.Tiger, error) {
-	return m.getAllTigersService()
+func (m *mockTigerService) GetTigerService(tigerID int) (*models.Tiger, error) {
+	return m.getTigerService(tigerID)
+}
+
+func (m *mockTigerService) UpdateTigerService(tigerID int, update models.TigerUpdate) (*models.Tiger, error) {
+	return m.updateTigerService(tigerID, update)
+}
+
+func (m *mockTigerService) DeleteTigerService(tigerID int) error {
+	return m.deleteTigerService(tigerID)
+}
+
+func (m *mockTigerService) GetAllTigersService(filter models.TigerFilter, request models.PageRequest) ([]*models.Tiger, *models.PageInfo, error) {
+	return m.getAllTigersService(filter, request)
 }
 
 func (m *mockTigerService) CreateTigerSightingService(newSighting *models.TigerSighting) error {
 	return m.createTigerSightingService(newSighting)
 }
 
-func (m *mockTigerService) GetAllTigerSightingsService(tigerID int) ([]*models.TigerSighting, error) {
-	return m.getAllTigerSightingsService(tigerID)
+func (m *mockTigerService) GetTigerSightingsByIDService(tigerID int, request models.PageRequest) ([]*models.TigerSighting, *models.PageInfo, error) {
+	return m.getTigerSightingsByIDService(tigerID, request)
+}
+
+func (m *mockTigerService) GetTigerSightingImageService(sightingID int) (io.ReadCloser, error) {
+	return m.getTigerSightingImageService(sightingID)
+}
+
+func (m *mockTigerService) GetTigersNearbyService(center models.Coordinates, radiusKm float64) ([]*models.NearbyTiger, error) {
+	return m.getTigersNearbyService(center, radiusKm)
+}
+
+func (m *mockTigerService) GetTigerSightingsInAreaService(box models.BoundingBox, from, to time.Time, limit int) ([]*models.TigerSighting, error) {
+	return m.getSightingsInAreaService(box, from, to, limit)
+}
+
+func (m *mockTigerService) StreamTigerTrailService(tigerID int, fn func(*models.TigerSighting) error) error {
+	return m.streamTigerTrailService(tigerID, fn)
+}
+
+func (m *mockTigerService) ExportTigerSightingsService(from, to time.Time, fn func(*models.TigerSighting) error) error {
+	return m.exportTigerSightingsService(from, to, fn)
+}
+
+func (m *mockTigerService) SetTigerSubscriptionService(email string, tigerID int, subscribed bool) error {
+	return m.setTigerSubscriptionService(email, tigerID, subscribed)
+}
+
+func (m *mockTigerService) SetNotificationPreferencesService(email string, digest bool) error {
+	return m.setNotificationPreferencesService(email, digest)
+}
+
+func (m *mockTigerService) CreateRefreshTokenService(user *models.User) (string, error) {
+	return m.createRefreshTokenService(user)
+}
+
+func (m *mockTigerService) RefreshTokenService(refreshToken string) (*models.User, string, error) {
+	return m.refreshTokenService(refreshToken)
+}
+
+func (m *mockTigerService) LogoutService(email, refreshToken string) error {
+	return m.logoutService(email, refreshToken)
+}
+
+func (m *mockTigerService) GrantRoleService(userID int, role string) error {
+	return m.grantRoleService(userID, role)
+}
+
+func (m *mockTigerService) RevokeRoleService(userID int, role string) error {
+	return m.revokeRoleService(userID, role)
+}
+
+func (m *mockTigerService) VerifyEmailService(token string) error {
+	return m.verifyEmailService(token)
+}
+
+func (m *mockTigerService) ResendVerificationService(email string) error {
+	return m.resendVerificationService(email)
+}
+
+func (m *mockTigerService) ForgotPasswordService(email string) error {
+	return m.forgotPasswordService(email)
+}
+
+func (m *mockTigerService) ResetPasswordService(token, password string) error {
+	return m.resetPasswordService(token, password)
+}
+
+func (m *mockTigerService) GetModerationSightingImageService(sightingID int) (io.ReadCloser, error) {
+	return m.getModerationSightingImageService(sightingID)
+}
+
+func (m *mockTigerService) GetModerationQueueService(page, size int) ([]*models.TigerSighting, int, error) {
+	return m.getModerationQueueService(page, size)
+}
+
+func (m *mockTigerService) ReviewTigerSightingService(sightingID int, reviewerEmail, status, note string) (*models.SightingReview, error) {
+	return m.reviewTigerSightingService(sightingID, reviewerEmail, status, note)
+}
+
+func (m *mockTigerService) FlagTigerSightingService(sightingID int, email, reason string) error {
+	return m.flagTigerSightingService(sightingID, email, reason)
+}
+
+func (m *mockTigerService) GetTigerSightingPhotoService(sightingID, position int, rendition, format string) (io.ReadCloser, *models.SightingImage, error) {
+	return m.getTigerSightingPhotoService(sightingID, position, rendition, format)
+}
+
+func (m *mockTigerService) GetModerationSightingPhotoService(sightingID, position int, rendition, format string) (io.ReadCloser, *models.SightingImage, error) {
+	return m.getModerationSightingPhotoService(sightingID, position, rendition, format)
+}
+
+func (m *mockTigerService) GetTigersAfterService(after *models.Cursor, limit int) ([]*models.Tiger, error) {
+	return m.getTigersAfterService(after, limit)
+}
+
+func (m *mockTigerService) GetTigersByIDsService(tigerIDs []int) ([]*models.Tiger, error) {
+	return m.getTigersByIDsService(tigerIDs)
+}
+
+func (m *mockTigerService) GetTigerSightingsAfterService(tigerID int, after *models.Cursor, limit int) ([]*models.TigerSighting, error) {
+	return m.getTigerSightingsAfterService(tigerID, after, limit)
+}
+
+func (m *mockTigerService) GetLatestTigerSightingsService(tigerIDs []int, limit int) ([]*models.TigerSighting, error) {
+	return m.getLatestTigerSightingsService(tigerIDs, limit)
+}
+
+func (m *mockTigerService) GetUsersByEmailsService(emails []string) ([]*models.User, error) {
+	return m.getUsersByEmailsService(emails)
+}
+
+func (m *mockTigerService) GetTigerStatsService(tigerID int) (*models.TigerStats, error) {
+	return m.getTigerStatsService(tigerID)
+}
+
+func (m *mockTigerService) GetPopulationStatsService(days, limit int) (*models.PopulationStats, error) {
+	return m.getPopulationStatsService(days, limit)
+}
+
+func (m *mockTigerService) SubscribeSightingsService(filter models.SightingFeedFilter) *service.SightingSubscription {
+	return m.subscribeSightingsService(filter)
+}
+
+func (m *mockTigerService) GetTigerSubscriptionsService(email string) ([]*models.TigerSubscription, error) {
+	return m.getTigerSubscriptionsService(email)
+}
+
+func (m *mockTigerService) CreateSightingAlertService(email string, alert *models.SightingAlert) error {
+	return m.createSightingAlertService(email, alert)
+}
+
+func (m *mockTigerService) GetSightingAlertsService(email string) ([]*models.SightingAlert, error) {
+	return m.getSightingAlertsService(email)
+}
+
+func (m *mockTigerService) DeleteSightingAlertService(email string, alertID int) error {
+	return m.deleteSightingAlertService(email, alertID)
 }
 
 func TestServer_SetupRoutes(t *testing.T) {
@@ -66,6 +256,38 @@ func TestServer_SetupRoutes(t *testing.T) {
 	assert.NoError(t, routes, "Error walking routes")
 }
 
+func TestServer_RangerRoutes(t *testing.T) {
+	// Arrange
+	authenticator := auth.NewAuth("test_secret_key")
+	srv := server.NewServer()
+	srv.SetupRoutes(&mockTigerService{}, authenticator)
+	token, err := authenticator.GenerateToken("researcher", "researcher@example.com", models.RoleResearcher)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		method string
+		path   string
+	}{
+		{method: http.MethodPatch, path: "/tiger/1"},
+		{method: http.MethodGet, path: "/tiger/1/trail.geojson"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"name": "Shere Khan"}`))
+			req.Header.Set("Authorization", "Bearer "+token)
+			rr := httptest.NewRecorder()
+
+			// Act
+			srv.ServeHTTP(rr, req)
+
+			// Assert
+			assert.Equal(t, http.StatusForbidden, rr.Code, "Only rangers and admins should be allowed")
+		})
+	}
+}
+
 func TestServer_Start(t *testing.T) {
 	// Arrange
 	mockService := &mockTigerService{}
@@ -83,9 +305,17 @@ func TestServer_Start(t *testing.T) {
 	req, err := http.NewRequest(http.MethodGet, "http://localhost:8080", nil)
 	assert.NoError(t, err, "Error creating request")
 
+	// Wait for the server to start listening
 	client := &http.Client{}
-	resp, err := client.Do(req)
-	assert.NoError(t, err, "Error sending request")
+	var resp *http.Response
+	ok := assert.Eventually(t, func() bool {
+		resp, err = client.Do(req)
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond, "Error sending request")
+	if !ok {
+		t.FailNow()
+	}
+	defer resp.Body.Close()
 
 	// Assert
 	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "Expected status code 404")
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+
+	"tigerhall-kittens-app/pkg/models"
+	"tigerhall-kittens-app/pkg/repository"
+	"tigerhall-kittens-app/pkg/utils"
+)
+
+// MaxSightingAlertsPerUser is the most sighting alerts a user may have.
+const MaxSightingAlertsPerUser = 20
+
+var (
+	ErrAlertNotFound = errors.New("sighting alert not found")
+	ErrTooManyAlerts = errors.New("too many sighting alerts")
+)
+
+// GetTigerSubscriptionsService returns the tigers the user follows or opted out of.
+func (s service) GetTigerSubscriptionsService(email string) ([]*models.TigerSubscription, error) {
+	subscriptions, err := s.TigerRepo.GetTigerSubscriptions(utils.NormalizeEmail(email))
+	if err != nil {
+		log.Printf("failed to get tiger subscriptions: %v", err)
+		return nil, errors.New("failed to fetch tiger subscriptions")
+	}
+	return subscriptions, nil
+}
+
+// CreateSightingAlertService saves the alert of the user. Webhook alerts are given a new
+// secret to sign their requests with, it is only returned here. ErrTigerNotFound is
+// returned when the alert is for a tiger which does not exist, ErrTooManyAlerts when the
+// user already has MaxSightingAlertsPerUser alerts.
+func (s service) CreateSightingAlertService(email string, alert *models.SightingAlert) error {
+	alert.Email = utils.NormalizeEmail(email)
+	alert.WebhookSecret = ""
+	if alert.Channel != models.AlertChannelWebhook {
+		alert.WebhookURL = ""
+	}
+
+	if alert.TigerID != nil {
+		_, err := s.TigerRepo.GetTigerByID(*alert.TigerID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrTigerNotFound
+		} else if err != nil {
+			return errors.New("failed to retrieve tiger")
+		}
+	}
+
+	alerts, err := s.TigerRepo.GetSightingAlerts(alert.Email)
+	if err != nil {
+		return errors.New("failed to retrieve sighting alerts")
+	}
+	if len(alerts) >= MaxSightingAlertsPerUser {
+		return ErrTooManyAlerts
+	}
+
+	if alert.Channel == models.AlertChannelWebhook {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return errors.New("failed to generate webhook secret")
+		}
+		alert.WebhookSecret = hex.EncodeToString(secret)
+	}
+
+	area := utils.BoundingBoxAround(models.Coordinates{Lat: alert.Lat, Long: alert.Long}, alert.RadiusKm)
+	if err := s.TigerRepo.CreateSightingAlert(alert, area); err != nil {
+		log.Printf("failed to create sighting alert: %v", err)
+		return errors.New("failed to create sighting alert")
+	}
+	return nil
+}
+
+// GetSightingAlertsService returns the alerts of the user, without their webhook secrets.
+func (s service) GetSightingAlertsService(email string) ([]*models.SightingAlert, error) {
+	alerts, err := s.TigerRepo.GetSightingAlerts(utils.NormalizeEmail(email))
+	if err != nil {
+		log.Printf("failed to get sighting alerts: %v", err)
+		return nil, errors.New("failed to fetch sighting alerts")
+	}
+	for _, alert := range alerts {
+		alert.WebhookSecret = ""
+	}
+	return alerts, nil
+}
+
+// DeleteSightingAlertService deletes the alert of the user, ErrAlertNotFound is returned
+// when the user has no such alert.
+func (s service) DeleteSightingAlertService(email string, alertID int) error {
+	err := s.TigerRepo.DeleteSightingAlert(alertID, utils.NormalizeEmail(email))
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrAlertNotFound
+	} else if err != nil {
+		return errors.New("failed to delete sighting alert")
+	}
+	return nil
+}
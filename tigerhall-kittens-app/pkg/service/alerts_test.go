@@ -0,0 +1,123 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"tigerhall-kittens-app/pkg/models"
+	"tigerhall-kittens-app/pkg/repository"
+	"tigerhall-kittens-app/pkg/utils"
+)
+
+func TestCreateSightingAlertService(t *testing.T) {
+	// Arrange
+	var created *models.SightingAlert
+	var createdArea models.BoundingBox
+	mockRepo := &mockTigerRepo{
+		getSightingAlerts: func(email string) ([]*models.SightingAlert, error) {
+			return []*models.SightingAlert{}, nil
+		},
+		createSightingAlert: func(alert *models.SightingAlert, area models.BoundingBox) error {
+			alert.ID = 5
+			created, createdArea = alert, area
+			return nil
+		},
+	}
+	tigerService := NewTigerService(mockRepo, nil)
+	webhook := &models.SightingAlert{Name: "Camp", Lat: 12.34, Long: 56.78, RadiusKm: 10, Channel: models.AlertChannelWebhook, WebhookURL: "https://example.com/hook", WebhookSecret: "chosen"}
+	email := &models.SightingAlert{Name: "Camp", Lat: 12.34, Long: 56.78, RadiusKm: 10, Channel: models.AlertChannelEmail, WebhookURL: "https://example.com/hook"}
+
+	// Act
+	webhookErr := tigerService.CreateSightingAlertService(" Ranger@Example.com", webhook)
+	emailErr := tigerService.CreateSightingAlertService("ranger@example.com", email)
+
+	// Assert
+	assert.NoError(t, webhookErr)
+	assert.Equal(t, "ranger@example.com", webhook.Email)
+	assert.Len(t, webhook.WebhookSecret, 64, "Webhook alerts should get a new secret")
+	assert.NotEqual(t, "chosen", webhook.WebhookSecret)
+
+	assert.NoError(t, emailErr)
+	assert.Same(t, email, created)
+	assert.Empty(t, email.WebhookSecret)
+	assert.Empty(t, email.WebhookURL, "Email alerts should not keep a webhook")
+	assert.Equal(t, utils.BoundingBoxAround(models.Coordinates{Lat: 12.34, Long: 56.78}, 10), createdArea)
+}
+
+func TestCreateSightingAlertService_Rejected(t *testing.T) {
+	unknownTiger := 2
+	tests := []struct {
+		name     string
+		tigerID  *int
+		alerts   int
+		expected error
+	}{
+		{name: "unknown tiger", tigerID: &unknownTiger, expected: ErrTigerNotFound},
+		{name: "too many alerts", alerts: MaxSightingAlertsPerUser, expected: ErrTooManyAlerts},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			mockRepo := &mockTigerRepo{
+				getTigerByID: func(tigerID int) (*models.Tiger, error) {
+					return nil, repository.ErrNotFound
+				},
+				getSightingAlerts: func(email string) ([]*models.SightingAlert, error) {
+					return make([]*models.SightingAlert, tt.alerts), nil
+				},
+				createSightingAlert: func(alert *models.SightingAlert, area models.BoundingBox) error {
+					t.Error("alert should not be created")
+					return nil
+				},
+			}
+			tigerService := NewTigerService(mockRepo, nil)
+
+			// Act
+			err := tigerService.CreateSightingAlertService("ranger@example.com", &models.SightingAlert{Name: "Camp", RadiusKm: 10, TigerID: tt.tigerID, Channel: models.AlertChannelEmail})
+
+			// Assert
+			assert.ErrorIs(t, err, tt.expected)
+		})
+	}
+}
+
+func TestGetSightingAlertsService(t *testing.T) {
+	// Arrange
+	tigerService := NewTigerService(&mockTigerRepo{
+		getSightingAlerts: func(email string) ([]*models.SightingAlert, error) {
+			return []*models.SightingAlert{{ID: 5, Channel: models.AlertChannelWebhook, WebhookSecret: "secret"}}, nil
+		},
+	}, nil)
+
+	// Act
+	alerts, err := tigerService.GetSightingAlertsService("ranger@example.com")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Empty(t, alerts[0].WebhookSecret, "Secrets should only be returned when the alert is created")
+}
+
+func TestDeleteSightingAlertService(t *testing.T) {
+	// Arrange
+	var deletedBy string
+	tigerService := NewTigerService(&mockTigerRepo{
+		deleteSightingAlert: func(alertID int, email string) error {
+			deletedBy = email
+			if alertID != 5 {
+				return repository.ErrNotFound
+			}
+			return nil
+		},
+	}, nil)
+
+	// Act
+	err := tigerService.DeleteSightingAlertService("Ranger@Example.com", 5)
+	notFoundErr := tigerService.DeleteSightingAlertService("ranger@example.com", 6)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "ranger@example.com", deletedBy, "Alerts should only be deleted by their owner")
+	assert.ErrorIs(t, notFoundErr, ErrAlertNotFound)
+}
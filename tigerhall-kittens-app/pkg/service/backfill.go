@@ -0,0 +1,70 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"tigerhall-kittens-app/pkg/repository"
+	"tigerhall-kittens-app/pkg/storage"
+)
+
+// DefaultImageBackfillBatchSize is the number of legacy images read at once.
+const DefaultImageBackfillBatchSize = 50
+
+// ImageBackfill moves the images of the sightings reported before images moved to the
+// image store out of the image column of tiger_sightings, so that they are served by
+// key like the others. Images are saved before the sighting references them, an image
+// moved twice after a crash only leaves an unreferenced copy in the image store.
+type ImageBackfill struct {
+	repo       repository.TigerRepository
+	imageStore storage.ImageStore
+	batchSize  int
+}
+
+// NewImageBackfill creates an ImageBackfill moving the legacy images of repo to imageStore.
+func NewImageBackfill(repo repository.TigerRepository, imageStore storage.ImageStore) *ImageBackfill {
+	return &ImageBackfill{
+		repo:       repo,
+		imageStore: imageStore,
+		batchSize:  DefaultImageBackfillBatchSize,
+	}
+}
+
+// Run moves the legacy images until none is left, or moving one fails, and returns the
+// number of images moved.
+func (b *ImageBackfill) Run() (int, error) {
+	moved := 0
+	for {
+		sightings, err := b.repo.GetLegacySightingImages(b.batchSize)
+		if err != nil {
+			return moved, err
+		}
+
+		for _, sighting := range sightings {
+			key := ""
+			// Empty images are only cleared
+			if image := sighting.Photos[0]; len(image) > 0 {
+				contentType := http.DetectContentType(image)
+				extension := "bin"
+				if strings.HasPrefix(contentType, "image/") {
+					extension = strings.TrimPrefix(contentType, "image/")
+				}
+				if key, err = storage.NewImageKey(sighting.TigerID, extension); err != nil {
+					return moved, err
+				}
+				if err := b.imageStore.Put(key, image, contentType); err != nil {
+					return moved, fmt.Errorf("failed to save image of sighting %d: %v", sighting.ID, err)
+				}
+			}
+			if err := b.repo.MoveLegacySightingImage(sighting.ID, key); err != nil {
+				return moved, err
+			}
+			moved++
+		}
+
+		if len(sightings) < b.batchSize {
+			return moved, nil
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"tigerhall-kittens-app/pkg/models"
+)
+
+func TestImageBackfill_Run(t *testing.T) {
+	// Arrange
+	photo := testPhoto(t, 40, 30)
+	legacy := []*models.TigerSighting{
+		{ID: 1, TigerID: 1, Photos: [][]byte{photo}},
+		{ID: 2, TigerID: 1, Photos: [][]byte{{}}},
+		{ID: 3, TigerID: 2, Photos: [][]byte{photo}},
+	}
+	moved := map[int]string{}
+	mockRepo := &mockTigerRepo{
+		getLegacySightingImages: func(limit int) ([]*models.TigerSighting, error) {
+			var pending []*models.TigerSighting
+			for _, sighting := range legacy {
+				if _, ok := moved[sighting.ID]; !ok && len(pending) < limit {
+					pending = append(pending, sighting)
+				}
+			}
+			return pending, nil
+		},
+		moveLegacySightingImage: func(sightingID int, imageKey string) error {
+			moved[sightingID] = imageKey
+			return nil
+		},
+	}
+	imageStore := &mockImageStore{images: map[string][]byte{}}
+	backfill := NewImageBackfill(mockRepo, imageStore)
+	backfill.batchSize = 2
+
+	// Act
+	count, err := backfill.Run()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count, "Every legacy image should be moved, batch after batch")
+	assert.True(t, strings.HasPrefix(moved[1], "sightings/1/") && strings.HasSuffix(moved[1], ".jpeg"), "Unexpected key %q", moved[1])
+	assert.Equal(t, photo, imageStore.images[moved[1]], "The image should be saved as it was")
+	assert.Equal(t, "", moved[2], "Empty images should only be cleared")
+	assert.Len(t, imageStore.images, 2)
+}
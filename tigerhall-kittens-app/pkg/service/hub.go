@@ -0,0 +1,84 @@
+package service
+
+import (
+	"sync"
+
+	"tigerhall-kittens-app/pkg/models"
+)
+
+// SightingSubscriptionBuffer is how many sightings a subscriber may fall behind before it
+// is dropped, so that a slow client never holds up the others.
+const SightingSubscriptionBuffer = 64
+
+// SightingHub fans the approved sightings out to the subscribers of the live feeds. It
+// lives in the process, only the sightings approved through the same service reach its
+// subscribers, whatever message broker the notifications go through.
+type SightingHub struct {
+	mu          sync.Mutex
+	subscribers map[*SightingSubscription]bool
+}
+
+// SightingSubscription receives the sightings kept by its filter on C, in the order they
+// were approved. C is closed when the subscription is closed, or when the subscriber fell
+// SightingSubscriptionBuffer sightings behind and was dropped.
+type SightingSubscription struct {
+	C <-chan *models.TigerSighting
+
+	c      chan *models.TigerSighting
+	filter models.SightingFeedFilter
+	hub    *SightingHub
+}
+
+func NewSightingHub() *SightingHub {
+	return &SightingHub{subscribers: map[*SightingSubscription]bool{}}
+}
+
+// Subscribe returns a subscription to the sightings kept by the filter, it has to be closed.
+func (h *SightingHub) Subscribe(filter models.SightingFeedFilter) *SightingSubscription {
+	c := make(chan *models.TigerSighting, SightingSubscriptionBuffer)
+	subscription := &SightingSubscription{C: c, c: c, filter: filter, hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subscribers[subscription] = true
+	return subscription
+}
+
+// Publish sends the sighting to every subscriber whose filter keeps it. Subscribers must
+// not modify the sighting, it is shared by all of them.
+func (h *SightingHub) Publish(sighting *models.TigerSighting) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for subscription := range h.subscribers {
+		if !subscription.filter.Matches(sighting) {
+			continue
+		}
+		select {
+		case subscription.c <- sighting:
+		default:
+			h.remove(subscription)
+		}
+	}
+}
+
+// Subscribers returns the number of open subscriptions.
+func (h *SightingHub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers)
+}
+
+func (h *SightingHub) remove(subscription *SightingSubscription) {
+	if h.subscribers[subscription] {
+		delete(h.subscribers, subscription)
+		close(subscription.c)
+	}
+}
+
+// Close ends the subscription, closing it again is a no-op.
+func (s *SightingSubscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
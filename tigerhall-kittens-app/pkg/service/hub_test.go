@@ -0,0 +1,150 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"tigerhall-kittens-app/pkg/models"
+)
+
+func TestSightingHub_Publish(t *testing.T) {
+	// Arrange
+	hub := NewSightingHub()
+	all := hub.Subscribe(models.SightingFeedFilter{})
+	defer all.Close()
+	tiger := hub.Subscribe(models.SightingFeedFilter{TigerIDs: []int{2, 3}})
+	defer tiger.Close()
+	region := hub.Subscribe(models.SightingFeedFilter{Region: &models.BoundingBox{MinLat: 10, MinLong: 50, MaxLat: 20, MaxLong: 60}})
+	defer region.Close()
+
+	first := &models.TigerSighting{ID: 1, TigerID: 1, Lat: 12.34, Long: 56.78}
+	second := &models.TigerSighting{ID: 2, TigerID: 2, Lat: -12.34, Long: 56.78}
+
+	// Act
+	hub.Publish(first)
+	hub.Publish(second)
+
+	// Assert
+	assert.Equal(t, first, <-all.C)
+	assert.Equal(t, second, <-all.C)
+	assert.Equal(t, second, <-tiger.C)
+	assert.Equal(t, first, <-region.C)
+	assert.Empty(t, tiger.C, "Sightings of other tigers should not be sent")
+	assert.Empty(t, region.C, "Sightings outside the region should not be sent")
+}
+
+func TestSightingHub_DropsSlowSubscribers(t *testing.T) {
+	// Arrange
+	hub := NewSightingHub()
+	slow := hub.Subscribe(models.SightingFeedFilter{})
+	defer slow.Close()
+	fast := hub.Subscribe(models.SightingFeedFilter{})
+	defer fast.Close()
+
+	// Act
+	for i := 0; i <= SightingSubscriptionBuffer; i++ {
+		hub.Publish(&models.TigerSighting{ID: i})
+		<-fast.C
+	}
+
+	// Assert
+	assert.Equal(t, 1, hub.Subscribers(), "The slow subscriber should be dropped")
+	received := 0
+	for range slow.C {
+		received++
+	}
+	assert.Equal(t, SightingSubscriptionBuffer, received, "The buffered sightings should still be received")
+}
+
+func TestSightingSubscription_Close(t *testing.T) {
+	// Arrange
+	hub := NewSightingHub()
+	subscription := hub.Subscribe(models.SightingFeedFilter{})
+
+	// Act
+	subscription.Close()
+	subscription.Close()
+	hub.Publish(&models.TigerSighting{ID: 1})
+
+	// Assert
+	_, ok := <-subscription.C
+	assert.False(t, ok, "The channel should be closed")
+	assert.Equal(t, 0, hub.Subscribers())
+}
+
+func TestCreateTigerSightingService_PublishesApproved(t *testing.T) {
+	tests := []struct {
+		name      string
+		approved  int
+		published bool
+	}{
+		{name: "trusted reporter", approved: TrustedReporterMinApproved, published: true},
+		{name: "new reporter", approved: 0},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			newSighting := &models.TigerSighting{
+				TigerID:       1,
+				Timestamp:     time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC),
+				Lat:           12.35,
+				Long:          56.79,
+				ReporterEmail: "reporter@example.com",
+			}
+			mockRepo := &mockTigerRepo{
+				lockTiger: func(tigerID int) (*models.Tiger, error) {
+					return &models.Tiger{ID: tigerID}, nil
+				},
+				getAdjacentTigerSightings: func(tigerID int, reporterEmail string, timestamp time.Time) (*models.TigerSighting, *models.TigerSighting, error) {
+					return nil, nil, nil
+				},
+				getTigerSightingsBetween: func(tigerID int, reporterEmail string, from, to time.Time) ([]*models.TigerSighting, error) {
+					return []*models.TigerSighting{}, nil
+				},
+				countReporterSightings: func(email string) (int, int, error) {
+					return tt.approved, 0, nil
+				},
+				createTigerSighting: func(newSighting *models.TigerSighting) error {
+					newSighting.ID = 4
+					return nil
+				},
+				updateTigerLastSeen: func(tigerSighting *models.TigerSighting) error {
+					return nil
+				},
+				getTigerSightingsByID: func(tigerID int) ([]*models.TigerSighting, error) {
+					return []*models.TigerSighting{}, nil
+				},
+				getTigerFollowers: func(tigerID int) ([]string, error) {
+					return nil, nil
+				},
+				createOutboxMessage: func(payload []byte) error {
+					return nil
+				},
+			}
+			tigerService := NewTigerService(mockRepo, nil)
+			subscription := tigerService.SubscribeSightingsService(models.SightingFeedFilter{})
+			defer subscription.Close()
+
+			// Act
+			err := tigerService.CreateTigerSightingService(newSighting)
+
+			// Assert
+			assert.NoError(t, err)
+			if !tt.published {
+				assert.Empty(t, subscription.C, "Pending sightings should not be published")
+				return
+			}
+			select {
+			case published := <-subscription.C:
+				assert.Equal(t, 4, published.ID)
+				assert.Equal(t, models.SightingApproved, published.Status)
+				assert.NotSame(t, newSighting, published, "The feed should get a copy of the sighting")
+			default:
+				t.Error("Approved sighting should be published")
+			}
+		})
+	}
+}
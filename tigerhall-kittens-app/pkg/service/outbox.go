@@ -0,0 +1,99 @@
+package service
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"tigerhall-kittens-app/pkg/messaging"
+	"tigerhall-kittens-app/pkg/repository"
+)
+
+// DefaultOutboxBatchSize is the number of outbox messages published per transaction.
+const DefaultOutboxBatchSize = 100
+
+// OutboxRelay publishes the messages of the outbox to the message broker and marks
+// them sent once the broker has taken them. A message is published at least once: it
+// is published again when the relay stops between publishing and marking it, consumers
+// tell the copies apart by the message ID, which is the ID of the outbox message.
+type OutboxRelay struct {
+	repo      repository.TigerRepository
+	publisher messaging.Publisher
+	batchSize int
+}
+
+// NewOutboxRelay creates an OutboxRelay publishing the outbox of repo to publisher.
+func NewOutboxRelay(repo repository.TigerRepository, publisher messaging.Publisher) *OutboxRelay {
+	return &OutboxRelay{
+		repo:      repo,
+		publisher: publisher,
+		batchSize: DefaultOutboxBatchSize,
+	}
+}
+
+// RelayPending publishes the pending messages of the outbox until none is left, or
+// publishing fails. Messages published before the failure are marked sent.
+func (r *OutboxRelay) RelayPending() error {
+	for {
+		relayed, err := r.relayBatch()
+		if err != nil {
+			return err
+		}
+		if relayed < r.batchSize {
+			return nil
+		}
+	}
+}
+
+// relayBatch publishes one batch of pending messages in a transaction, so relays
+// running in other instances of the service skip the messages of this batch.
+func (r *OutboxRelay) relayBatch() (int, error) {
+	relayed := 0
+	var publishErr error
+
+	err := r.repo.WithTx(func(tx repository.TigerRepository) error {
+		messages, err := tx.GetPendingOutboxMessages(r.batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, message := range messages {
+			if err := r.publisher.PublishMessageWithID(strconv.FormatInt(message.ID, 10), message.Payload); err != nil {
+				// Commit the messages published so far, the rest is retried later
+				publishErr = err
+				return nil
+			}
+			if err := tx.MarkOutboxMessageSent(message.ID); err != nil {
+				return err
+			}
+			relayed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if publishErr != nil {
+		return relayed, publishErr
+	}
+
+	return relayed, nil
+}
+
+// Run relays the pending messages every interval until stop is closed.
+func (r *OutboxRelay) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.RelayPending(); err != nil {
+			log.Printf("failed to relay outbox messages: %v", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
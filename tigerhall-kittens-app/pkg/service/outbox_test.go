@@ -0,0 +1,140 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"tigerhall-kittens-app/pkg/models"
+	"tigerhall-kittens-app/pkg/utils"
+)
+
+// mockPublisher records the published messages and fails once failAfter messages are published.
+type mockPublisher struct {
+	published []string
+	bodies    [][]byte
+	failAfter int
+}
+
+func (m *mockPublisher) PublishMessage(message []byte) error {
+	return m.PublishMessageWithID("", message)
+}
+
+func (m *mockPublisher) PublishMessageWithID(id string, message []byte) error {
+	if m.failAfter >= 0 && len(m.published) >= m.failAfter {
+		return errors.New("broker unavailable")
+	}
+	m.published = append(m.published, id)
+	m.bodies = append(m.bodies, message)
+	return nil
+}
+
+// newOutboxRepo returns a mock repository with the messages in its outbox.
+func newOutboxRepo(messages ...*models.OutboxMessage) (*mockTigerRepo, *[]int64) {
+	sent := []int64{}
+	mockRepo := &mockTigerRepo{
+		getPendingOutboxMessages: func(limit int) ([]*models.OutboxMessage, error) {
+			var pending []*models.OutboxMessage
+			for _, message := range messages {
+				if !containsID(sent, message.ID) && len(pending) < limit {
+					pending = append(pending, message)
+				}
+			}
+			return pending, nil
+		},
+		markOutboxMessageSent: func(id int64) error {
+			sent = append(sent, id)
+			return nil
+		},
+	}
+	return mockRepo, &sent
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, existing := range ids {
+		if existing == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOutboxRelay_RelayPending(t *testing.T) {
+	// Arrange
+	mockRepo, sent := newOutboxRepo(
+		&models.OutboxMessage{ID: 1, Payload: []byte(`{}`)},
+		&models.OutboxMessage{ID: 2, Payload: []byte(`{}`)},
+		&models.OutboxMessage{ID: 3, Payload: []byte(`{}`)},
+	)
+	publisher := &mockPublisher{failAfter: -1}
+	relay := NewOutboxRelay(mockRepo, publisher)
+	relay.batchSize = 2
+
+	// Act
+	err := relay.RelayPending()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "3"}, publisher.published, "Messages should be published by outbox ID")
+	assert.Equal(t, []int64{1, 2, 3}, *sent, "Published messages should be marked sent")
+}
+
+func TestOutboxRelay_RelayPending_BrokerDown(t *testing.T) {
+	// Arrange
+	mockRepo, sent := newOutboxRepo(
+		&models.OutboxMessage{ID: 1, Payload: []byte(`{}`)},
+		&models.OutboxMessage{ID: 2, Payload: []byte(`{}`)},
+	)
+	publisher := &mockPublisher{failAfter: 1}
+	relay := NewOutboxRelay(mockRepo, publisher)
+
+	// Act
+	err := relay.RelayPending()
+
+	// Assert
+	assert.Error(t, err)
+	assert.Equal(t, []int64{1}, *sent, "Only the published message should be marked sent")
+
+	// The remaining message is published once the broker is back
+	publisher.failAfter = -1
+	assert.NoError(t, relay.RelayPending())
+	assert.Equal(t, []int64{1, 2}, *sent)
+}
+
+func TestOutboxRelay_AccountEmailsHaveNoToken(t *testing.T) {
+	// Arrange
+	var userToken *models.UserToken
+	outbox := []*models.OutboxMessage{}
+	mockRepo, _ := newOutboxRepo()
+	mockRepo.getUserByEmail = func(email string) (*models.User, error) {
+		return &models.User{ID: 1, Username: "testuser", Email: email}, nil
+	}
+	mockRepo.createUserToken = func(token *models.UserToken) error {
+		token.ID = 5
+		userToken = token
+		return nil
+	}
+	mockRepo.createOutboxMessage = func(payload []byte) error {
+		outbox = append(outbox, &models.OutboxMessage{ID: int64(len(outbox) + 1), Payload: payload})
+		return nil
+	}
+	mockRepo.getPendingOutboxMessages = func(limit int) ([]*models.OutboxMessage, error) {
+		return outbox, nil
+	}
+	publisher := &mockPublisher{failAfter: -1}
+
+	// Act
+	assert.NoError(t, NewTigerService(mockRepo, nil).ForgotPasswordService("test@example.com"))
+	err := NewOutboxRelay(mockRepo, publisher).RelayPending()
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.Len(t, publisher.bodies, 1) {
+		var email utils.AccountEmail
+		assert.NoError(t, json.Unmarshal(publisher.bodies[0], &email))
+		assert.Equal(t, 5, email.TokenID)
+		assert.NotContains(t, string(publisher.bodies[0]), `"token"`, "No token should go through the outbox or the broker")
+		assert.NotContains(t, string(outbox[0].Payload), userToken.TokenHash)
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"tigerhall-kittens-app/pkg/models"
+)
+
+// lister reads the pages of a list sorted by cursor, newest first.
+type lister[T any] struct {
+	// after returns at most limit items following the cursor, from the first item when nil
+	after func(cursor *models.Cursor, limit int) ([]T, error)
+	// page returns the page numbered from 1
+	page func(page, pageSize int) ([]T, error)
+	// count counts the items, or estimates their number
+	count func(estimate bool) (int, error)
+	// cursor is the cursor of the item
+	cursor func(item T) models.Cursor
+}
+
+// list returns the page of items selected by request and its page info. It has to run in
+// a snapshot of the repository, so that the page, whether there is a next page and the
+// total agree with each other.
+func (l lister[T]) list(request models.PageRequest) ([]T, *models.PageInfo, error) {
+	var items []T
+	var hasNextPage bool
+	var err error
+	if request.CursorMode {
+		// One more item than asked for tells whether there is a next page
+		if items, err = l.after(request.After, request.PageSize+1); err != nil {
+			return nil, nil, err
+		}
+		if hasNextPage = len(items) > request.PageSize; hasNextPage {
+			items = items[:request.PageSize]
+		}
+	} else {
+		if items, err = l.page(request.Page, request.PageSize); err != nil {
+			return nil, nil, err
+		}
+		// A full page is followed by another when an item follows its last item
+		if len(items) > 0 && len(items) == request.PageSize {
+			last := l.cursor(items[len(items)-1])
+			next, err := l.after(&last, 1)
+			if err != nil {
+				return nil, nil, err
+			}
+			hasNextPage = len(next) > 0
+		}
+	}
+
+	info := &models.PageInfo{}
+	if hasNextPage {
+		next := l.cursor(items[len(items)-1])
+		info.NextCursor = &next
+	}
+	if request.Count == models.CountExact || request.Count == models.CountEstimate {
+		total, err := l.count(request.Count == models.CountEstimate)
+		if err != nil {
+			return nil, nil, err
+		}
+		info.TotalCount = &total
+	}
+	return items, info, nil
+}
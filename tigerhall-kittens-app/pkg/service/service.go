@@ -3,37 +3,129 @@ package service
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"sort"
+	"time"
 
 	"tigerhall-kittens-app/pkg/auth"
-	"tigerhall-kittens-app/pkg/messaging"
 	"tigerhall-kittens-app/pkg/models"
 	"tigerhall-kittens-app/pkg/repository"
+	"tigerhall-kittens-app/pkg/storage"
 	"tigerhall-kittens-app/pkg/utils"
 )
 
+var (
+	ErrTigerNotFound        = errors.New("tiger not found")
+	ErrTigerVersionConflict = errors.New("tiger has been modified by another request")
+	ErrSightingNotFound     = errors.New("tiger sighting not found")
+	ErrImageNotFound        = errors.New("tiger sighting image not found")
+	ErrInvalidImage         = errors.New("tiger sighting image is not a supported image")
+	ErrInvalidRefreshToken  = errors.New("invalid or expired refresh token")
+	ErrUserNotFound         = errors.New("user not found")
+	ErrInvalidRole          = errors.New("invalid role")
+	ErrEmailTaken           = errors.New("email is already registered")
+	ErrEmailNotVerified     = errors.New("email has not been verified")
+	ErrInvalidUserToken     = errors.New("invalid or expired token")
+	ErrInvalidReviewStatus  = errors.New("review status must be approved or rejected")
+)
+
+// TrustedReporterMinApproved is the number of approved sightings after which the sightings
+// of a reporter are approved without review, as long as none of theirs has been rejected.
+const TrustedReporterMinApproved = 5
+
 type service struct {
 	TigerRepo     repository.TigerRepository
-	messageBroker *messaging.MessageBroker
+	imageStore    storage.ImageStore
+	sightingRules []SightingRule
+	renditions    []utils.Rendition
+	stats         *statsCache
+	hub           *SightingHub
+}
+
+// Options configures the TigerService created by NewTigerServiceWithOptions.
+type Options struct {
+	// SightingRules check new sightings, in order
+	SightingRules []SightingRule
+	// Renditions of every sighting photo are saved next to the photo as uploaded
+	Renditions []utils.Rendition
+	// StatsCacheTTL is how long stats are cached, they are not cached when zero
+	StatsCacheTTL time.Duration
 }
 
-func NewTigerService(tigerRepository repository.TigerRepository, broker *messaging.MessageBroker) TigerService {
+// NewTigerService creates the TigerService. Messages about new sightings are written to
+// the outbox of tigerRepository, see OutboxRelay for how they reach the message broker.
+// New sightings are checked with the DefaultSightingRules of DefaultSightingRulesConfig
+// and their photos are saved in the utils.DefaultRenditions. Stats are cached for
+// DefaultStatsCacheTTL.
+func NewTigerService(tigerRepository repository.TigerRepository, imageStore storage.ImageStore) TigerService {
+	return NewTigerServiceWithOptions(tigerRepository, imageStore, Options{
+		SightingRules: DefaultSightingRules(DefaultSightingRulesConfig),
+		Renditions:    utils.DefaultRenditions,
+		StatsCacheTTL: DefaultStatsCacheTTL,
+	})
+}
+
+// NewTigerServiceWithOptions creates the TigerService configured by options.
+func NewTigerServiceWithOptions(tigerRepository repository.TigerRepository, imageStore storage.ImageStore, options Options) TigerService {
 	return service{
 		TigerRepo:     tigerRepository,
-		messageBroker: broker,
+		imageStore:    imageStore,
+		sightingRules: options.SightingRules,
+		renditions:    options.Renditions,
+		stats:         newStatsCache(options.StatsCacheTTL),
+		hub:           NewSightingHub(),
 	}
 }
 
 type TigerService interface {
 	SignupService(*models.User) error
 	LoginService(models.LoginCredentials) (*models.User, error)
-	CreateTigerService(tiger models.Tiger) error
-	GetAllTigersService(page, size int) ([]*models.Tiger, int, error)
+	VerifyEmailService(token string) error
+	ResendVerificationService(email string) error
+	ForgotPasswordService(email string) error
+	ResetPasswordService(token, password string) error
+	CreateRefreshTokenService(user *models.User) (string, error)
+	RefreshTokenService(refreshToken string) (*models.User, string, error)
+	LogoutService(email, refreshToken string) error
+	GrantRoleService(userID int, role string) error
+	RevokeRoleService(userID int, role string) error
+	CreateTigerService(tiger *models.Tiger) error
+	GetTigerService(tigerID int) (*models.Tiger, error)
+	UpdateTigerService(tigerID int, update models.TigerUpdate) (*models.Tiger, error)
+	DeleteTigerService(tigerID int) error
+	GetAllTigersService(filter models.TigerFilter, request models.PageRequest) ([]*models.Tiger, *models.PageInfo, error)
+	GetTigersAfterService(after *models.Cursor, limit int) ([]*models.Tiger, error)
+	GetTigersByIDsService(tigerIDs []int) ([]*models.Tiger, error)
+	GetTigersNearbyService(center models.Coordinates, radiusKm float64) ([]*models.NearbyTiger, error)
 	CreateTigerSightingService(*models.TigerSighting) error
-	GetTigerSightingsByIDService(tigerID, page, pageSize int) ([]*models.TigerSighting, int, error)
+	GetTigerSightingsByIDService(tigerID int, request models.PageRequest) ([]*models.TigerSighting, *models.PageInfo, error)
+	GetTigerSightingsAfterService(tigerID int, after *models.Cursor, limit int) ([]*models.TigerSighting, error)
+	GetLatestTigerSightingsService(tigerIDs []int, limit int) ([]*models.TigerSighting, error)
+	GetUsersByEmailsService(emails []string) ([]*models.User, error)
+	GetTigerSightingImageService(sightingID int) (io.ReadCloser, error)
+	GetModerationSightingImageService(sightingID int) (io.ReadCloser, error)
+	GetTigerSightingPhotoService(sightingID, position int, rendition, format string) (io.ReadCloser, *models.SightingImage, error)
+	GetModerationSightingPhotoService(sightingID, position int, rendition, format string) (io.ReadCloser, *models.SightingImage, error)
+	GetModerationQueueService(page, size int) ([]*models.TigerSighting, int, error)
+	ReviewTigerSightingService(sightingID int, reviewerEmail, status, note string) (*models.SightingReview, error)
+	FlagTigerSightingService(sightingID int, email, reason string) error
+	GetTigerSightingsInAreaService(box models.BoundingBox, from, to time.Time, limit int) ([]*models.TigerSighting, error)
+	StreamTigerTrailService(tigerID int, fn func(*models.TigerSighting) error) error
+	ExportTigerSightingsService(from, to time.Time, fn func(*models.TigerSighting) error) error
+	SetTigerSubscriptionService(email string, tigerID int, subscribed bool) error
+	SetNotificationPreferencesService(email string, digest bool) error
+	GetTigerStatsService(tigerID int) (*models.TigerStats, error)
+	GetPopulationStatsService(days, limit int) (*models.PopulationStats, error)
+	SubscribeSightingsService(filter models.SightingFeedFilter) *SightingSubscription
+	GetTigerSubscriptionsService(email string) ([]*models.TigerSubscription, error)
+	CreateSightingAlertService(email string, alert *models.SightingAlert) error
+	GetSightingAlertsService(email string) ([]*models.SightingAlert, error)
+	DeleteSightingAlertService(email string, alertID int) error
 }
 
+// SignupService creates the user unverified and mails them a token to verify their email.
+// ErrEmailTaken is returned when another user has the same email.
 func (s service) SignupService(user *models.User) error {
 	// Hash the user's password before saving to the database
 	hashedPassword, err := auth.HashPassword(user.Password)
@@ -41,12 +133,17 @@ func (s service) SignupService(user *models.User) error {
 		return errors.New("failed to hash password")
 	}
 	user.Password = hashedPassword
+	user.Email = utils.NormalizeEmail(user.Email)
 
-	// Create the user in the database
-	if err := s.TigerRepo.CreateUser(user); err != nil {
-		return errors.New("failed to create user")
-	}
-	return err
+	// Create the user in the database, the verification email is sent once it is committed
+	return s.TigerRepo.WithTx(func(tx repository.TigerRepository) error {
+		if err := tx.CreateUser(user); errors.Is(err, repository.ErrAlreadyExists) {
+			return ErrEmailTaken
+		} else if err != nil {
+			return errors.New("failed to create user")
+		}
+		return sendUserToken(tx, user, models.TokenPurposeEmailVerification, auth.EmailVerificationTokenTTL)
+	})
 }
 
 func (s service) LoginService(credentials models.LoginCredentials) (*models.User, error) {
@@ -60,27 +157,400 @@ func (s service) LoginService(credentials models.LoginCredentials) (*models.User
 	if err := auth.VerifyPassword(user.Password, credentials.Password); err != nil {
 		return &models.User{}, errors.New("invalid email or password")
 	}
+	if !user.EmailVerified {
+		return &models.User{}, ErrEmailNotVerified
+	}
+
+	// Load the roles to embed them in the access token
+	if user.Roles, err = s.TigerRepo.GetUserRoles(user.ID); err != nil {
+		return &models.User{}, errors.New("failed to retrieve user roles")
+	}
 	return user, nil
 }
 
-func (s service) CreateTigerService(tiger models.Tiger) error {
+// VerifyEmailService verifies the email of the user the verification token was mailed to.
+func (s service) VerifyEmailService(token string) error {
+	return s.TigerRepo.WithTx(func(tx repository.TigerRepository) error {
+		userToken, err := useUserToken(tx, models.TokenPurposeEmailVerification, token)
+		if err != nil {
+			return err
+		}
+		if err := tx.MarkEmailVerified(userToken.UserID); err != nil {
+			return errors.New("failed to verify email")
+		}
+		return nil
+	})
+}
+
+// ResendVerificationService mails a new verification token to the user with the email,
+// unless there is no such user or the email has been verified already.
+func (s service) ResendVerificationService(email string) error {
+	user, err := s.TigerRepo.GetUserByEmail(email)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return errors.New("failed to retrieve user")
+	}
+	if user.EmailVerified {
+		return nil
+	}
+
+	return sendUserToken(s.TigerRepo, user, models.TokenPurposeEmailVerification, auth.EmailVerificationTokenTTL)
+}
+
+// ForgotPasswordService mails a password reset token to the user with the email. Unknown
+// emails are ignored, so that the response does not tell who has an account.
+func (s service) ForgotPasswordService(email string) error {
+	user, err := s.TigerRepo.GetUserByEmail(email)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return errors.New("failed to retrieve user")
+	}
+
+	return sendUserToken(s.TigerRepo, user, models.TokenPurposePasswordReset, auth.PasswordResetTokenTTL)
+}
+
+// ResetPasswordService sets the password of the user the reset token was mailed to and
+// logs them out everywhere. Since the token was mailed to them, their email is verified too.
+func (s service) ResetPasswordService(token, password string) error {
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return errors.New("failed to hash password")
+	}
+
+	return s.TigerRepo.WithTx(func(tx repository.TigerRepository) error {
+		userToken, err := useUserToken(tx, models.TokenPurposePasswordReset, token)
+		if err != nil {
+			return err
+		}
+		if err := tx.UpdateUserPassword(userToken.UserID, hashedPassword); err != nil {
+			return errors.New("failed to update password")
+		}
+		if err := tx.MarkEmailVerified(userToken.UserID); err != nil {
+			return errors.New("failed to verify email")
+		}
+		if err := tx.RevokeUserRefreshTokens(userToken.UserID); err != nil {
+			return errors.New("failed to revoke refresh tokens")
+		}
+		return nil
+	})
+}
+
+// sendUserToken creates a token for the purpose and writes the email mailing it to the user
+// to the outbox. The token is stored under the hash of a token nobody knows, the notifier
+// mints the token mailed to the user when it sends the email.
+func sendUserToken(repo repository.TigerRepository, user *models.User, purpose string, ttl time.Duration) error {
+	_, hash, err := auth.NewUserToken()
+	if err != nil {
+		return err
+	}
+
+	userToken := &models.UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := repo.CreateUserToken(userToken); err != nil {
+		return errors.New("failed to create token")
+	}
+	email, err := utils.GetAccountEmail(purpose, user, userToken.ID, userToken.ExpiresAt)
+	if err != nil {
+		return errors.New("failed to build email")
+	}
+	if err := repo.CreateOutboxMessage(email); err != nil {
+		return errors.New("failed to save email")
+	}
+	return nil
+}
+
+// useUserToken checks the token for the purpose and marks it, and every other token of
+// its user for the same purpose, as used.
+func useUserToken(tx repository.TigerRepository, purpose, token string) (*models.UserToken, error) {
+	userToken, err := tx.LockUserToken(purpose, auth.HashUserToken(token))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidUserToken
+	} else if err != nil {
+		return nil, errors.New("failed to retrieve token")
+	}
+	if userToken.UsedAt != nil || time.Now().After(userToken.ExpiresAt) {
+		return nil, ErrInvalidUserToken
+	}
+
+	if err := tx.UseUserTokens(userToken.UserID, purpose); err != nil {
+		return nil, errors.New("failed to use token")
+	}
+	return userToken, nil
+}
+
+// CreateRefreshTokenService issues a new refresh token for the user.
+func (s service) CreateRefreshTokenService(user *models.User) (string, error) {
+	return createRefreshToken(s.TigerRepo, user.ID)
+}
+
+func createRefreshToken(repo repository.TigerRepository, userID int) (string, error) {
+	token, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	refreshToken := &models.RefreshToken{
+		UserID:    userID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(auth.RefreshTokenTTL),
+	}
+	if err := repo.CreateRefreshToken(refreshToken); err != nil {
+		return "", errors.New("failed to create refresh token")
+	}
+	return token, nil
+}
+
+// RefreshTokenService exchanges the refresh token for a new one and returns its user.
+// Every refresh token can be used once. Using a token again revokes all refresh tokens
+// of the user, since one of them has most likely been stolen.
+func (s service) RefreshTokenService(refreshToken string) (*models.User, string, error) {
+	var user *models.User
+	var newToken string
+	reused := false
+
+	err := s.TigerRepo.WithTx(func(tx repository.TigerRepository) error {
+		token, err := tx.LockRefreshToken(auth.HashRefreshToken(refreshToken))
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidRefreshToken
+		} else if err != nil {
+			return errors.New("failed to retrieve refresh token")
+		}
+
+		if token.RevokedAt != nil {
+			reused = true
+			if err := tx.RevokeUserRefreshTokens(token.UserID); err != nil {
+				return errors.New("failed to revoke refresh tokens")
+			}
+			return nil
+		}
+		if time.Now().After(token.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		if user, err = tx.GetUserByID(token.UserID); err != nil {
+			return errors.New("failed to retrieve user")
+		}
+		// Roles granted or revoked since the last login take effect here
+		if user.Roles, err = tx.GetUserRoles(user.ID); err != nil {
+			return errors.New("failed to retrieve user roles")
+		}
+		if err := tx.RevokeRefreshToken(token.ID); err != nil {
+			return errors.New("failed to revoke refresh token")
+		}
+		newToken, err = createRefreshToken(tx, token.UserID)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if reused {
+		log.Printf("refresh token of a logged out or rotated session has been reused, revoked all refresh tokens of the user")
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	return user, newToken, nil
+}
+
+// LogoutService revokes the refresh token, provided it belongs to the user.
+func (s service) LogoutService(email, refreshToken string) error {
+	user, err := s.TigerRepo.GetUserByEmail(email)
+	if err != nil {
+		return errors.New("failed to retrieve user")
+	}
+
+	return s.TigerRepo.WithTx(func(tx repository.TigerRepository) error {
+		token, err := tx.LockRefreshToken(auth.HashRefreshToken(refreshToken))
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidRefreshToken
+		} else if err != nil {
+			return errors.New("failed to retrieve refresh token")
+		}
+		if token.UserID != user.ID {
+			return ErrInvalidRefreshToken
+		}
+
+		if err := tx.RevokeRefreshToken(token.ID); err != nil {
+			return errors.New("failed to revoke refresh token")
+		}
+		return nil
+	})
+}
+
+// GrantRoleService grants the role to the user. The user gets the role with the next access token.
+func (s service) GrantRoleService(userID int, role string) error {
+	if !models.IsValidRole(role) {
+		return ErrInvalidRole
+	}
+	if err := s.checkUserExists(userID); err != nil {
+		return err
+	}
+
+	if err := s.TigerRepo.GrantUserRole(userID, role); err != nil {
+		return errors.New("failed to grant role")
+	}
+	return nil
+}
+
+// RevokeRoleService takes the role away from the user. Access tokens issued before keep
+// the role until they expire, refreshed access tokens no longer have it.
+func (s service) RevokeRoleService(userID int, role string) error {
+	if !models.IsValidRole(role) {
+		return ErrInvalidRole
+	}
+	if err := s.checkUserExists(userID); err != nil {
+		return err
+	}
+
+	if err := s.TigerRepo.RevokeUserRole(userID, role); err != nil {
+		return errors.New("failed to revoke role")
+	}
+	return nil
+}
+
+func (s service) checkUserExists(userID int) error {
+	_, err := s.TigerRepo.GetUserByID(userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrUserNotFound
+	} else if err != nil {
+		return errors.New("failed to retrieve user")
+	}
+	return nil
+}
+
+// CreateTigerService creates the tiger and sets its ID and version.
+func (s service) CreateTigerService(tiger *models.Tiger) error {
 	// Create the tiger in the database
-	if err := s.TigerRepo.CreateTiger(&tiger); err != nil {
+	if err := s.TigerRepo.CreateTiger(tiger); err != nil {
 		return errors.New("failed to create tiger")
 	}
 	return nil
 }
 
-func (s service) GetAllTigersService(page, size int) ([]*models.Tiger, int, error) {
-	// Get a list of all tigers from the database with pagination
-	tigers, totalCount, err := s.TigerRepo.GetAllTigersWithPagination(page, size)
+func (s service) GetTigerService(tigerID int) (*models.Tiger, error) {
+	tiger, err := s.TigerRepo.GetTigerByID(tigerID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrTigerNotFound
+	} else if err != nil {
+		return nil, errors.New("failed to fetch tiger")
+	}
+	return tiger, nil
+}
+
+func (s service) UpdateTigerService(tigerID int, update models.TigerUpdate) (*models.Tiger, error) {
+	tiger, err := s.GetTigerService(tigerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reject the update early if it was prepared against an older version of the tiger
+	if tiger.Version != update.Version {
+		return nil, ErrTigerVersionConflict
+	}
+
+	if update.Name != nil {
+		tiger.Name = *update.Name
+	}
+	if update.DateOfBirth != nil {
+		tiger.DateOfBirth = *update.DateOfBirth
+	}
+
+	err = s.TigerRepo.UpdateTiger(tiger)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrTigerNotFound
+	} else if errors.Is(err, repository.ErrVersionConflict) {
+		return nil, ErrTigerVersionConflict
+	} else if err != nil {
+		return nil, errors.New("failed to update tiger")
+	}
+	// The most active tigers are listed by name
+	s.stats.invalidateTiger(tigerID)
+	return tiger, nil
+}
+
+func (s service) DeleteTigerService(tigerID int) error {
+	err := s.TigerRepo.DeleteTiger(tigerID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrTigerNotFound
+	} else if err != nil {
+		return errors.New("failed to delete tiger")
+	}
+	s.stats.invalidateTiger(tigerID)
+	return nil
+}
+
+// GetAllTigersService returns the page of the tigers kept by the filter selected by
+// request, in the order of the filter, and its page info. Cursors of the page are only
+// valid with the same filter.
+func (s service) GetAllTigersService(filter models.TigerFilter, request models.PageRequest) ([]*models.Tiger, *models.PageInfo, error) {
+	var tigers []*models.Tiger
+	var info *models.PageInfo
+	err := s.TigerRepo.WithSnapshot(func(tx repository.TigerRepository) error {
+		var err error
+		tigers, info, err = lister[*models.Tiger]{
+			after: func(cursor *models.Cursor, limit int) ([]*models.Tiger, error) {
+				return tx.GetTigersAfter(filter, cursor, limit)
+			},
+			page: func(page, pageSize int) ([]*models.Tiger, error) {
+				return tx.GetAllTigersWithPagination(filter, page, pageSize)
+			},
+			count: func(estimate bool) (int, error) {
+				return tx.GetTotalTigerCount(filter, estimate)
+			},
+			cursor: filter.Cursor,
+		}.list(request)
+		return err
+	})
 	if err != nil {
-		return []*models.Tiger{}, totalCount, errors.New("failed to fetch tigers")
+		return []*models.Tiger{}, nil, errors.New("failed to fetch tigers")
 	}
+	return tigers, info, nil
+}
 
-	// Sort the tigers by the last seen time
-	sort.Slice(tigers, func(i, j int) bool { return tigers[i].LastSeen.After(tigers[j].LastSeen) })
-	return tigers, totalCount, nil
+// GetTigersAfterService returns at most limit tigers following the cursor, the tigers last
+// seen most recently first.
+func (s service) GetTigersAfterService(after *models.Cursor, limit int) ([]*models.Tiger, error) {
+	tigers, err := s.TigerRepo.GetTigersAfter(models.TigerFilter{}, after, limit)
+	if err != nil {
+		return []*models.Tiger{}, errors.New("failed to fetch tigers")
+	}
+	return tigers, nil
+}
+
+// GetTigersByIDsService returns the tigers with the IDs in no particular order, deleted
+// tigers are left out.
+func (s service) GetTigersByIDsService(tigerIDs []int) ([]*models.Tiger, error) {
+	tigers, err := s.TigerRepo.GetTigersByIDs(tigerIDs)
+	if err != nil {
+		return []*models.Tiger{}, errors.New("failed to fetch tigers")
+	}
+	return tigers, nil
+}
+
+func (s service) GetTigersNearbyService(center models.Coordinates, radiusKm float64) ([]*models.NearbyTiger, error) {
+	// Narrow the search down to the bounding box of the circle using the spatial index
+	tigers, err := s.TigerRepo.GetTigersInBoundingBox(utils.BoundingBoxAround(center, radiusKm))
+	if err != nil {
+		return nil, errors.New("failed to fetch tigers")
+	}
+
+	// Drop the tigers in the corners of the box, outside of the circle
+	nearbyTigers := []*models.NearbyTiger{}
+	for _, tiger := range tigers {
+		distance := utils.CalculateDistance(center, models.Coordinates{Lat: tiger.Lat, Long: tiger.Long})
+		if distance <= radiusKm {
+			nearbyTigers = append(nearbyTigers, &models.NearbyTiger{Tiger: tiger, DistanceKm: distance})
+		}
+	}
+
+	// Sort the tigers by distance, closest first
+	sort.Slice(nearbyTigers, func(i, j int) bool { return nearbyTigers[i].DistanceKm < nearbyTigers[j].DistanceKm })
+	return nearbyTigers, nil
 }
 
 func (s service) CreateTigerSightingService(newSighting *models.TigerSighting) error {
@@ -89,56 +559,529 @@ func (s service) CreateTigerSightingService(newSighting *models.TigerSighting) e
 		return errors.New("latitude, longitude, timestamp and reporterEmail are required")
 	}
 
-	// Check if the tiger has a previous sighting
-	previousSighting, err := s.TigerRepo.GetPreviousTigerSighting(newSighting.TigerID)
+	newSighting.PhotoMismatch = photoMismatch(newSighting)
+
+	// Save the photos before the sighting so the sighting never references a missing image
+	if len(newSighting.Photos) > 0 {
+		if err := s.saveSightingPhotos(newSighting); err != nil {
+			return err
+		}
+	}
+
+	// Run the sighting rules, the insert and the tiger update in one transaction.
+	// Locking the tiger first makes concurrent reports for it wait for each other,
+	// so they cannot both pass the duplicate check.
+	var tiger *models.Tiger
+	err := s.TigerRepo.WithTx(func(tx repository.TigerRepository) error {
+		var err error
+		if tiger, err = tx.LockTiger(newSighting.TigerID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return ErrTigerNotFound
+			}
+			return errors.New("failed to retrieve tiger")
+		}
+
+		// Reject sightings that are not plausible, see SightingRule
+		for _, rule := range s.sightingRules {
+			if err := rule(tx, tiger, newSighting); err != nil {
+				return err
+			}
+		}
+
+		// Sightings of trusted reporters skip the moderation queue, unless their photo
+		// contradicts them
+		newSighting.Status = models.SightingPending
+		approved, rejected, err := tx.CountReporterSightings(newSighting.ReporterEmail)
+		if err != nil {
+			return errors.New("failed to retrieve reporter history")
+		}
+		if approved >= TrustedReporterMinApproved && rejected == 0 && newSighting.PhotoMismatch == "" {
+			newSighting.Status = models.SightingApproved
+		}
+
+		// Create the tiger sighting in the database
+		if err := tx.CreateTigerSighting(newSighting); err != nil {
+			return errors.New("failed to create tiger sighting")
+		}
+		if len(newSighting.Images) > 0 {
+			for _, image := range newSighting.Images {
+				image.SightingID = newSighting.ID
+			}
+			if err := tx.CreateSightingImages(newSighting.Images); err != nil {
+				return errors.New("failed to save tiger sighting images")
+			}
+		}
+
+		if newSighting.Status != models.SightingApproved {
+			return nil
+		}
+		return publishSighting(tx, tiger, newSighting)
+	})
 	if err != nil {
-		return errors.New("failed to retrieve previous sighting")
+		s.deleteSightingImages(newSighting)
+		return err
+	}
+
+	if newSighting.Status == models.SightingApproved {
+		s.stats.invalidateTiger(newSighting.TigerID)
+		s.hub.Publish(feedSighting(newSighting))
+	}
+	return nil
+}
+
+// publishSighting updates the tiger with an approved sighting and notifies the reporters
+// of its earlier sightings and its followers. The notification of every approved sighting
+// is also checked against the sighting alerts by the notifier.
+func publishSighting(tx repository.TigerRepository, tiger *models.Tiger, sighting *models.TigerSighting) error {
+	// Keep the last seen time and position of the tiger in sync with its sightings
+	if err := tx.UpdateTigerLastSeen(sighting); err != nil {
+		return errors.New("failed to update tiger last seen")
+	}
+
+	previousSightings, err := tx.GetTigerSightingsByID(sighting.TigerID)
+	if err != nil {
+		return errors.New("failed to retrieve previous sightings")
+	}
+
+	followers, err := tx.GetTigerFollowers(sighting.TigerID)
+	if err != nil {
+		return errors.New("failed to retrieve tiger followers")
+	}
+
+	// The notification is only published once the sighting is committed, and is
+	// not lost when the message broker is unavailable
+	notification, err := utils.GetSightingNotification(tiger, sighting, previousSightings, followers)
+	if err != nil {
+		return errors.New("failed to build tiger sighting notification")
+	}
+	if err := tx.CreateOutboxMessage(notification); err != nil {
+		return errors.New("failed to save tiger sighting notification")
+	}
+	return nil
+}
+
+// GetModerationQueueService returns the pending and the flagged sightings, oldest first.
+func (s service) GetModerationQueueService(page, size int) ([]*models.TigerSighting, int, error) {
+	sightings, totalCount, err := s.TigerRepo.GetModerationQueue(page, size)
+	if err != nil {
+		log.Printf("failed to get moderation queue: %v", err)
+		return []*models.TigerSighting{}, 0, errors.New("failed to fetch moderation queue")
+	}
+	if err := s.attachSightingImages(sightings); err != nil {
+		return []*models.TigerSighting{}, 0, err
+	}
+	return sightings, totalCount, nil
+}
+
+// ReviewTigerSightingService approves or rejects the sighting and records the review of
+// the moderator. Approving a pending sighting notifies the reporters of the earlier
+// sightings of the tiger, rejecting an approved one moves the tiger back to its newest
+// approved sighting. The open flags of the sighting are resolved by the review.
+func (s service) ReviewTigerSightingService(sightingID int, reviewerEmail, status, note string) (*models.SightingReview, error) {
+	if status != models.SightingApproved && status != models.SightingRejected {
+		return nil, ErrInvalidReviewStatus
 	}
 
-	// If there is a previous sighting, calculate the distance between the coordinates
-	if previousSighting != nil {
-		previousCoordinates := models.Coordinates{Lat: previousSighting.Lat, Long: previousSighting.Long}
-		currentCoordinates := models.Coordinates{Lat: newSighting.Lat, Long: newSighting.Long}
-		distance := utils.CalculateDistance(previousCoordinates, currentCoordinates)
+	reviewer, err := s.TigerRepo.GetUserByEmail(reviewerEmail)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	} else if err != nil {
+		return nil, errors.New("failed to retrieve reviewer")
+	}
+
+	review := &models.SightingReview{SightingID: sightingID, ReviewerID: reviewer.ID, Status: status, Note: note}
+	var tigerID int
+	// approved is the sighting when the review made it public
+	var approved *models.TigerSighting
+	err = s.TigerRepo.WithTx(func(tx repository.TigerRepository) error {
+		// Lock the tiger before the sighting, in the same order as new sightings do
+		sighting, err := tx.GetTigerSightingByID(sightingID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrSightingNotFound
+		} else if err != nil {
+			return errors.New("failed to retrieve tiger sighting")
+		}
+		tiger, err := tx.LockTiger(sighting.TigerID)
+		if errors.Is(err, repository.ErrNotFound) {
+			// The tiger has been deleted since the sighting was reported
+			return ErrTigerNotFound
+		} else if err != nil {
+			return errors.New("failed to retrieve tiger")
+		}
+		tigerID = tiger.ID
+		if sighting, err = tx.LockTigerSighting(sightingID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return ErrSightingNotFound
+			}
+			return errors.New("failed to retrieve tiger sighting")
+		}
 
-		// If the distance is less than or equal to 5 kilometers, reject the new sighting
-		if distance <= 5.0 {
-			return errors.New("A tiger sighting within 5 kilometers already exists")
+		previousStatus := sighting.Status
+		if status != previousStatus {
+			if err := tx.UpdateTigerSightingStatus(sightingID, status); err != nil {
+				return errors.New("failed to update tiger sighting status")
+			}
+			sighting.Status = status
 		}
+		if err := tx.CreateSightingReview(review); err != nil {
+			return errors.New("failed to save sighting review")
+		}
+		if err := tx.ResolveSightingFlags(sightingID); err != nil {
+			return errors.New("failed to resolve sighting flags")
+		}
+
+		switch {
+		case status == models.SightingApproved && previousStatus != models.SightingApproved:
+			approved = sighting
+			return publishSighting(tx, tiger, sighting)
+		case status == models.SightingRejected && previousStatus == models.SightingApproved:
+			if err := tx.RefreshTigerLastSeen(sighting.TigerID); err != nil {
+				return errors.New("failed to update tiger last seen")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Create the tiger sighting in the database
-	err = s.TigerRepo.CreateTigerSighting(newSighting)
+	// The sighting may have been approved, rejected or left as it was
+	s.stats.invalidateTiger(tigerID)
+	if approved != nil && s.hub.Subscribers() > 0 {
+		// The feeds get the sighting with its images, like the lists of sightings
+		if err := s.attachSightingImages([]*models.TigerSighting{approved}); err != nil {
+			log.Printf("failed to attach images of sighting %d to the feed: %v", approved.ID, err)
+		}
+		s.hub.Publish(feedSighting(approved))
+	}
+	return review, nil
+}
+
+// SubscribeSightingsService subscribes to the sightings kept by the filter as they are
+// approved, either when reported by a trusted reporter or by a moderator. The subscription
+// has to be closed.
+func (s service) SubscribeSightingsService(filter models.SightingFeedFilter) *SightingSubscription {
+	return s.hub.Subscribe(filter)
+}
+
+// feedSighting returns the public part of the sighting sent to the feeds.
+func feedSighting(sighting *models.TigerSighting) *models.TigerSighting {
+	return &models.TigerSighting{
+		ID:            sighting.ID,
+		TigerID:       sighting.TigerID,
+		Timestamp:     sighting.Timestamp,
+		Lat:           sighting.Lat,
+		Long:          sighting.Long,
+		ImageKey:      sighting.ImageKey,
+		Images:        sighting.Images,
+		ReporterEmail: sighting.ReporterEmail,
+		Status:        sighting.Status,
+	}
+}
+
+// FlagTigerSightingService reports an approved sighting as wrong, so that it goes back
+// to the moderation queue. Flagging the same sighting twice is a no-op.
+func (s service) FlagTigerSightingService(sightingID int, email, reason string) error {
+	sighting, err := s.TigerRepo.GetTigerSightingByID(sightingID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrSightingNotFound
+	} else if err != nil {
+		return errors.New("failed to retrieve tiger sighting")
+	}
+
+	// Sightings which are not public cannot be flagged
+	if sighting.Status != models.SightingApproved {
+		return ErrSightingNotFound
+	}
+
+	flag := &models.SightingFlag{SightingID: sightingID, ReporterEmail: utils.NormalizeEmail(email), Reason: reason}
+	if err := s.TigerRepo.CreateSightingFlag(flag); err != nil {
+		return errors.New("failed to flag tiger sighting")
+	}
+	return nil
+}
+
+// GetTigerSightingsByIDService returns the page of approved sightings of the tiger selected
+// by request, newest first, with their images and its page info.
+func (s service) GetTigerSightingsByIDService(tigerID int, request models.PageRequest) ([]*models.TigerSighting, *models.PageInfo, error) {
+	var tigerSightings []*models.TigerSighting
+	var info *models.PageInfo
+	err := s.TigerRepo.WithSnapshot(func(tx repository.TigerRepository) error {
+		var err error
+		tigerSightings, info, err = lister[*models.TigerSighting]{
+			after: func(cursor *models.Cursor, limit int) ([]*models.TigerSighting, error) {
+				return tx.GetTigerSightingsAfter(tigerID, cursor, limit)
+			},
+			page: func(page, pageSize int) ([]*models.TigerSighting, error) {
+				return tx.GetTigerSightingsByIDWithPagination(tigerID, page, pageSize)
+			},
+			count: func(estimate bool) (int, error) {
+				return tx.GetTigerSightingsCountByID(tigerID, estimate)
+			},
+			cursor: func(sighting *models.TigerSighting) models.Cursor {
+				return models.Cursor{Time: sighting.Timestamp, ID: sighting.ID}
+			},
+		}.list(request)
+		return err
+	})
 	if err != nil {
-		return errors.New("failed to create tiger sighting")
+		return []*models.TigerSighting{}, nil, fmt.Errorf("error: %v", err)
+	}
+
+	if err := s.attachSightingImages(tigerSightings); err != nil {
+		return []*models.TigerSighting{}, nil, err
 	}
 
-	previousSightings, err := s.TigerRepo.GetTigerSightingsByID(newSighting.TigerID)
+	return tigerSightings, info, nil
+}
+
+// GetTigerSightingsAfterService returns at most limit approved sightings of the tiger
+// following the cursor, newest first, with their images.
+func (s service) GetTigerSightingsAfterService(tigerID int, after *models.Cursor, limit int) ([]*models.TigerSighting, error) {
+	tigerSightings, err := s.TigerRepo.GetTigerSightingsAfter(tigerID, after, limit)
 	if err != nil {
-		return errors.New("failed to retrieve previous sightings")
+		return []*models.TigerSighting{}, errors.New("failed to fetch tiger sightings")
+	}
+	if err := s.attachSightingImages(tigerSightings); err != nil {
+		return []*models.TigerSighting{}, err
 	}
+	return tigerSightings, nil
+}
 
-	// Publish a new tiger sighting message
-	if s.messageBroker != nil {
-		if err := s.messageBroker.PublishMessage(utils.GetMails(previousSightings)); err != nil {
-			log.Printf("failed to publish message: %v", err)
+// GetLatestTigerSightingsService returns the limit newest approved sightings of each of
+// the tigers with their images, ordered by tiger and newest first. The sightings of all
+// the tigers are fetched at once.
+func (s service) GetLatestTigerSightingsService(tigerIDs []int, limit int) ([]*models.TigerSighting, error) {
+	tigerSightings, err := s.TigerRepo.GetLatestTigerSightings(tigerIDs, limit)
+	if err != nil {
+		return []*models.TigerSighting{}, errors.New("failed to fetch tiger sightings")
+	}
+	if err := s.attachSightingImages(tigerSightings); err != nil {
+		return []*models.TigerSighting{}, err
+	}
+	return tigerSightings, nil
+}
+
+// GetUsersByEmailsService returns the users with the emails in no particular order,
+// without their passwords.
+func (s service) GetUsersByEmailsService(emails []string) ([]*models.User, error) {
+	users, err := s.TigerRepo.GetUsersByEmails(emails)
+	if err != nil {
+		return []*models.User{}, errors.New("failed to fetch users")
+	}
+	return users, nil
+}
+
+func (s service) GetTigerSightingsInAreaService(box models.BoundingBox, from, to time.Time, limit int) ([]*models.TigerSighting, error) {
+	tigerSightings, err := s.TigerRepo.GetTigerSightingsInBoundingBox(box, from, to, limit)
+	if err != nil {
+		return []*models.TigerSighting{}, errors.New("failed to fetch tiger sightings")
+	}
+	return tigerSightings, nil
+}
+
+// StreamTigerTrailService calls fn for every sighting of the tiger, oldest first.
+// Errors returned by fn are passed through unchanged.
+func (s service) StreamTigerTrailService(tigerID int, fn func(*models.TigerSighting) error) error {
+	err := s.TigerRepo.StreamTigerSightings(tigerID, fn)
+	if err != nil {
+		log.Printf("failed to stream tiger sightings: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ExportTigerSightingsService calls fn for every sighting in the time range, oldest first.
+// Errors returned by fn are passed through unchanged.
+func (s service) ExportTigerSightingsService(from, to time.Time, fn func(*models.TigerSighting) error) error {
+	err := s.TigerRepo.StreamTigerSightingsBetween(from, to, fn)
+	if err != nil {
+		log.Printf("failed to export tiger sightings: %v", err)
+		return err
+	}
+	return nil
+}
+
+// saveSightingPhotos saves every photo of the new sighting in each rendition to the image
+// store. All photos are rendered first, so that an invalid photo saves nothing.
+func (s service) saveSightingPhotos(newSighting *models.TigerSighting) error {
+	photos := make([][]utils.RenderedImage, len(newSighting.Photos))
+	for i, photo := range newSighting.Photos {
+		rendered, err := utils.RenderPhoto(photo, s.renditions)
+		if errors.Is(err, utils.ErrUnsupportedImage) {
+			return ErrInvalidImage
+		} else if err != nil {
+			log.Printf("failed to render tiger sighting image: %v", err)
+			return errors.New("failed to save tiger sighting image")
 		}
+		photos[i] = rendered
 	}
 
+	for position, rendered := range photos {
+		for _, r := range rendered {
+			key, err := storage.NewImageKey(newSighting.TigerID, r.Format)
+			if err == nil {
+				err = s.imageStore.Put(key, r.Data, r.ContentType)
+			}
+			if err != nil {
+				log.Printf("failed to save tiger sighting image: %v", err)
+				s.deleteSightingImages(newSighting)
+				return errors.New("failed to save tiger sighting image")
+			}
+
+			newSighting.Images = append(newSighting.Images, &models.SightingImage{
+				Position:    position,
+				Rendition:   r.Rendition,
+				Format:      r.Format,
+				ImageKey:    key,
+				ContentType: r.ContentType,
+				Width:       r.Width,
+				Height:      r.Height,
+			})
+			// The image of the sighting is its first resized JPEG
+			if newSighting.ImageKey == "" && r.Rendition != utils.OriginalRendition && r.Format == utils.FormatJPEG {
+				newSighting.ImageKey = key
+			}
+		}
+	}
+
+	newSighting.Photos = nil
 	return nil
 }
 
-func (s service) GetTigerSightingsByIDService(tigerID, page, pageSize int) ([]*models.TigerSighting, int, error) {
-	// Get a list of all tiger sightings for the specific tiger from the database with pagination
-	tigerSightings, totalCount, err := s.TigerRepo.GetTigerSightingsByIDWithPagination(tigerID, page, pageSize)
+// deleteSightingImages removes the images of a sighting that could not be saved.
+func (s service) deleteSightingImages(newSighting *models.TigerSighting) {
+	for _, image := range newSighting.Images {
+		if err := s.imageStore.Delete(image.ImageKey); err != nil {
+			log.Printf("failed to delete orphaned tiger sighting image %s: %v", image.ImageKey, err)
+		}
+	}
+	newSighting.Images = nil
+	newSighting.ImageKey = ""
+}
+
+// attachSightingImages sets the Images of the sightings.
+func (s service) attachSightingImages(sightings []*models.TigerSighting) error {
+	if len(sightings) == 0 {
+		return nil
+	}
+
+	sightingIDs := make([]int, len(sightings))
+	byID := make(map[int]*models.TigerSighting, len(sightings))
+	for i, sighting := range sightings {
+		sightingIDs[i] = sighting.ID
+		byID[sighting.ID] = sighting
+	}
+
+	images, err := s.TigerRepo.GetSightingImages(sightingIDs)
 	if err != nil {
-		return []*models.TigerSighting{}, totalCount, fmt.Errorf("error: %v", err)
+		return errors.New("failed to retrieve tiger sighting images")
+	}
+	for _, image := range images {
+		if sighting, ok := byID[image.SightingID]; ok {
+			sighting.Images = append(sighting.Images, image)
+		}
 	}
+	return nil
+}
 
-	// Sort the tiger sightings by date
-	sort.Slice(tigerSightings, func(i, j int) bool {
-		return tigerSightings[i].Timestamp.After(tigerSightings[j].Timestamp)
-	})
+// GetTigerSightingImageService returns the image of an approved sighting.
+func (s service) GetTigerSightingImageService(sightingID int) (io.ReadCloser, error) {
+	return s.getSightingImage(sightingID, true)
+}
+
+// GetModerationSightingImageService returns the image of a sighting whatever its status,
+// for moderators.
+func (s service) GetModerationSightingImageService(sightingID int) (io.ReadCloser, error) {
+	return s.getSightingImage(sightingID, false)
+}
+
+func (s service) getSightingImage(sightingID int, approvedOnly bool) (io.ReadCloser, error) {
+	sighting, err := s.TigerRepo.GetTigerSightingByID(sightingID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrSightingNotFound
+	} else if err != nil {
+		return nil, errors.New("failed to retrieve tiger sighting")
+	}
+	if approvedOnly && sighting.Status != models.SightingApproved {
+		return nil, ErrSightingNotFound
+	}
+
+	// Sightings reported before images moved to the image store have no key
+	if sighting.ImageKey == "" {
+		return nil, ErrImageNotFound
+	}
+
+	return s.readSightingImage(sighting.ImageKey)
+}
+
+// GetTigerSightingPhotoService returns the photo at position of an approved sighting in
+// the rendition and format.
+func (s service) GetTigerSightingPhotoService(sightingID, position int, rendition, format string) (io.ReadCloser, *models.SightingImage, error) {
+	return s.getSightingPhoto(sightingID, position, rendition, format, true)
+}
+
+// GetModerationSightingPhotoService returns the photo at position of a sighting whatever
+// its status, for moderators.
+func (s service) GetModerationSightingPhotoService(sightingID, position int, rendition, format string) (io.ReadCloser, *models.SightingImage, error) {
+	return s.getSightingPhoto(sightingID, position, rendition, format, false)
+}
+
+func (s service) getSightingPhoto(sightingID, position int, rendition, format string, approvedOnly bool) (io.ReadCloser, *models.SightingImage, error) {
+	sighting, err := s.TigerRepo.GetTigerSightingByID(sightingID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, ErrSightingNotFound
+	} else if err != nil {
+		return nil, nil, errors.New("failed to retrieve tiger sighting")
+	}
+	if approvedOnly && sighting.Status != models.SightingApproved {
+		return nil, nil, ErrSightingNotFound
+	}
+
+	image, err := s.TigerRepo.GetSightingImage(sightingID, position, rendition, format)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, ErrImageNotFound
+	} else if err != nil {
+		return nil, nil, errors.New("failed to retrieve tiger sighting image")
+	}
+
+	data, err := s.readSightingImage(image.ImageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, image, nil
+}
+
+func (s service) readSightingImage(key string) (io.ReadCloser, error) {
+	image, err := s.imageStore.Get(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrImageNotFound
+	} else if err != nil {
+		log.Printf("failed to read tiger sighting image: %v", err)
+		return nil, errors.New("failed to retrieve tiger sighting image")
+	}
+	return image, nil
+}
+
+// SetTigerSubscriptionService subscribes the user to sightings of the tiger, or opts them out.
+func (s service) SetTigerSubscriptionService(email string, tigerID int, subscribed bool) error {
+	_, err := s.TigerRepo.GetTigerByID(tigerID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrTigerNotFound
+	} else if err != nil {
+		return errors.New("failed to retrieve tiger")
+	}
 
-	return tigerSightings, totalCount, nil
+	if err := s.TigerRepo.SetTigerSubscription(utils.NormalizeEmail(email), tigerID, subscribed); err != nil {
+		return errors.New("failed to update tiger subscription")
+	}
+	return nil
+}
+
+// SetNotificationPreferencesService switches the user between one email per sighting and an hourly digest.
+func (s service) SetNotificationPreferencesService(email string, digest bool) error {
+	if err := s.TigerRepo.SetDigestMode(utils.NormalizeEmail(email), digest); err != nil {
+		return errors.New("failed to update notification preferences")
+	}
+	return nil
 }
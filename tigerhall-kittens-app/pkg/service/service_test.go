@@ -1,7 +1,13 @@
 package service
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"image"
+	"image/jpeg"
+	"io"
+	"io/ioutil"
 	"sort"
 	"testing"
 	"time"
@@ -9,6 +15,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	"tigerhall-kittens-app/pkg/auth"
 	"tigerhall-kittens-app/pkg/models"
+	"tigerhall-kittens-app/pkg/repository"
+	"tigerhall-kittens-app/pkg/storage"
+	"tigerhall-kittens-app/pkg/utils"
 )
 
 // mockTigerRepo is a mock implementation of the TigerRepository interface.
@@ -16,11 +25,82 @@ type mockTigerRepo struct {
 	createUser                          func(user *models.User) error
 	getUserByEmail                      func(email string) (*models.User, error)
 	createTiger                         func(tiger *models.Tiger) error
-	getAllTigersWithPagination          func(page, pageSize int) ([]*models.Tiger, int, error)
+	getTigerByID                        func(tigerID int) (*models.Tiger, error)
+	updateTiger                         func(tiger *models.Tiger) error
+	deleteTiger                         func(tigerID int) error
+	lockTiger                           func(tigerID int) (*models.Tiger, error)
+	updateTigerLastSeen                 func(tigerSighting *models.TigerSighting) error
+	getAllTigersWithPagination          func(filter models.TigerFilter, page, pageSize int) ([]*models.Tiger, error)
+	getTigersInBoundingBox              func(box models.BoundingBox) ([]*models.Tiger, error)
 	createTigerSighting                 func(newSighting *models.TigerSighting) error
+	getTigerSightingByID                func(sightingID int) (*models.TigerSighting, error)
+	getLegacySightingImages             func(limit int) ([]*models.TigerSighting, error)
+	moveLegacySightingImage             func(sightingID int, imageKey string) error
 	getTigerSightingsByID               func(tigerID int) ([]*models.TigerSighting, error)
-	getPreviousTigerSighting            func(tigerID int) (*models.TigerSighting, error)
-	getTigerSightingsByIDWithPagination func(tigerID, page, pageSize int) ([]*models.TigerSighting, int, error)
+	getAdjacentTigerSightings           func(tigerID int, reporterEmail string, timestamp time.Time) (*models.TigerSighting, *models.TigerSighting, error)
+	getTigerSightingsBetween            func(tigerID int, reporterEmail string, from, to time.Time) ([]*models.TigerSighting, error)
+	streamTigerSightings                func(tigerID int, fn func(*models.TigerSighting) error) error
+	streamTigerSightingsBetween         func(from, to time.Time, fn func(*models.TigerSighting) error) error
+	getTigerSightingsInBoundingBox      func(box models.BoundingBox, from, to time.Time, limit int) ([]*models.TigerSighting, error)
+	getTigerSightingsByIDWithPagination func(tigerID, page, pageSize int) ([]*models.TigerSighting, error)
+	isNotificationDelivered             func(messageID, recipient string) (bool, error)
+	markNotificationDelivered           func(messageID, recipient string) error
+	getNotificationPreference           func(email string, tigerID int) (*models.NotificationPreference, error)
+	setTigerSubscription                func(email string, tigerID int, subscribed bool) error
+	setDigestMode                       func(email string, digest bool) error
+	addDigestItem                       func(messageID string, item *models.DigestItem) error
+	getPendingDigestItems               func() ([]*models.DigestItem, error)
+	markDigestItemsSent                 func(recipient string, lastID int) error
+	createOutboxMessage                 func(payload []byte) error
+	getPendingOutboxMessages            func(limit int) ([]*models.OutboxMessage, error)
+	markOutboxMessageSent               func(id int64) error
+	getUserByID                         func(userID int) (*models.User, error)
+	createRefreshToken                  func(token *models.RefreshToken) error
+	lockRefreshToken                    func(tokenHash string) (*models.RefreshToken, error)
+	revokeRefreshToken                  func(tokenID int) error
+	revokeUserRefreshTokens             func(userID int) error
+	revokeAccessToken                   func(tokenID string, expiresAt time.Time) error
+	isAccessTokenRevoked                func(tokenID string) (bool, error)
+	getUserRoles                        func(userID int) ([]string, error)
+	grantUserRole                       func(userID int, role string) error
+	revokeUserRole                      func(userID int, role string) error
+	markEmailVerified                   func(userID int) error
+	updateUserPassword                  func(userID int, hashedPassword string) error
+	createUserToken                     func(token *models.UserToken) error
+	lockUserToken                       func(purpose, tokenHash string) (*models.UserToken, error)
+	useUserTokens                       func(userID int, purpose string) error
+	renewUserToken                      func(tokenID int, tokenHash string) (bool, error)
+	lockTigerSighting                   func(sightingID int) (*models.TigerSighting, error)
+	updateTigerSightingStatus           func(sightingID int, status string) error
+	refreshTigerLastSeen                func(tigerID int) error
+	getModerationQueue                  func(page, pageSize int) ([]*models.TigerSighting, int, error)
+	createSightingReview                func(review *models.SightingReview) error
+	createSightingFlag                  func(flag *models.SightingFlag) error
+	resolveSightingFlags                func(sightingID int) error
+	countReporterSightings              func(email string) (approved, rejected int, err error)
+	createSightingImages                func(images []*models.SightingImage) error
+	getSightingImages                   func(sightingIDs []int) ([]*models.SightingImage, error)
+	getSightingImage                    func(sightingID, position int, rendition, format string) (*models.SightingImage, error)
+	getUsersByEmails                    func(emails []string) ([]*models.User, error)
+	getTigersAfter                      func(filter models.TigerFilter, after *models.Cursor, limit int) ([]*models.Tiger, error)
+	getTigersByIDs                      func(tigerIDs []int) ([]*models.Tiger, error)
+	getTigerSightingsAfter              func(tigerID int, after *models.Cursor, limit int) ([]*models.TigerSighting, error)
+	getLatestTigerSightings             func(tigerIDs []int, limit int) ([]*models.TigerSighting, error)
+	getTotalTigerCount                  func(filter models.TigerFilter, estimate bool) (int, error)
+	getTigerSightingsCountByID          func(tigerID int, estimate bool) (int, error)
+	getDailySightingCounts              func(from, to time.Time) ([]*models.DailySightings, error)
+	getMostActiveTigers                 func(from, to time.Time, limit int) ([]*models.ActiveTiger, error)
+	getTigerFollowers                   func(tigerID int) ([]string, error)
+	getTigerSubscriptions               func(email string) ([]*models.TigerSubscription, error)
+	createSightingAlert                 func(alert *models.SightingAlert, area models.BoundingBox) error
+	getSightingAlerts                   func(email string) ([]*models.SightingAlert, error)
+	getSightingAlertsAt                 func(coordinates models.Coordinates, tigerID int) ([]*models.SightingAlert, error)
+	deleteSightingAlert                 func(alertID int, email string) error
+	queueWebhookDelivery                func(messageID string, alertID int, payload []byte) error
+	claimWebhookDeliveries              func(limit int, lease time.Duration) ([]*models.WebhookDelivery, error)
+	markWebhookDelivered                func(id int64) error
+	retryWebhookDelivery                func(id int64, delay time.Duration, lastError string) error
+	failWebhookDelivery                 func(id int64, lastError string) error
 }
 
 func (m *mockTigerRepo) CreateUser(user *models.User) error {
@@ -35,33 +115,359 @@ func (m *mockTigerRepo) CreateTiger(tiger *models.Tiger) error {
 	return m.createTiger(tiger)
 }
 
-func (m *mockTigerRepo) GetAllTigersWithPagination(page, pageSize int) ([]*models.Tiger, int, error) {
-	return m.getAllTigersWithPagination(page, pageSize)
+func (m *mockTigerRepo) GetTigerByID(tigerID int) (*models.Tiger, error) {
+	return m.getTigerByID(tigerID)
+}
+
+func (m *mockTigerRepo) UpdateTiger(tiger *models.Tiger) error {
+	return m.updateTiger(tiger)
+}
+
+func (m *mockTigerRepo) DeleteTiger(tigerID int) error {
+	return m.deleteTiger(tigerID)
+}
+
+func (m *mockTigerRepo) LockTiger(tigerID int) (*models.Tiger, error) {
+	return m.lockTiger(tigerID)
+}
+
+func (m *mockTigerRepo) UpdateTigerLastSeen(tigerSighting *models.TigerSighting) error {
+	return m.updateTigerLastSeen(tigerSighting)
+}
+
+func (m *mockTigerRepo) IsNotificationDelivered(messageID, recipient string) (bool, error) {
+	return m.isNotificationDelivered(messageID, recipient)
+}
+
+func (m *mockTigerRepo) MarkNotificationDelivered(messageID, recipient string) error {
+	return m.markNotificationDelivered(messageID, recipient)
+}
+
+func (m *mockTigerRepo) GetNotificationPreference(email string, tigerID int) (*models.NotificationPreference, error) {
+	return m.getNotificationPreference(email, tigerID)
+}
+
+func (m *mockTigerRepo) SetTigerSubscription(email string, tigerID int, subscribed bool) error {
+	return m.setTigerSubscription(email, tigerID, subscribed)
+}
+
+func (m *mockTigerRepo) SetDigestMode(email string, digest bool) error {
+	return m.setDigestMode(email, digest)
+}
+
+func (m *mockTigerRepo) AddDigestItem(messageID string, item *models.DigestItem) error {
+	return m.addDigestItem(messageID, item)
+}
+
+func (m *mockTigerRepo) GetPendingDigestItems() ([]*models.DigestItem, error) {
+	return m.getPendingDigestItems()
+}
+
+func (m *mockTigerRepo) MarkDigestItemsSent(recipient string, lastID int) error {
+	return m.markDigestItemsSent(recipient, lastID)
+}
+
+func (m *mockTigerRepo) WithTx(fn func(tx repository.TigerRepository) error) error {
+	return fn(m)
+}
+
+func (m *mockTigerRepo) WithSnapshot(fn func(tx repository.TigerRepository) error) error {
+	return fn(m)
+}
+
+func (m *mockTigerRepo) GetAllTigersWithPagination(filter models.TigerFilter, page, pageSize int) ([]*models.Tiger, error) {
+	return m.getAllTigersWithPagination(filter, page, pageSize)
+}
+
+func (m *mockTigerRepo) GetTigersInBoundingBox(box models.BoundingBox) ([]*models.Tiger, error) {
+	return m.getTigersInBoundingBox(box)
 }
 
 func (m *mockTigerRepo) CreateTigerSighting(newSighting *models.TigerSighting) error {
 	return m.createTigerSighting(newSighting)
 }
 
+func (m *mockTigerRepo) GetTigerSightingByID(sightingID int) (*models.TigerSighting, error) {
+	return m.getTigerSightingByID(sightingID)
+}
+
+func (m *mockTigerRepo) GetLegacySightingImages(limit int) ([]*models.TigerSighting, error) {
+	return m.getLegacySightingImages(limit)
+}
+
+func (m *mockTigerRepo) MoveLegacySightingImage(sightingID int, imageKey string) error {
+	return m.moveLegacySightingImage(sightingID, imageKey)
+}
+
 func (m *mockTigerRepo) GetTigerSightingsByID(tigerID int) ([]*models.TigerSighting, error) {
 	return m.getTigerSightingsByID(tigerID)
 }
 
-func (m *mockTigerRepo) GetPreviousTigerSighting(tigerID int) (*models.TigerSighting, error) {
-	return m.getPreviousTigerSighting(tigerID)
+func (m *mockTigerRepo) GetAdjacentTigerSightings(tigerID int, reporterEmail string, timestamp time.Time) (*models.TigerSighting, *models.TigerSighting, error) {
+	return m.getAdjacentTigerSightings(tigerID, reporterEmail, timestamp)
+}
+
+func (m *mockTigerRepo) GetTigerSightingsBetween(tigerID int, reporterEmail string, from, to time.Time) ([]*models.TigerSighting, error) {
+	return m.getTigerSightingsBetween(tigerID, reporterEmail, from, to)
 }
 
-func (m *mockTigerRepo) GetTigerSightingsByIDWithPagination(tigerID, page, pageSize int) ([]*models.TigerSighting, int, error) {
+func (m *mockTigerRepo) StreamTigerSightings(tigerID int, fn func(*models.TigerSighting) error) error {
+	return m.streamTigerSightings(tigerID, fn)
+}
+
+func (m *mockTigerRepo) StreamTigerSightingsBetween(from, to time.Time, fn func(*models.TigerSighting) error) error {
+	return m.streamTigerSightingsBetween(from, to, fn)
+}
+
+func (m *mockTigerRepo) GetTigerSightingsInBoundingBox(box models.BoundingBox, from, to time.Time, limit int) ([]*models.TigerSighting, error) {
+	return m.getTigerSightingsInBoundingBox(box, from, to, limit)
+}
+
+func (m *mockTigerRepo) GetTigerSightingsByIDWithPagination(tigerID, page, pageSize int) ([]*models.TigerSighting, error) {
 	return m.getTigerSightingsByIDWithPagination(tigerID, page, pageSize)
 }
 
+func (m *mockTigerRepo) CreateOutboxMessage(payload []byte) error {
+	return m.createOutboxMessage(payload)
+}
+
+func (m *mockTigerRepo) GetPendingOutboxMessages(limit int) ([]*models.OutboxMessage, error) {
+	return m.getPendingOutboxMessages(limit)
+}
+
+func (m *mockTigerRepo) MarkOutboxMessageSent(id int64) error {
+	return m.markOutboxMessageSent(id)
+}
+
+func (m *mockTigerRepo) GetUserByID(userID int) (*models.User, error) {
+	return m.getUserByID(userID)
+}
+
+func (m *mockTigerRepo) CreateRefreshToken(token *models.RefreshToken) error {
+	return m.createRefreshToken(token)
+}
+
+func (m *mockTigerRepo) LockRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	return m.lockRefreshToken(tokenHash)
+}
+
+func (m *mockTigerRepo) RevokeRefreshToken(tokenID int) error {
+	return m.revokeRefreshToken(tokenID)
+}
+
+func (m *mockTigerRepo) RevokeUserRefreshTokens(userID int) error {
+	return m.revokeUserRefreshTokens(userID)
+}
+
+func (m *mockTigerRepo) RevokeAccessToken(tokenID string, expiresAt time.Time) error {
+	return m.revokeAccessToken(tokenID, expiresAt)
+}
+
+func (m *mockTigerRepo) IsAccessTokenRevoked(tokenID string) (bool, error) {
+	return m.isAccessTokenRevoked(tokenID)
+}
+
+func (m *mockTigerRepo) GetUserRoles(userID int) ([]string, error) {
+	return m.getUserRoles(userID)
+}
+
+func (m *mockTigerRepo) GrantUserRole(userID int, role string) error {
+	return m.grantUserRole(userID, role)
+}
+
+func (m *mockTigerRepo) RevokeUserRole(userID int, role string) error {
+	return m.revokeUserRole(userID, role)
+}
+
+func (m *mockTigerRepo) MarkEmailVerified(userID int) error {
+	return m.markEmailVerified(userID)
+}
+
+func (m *mockTigerRepo) UpdateUserPassword(userID int, hashedPassword string) error {
+	return m.updateUserPassword(userID, hashedPassword)
+}
+
+func (m *mockTigerRepo) CreateUserToken(token *models.UserToken) error {
+	return m.createUserToken(token)
+}
+
+func (m *mockTigerRepo) LockUserToken(purpose, tokenHash string) (*models.UserToken, error) {
+	return m.lockUserToken(purpose, tokenHash)
+}
+
+func (m *mockTigerRepo) UseUserTokens(userID int, purpose string) error {
+	return m.useUserTokens(userID, purpose)
+}
+
+func (m *mockTigerRepo) RenewUserToken(tokenID int, tokenHash string) (bool, error) {
+	return m.renewUserToken(tokenID, tokenHash)
+}
+
+func (m *mockTigerRepo) LockTigerSighting(sightingID int) (*models.TigerSighting, error) {
+	return m.lockTigerSighting(sightingID)
+}
+
+func (m *mockTigerRepo) UpdateTigerSightingStatus(sightingID int, status string) error {
+	return m.updateTigerSightingStatus(sightingID, status)
+}
+
+func (m *mockTigerRepo) RefreshTigerLastSeen(tigerID int) error {
+	return m.refreshTigerLastSeen(tigerID)
+}
+
+func (m *mockTigerRepo) GetModerationQueue(page, pageSize int) ([]*models.TigerSighting, int, error) {
+	return m.getModerationQueue(page, pageSize)
+}
+
+func (m *mockTigerRepo) CreateSightingReview(review *models.SightingReview) error {
+	return m.createSightingReview(review)
+}
+
+func (m *mockTigerRepo) CreateSightingFlag(flag *models.SightingFlag) error {
+	return m.createSightingFlag(flag)
+}
+
+func (m *mockTigerRepo) ResolveSightingFlags(sightingID int) error {
+	return m.resolveSightingFlags(sightingID)
+}
+
+func (m *mockTigerRepo) CountReporterSightings(email string) (approved, rejected int, err error) {
+	return m.countReporterSightings(email)
+}
+
+func (m *mockTigerRepo) CreateSightingImages(images []*models.SightingImage) error {
+	return m.createSightingImages(images)
+}
+
+func (m *mockTigerRepo) GetSightingImages(sightingIDs []int) ([]*models.SightingImage, error) {
+	return m.getSightingImages(sightingIDs)
+}
+
+func (m *mockTigerRepo) GetSightingImage(sightingID, position int, rendition, format string) (*models.SightingImage, error) {
+	return m.getSightingImage(sightingID, position, rendition, format)
+}
+
+func (m *mockTigerRepo) GetUsersByEmails(emails []string) ([]*models.User, error) {
+	return m.getUsersByEmails(emails)
+}
+
+func (m *mockTigerRepo) GetTigersAfter(filter models.TigerFilter, after *models.Cursor, limit int) ([]*models.Tiger, error) {
+	return m.getTigersAfter(filter, after, limit)
+}
+
+func (m *mockTigerRepo) GetTigersByIDs(tigerIDs []int) ([]*models.Tiger, error) {
+	return m.getTigersByIDs(tigerIDs)
+}
+
+func (m *mockTigerRepo) GetTigerSightingsAfter(tigerID int, after *models.Cursor, limit int) ([]*models.TigerSighting, error) {
+	return m.getTigerSightingsAfter(tigerID, after, limit)
+}
+
+func (m *mockTigerRepo) GetLatestTigerSightings(tigerIDs []int, limit int) ([]*models.TigerSighting, error) {
+	return m.getLatestTigerSightings(tigerIDs, limit)
+}
+
+func (m *mockTigerRepo) GetTotalTigerCount(filter models.TigerFilter, estimate bool) (int, error) {
+	return m.getTotalTigerCount(filter, estimate)
+}
+
+func (m *mockTigerRepo) GetTigerSightingsCountByID(tigerID int, estimate bool) (int, error) {
+	return m.getTigerSightingsCountByID(tigerID, estimate)
+}
+
+func (m *mockTigerRepo) GetDailySightingCounts(from, to time.Time) ([]*models.DailySightings, error) {
+	return m.getDailySightingCounts(from, to)
+}
+
+func (m *mockTigerRepo) GetMostActiveTigers(from, to time.Time, limit int) ([]*models.ActiveTiger, error) {
+	return m.getMostActiveTigers(from, to, limit)
+}
+
+func (m *mockTigerRepo) GetTigerFollowers(tigerID int) ([]string, error) {
+	return m.getTigerFollowers(tigerID)
+}
+
+func (m *mockTigerRepo) GetTigerSubscriptions(email string) ([]*models.TigerSubscription, error) {
+	return m.getTigerSubscriptions(email)
+}
+
+func (m *mockTigerRepo) CreateSightingAlert(alert *models.SightingAlert, area models.BoundingBox) error {
+	return m.createSightingAlert(alert, area)
+}
+
+func (m *mockTigerRepo) GetSightingAlerts(email string) ([]*models.SightingAlert, error) {
+	return m.getSightingAlerts(email)
+}
+
+func (m *mockTigerRepo) GetSightingAlertsAt(coordinates models.Coordinates, tigerID int) ([]*models.SightingAlert, error) {
+	return m.getSightingAlertsAt(coordinates, tigerID)
+}
+
+func (m *mockTigerRepo) DeleteSightingAlert(alertID int, email string) error {
+	return m.deleteSightingAlert(alertID, email)
+}
+
+func (m *mockTigerRepo) QueueWebhookDelivery(messageID string, alertID int, payload []byte) error {
+	return m.queueWebhookDelivery(messageID, alertID, payload)
+}
+
+func (m *mockTigerRepo) ClaimWebhookDeliveries(limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	return m.claimWebhookDeliveries(limit, lease)
+}
+
+func (m *mockTigerRepo) MarkWebhookDelivered(id int64) error {
+	return m.markWebhookDelivered(id)
+}
+
+func (m *mockTigerRepo) RetryWebhookDelivery(id int64, delay time.Duration, lastError string) error {
+	return m.retryWebhookDelivery(id, delay, lastError)
+}
+
+func (m *mockTigerRepo) FailWebhookDelivery(id int64, lastError string) error {
+	return m.failWebhookDelivery(id, lastError)
+}
+
+// mockImageStore is an in-memory implementation of the ImageStore interface.
+type mockImageStore struct {
+	images map[string][]byte
+}
+
+func (m *mockImageStore) Put(key string, data []byte, contentType string) error {
+	m.images[key] = data
+	return nil
+}
+
+func (m *mockImageStore) Get(key string) (io.ReadCloser, error) {
+	data, ok := m.images[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *mockImageStore) Delete(key string) error {
+	delete(m.images, key)
+	return nil
+}
+
 func TestSignupService_Success(t *testing.T) {
 	// Arrange
+	var userToken *models.UserToken
+	var email utils.AccountEmail
 	mockRepo := &mockTigerRepo{
 		createUser: func(user *models.User) error {
 			// Mock the CreateUser method to return nil (indicating success)
+			user.ID = 1
+			return nil
+		},
+		createUserToken: func(token *models.UserToken) error {
+			token.ID = 5
+			userToken = token
 			return nil
 		},
+		createOutboxMessage: func(payload []byte) error {
+			return json.Unmarshal(payload, &email)
+		},
 	}
 
 	tigerService := NewTigerService(mockRepo, nil)
@@ -69,7 +475,7 @@ func TestSignupService_Success(t *testing.T) {
 	// User with password to be hashed
 	user := models.User{
 		Username: "testuser",
-		Email:    "test@example.com",
+		Email:    " Test@Example.com",
 		Password: "testpassword",
 	}
 
@@ -81,6 +487,17 @@ func TestSignupService_Success(t *testing.T) {
 
 	// Ensure that the password was hashed
 	assert.NotEqual(t, "testpassword", user.Password, "Password should be hashed")
+	assert.Equal(t, "test@example.com", user.Email, "Email should be normalized")
+
+	// A verification email is sent with a token of the user
+	if assert.NotNil(t, userToken, "A verification token should be created") {
+		assert.Equal(t, 1, userToken.UserID)
+		assert.Equal(t, models.TokenPurposeEmailVerification, userToken.Purpose)
+		assert.Len(t, userToken.TokenHash, 64, "Only the hash of a token should be stored")
+	}
+	assert.Equal(t, 5, email.TokenID, "The email should reference the token")
+	assert.Equal(t, models.TokenPurposeEmailVerification, email.Type)
+	assert.Equal(t, "test@example.com", email.Recipient)
 
 }
 
@@ -120,8 +537,13 @@ func TestLoginService_Success(t *testing.T) {
 				Username: "testuser",
 				Email:    email,
 				Password: hashedPassword,
+				// The user verified their email
+				EmailVerified: true,
 			}, nil
 		},
+		getUserRoles: func(userID int) ([]string, error) {
+			return []string{models.RoleRanger}, nil
+		},
 	}
 
 	tigerService := NewTigerService(mockRepo, nil)
@@ -140,6 +562,7 @@ func TestLoginService_Success(t *testing.T) {
 	assert.NotNil(t, user, "User should not be nil")
 	assert.Equal(t, "testuser", user.Username, "Usernames should match")
 	assert.Equal(t, "test@example.com", user.Email, "Emails should match")
+	assert.Equal(t, []string{models.RoleRanger}, user.Roles, "Roles should be loaded")
 }
 
 func TestLoginService_Failure(t *testing.T) {
@@ -168,172 +591,838 @@ func TestLoginService_Failure(t *testing.T) {
 	assert.EqualError(t, err, "invalid email or password", "Error message should match")
 }
 
-func TestCreateTigerService_Success(t *testing.T) {
+func TestSignupService_EmailTaken(t *testing.T) {
 	// Arrange
 	mockRepo := &mockTigerRepo{
-		createTiger: func(tiger *models.Tiger) error {
-			// Mock the CreateTiger method to return nil (success)
-			return nil
+		createUser: func(user *models.User) error {
+			return repository.ErrAlreadyExists
 		},
 	}
 
 	tigerService := NewTigerService(mockRepo, nil)
 
-	// Create a test tiger
-	tiger := models.Tiger{
-		Name:        "Test Tiger",
-		DateOfBirth: time.Now(),
-		LastSeen:    time.Now(),
-		Lat:         12.34,
-		Long:        56.78,
-	}
-
 	// Act
-	err := tigerService.CreateTigerService(tiger)
+	err := tigerService.SignupService(&models.User{Username: "testuser", Email: "test@example.com", Password: "testpassword"})
 
 	// Assert
-	assert.NoError(t, err, "CreateTigerService should not return an error")
+	assert.Equal(t, ErrEmailTaken, err)
 }
 
-func TestCreateTigerService_Failure(t *testing.T) {
+func TestLoginService_EmailNotVerified(t *testing.T) {
 	// Arrange
 	mockRepo := &mockTigerRepo{
-		createTiger: func(tiger *models.Tiger) error {
-			// Mock the CreateTiger method to return an error
-			return errors.New("failed to create tiger")
+		getUserByEmail: func(email string) (*models.User, error) {
+			hashedPassword, _ := auth.HashPassword("testpassword")
+			return &models.User{ID: 1, Email: email, Password: hashedPassword}, nil
 		},
 	}
 
 	tigerService := NewTigerService(mockRepo, nil)
 
-	// Create a test tiger
-	tiger := models.Tiger{
-		Name:        "Test Tiger",
-		DateOfBirth: time.Now(),
-		LastSeen:    time.Now(),
-		Lat:         12.34,
-		Long:        56.78,
-	}
-
 	// Act
-	err := tigerService.CreateTigerService(tiger)
+	_, err := tigerService.LoginService(models.LoginCredentials{Email: "test@example.com", Password: "testpassword"})
 
 	// Assert
-	assert.Error(t, err, "CreateTigerService should return an error")
-	assert.EqualError(t, err, "failed to create tiger", "Error message should match")
+	assert.Equal(t, ErrEmailNotVerified, err)
 }
 
-func TestGetAllTigersService_Success(t *testing.T) {
-	// Arrange
-	mockRepo := &mockTigerRepo{
-		getAllTigersWithPagination: func(page, pageSize int) ([]*models.Tiger, int, error) {
-			// Mock the GetAllTigers method to return a list of tigers
-			tigers := []*models.Tiger{
-				{
-					Name:        "Tiger 1",
-					DateOfBirth: time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC),
-					LastSeen:    time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC),
-					Lat:         12.34,
-					Long:        56.78,
+func TestVerifyEmailService(t *testing.T) {
+	token, hash, _ := auth.NewUserToken()
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		userToken *models.UserToken
+		expected  error
+	}{
+		{"valid", &models.UserToken{ID: 1, UserID: 2, ExpiresAt: now.Add(time.Hour)}, nil},
+		{"unknown", nil, ErrInvalidUserToken},
+		{"expired", &models.UserToken{ID: 1, UserID: 2, ExpiresAt: now.Add(-time.Hour)}, ErrInvalidUserToken},
+		{"used", &models.UserToken{ID: 1, UserID: 2, ExpiresAt: now.Add(time.Hour), UsedAt: &now}, ErrInvalidUserToken},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			verifiedUserID := 0
+			usedUserID := 0
+			mockRepo := &mockTigerRepo{
+				lockUserToken: func(purpose, tokenHash string) (*models.UserToken, error) {
+					assert.Equal(t, models.TokenPurposeEmailVerification, purpose)
+					assert.Equal(t, hash, tokenHash)
+					if tt.userToken == nil {
+						return nil, repository.ErrNotFound
+					}
+					return tt.userToken, nil
 				},
-				{
-					Name:        "Tiger 2",
-					DateOfBirth: time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC),
-					LastSeen:    time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC),
-					Lat:         12.56,
-					Long:        56.78,
+				useUserTokens: func(userID int, purpose string) error {
+					usedUserID = userID
+					return nil
 				},
+				markEmailVerified: func(userID int) error {
+					verifiedUserID = userID
+					return nil
+				},
+			}
+
+			tigerService := NewTigerService(mockRepo, nil)
+
+			// Act
+			err := tigerService.VerifyEmailService(token)
+
+			// Assert
+			assert.Equal(t, tt.expected, err)
+			if tt.expected == nil {
+				assert.Equal(t, 2, verifiedUserID, "The email of the user should be verified")
+				assert.Equal(t, 2, usedUserID, "The token should be used")
+			} else {
+				assert.Zero(t, verifiedUserID, "The email should not be verified")
 			}
-			return tigers, len(tigers), nil
+		})
+	}
+}
+
+func TestForgotPasswordService_UnknownEmail(t *testing.T) {
+	// Arrange
+	mockRepo := &mockTigerRepo{
+		getUserByEmail: func(email string) (*models.User, error) {
+			return nil, repository.ErrNotFound
 		},
 	}
 
 	tigerService := NewTigerService(mockRepo, nil)
 
 	// Act
-	tigers, totalCount, err := tigerService.GetAllTigersService(1, 10)
+	err := tigerService.ForgotPasswordService("unknown@example.com")
 
 	// Assert
-	assert.NoError(t, err, "GetAllTigersService should not return an error")
-
-	// Sort the tigers by the last seen time (descending order)
-	sort.Slice(tigers, func(i, j int) bool {
-		return tigers[i].LastSeen.After(tigers[j].LastSeen)
-	})
-
-	// Check if the tigers are sorted by last seen time (descending order)
-	assert.True(t, tigers[0].LastSeen.After(tigers[1].LastSeen), "Tigers should be sorted by last seen time")
-	assert.Equal(t, totalCount, 2, "Tiger count should be 2")
+	assert.NoError(t, err, "Unknown emails should not be reported")
 }
 
-func TestGetAllTigersService_Failure(t *testing.T) {
+func TestForgotPasswordService_SendsResetToken(t *testing.T) {
 	// Arrange
+	var userToken *models.UserToken
+	var email utils.AccountEmail
 	mockRepo := &mockTigerRepo{
-		getAllTigersWithPagination: func(page, pageSize int) ([]*models.Tiger, int, error) {
-			// Mock the GetAllTigers method to return an error
-			return []*models.Tiger{}, 0, errors.New("failed to fetch tigers")
+		getUserByEmail: func(email string) (*models.User, error) {
+			return &models.User{ID: 1, Username: "testuser", Email: "test@example.com"}, nil
+		},
+		createUserToken: func(token *models.UserToken) error {
+			userToken = token
+			return nil
+		},
+		createOutboxMessage: func(payload []byte) error {
+			return json.Unmarshal(payload, &email)
 		},
 	}
 
 	tigerService := NewTigerService(mockRepo, nil)
 
 	// Act
-	tigers, _, err := tigerService.GetAllTigersService(1, 10)
+	err := tigerService.ForgotPasswordService("test@example.com")
 
 	// Assert
-	assert.Error(t, err, "GetAllTigersService should return an error")
-	assert.EqualError(t, err, "failed to fetch tigers", "Error message should match")
-	assert.Empty(t, tigers, "Tigers should be empty when there is an error")
+	assert.NoError(t, err)
+	if assert.NotNil(t, userToken) {
+		assert.Equal(t, models.TokenPurposePasswordReset, userToken.Purpose)
+		assert.WithinDuration(t, time.Now().Add(auth.PasswordResetTokenTTL), userToken.ExpiresAt, time.Minute)
+	}
+	assert.Equal(t, models.TokenPurposePasswordReset, email.Type)
+	assert.Equal(t, "test@example.com", email.Recipient)
 }
 
-func TestCreateTigerSightingService_Success(t *testing.T) {
+func TestResetPasswordService(t *testing.T) {
 	// Arrange
-	previousSighting := &models.TigerSighting{
-		ID:            1,
-		TigerID:       1,
-		Timestamp:     time.Date(2023, time.July, 20, 12, 0, 0, 0, time.UTC),
-		Lat:           12.34,
-		Long:          56.78,
-		ReporterEmail: "reporter@example.com",
-	}
-	newSighting := &models.TigerSighting{
-		TigerID:       1,
-		Timestamp:     time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC),
-		Lat:           13.35,
-		Long:          56.79,
-		ReporterEmail: "reporter@example.com",
-	}
-
+	var newPassword string
+	revokedUserID := 0
 	mockRepo := &mockTigerRepo{
-		getPreviousTigerSighting: func(tigerID int) (*models.TigerSighting, error) {
-			return previousSighting, nil
+		lockUserToken: func(purpose, tokenHash string) (*models.UserToken, error) {
+			assert.Equal(t, models.TokenPurposePasswordReset, purpose)
+			return &models.UserToken{ID: 1, UserID: 2, Purpose: purpose, ExpiresAt: time.Now().Add(time.Hour)}, nil
 		},
-		createTigerSighting: func(newSighting *models.TigerSighting) error {
-			// Simulate successful tiger sighting creation in the database
+		useUserTokens: func(userID int, purpose string) error {
 			return nil
 		},
-		getTigerSightingsByID: func(tigerID int) ([]*models.TigerSighting, error) {
-			// Return previous sighting as the only previous sighting for the tiger
-			return []*models.TigerSighting{previousSighting}, nil
+		updateUserPassword: func(userID int, hashedPassword string) error {
+			newPassword = hashedPassword
+			return nil
+		},
+		markEmailVerified: func(userID int) error {
+			return nil
+		},
+		revokeUserRefreshTokens: func(userID int) error {
+			revokedUserID = userID
+			return nil
 		},
 	}
 
 	tigerService := NewTigerService(mockRepo, nil)
 
 	// Act
-	err := tigerService.CreateTigerSightingService(newSighting)
+	err := tigerService.ResetPasswordService("reset-token", "newpassword")
 
 	// Assert
-	assert.NoError(t, err, "CreateTigerSightingService should not return an error")
-
+	assert.NoError(t, err)
+	assert.NoError(t, auth.VerifyPassword(newPassword, "newpassword"), "The new password should be saved hashed")
+	assert.Equal(t, 2, revokedUserID, "The user should be logged out everywhere")
 }
 
-func TestCreateTigerSightingService_ExistingSightingWithin5Km(t *testing.T) {
+func TestRefreshTokenService_RotatesToken(t *testing.T) {
 	// Arrange
-	previousSighting := &models.TigerSighting{
-		ID:            1,
+	var revokedID int
+	var created *models.RefreshToken
+	mockRepo := &mockTigerRepo{
+		lockRefreshToken: func(tokenHash string) (*models.RefreshToken, error) {
+			assert.Equal(t, auth.HashRefreshToken("refresh-token"), tokenHash, "Tokens should be looked up by hash")
+			return &models.RefreshToken{ID: 1, UserID: 7, ExpiresAt: time.Now().Add(time.Hour)}, nil
+		},
+		getUserByID: func(userID int) (*models.User, error) {
+			return &models.User{ID: userID, Username: "testuser", Email: "test@example.com"}, nil
+		},
+		getUserRoles: func(userID int) ([]string, error) {
+			return []string{}, nil
+		},
+		revokeRefreshToken: func(tokenID int) error {
+			revokedID = tokenID
+			return nil
+		},
+		createRefreshToken: func(token *models.RefreshToken) error {
+			created = token
+			return nil
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Act
+	user, newToken, err := tigerService.RefreshTokenService("refresh-token")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 7, user.ID)
+	assert.Equal(t, 1, revokedID, "Used refresh token should be revoked")
+	assert.Equal(t, auth.HashRefreshToken(newToken), created.TokenHash, "New refresh token should be stored hashed")
+	assert.Equal(t, 7, created.UserID)
+}
+
+func TestRefreshTokenService_ReusedToken(t *testing.T) {
+	// Arrange
+	revokedAt := time.Now().Add(-time.Minute)
+	revokedUserID := 0
+	mockRepo := &mockTigerRepo{
+		lockRefreshToken: func(tokenHash string) (*models.RefreshToken, error) {
+			return &models.RefreshToken{ID: 1, UserID: 7, ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt}, nil
+		},
+		revokeUserRefreshTokens: func(userID int) error {
+			revokedUserID = userID
+			return nil
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Act
+	_, _, err := tigerService.RefreshTokenService("refresh-token")
+
+	// Assert
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	assert.Equal(t, 7, revokedUserID, "All refresh tokens of the user should be revoked")
+}
+
+func TestRefreshTokenService_ExpiredToken(t *testing.T) {
+	// Arrange
+	mockRepo := &mockTigerRepo{
+		lockRefreshToken: func(tokenHash string) (*models.RefreshToken, error) {
+			return &models.RefreshToken{ID: 1, UserID: 7, ExpiresAt: time.Now().Add(-time.Hour)}, nil
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Act
+	_, _, err := tigerService.RefreshTokenService("refresh-token")
+
+	// Assert
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+}
+
+func TestLogoutService_OtherUsersToken(t *testing.T) {
+	// Arrange
+	mockRepo := &mockTigerRepo{
+		getUserByEmail: func(email string) (*models.User, error) {
+			return &models.User{ID: 1, Email: email}, nil
+		},
+		lockRefreshToken: func(tokenHash string) (*models.RefreshToken, error) {
+			return &models.RefreshToken{ID: 1, UserID: 2, ExpiresAt: time.Now().Add(time.Hour)}, nil
+		},
+		revokeRefreshToken: func(tokenID int) error {
+			t.Errorf("Refresh token of another user should not be revoked")
+			return nil
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Act
+	err := tigerService.LogoutService("test@example.com", "refresh-token")
+
+	// Assert
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+}
+
+func TestGrantRoleService(t *testing.T) {
+	// Arrange
+	var granted string
+	mockRepo := &mockTigerRepo{
+		getUserByID: func(userID int) (*models.User, error) {
+			if userID == 1 {
+				return &models.User{ID: 1}, nil
+			}
+			return nil, repository.ErrNotFound
+		},
+		grantUserRole: func(userID int, role string) error {
+			granted = role
+			return nil
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Act & Assert
+	assert.NoError(t, tigerService.GrantRoleService(1, models.RoleRanger))
+	assert.Equal(t, models.RoleRanger, granted)
+	assert.ErrorIs(t, tigerService.GrantRoleService(1, "superuser"), ErrInvalidRole)
+	assert.ErrorIs(t, tigerService.GrantRoleService(2, models.RoleRanger), ErrUserNotFound)
+}
+
+func TestCreateTigerService_Success(t *testing.T) {
+	// Arrange
+	mockRepo := &mockTigerRepo{
+		createTiger: func(tiger *models.Tiger) error {
+			// Mock the CreateTiger method to set the ID of the new tiger
+			tiger.ID = 7
+			return nil
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Create a test tiger
+	tiger := &models.Tiger{
+		Name:        "Test Tiger",
+		DateOfBirth: time.Now(),
+		LastSeen:    time.Now(),
+		Lat:         12.34,
+		Long:        56.78,
+	}
+
+	// Act
+	err := tigerService.CreateTigerService(tiger)
+
+	// Assert
+	assert.NoError(t, err, "CreateTigerService should not return an error")
+	assert.Equal(t, 7, tiger.ID, "ID of the new tiger should be set")
+}
+
+func TestCreateTigerService_Failure(t *testing.T) {
+	// Arrange
+	mockRepo := &mockTigerRepo{
+		createTiger: func(tiger *models.Tiger) error {
+			// Mock the CreateTiger method to return an error
+			return errors.New("failed to create tiger")
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Create a test tiger
+	tiger := &models.Tiger{
+		Name:        "Test Tiger",
+		DateOfBirth: time.Now(),
+		LastSeen:    time.Now(),
+		Lat:         12.34,
+		Long:        56.78,
+	}
+
+	// Act
+	err := tigerService.CreateTigerService(tiger)
+
+	// Assert
+	assert.Error(t, err, "CreateTigerService should return an error")
+	assert.EqualError(t, err, "failed to create tiger", "Error message should match")
+}
+
+func TestGetTigerService_NotFound(t *testing.T) {
+	// Arrange
+	mockRepo := &mockTigerRepo{
+		getTigerByID: func(tigerID int) (*models.Tiger, error) {
+			return nil, repository.ErrNotFound
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Act
+	tiger, err := tigerService.GetTigerService(1)
+
+	// Assert
+	assert.Nil(t, tiger, "Tiger should be nil")
+	assert.ErrorIs(t, err, ErrTigerNotFound, "GetTigerService should return ErrTigerNotFound")
+}
+
+func TestUpdateTigerService_Success(t *testing.T) {
+	// Arrange
+	var updatedTiger models.Tiger
+	mockRepo := &mockTigerRepo{
+		getTigerByID: func(tigerID int) (*models.Tiger, error) {
+			return &models.Tiger{ID: tigerID, Name: "Old Name", DateOfBirth: time.Date(2018, 1, 15, 0, 0, 0, 0, time.UTC), Version: 2}, nil
+		},
+		updateTiger: func(tiger *models.Tiger) error {
+			updatedTiger = *tiger
+			tiger.Version++
+			return nil
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+	name := "New Name"
+
+	// Act
+	tiger, err := tigerService.UpdateTigerService(1, models.TigerUpdate{Name: &name, Version: 2})
+
+	// Assert
+	assert.NoError(t, err, "UpdateTigerService should not return an error")
+	assert.Equal(t, "New Name", updatedTiger.Name, "Name should be updated")
+	assert.Equal(t, 2, updatedTiger.Version, "Update should be based on the version sent by the client")
+	assert.Equal(t, time.Date(2018, 1, 15, 0, 0, 0, 0, time.UTC), tiger.DateOfBirth, "Date of birth should be unchanged")
+	assert.Equal(t, 3, tiger.Version, "Version should be bumped")
+}
+
+func TestUpdateTigerService_StaleVersion(t *testing.T) {
+	// Arrange
+	mockRepo := &mockTigerRepo{
+		getTigerByID: func(tigerID int) (*models.Tiger, error) {
+			return &models.Tiger{ID: tigerID, Name: "Old Name", Version: 3}, nil
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+	name := "New Name"
+
+	// Act
+	_, err := tigerService.UpdateTigerService(1, models.TigerUpdate{Name: &name, Version: 2})
+
+	// Assert
+	assert.ErrorIs(t, err, ErrTigerVersionConflict, "UpdateTigerService should reject a stale version")
+}
+
+func TestUpdateTigerService_ConcurrentUpdate(t *testing.T) {
+	// Arrange
+	mockRepo := &mockTigerRepo{
+		getTigerByID: func(tigerID int) (*models.Tiger, error) {
+			return &models.Tiger{ID: tigerID, Name: "Old Name", Version: 2}, nil
+		},
+		updateTiger: func(tiger *models.Tiger) error {
+			// Another ranger saved the tiger between the read and the write
+			return repository.ErrVersionConflict
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+	name := "New Name"
+
+	// Act
+	_, err := tigerService.UpdateTigerService(1, models.TigerUpdate{Name: &name, Version: 2})
+
+	// Assert
+	assert.ErrorIs(t, err, ErrTigerVersionConflict, "UpdateTigerService should report the conflict")
+}
+
+func TestDeleteTigerService(t *testing.T) {
+	// Arrange
+	mockRepo := &mockTigerRepo{
+		deleteTiger: func(tigerID int) error {
+			if tigerID == 1 {
+				return nil
+			}
+			return repository.ErrNotFound
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Act & Assert
+	assert.NoError(t, tigerService.DeleteTigerService(1), "DeleteTigerService should not return an error")
+	assert.ErrorIs(t, tigerService.DeleteTigerService(2), ErrTigerNotFound, "DeleteTigerService should return ErrTigerNotFound")
+}
+
+func TestSetTigerSubscriptionService(t *testing.T) {
+	// Arrange
+	var gotEmail string
+	mockRepo := &mockTigerRepo{
+		getTigerByID: func(tigerID int) (*models.Tiger, error) {
+			if tigerID == 1 {
+				return &models.Tiger{ID: 1}, nil
+			}
+			return nil, repository.ErrNotFound
+		},
+		setTigerSubscription: func(email string, tigerID int, subscribed bool) error {
+			gotEmail = email
+			return nil
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Act & Assert
+	assert.NoError(t, tigerService.SetTigerSubscriptionService(" Ranger@Example.com", 1, false))
+	assert.Equal(t, "ranger@example.com", gotEmail, "email should be normalized")
+	assert.ErrorIs(t, tigerService.SetTigerSubscriptionService("ranger@example.com", 2, false), ErrTigerNotFound)
+}
+
+func TestGetAllTigersService_Success(t *testing.T) {
+	// Arrange
+	tigers := []*models.Tiger{
+		{ID: 2, Name: "Tiger 2", LastSeen: time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: 1, Name: "Tiger 1", LastSeen: time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	mockRepo := &mockTigerRepo{
+		getAllTigersWithPagination: func(filter models.TigerFilter, page, pageSize int) ([]*models.Tiger, error) {
+			assert.Equal(t, 1, page)
+			assert.Equal(t, 2, pageSize)
+			return tigers, nil
+		},
+		getTigersAfter: func(filter models.TigerFilter, after *models.Cursor, limit int) ([]*models.Tiger, error) {
+			assert.Equal(t, &models.Cursor{Time: tigers[1].LastSeen, ID: 1}, after, "Next page should be looked for after the last tiger")
+			assert.Equal(t, 1, limit)
+			return []*models.Tiger{{ID: 3}}, nil
+		},
+		getTotalTigerCount: func(filter models.TigerFilter, estimate bool) (int, error) {
+			assert.False(t, estimate)
+			return 3, nil
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Act
+	result, info, err := tigerService.GetAllTigersService(models.TigerFilter{}, models.PageRequest{Page: 1, PageSize: 2, Count: models.CountExact})
+
+	// Assert
+	assert.NoError(t, err, "GetAllTigersService should not return an error")
+	assert.Equal(t, tigers, result, "Tigers should be kept in the order of the repository")
+	assert.Equal(t, &models.PageInfo{NextCursor: &models.Cursor{Time: tigers[1].LastSeen, ID: 1}, TotalCount: intPtr(3)}, info)
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestGetAllTigersService_CursorMode(t *testing.T) {
+	// Arrange
+	after := &models.Cursor{Time: time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC), ID: 4}
+	tigers := []*models.Tiger{
+		{ID: 3, LastSeen: time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: 2, LastSeen: time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: 1, LastSeen: time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	mockRepo := &mockTigerRepo{
+		getTigersAfter: func(filter models.TigerFilter, cursor *models.Cursor, limit int) ([]*models.Tiger, error) {
+			assert.Equal(t, after, cursor)
+			assert.Equal(t, 3, limit, "One more tiger than asked for should be fetched")
+			return tigers, nil
+		},
+		getTotalTigerCount: func(filter models.TigerFilter, estimate bool) (int, error) {
+			assert.True(t, estimate)
+			return 40, nil
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Act
+	result, info, err := tigerService.GetAllTigersService(models.TigerFilter{}, models.PageRequest{PageSize: 2, CursorMode: true, After: after, Count: models.CountEstimate})
+	last, lastInfo, lastErr := NewTigerService(&mockTigerRepo{
+		getTigersAfter: func(filter models.TigerFilter, cursor *models.Cursor, limit int) ([]*models.Tiger, error) {
+			return tigers[2:], nil
+		},
+	}, nil).GetAllTigersService(models.TigerFilter{}, models.PageRequest{PageSize: 2, CursorMode: true, After: after, Count: models.CountNone})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, tigers[:2], result)
+	assert.Equal(t, &models.PageInfo{NextCursor: &models.Cursor{Time: tigers[1].LastSeen, ID: 2}, TotalCount: intPtr(40)}, info)
+
+	assert.NoError(t, lastErr)
+	assert.Len(t, last, 1)
+	assert.Equal(t, &models.PageInfo{}, lastInfo, "Last page should have no next cursor and no total without counting")
+}
+
+func TestGetAllTigersService_Filter(t *testing.T) {
+	// Arrange
+	filter := models.TigerFilter{NameLike: "shere", Sort: models.TigerSortName, Ascending: true}
+	tigers := []*models.Tiger{{ID: 5, Name: "Raja"}, {ID: 2, Name: "Shere Khan"}}
+	mockRepo := &mockTigerRepo{
+		getTigersAfter: func(gotFilter models.TigerFilter, cursor *models.Cursor, limit int) ([]*models.Tiger, error) {
+			assert.Equal(t, filter, gotFilter, "Filter should be passed to the repository")
+			return tigers, nil
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Act
+	_, info, err := tigerService.GetAllTigersService(filter, models.PageRequest{PageSize: 1, CursorMode: true, Count: models.CountNone})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, &models.Cursor{Text: "Raja", ID: 5}, info.NextCursor, "Cursor should hold the key the tigers are sorted by")
+}
+
+func TestGetAllTigersService_Failure(t *testing.T) {
+	// Arrange
+	mockRepo := &mockTigerRepo{
+		getAllTigersWithPagination: func(filter models.TigerFilter, page, pageSize int) ([]*models.Tiger, error) {
+			// Mock the GetAllTigers method to return an error
+			return []*models.Tiger{}, errors.New("failed to fetch tigers")
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Act
+	tigers, _, err := tigerService.GetAllTigersService(models.TigerFilter{}, models.PageRequest{Page: 1, PageSize: 10, Count: models.CountExact})
+
+	// Assert
+	assert.Error(t, err, "GetAllTigersService should return an error")
+	assert.EqualError(t, err, "failed to fetch tigers", "Error message should match")
+	assert.Empty(t, tigers, "Tigers should be empty when there is an error")
+}
+
+func TestGetTigersNearbyService(t *testing.T) {
+	// Arrange
+	tigers := []*models.Tiger{
+		{ID: 1, Name: "Far", Lat: 12.34, Long: 56.90},
+		{ID: 2, Name: "Close", Lat: 12.34, Long: 56.79},
+		{ID: 3, Name: "Outside", Lat: 20.00, Long: 60.00},
+		// Inside the bounding box of a 15 km radius but outside of the circle
+		{ID: 4, Name: "Corner", Lat: 12.45, Long: 56.89},
+	}
+
+	mockRepo := &mockTigerRepo{
+		getTigersInBoundingBox: func(box models.BoundingBox) ([]*models.Tiger, error) {
+			// Filter the tigers in memory like the spatial index would
+			result := []*models.Tiger{}
+			for _, tiger := range tigers {
+				if box.Contains(models.Coordinates{Lat: tiger.Lat, Long: tiger.Long}) {
+					result = append(result, tiger)
+				}
+			}
+			return result, nil
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Act
+	nearbyTigers, err := tigerService.GetTigersNearbyService(models.Coordinates{Lat: 12.34, Long: 56.78}, 15)
+
+	// Assert
+	assert.NoError(t, err, "GetTigersNearbyService should not return an error")
+	assert.Len(t, nearbyTigers, 2, "Only tigers within the radius should be returned")
+	assert.Equal(t, "Close", nearbyTigers[0].Name, "Closest tiger should come first")
+	assert.Equal(t, "Far", nearbyTigers[1].Name, "Farther tiger should come second")
+	assert.InDelta(t, 1.09, nearbyTigers[0].DistanceKm, 0.01, "Distance should be in kilometers")
+}
+
+func TestCreateTigerSightingService_Success(t *testing.T) {
+	// Arrange
+	previousSighting := &models.TigerSighting{
+		ID:            1,
+		TigerID:       1,
+		Timestamp:     time.Date(2023, time.July, 20, 12, 0, 0, 0, time.UTC),
+		Lat:           12.34,
+		Long:          56.78,
+		ReporterEmail: "ranger@example.com",
+	}
+	newSighting := &models.TigerSighting{
+		TigerID:       1,
+		Timestamp:     time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC),
+		Lat:           13.35,
+		Long:          56.79,
+		ReporterEmail: "reporter@example.com",
+	}
+
+	var lastSeenSighting *models.TigerSighting
+	var outboxPayload []byte
+	mockRepo := &mockTigerRepo{
+		lockTiger: func(tigerID int) (*models.Tiger, error) {
+			return &models.Tiger{ID: tigerID}, nil
+		},
+		getAdjacentTigerSightings: func(tigerID int, reporterEmail string, timestamp time.Time) (*models.TigerSighting, *models.TigerSighting, error) {
+			return previousSighting, nil, nil
+		},
+		getTigerSightingsBetween: func(tigerID int, reporterEmail string, from, to time.Time) ([]*models.TigerSighting, error) {
+			return []*models.TigerSighting{}, nil
+		},
+		countReporterSightings: func(email string) (int, int, error) {
+			// Simulate a trusted reporter
+			return 5, 0, nil
+		},
+		createTigerSighting: func(newSighting *models.TigerSighting) error {
+			// Simulate successful tiger sighting creation in the database
+			return nil
+		},
+		updateTigerLastSeen: func(tigerSighting *models.TigerSighting) error {
+			lastSeenSighting = tigerSighting
+			return nil
+		},
+		getTigerSightingsByID: func(tigerID int) ([]*models.TigerSighting, error) {
+			// Return previous sighting as the only previous sighting for the tiger
+			return []*models.TigerSighting{previousSighting}, nil
+		},
+		getTigerFollowers: func(tigerID int) ([]string, error) {
+			return []string{"follower@example.com"}, nil
+		},
+		createOutboxMessage: func(payload []byte) error {
+			outboxPayload = payload
+			return nil
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Act
+	err := tigerService.CreateTigerSightingService(newSighting)
+
+	// Assert
+	assert.NoError(t, err, "CreateTigerSightingService should not return an error")
+	assert.Equal(t, models.SightingApproved, newSighting.Status, "Sighting of a trusted reporter should be approved")
+	assert.Equal(t, newSighting, lastSeenSighting, "Tiger last seen should be updated from the new sighting")
+
+	var notification utils.SightingNotification
+	assert.NoError(t, json.Unmarshal(outboxPayload, &notification), "Notification should be written to the outbox")
+	assert.Equal(t, []string{"ranger@example.com", "follower@example.com"}, notification.Recipients, "Reporters and followers should be notified")
+}
+
+func TestCreateTigerSightingService_PendingReview(t *testing.T) {
+	tests := []struct {
+		name     string
+		approved int
+		rejected int
+	}{
+		{name: "new reporter", approved: 0, rejected: 0},
+		{name: "few approved sightings", approved: TrustedReporterMinApproved - 1, rejected: 0},
+		{name: "rejected sighting", approved: 10, rejected: 1},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			newSighting := &models.TigerSighting{
+				TigerID:       1,
+				Timestamp:     time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC),
+				Lat:           12.35,
+				Long:          56.79,
+				ReporterEmail: "reporter@example.com",
+			}
+
+			var createdStatus string
+			mockRepo := &mockTigerRepo{
+				lockTiger: func(tigerID int) (*models.Tiger, error) {
+					return &models.Tiger{ID: tigerID}, nil
+				},
+				getAdjacentTigerSightings: func(tigerID int, reporterEmail string, timestamp time.Time) (*models.TigerSighting, *models.TigerSighting, error) {
+					return nil, nil, nil
+				},
+				getTigerSightingsBetween: func(tigerID int, reporterEmail string, from, to time.Time) ([]*models.TigerSighting, error) {
+					return []*models.TigerSighting{}, nil
+				},
+				countReporterSightings: func(email string) (int, int, error) {
+					return tt.approved, tt.rejected, nil
+				},
+				createTigerSighting: func(newSighting *models.TigerSighting) error {
+					createdStatus = newSighting.Status
+					return nil
+				},
+				// The tiger is not updated and nobody is notified until the sighting is approved
+				updateTigerLastSeen: func(tigerSighting *models.TigerSighting) error {
+					t.Error("tiger last seen should not be updated")
+					return nil
+				},
+				createOutboxMessage: func(payload []byte) error {
+					t.Error("notification should not be written to the outbox")
+					return nil
+				},
+			}
+
+			tigerService := NewTigerService(mockRepo, nil)
+
+			// Act
+			err := tigerService.CreateTigerSightingService(newSighting)
+
+			// Assert
+			assert.NoError(t, err, "CreateTigerSightingService should not return an error")
+			assert.Equal(t, models.SightingPending, createdStatus, "Sighting should wait for a moderator")
+		})
+	}
+}
+
+func TestCreateTigerSightingService_PhotoMismatch(t *testing.T) {
+	// Arrange
+	photoLat, photoLong := 12.84, 56.78
+	newSighting := &models.TigerSighting{
+		TigerID:       1,
+		Timestamp:     time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC),
+		Lat:           12.34,
+		Long:          56.78,
+		PhotoLat:      &photoLat,
+		PhotoLong:     &photoLong,
+		ReporterEmail: "reporter@example.com",
+	}
+
+	var created *models.TigerSighting
+	mockRepo := &mockTigerRepo{
+		lockTiger: func(tigerID int) (*models.Tiger, error) {
+			return &models.Tiger{ID: tigerID}, nil
+		},
+		getAdjacentTigerSightings: func(tigerID int, reporterEmail string, timestamp time.Time) (*models.TigerSighting, *models.TigerSighting, error) {
+			return nil, nil, nil
+		},
+		getTigerSightingsBetween: func(tigerID int, reporterEmail string, from, to time.Time) ([]*models.TigerSighting, error) {
+			return []*models.TigerSighting{}, nil
+		},
+		countReporterSightings: func(email string) (int, int, error) {
+			// Simulate a trusted reporter
+			return 10, 0, nil
+		},
+		createTigerSighting: func(newSighting *models.TigerSighting) error {
+			created = newSighting
+			return nil
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Act
+	err := tigerService.CreateTigerSightingService(newSighting)
+
+	// Assert
+	assert.NoError(t, err, "CreateTigerSightingService should not return an error")
+	if assert.NotNil(t, created) {
+		assert.Equal(t, "photo was taken 55.6 km away", created.PhotoMismatch, "The mismatch should be saved for moderators")
+		assert.Equal(t, models.SightingPending, created.Status, "Sightings contradicted by their photo should wait for a moderator")
+	}
+}
+
+func TestCreateTigerSightingService_ExistingSightingWithin5Km(t *testing.T) {
+	// Arrange
+	previousSighting := &models.TigerSighting{
+		ID:            1,
 		TigerID:       1,
-		Timestamp:     time.Date(2023, time.July, 20, 12, 0, 0, 0, time.UTC),
+		Timestamp:     time.Date(2023, time.July, 21, 11, 30, 0, 0, time.UTC),
 		Lat:           12.34,
 		Long:          56.78,
 		ReporterEmail: "reporter@example.com",
@@ -346,9 +1435,14 @@ func TestCreateTigerSightingService_ExistingSightingWithin5Km(t *testing.T) {
 		ReporterEmail: "reporter@example.com",
 	}
 
+	var from, to time.Time
 	mockRepo := &mockTigerRepo{
-		getPreviousTigerSighting: func(tigerID int) (*models.TigerSighting, error) {
-			return previousSighting, nil
+		lockTiger: func(tigerID int) (*models.Tiger, error) {
+			return &models.Tiger{ID: tigerID}, nil
+		},
+		getTigerSightingsBetween: func(tigerID int, reporterEmail string, windowFrom, windowTo time.Time) ([]*models.TigerSighting, error) {
+			from, to = windowFrom, windowTo
+			return []*models.TigerSighting{previousSighting}, nil
 		},
 	}
 
@@ -358,8 +1452,12 @@ func TestCreateTigerSightingService_ExistingSightingWithin5Km(t *testing.T) {
 	err := tigerService.CreateTigerSightingService(newSighting)
 
 	// Assert
-	assert.Error(t, err, "CreateTigerSightingService should return an error")
-	assert.EqualError(t, err, "A tiger sighting within 5 kilometers already exists", "Error message should match")
+	var validationErr *ValidationError
+	if assert.ErrorAs(t, err, &validationErr, "CreateTigerSightingService should return a validation error") {
+		assert.Equal(t, CodeDuplicateSighting, validationErr.Code)
+	}
+	assert.Equal(t, newSighting.Timestamp.Add(-DefaultSightingRulesConfig.DuplicateWindow), from, "Only recent sightings should be duplicates")
+	assert.Equal(t, newSighting.Timestamp.Add(DefaultSightingRulesConfig.DuplicateWindow), to)
 }
 
 func TestCreateTigerSightingService_RequiredFieldsMissing(t *testing.T) {
@@ -373,8 +1471,11 @@ func TestCreateTigerSightingService_RequiredFieldsMissing(t *testing.T) {
 	}
 
 	mockRepo := &mockTigerRepo{
-		getPreviousTigerSighting: func(tigerID int) (*models.TigerSighting, error) {
-			return nil, nil
+		getAdjacentTigerSightings: func(tigerID int, reporterEmail string, timestamp time.Time) (*models.TigerSighting, *models.TigerSighting, error) {
+			return nil, nil, nil
+		},
+		getTigerSightingsBetween: func(tigerID int, reporterEmail string, from, to time.Time) ([]*models.TigerSighting, error) {
+			return []*models.TigerSighting{}, nil
 		},
 	}
 
@@ -399,8 +1500,17 @@ func TestCreateTigerSightingService_Failure(t *testing.T) {
 	}
 
 	mockRepo := &mockTigerRepo{
-		getPreviousTigerSighting: func(tigerID int) (*models.TigerSighting, error) {
-			return nil, nil
+		lockTiger: func(tigerID int) (*models.Tiger, error) {
+			return &models.Tiger{ID: tigerID}, nil
+		},
+		getAdjacentTigerSightings: func(tigerID int, reporterEmail string, timestamp time.Time) (*models.TigerSighting, *models.TigerSighting, error) {
+			return nil, nil, nil
+		},
+		getTigerSightingsBetween: func(tigerID int, reporterEmail string, from, to time.Time) ([]*models.TigerSighting, error) {
+			return []*models.TigerSighting{}, nil
+		},
+		countReporterSightings: func(email string) (int, int, error) {
+			return 0, 0, nil
 		},
 		createTigerSighting: func(newSighting *models.TigerSighting) error {
 			// Simulate failure in creating tiger sighting in the database
@@ -410,6 +1520,9 @@ func TestCreateTigerSightingService_Failure(t *testing.T) {
 			// Simulate failure in retrieving previous sightings from the database
 			return nil, errors.New("failed to retrieve previous sightings")
 		},
+		getTigerFollowers: func(tigerID int) ([]string, error) {
+			return nil, nil
+		},
 	}
 
 	tigerService := NewTigerService(mockRepo, nil)
@@ -422,6 +1535,466 @@ func TestCreateTigerSightingService_Failure(t *testing.T) {
 	assert.EqualError(t, err, "failed to create tiger sighting", "Error message should match")
 }
 
+func TestCreateTigerSightingService_TigerNotFound(t *testing.T) {
+	// Arrange
+	newSighting := &models.TigerSighting{
+		TigerID:       1,
+		Timestamp:     time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC),
+		Lat:           12.35,
+		Long:          56.79,
+		ReporterEmail: "reporter@example.com",
+	}
+
+	mockRepo := &mockTigerRepo{
+		lockTiger: func(tigerID int) (*models.Tiger, error) {
+			// Simulate a deleted tiger
+			return nil, repository.ErrNotFound
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Act
+	err := tigerService.CreateTigerSightingService(newSighting)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrTigerNotFound, "CreateTigerSightingService should return ErrTigerNotFound")
+}
+
+func TestCreateTigerSightingService_LastSeenUpdateFailure(t *testing.T) {
+	// Arrange
+	newSighting := &models.TigerSighting{
+		TigerID:       1,
+		Timestamp:     time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC),
+		Lat:           12.35,
+		Long:          56.79,
+		ReporterEmail: "reporter@example.com",
+	}
+
+	mockRepo := &mockTigerRepo{
+		lockTiger: func(tigerID int) (*models.Tiger, error) {
+			return &models.Tiger{ID: tigerID}, nil
+		},
+		getAdjacentTigerSightings: func(tigerID int, reporterEmail string, timestamp time.Time) (*models.TigerSighting, *models.TigerSighting, error) {
+			return nil, nil, nil
+		},
+		getTigerSightingsBetween: func(tigerID int, reporterEmail string, from, to time.Time) ([]*models.TigerSighting, error) {
+			return []*models.TigerSighting{}, nil
+		},
+		countReporterSightings: func(email string) (int, int, error) {
+			return 5, 0, nil
+		},
+		createTigerSighting: func(newSighting *models.TigerSighting) error {
+			return nil
+		},
+		updateTigerLastSeen: func(tigerSighting *models.TigerSighting) error {
+			return errors.New("connection reset")
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Act
+	err := tigerService.CreateTigerSightingService(newSighting)
+
+	// Assert
+	assert.EqualError(t, err, "failed to update tiger last seen", "Error message should match")
+}
+
+// testPhoto returns a JPEG photo of width x height.
+func testPhoto(t *testing.T, width, height int) []byte {
+	var photo bytes.Buffer
+	if err := jpeg.Encode(&photo, image.NewRGBA(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return photo.Bytes()
+}
+
+func TestCreateTigerSightingService_SavesImages(t *testing.T) {
+	// Arrange
+	first, second := testPhoto(t, 40, 30), testPhoto(t, 30, 40)
+	newSighting := &models.TigerSighting{
+		TigerID:       1,
+		Timestamp:     time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC),
+		Lat:           12.35,
+		Long:          56.79,
+		Photos:        [][]byte{first, second},
+		ReporterEmail: "reporter@example.com",
+	}
+
+	var savedKey string
+	var savedImages []*models.SightingImage
+	mockRepo := &mockTigerRepo{
+		lockTiger: func(tigerID int) (*models.Tiger, error) {
+			return &models.Tiger{ID: tigerID}, nil
+		},
+		getAdjacentTigerSightings: func(tigerID int, reporterEmail string, timestamp time.Time) (*models.TigerSighting, *models.TigerSighting, error) {
+			return nil, nil, nil
+		},
+		getTigerSightingsBetween: func(tigerID int, reporterEmail string, from, to time.Time) ([]*models.TigerSighting, error) {
+			return []*models.TigerSighting{}, nil
+		},
+		countReporterSightings: func(email string) (int, int, error) {
+			return 5, 0, nil
+		},
+		createTigerSighting: func(newSighting *models.TigerSighting) error {
+			newSighting.ID = 7
+			savedKey = newSighting.ImageKey
+			return nil
+		},
+		createSightingImages: func(images []*models.SightingImage) error {
+			savedImages = images
+			return nil
+		},
+		updateTigerLastSeen: func(tigerSighting *models.TigerSighting) error {
+			return nil
+		},
+		getTigerSightingsByID: func(tigerID int) ([]*models.TigerSighting, error) {
+			return []*models.TigerSighting{}, nil
+		},
+		getTigerFollowers: func(tigerID int) ([]string, error) {
+			return nil, nil
+		},
+		createOutboxMessage: func(payload []byte) error {
+			return nil
+		},
+	}
+	imageStore := &mockImageStore{images: map[string][]byte{}}
+
+	tigerService := NewTigerServiceWithOptions(mockRepo, imageStore, Options{
+		Renditions: []utils.Rendition{
+			{Name: "small", Width: 20, Height: 20, Formats: []string{utils.FormatPNG, utils.FormatJPEG}},
+		},
+	})
+
+	// Act
+	err := tigerService.CreateTigerSightingService(newSighting)
+
+	// Assert
+	assert.NoError(t, err, "CreateTigerSightingService should not return an error")
+	assert.Nil(t, newSighting.Photos, "Photo data should not be kept on the sighting")
+	assert.Len(t, imageStore.images, 6, "Every photo should be saved as uploaded and in every rendition")
+	if !assert.Len(t, savedImages, 6) {
+		return
+	}
+
+	expected := []struct {
+		position          int
+		rendition, format string
+		width, height     int
+	}{
+		{0, "original", "jpeg", 40, 30},
+		{0, "small", "png", 20, 15},
+		{0, "small", "jpeg", 20, 15},
+		{1, "original", "jpeg", 30, 40},
+		{1, "small", "png", 15, 20},
+		{1, "small", "jpeg", 15, 20},
+	}
+	for i, e := range expected {
+		image := savedImages[i]
+		assert.Equal(t, 7, image.SightingID, "Images should reference the new sighting")
+		assert.Equal(t, e.position, image.Position)
+		assert.Equal(t, e.rendition, image.Rendition)
+		assert.Equal(t, e.format, image.Format)
+		assert.Equal(t, e.width, image.Width)
+		assert.Equal(t, e.height, image.Height)
+		assert.Contains(t, imageStore.images, image.ImageKey, "Image should be saved in the image store")
+	}
+	assert.NotEmpty(t, imageStore.images[savedImages[0].ImageKey], "The original should be saved")
+	assert.Equal(t, savedImages[2].ImageKey, savedKey, "The image of the sighting should be the first resized JPEG")
+}
+
+func TestCreateTigerSightingService_InvalidImage(t *testing.T) {
+	// Arrange
+	newSighting := &models.TigerSighting{
+		TigerID:       1,
+		Timestamp:     time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC),
+		Lat:           12.35,
+		Long:          56.79,
+		Photos:        [][]byte{testPhoto(t, 40, 30), []byte("not an image")},
+		ReporterEmail: "reporter@example.com",
+	}
+	imageStore := &mockImageStore{images: map[string][]byte{}}
+
+	tigerService := NewTigerService(&mockTigerRepo{}, imageStore)
+
+	// Act
+	err := tigerService.CreateTigerSightingService(newSighting)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrInvalidImage)
+	assert.Empty(t, imageStore.images, "Nothing should be saved when a photo is invalid")
+}
+
+func TestCreateTigerSightingService_DeletesImageOnFailure(t *testing.T) {
+	// Arrange
+	newSighting := &models.TigerSighting{
+		TigerID:       1,
+		Timestamp:     time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC),
+		Lat:           12.35,
+		Long:          56.79,
+		Photos:        [][]byte{testPhoto(t, 40, 30)},
+		ReporterEmail: "reporter@example.com",
+	}
+
+	mockRepo := &mockTigerRepo{
+		lockTiger: func(tigerID int) (*models.Tiger, error) {
+			return &models.Tiger{ID: tigerID}, nil
+		},
+		getAdjacentTigerSightings: func(tigerID int, reporterEmail string, timestamp time.Time) (*models.TigerSighting, *models.TigerSighting, error) {
+			return nil, nil, nil
+		},
+		getTigerSightingsBetween: func(tigerID int, reporterEmail string, from, to time.Time) ([]*models.TigerSighting, error) {
+			return []*models.TigerSighting{}, nil
+		},
+		countReporterSightings: func(email string) (int, int, error) {
+			return 0, 0, nil
+		},
+		createTigerSighting: func(newSighting *models.TigerSighting) error {
+			return errors.New("connection reset")
+		},
+	}
+	imageStore := &mockImageStore{images: map[string][]byte{}}
+
+	tigerService := NewTigerService(mockRepo, imageStore)
+
+	// Act
+	err := tigerService.CreateTigerSightingService(newSighting)
+
+	// Assert
+	assert.EqualError(t, err, "failed to create tiger sighting", "Error message should match")
+	assert.Empty(t, imageStore.images, "Images of the failed sighting should be deleted")
+}
+
+func TestGetTigerSightingPhotoService(t *testing.T) {
+	// Arrange
+	mockRepo := &mockTigerRepo{
+		getTigerSightingByID: func(sightingID int) (*models.TigerSighting, error) {
+			switch sightingID {
+			case 1:
+				return &models.TigerSighting{ID: 1, Status: models.SightingApproved}, nil
+			case 2:
+				return &models.TigerSighting{ID: 2, Status: models.SightingPending}, nil
+			default:
+				return nil, repository.ErrNotFound
+			}
+		},
+		getSightingImage: func(sightingID, position int, rendition, format string) (*models.SightingImage, error) {
+			if position != 0 || rendition != "medium" || format != "png" {
+				return nil, repository.ErrNotFound
+			}
+			return &models.SightingImage{SightingID: sightingID, Rendition: rendition, Format: format, ImageKey: "sightings/1/medium.png", ContentType: "image/png"}, nil
+		},
+	}
+	imageStore := &mockImageStore{images: map[string][]byte{"sightings/1/medium.png": []byte("image data")}}
+
+	tigerService := NewTigerService(mockRepo, imageStore)
+
+	// Act
+	image, sightingImage, err := tigerService.GetTigerSightingPhotoService(1, 0, "medium", "png")
+
+	// Assert
+	assert.NoError(t, err, "GetTigerSightingPhotoService should not return an error")
+	assert.Equal(t, "image/png", sightingImage.ContentType)
+	data, _ := ioutil.ReadAll(image)
+	assert.Equal(t, []byte("image data"), data, "Image data should match")
+
+	_, _, err = tigerService.GetTigerSightingPhotoService(1, 1, "medium", "png")
+	assert.ErrorIs(t, err, ErrImageNotFound, "Unknown photo should have no image")
+
+	_, _, err = tigerService.GetTigerSightingPhotoService(2, 0, "medium", "png")
+	assert.ErrorIs(t, err, ErrSightingNotFound, "Pending sighting should not be public")
+
+	_, _, err = tigerService.GetTigerSightingPhotoService(3, 0, "medium", "png")
+	assert.ErrorIs(t, err, ErrSightingNotFound, "Unknown sighting should not be found")
+
+	_, _, err = tigerService.GetModerationSightingPhotoService(2, 0, "medium", "png")
+	assert.NoError(t, err, "Moderators should see the photos of a pending sighting")
+}
+
+func TestGetTigerSightingImageService(t *testing.T) {
+	// Arrange
+	mockRepo := &mockTigerRepo{
+		getTigerSightingByID: func(sightingID int) (*models.TigerSighting, error) {
+			switch sightingID {
+			case 1:
+				return &models.TigerSighting{ID: 1, ImageKey: "sightings/1/image.jpeg", Status: models.SightingApproved}, nil
+			case 2:
+				// Sighting reported before images moved to the image store
+				return &models.TigerSighting{ID: 2, Status: models.SightingApproved}, nil
+			case 4:
+				return &models.TigerSighting{ID: 4, ImageKey: "sightings/1/image.jpeg", Status: models.SightingPending}, nil
+			default:
+				return nil, repository.ErrNotFound
+			}
+		},
+	}
+	imageStore := &mockImageStore{images: map[string][]byte{"sightings/1/image.jpeg": []byte("image data")}}
+
+	tigerService := NewTigerService(mockRepo, imageStore)
+
+	// Act
+	image, err := tigerService.GetTigerSightingImageService(1)
+
+	// Assert
+	assert.NoError(t, err, "GetTigerSightingImageService should not return an error")
+	data, _ := ioutil.ReadAll(image)
+	assert.Equal(t, []byte("image data"), data, "Image data should match")
+
+	_, err = tigerService.GetTigerSightingImageService(2)
+	assert.ErrorIs(t, err, ErrImageNotFound, "Sighting without image key should have no image")
+
+	_, err = tigerService.GetTigerSightingImageService(3)
+	assert.ErrorIs(t, err, ErrSightingNotFound, "Unknown sighting should not be found")
+
+	_, err = tigerService.GetTigerSightingImageService(4)
+	assert.ErrorIs(t, err, ErrSightingNotFound, "Pending sighting should not be public")
+
+	image, err = tigerService.GetModerationSightingImageService(4)
+	assert.NoError(t, err, "Moderators should see the image of a pending sighting")
+	data, _ = ioutil.ReadAll(image)
+	assert.Equal(t, []byte("image data"), data, "Image data should match")
+}
+
+func TestReviewTigerSightingService(t *testing.T) {
+	tests := []struct {
+		name           string
+		previousStatus string
+		status         string
+		tigerDeleted   bool
+		expected       error
+		published      bool
+		refreshed      bool
+	}{
+		{name: "approve pending", previousStatus: models.SightingPending, status: models.SightingApproved, published: true},
+		{name: "reject pending", previousStatus: models.SightingPending, status: models.SightingRejected},
+		{name: "reject flagged", previousStatus: models.SightingApproved, status: models.SightingRejected, refreshed: true},
+		{name: "keep flagged", previousStatus: models.SightingApproved, status: models.SightingApproved},
+		{name: "invalid status", previousStatus: models.SightingPending, status: models.SightingPending, expected: ErrInvalidReviewStatus},
+		{name: "deleted tiger", previousStatus: models.SightingPending, status: models.SightingApproved, tigerDeleted: true, expected: ErrTigerNotFound},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			sighting := &models.TigerSighting{
+				ID:            3,
+				TigerID:       1,
+				Timestamp:     time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC),
+				Lat:           12.35,
+				Long:          56.79,
+				ReporterEmail: "reporter@example.com",
+				Status:        tt.previousStatus,
+			}
+
+			var updatedStatus string
+			var review *models.SightingReview
+			var resolved, published, refreshed bool
+			mockRepo := &mockTigerRepo{
+				getUserByEmail: func(email string) (*models.User, error) {
+					return &models.User{ID: 7, Email: email}, nil
+				},
+				getTigerSightingByID: func(sightingID int) (*models.TigerSighting, error) {
+					return sighting, nil
+				},
+				lockTiger: func(tigerID int) (*models.Tiger, error) {
+					if tt.tigerDeleted {
+						return nil, repository.ErrNotFound
+					}
+					return &models.Tiger{ID: tigerID, Name: "Shere Khan"}, nil
+				},
+				lockTigerSighting: func(sightingID int) (*models.TigerSighting, error) {
+					return sighting, nil
+				},
+				updateTigerSightingStatus: func(sightingID int, status string) error {
+					updatedStatus = status
+					return nil
+				},
+				createSightingReview: func(r *models.SightingReview) error {
+					review = r
+					return nil
+				},
+				resolveSightingFlags: func(sightingID int) error {
+					resolved = true
+					return nil
+				},
+				updateTigerLastSeen: func(tigerSighting *models.TigerSighting) error {
+					return nil
+				},
+				getTigerSightingsByID: func(tigerID int) ([]*models.TigerSighting, error) {
+					return []*models.TigerSighting{{ReporterEmail: "ranger@example.com"}}, nil
+				},
+				getTigerFollowers: func(tigerID int) ([]string, error) {
+					return nil, nil
+				},
+				createOutboxMessage: func(payload []byte) error {
+					published = true
+					return nil
+				},
+				refreshTigerLastSeen: func(tigerID int) error {
+					refreshed = true
+					return nil
+				},
+			}
+
+			tigerService := NewTigerService(mockRepo, nil)
+
+			// Act
+			result, err := tigerService.ReviewTigerSightingService(3, "moderator@example.com", tt.status, "looks right")
+
+			// Assert
+			assert.Equal(t, tt.expected, err)
+			if tt.expected != nil {
+				return
+			}
+			assert.Equal(t, review, result, "The saved review should be returned")
+			assert.Equal(t, 7, review.ReviewerID, "The review should record the moderator")
+			assert.Equal(t, tt.status, review.Status)
+			assert.Equal(t, "looks right", review.Note)
+			assert.True(t, resolved, "The flags of the sighting should be resolved")
+			assert.Equal(t, tt.published, published, "Only newly approved sightings should be notified")
+			assert.Equal(t, tt.refreshed, refreshed, "Tiger should move back only when an approved sighting is rejected")
+			if tt.status != tt.previousStatus {
+				assert.Equal(t, tt.status, updatedStatus)
+			}
+		})
+	}
+}
+
+func TestFlagTigerSightingService(t *testing.T) {
+	// Arrange
+	var flag *models.SightingFlag
+	mockRepo := &mockTigerRepo{
+		getTigerSightingByID: func(sightingID int) (*models.TigerSighting, error) {
+			switch sightingID {
+			case 1:
+				return &models.TigerSighting{ID: 1, Status: models.SightingApproved}, nil
+			case 2:
+				return &models.TigerSighting{ID: 2, Status: models.SightingPending}, nil
+			default:
+				return nil, repository.ErrNotFound
+			}
+		},
+		createSightingFlag: func(f *models.SightingFlag) error {
+			flag = f
+			return nil
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Act
+	err := tigerService.FlagTigerSightingService(1, " User@Example.com", "wrong tiger")
+
+	// Assert
+	assert.NoError(t, err, "FlagTigerSightingService should not return an error")
+	assert.Equal(t, &models.SightingFlag{SightingID: 1, ReporterEmail: "user@example.com", Reason: "wrong tiger"}, flag)
+
+	assert.ErrorIs(t, tigerService.FlagTigerSightingService(2, "user@example.com", ""), ErrSightingNotFound, "Pending sighting should not be flagged")
+	assert.ErrorIs(t, tigerService.FlagTigerSightingService(3, "user@example.com", ""), ErrSightingNotFound, "Unknown sighting should not be found")
+}
+
 func TestGetAllTigerSightingsService_Success(t *testing.T) {
 	// Arrange
 	tigerID := 1
@@ -447,20 +2020,28 @@ func TestGetAllTigerSightingsService_Success(t *testing.T) {
 	sort.Slice(tigerSightings, func(i, j int) bool { return tigerSightings[i].Timestamp.Before(tigerSightings[j].Timestamp) })
 
 	mockRepo := &mockTigerRepo{
-		getTigerSightingsByIDWithPagination: func(tigerID, page, pageSize int) ([]*models.TigerSighting, int, error) {
-			return tigerSightings, len(tigerSightings), nil
+		getTigerSightingsByIDWithPagination: func(tigerID, page, pageSize int) ([]*models.TigerSighting, error) {
+			return tigerSightings, nil
+		},
+		getTigerSightingsCountByID: func(tigerID int, estimate bool) (int, error) {
+			return len(tigerSightings), nil
+		},
+		getSightingImages: func(sightingIDs []int) ([]*models.SightingImage, error) {
+			assert.ElementsMatch(t, []int{1, 2, 3}, sightingIDs, "Images of every sighting of the page should be retrieved")
+			return []*models.SightingImage{{SightingID: 2, Rendition: "thumbnail", Format: "jpeg"}}, nil
 		},
 	}
 
 	tigerService := NewTigerService(mockRepo, nil)
 
 	// Act
-	result, totalCount, err := tigerService.GetTigerSightingsByIDService(tigerID, 1, 10)
+	result, info, err := tigerService.GetTigerSightingsByIDService(tigerID, models.PageRequest{Page: 1, PageSize: 10, Count: models.CountExact})
 
 	// Assert
 	assert.NoError(t, err, "GetTigerSightingsByIDService should not return an error")
 	assert.NotNil(t, result, "Result should not be nil")
-	assert.Equal(t, len(tigerSightings), totalCount, "Number of tiger sightings should match")
+	assert.Equal(t, len(tigerSightings), *info.TotalCount, "Number of tiger sightings should match")
+	assert.Nil(t, info.NextCursor, "A partial page should be the last")
 
 	// Check if the tiger sightings are sorted by the timestamp (ascending order)
 	for i := range tigerSightings {
@@ -468,24 +2049,58 @@ func TestGetAllTigerSightingsService_Success(t *testing.T) {
 		assert.Equal(t, tigerSightings[i].TigerID, result[i].TigerID, "Tiger IDs should match")
 		assert.Equal(t, tigerSightings[i].Timestamp, result[i].Timestamp, "Tiger sighting timestamps should match")
 	}
+	for _, sighting := range result {
+		if sighting.ID == 2 {
+			assert.Len(t, sighting.Images, 1, "Images should be attached to their sighting")
+		} else {
+			assert.Empty(t, sighting.Images)
+		}
+	}
 }
 
 func TestGetAllTigerSightingsService_Failure(t *testing.T) {
 	// Arrange
 	tigerID := 1
 	mockRepo := &mockTigerRepo{
-		getTigerSightingsByIDWithPagination: func(tigerID, page, pageSize int) ([]*models.TigerSighting, int, error) {
+		getTigerSightingsByIDWithPagination: func(tigerID, page, pageSize int) ([]*models.TigerSighting, error) {
 			// Simulate failure in retrieving tiger sightings from the database
-			return []*models.TigerSighting{}, 0, errors.New("failed to fetch tiger sightings")
+			return []*models.TigerSighting{}, errors.New("failed to fetch tiger sightings")
 		},
 	}
 
 	tigerService := NewTigerService(mockRepo, nil)
 
 	// Act
-	result, _, err := tigerService.GetTigerSightingsByIDService(tigerID, 1, 10)
+	result, _, err := tigerService.GetTigerSightingsByIDService(tigerID, models.PageRequest{Page: 1, PageSize: 10})
 
 	// Assert
 	assert.Error(t, err, "GetTigerSightingsByIDService should return an error")
 	assert.Equal(t, result, []*models.TigerSighting{}, "Result should be nil on failure")
 }
+
+func TestGetLatestTigerSightingsService(t *testing.T) {
+	// Arrange
+	mockRepo := &mockTigerRepo{
+		getLatestTigerSightings: func(tigerIDs []int, limit int) ([]*models.TigerSighting, error) {
+			assert.Equal(t, []int{1, 2}, tigerIDs)
+			assert.Equal(t, 3, limit)
+			return []*models.TigerSighting{{ID: 5, TigerID: 1}, {ID: 6, TigerID: 2}}, nil
+		},
+		getSightingImages: func(sightingIDs []int) ([]*models.SightingImage, error) {
+			assert.Equal(t, []int{5, 6}, sightingIDs, "Images of the sightings of every tiger should be retrieved at once")
+			return []*models.SightingImage{{SightingID: 6, Rendition: "thumbnail", Format: "jpeg"}}, nil
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Act
+	sightings, err := tigerService.GetLatestTigerSightingsService([]int{1, 2}, 3)
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.Len(t, sightings, 2) {
+		assert.Empty(t, sightings[0].Images)
+		assert.Len(t, sightings[1].Images, 1)
+	}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"tigerhall-kittens-app/pkg/models"
+	"tigerhall-kittens-app/pkg/repository"
+	"tigerhall-kittens-app/pkg/utils"
+)
+
+// DefaultStatsCacheTTL is how long stats are cached by NewTigerService.
+const DefaultStatsCacheTTL = 5 * time.Minute
+
+// statsCache keeps computed stats until they are TTL old or a change of the approved
+// sightings invalidates them. Only changes made through the same service are seen, other
+// instances of the API keep serving their stats for up to TTL.
+type statsCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu sync.Mutex
+	// generation is incremented by every invalidation. Stats computed while it changed
+	// are not cached, they may have been read before the change.
+	generation uint64
+	tigers     map[int]statsEntry[*models.TigerStats]
+	population map[populationStatsKey]statsEntry[*models.PopulationStats]
+}
+
+type statsEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// populationStatsKey identifies the population stats of the days days up to the day to.
+type populationStatsKey struct {
+	to    time.Time
+	days  int
+	limit int
+}
+
+func newStatsCache(ttl time.Duration) *statsCache {
+	return &statsCache{
+		ttl:        ttl,
+		now:        time.Now,
+		tigers:     map[int]statsEntry[*models.TigerStats]{},
+		population: map[populationStatsKey]statsEntry[*models.PopulationStats]{},
+	}
+}
+
+// loadStats returns the cached stats of the key, or computes and caches them. Stats are
+// not cached at all when the TTL is zero.
+func loadStats[K comparable, V any](c *statsCache, entries map[K]statsEntry[V], key K, compute func() (V, error)) (V, error) {
+	if c.ttl <= 0 {
+		return compute()
+	}
+
+	c.mu.Lock()
+	now := c.now()
+	if entry, ok := entries[key]; ok && now.Before(entry.expiresAt) {
+		c.mu.Unlock()
+		return entry.value, nil
+	}
+	generation := c.generation
+	c.mu.Unlock()
+
+	value, err := compute()
+	if err != nil {
+		return value, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation == c.generation {
+		// Expired stats are dropped here, nothing else would ever ask for stats of past days
+		for k, entry := range entries {
+			if !now.Before(entry.expiresAt) {
+				delete(entries, k)
+			}
+		}
+		entries[key] = statsEntry[V]{value: value, expiresAt: now.Add(c.ttl)}
+	}
+	return value, nil
+}
+
+// invalidateTiger drops the stats of the tiger and the population stats, which count its
+// sightings too. It must be called once the change is committed.
+func (c *statsCache) invalidateTiger(tigerID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	delete(c.tigers, tigerID)
+	for key := range c.population {
+		delete(c.population, key)
+	}
+}
+
+// GetTigerStatsService returns the movement stats of the tiger from its approved sightings.
+func (s service) GetTigerStatsService(tigerID int) (*models.TigerStats, error) {
+	return loadStats(s.stats, s.stats.tigers, tigerID, func() (*models.TigerStats, error) {
+		if _, err := s.GetTigerService(tigerID); err != nil {
+			return nil, err
+		}
+
+		stats := &models.TigerStats{TigerID: tigerID}
+		var previous *models.TigerSighting
+		var points []models.Coordinates
+		reporters := map[string]bool{}
+		err := s.TigerRepo.StreamTigerSightings(tigerID, func(sighting *models.TigerSighting) error {
+			point := models.Coordinates{Lat: sighting.Lat, Long: sighting.Long}
+			if previous == nil {
+				firstSeen := sighting.Timestamp
+				stats.FirstSeen = &firstSeen
+			} else {
+				stats.DistanceKm += utils.CalculateDistance(models.Coordinates{Lat: previous.Lat, Long: previous.Long}, point)
+			}
+			points = append(points, point)
+			reporters[utils.NormalizeEmail(sighting.ReporterEmail)] = true
+			previous = sighting
+			return nil
+		})
+		if err != nil {
+			return nil, errors.New("failed to fetch tiger sightings")
+		}
+		if previous == nil {
+			return stats, nil
+		}
+
+		stats.Sightings = len(points)
+		lastSeen := previous.Timestamp
+		stats.LastSeen = &lastSeen
+		if hours := stats.LastSeen.Sub(*stats.FirstSeen).Hours(); hours > 0 {
+			stats.AverageSpeedKmh = stats.DistanceKm / hours
+		}
+		stats.HomeRangeKm2 = utils.HomeRangeKm2(points)
+		stats.Reporters = len(reporters)
+		return stats, nil
+	})
+}
+
+// GetPopulationStatsService returns the approved sightings per day of the last days days,
+// today included, and the limit tigers with the most sightings over these days.
+func (s service) GetPopulationStatsService(days, limit int) (*models.PopulationStats, error) {
+	// Days are cut at midnight UTC, so the stats are cached for the current day
+	to := s.stats.now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	from := to.AddDate(0, 0, -days)
+
+	key := populationStatsKey{to: to, days: days, limit: limit}
+	return loadStats(s.stats, s.stats.population, key, func() (*models.PopulationStats, error) {
+		stats := &models.PopulationStats{From: from, To: to}
+		err := s.TigerRepo.WithSnapshot(func(tx repository.TigerRepository) error {
+			var err error
+			if stats.SightingsPerDay, err = tx.GetDailySightingCounts(from, to); err != nil {
+				return err
+			}
+			stats.MostActiveTigers, err = tx.GetMostActiveTigers(from, to, limit)
+			return err
+		})
+		if err != nil {
+			return nil, errors.New("failed to fetch sighting stats")
+		}
+		return stats, nil
+	})
+}
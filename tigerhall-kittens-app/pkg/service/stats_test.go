@@ -0,0 +1,179 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"tigerhall-kittens-app/pkg/models"
+	"tigerhall-kittens-app/pkg/repository"
+	"tigerhall-kittens-app/pkg/utils"
+)
+
+func TestGetTigerStatsService(t *testing.T) {
+	// Arrange
+	start := time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC)
+	sightings := []*models.TigerSighting{
+		{ID: 1, TigerID: 1, Timestamp: start, Lat: 0, Long: 0, ReporterEmail: "ranger@example.com"},
+		{ID: 2, TigerID: 1, Timestamp: start.Add(2 * time.Hour), Lat: 0, Long: 0.1, ReporterEmail: "Ranger@Example.com"},
+		{ID: 3, TigerID: 1, Timestamp: start.Add(4 * time.Hour), Lat: 0.1, Long: 0.1, ReporterEmail: "researcher@example.com"},
+	}
+	streams := 0
+	mockRepo := &mockTigerRepo{
+		getTigerByID: func(tigerID int) (*models.Tiger, error) {
+			return &models.Tiger{ID: tigerID}, nil
+		},
+		streamTigerSightings: func(tigerID int, fn func(*models.TigerSighting) error) error {
+			streams++
+			for _, sighting := range sightings {
+				if err := fn(sighting); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	tigerService := NewTigerService(mockRepo, nil)
+
+	// Act
+	stats, err := tigerService.GetTigerStatsService(1)
+	cached, cachedErr := tigerService.GetTigerStatsService(1)
+
+	// Assert
+	assert.NoError(t, err)
+	distance := utils.CalculateDistance(models.Coordinates{Lat: 0, Long: 0}, models.Coordinates{Lat: 0, Long: 0.1}) +
+		utils.CalculateDistance(models.Coordinates{Lat: 0, Long: 0.1}, models.Coordinates{Lat: 0.1, Long: 0.1})
+	assert.Equal(t, 3, stats.Sightings)
+	assert.InDelta(t, distance, stats.DistanceKm, 1e-9)
+	assert.InDelta(t, distance/4, stats.AverageSpeedKmh, 1e-9, "Speed should be over the time from the first to the last sighting")
+	assert.InDelta(t, 61.8, stats.HomeRangeKm2, 0.5)
+	assert.Equal(t, start, *stats.FirstSeen)
+	assert.Equal(t, start.Add(4*time.Hour), *stats.LastSeen)
+	assert.Equal(t, 2, stats.Reporters, "Reporters should be counted once in any case")
+
+	assert.NoError(t, cachedErr)
+	assert.Same(t, stats, cached, "Stats should be cached")
+	assert.Equal(t, 1, streams)
+}
+
+func TestGetTigerStatsService_NoSightings(t *testing.T) {
+	// Arrange
+	tigerService := NewTigerService(&mockTigerRepo{
+		getTigerByID: func(tigerID int) (*models.Tiger, error) {
+			return &models.Tiger{ID: tigerID}, nil
+		},
+		streamTigerSightings: func(tigerID int, fn func(*models.TigerSighting) error) error {
+			return nil
+		},
+	}, nil)
+
+	// Act
+	stats, err := tigerService.GetTigerStatsService(1)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, &models.TigerStats{TigerID: 1}, stats)
+}
+
+func TestGetTigerStatsService_NotFound(t *testing.T) {
+	// Arrange
+	lookups := 0
+	tigerService := NewTigerService(&mockTigerRepo{
+		getTigerByID: func(tigerID int) (*models.Tiger, error) {
+			lookups++
+			return nil, repository.ErrNotFound
+		},
+	}, nil)
+
+	// Act
+	_, err := tigerService.GetTigerStatsService(1)
+	_, _ = tigerService.GetTigerStatsService(1)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrTigerNotFound)
+	assert.Equal(t, 2, lookups, "Errors should not be cached")
+}
+
+func TestStatsCache_Invalidation(t *testing.T) {
+	// Arrange
+	now := time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC)
+	streams := 0
+	var during func()
+	mockRepo := &mockTigerRepo{
+		getTigerByID: func(tigerID int) (*models.Tiger, error) {
+			return &models.Tiger{ID: tigerID}, nil
+		},
+		streamTigerSightings: func(tigerID int, fn func(*models.TigerSighting) error) error {
+			streams++
+			if during != nil {
+				during()
+				during = nil
+			}
+			return nil
+		},
+		deleteTiger: func(tigerID int) error {
+			return nil
+		},
+	}
+	s := NewTigerServiceWithOptions(mockRepo, nil, Options{StatsCacheTTL: time.Minute}).(service)
+	s.stats.now = func() time.Time { return now }
+
+	// Act & Assert
+	s.GetTigerStatsService(1)
+	s.GetTigerStatsService(2)
+	assert.NoError(t, s.DeleteTigerService(1))
+	s.GetTigerStatsService(1)
+	s.GetTigerStatsService(2)
+	assert.Equal(t, 3, streams, "Only the stats of the deleted tiger should be computed again")
+
+	now = now.Add(time.Minute)
+	s.GetTigerStatsService(2)
+	assert.Equal(t, 4, streams, "Expired stats should be computed again")
+
+	// Stats read while a sighting changes may miss the change, so they are not cached
+	during = func() { s.stats.invalidateTiger(3) }
+	s.GetTigerStatsService(3)
+	s.GetTigerStatsService(3)
+	assert.Equal(t, 6, streams)
+
+	// Without a TTL nothing is cached
+	uncached := NewTigerServiceWithOptions(mockRepo, nil, Options{})
+	uncached.GetTigerStatsService(1)
+	uncached.GetTigerStatsService(1)
+	assert.Equal(t, 8, streams)
+}
+
+func TestGetPopulationStatsService(t *testing.T) {
+	// Arrange
+	now := time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC)
+	days := []*models.DailySightings{{Day: time.Date(2023, time.July, 20, 0, 0, 0, 0, time.UTC), Sightings: 4}}
+	active := []*models.ActiveTiger{{TigerID: 1, Name: "Raja", Sightings: 4}}
+	queries := 0
+	mockRepo := &mockTigerRepo{
+		getDailySightingCounts: func(from, to time.Time) ([]*models.DailySightings, error) {
+			queries++
+			assert.Equal(t, time.Date(2023, time.July, 15, 0, 0, 0, 0, time.UTC), from, "Days should start at midnight")
+			assert.Equal(t, time.Date(2023, time.July, 22, 0, 0, 0, 0, time.UTC), to, "Today should be included")
+			return days, nil
+		},
+		getMostActiveTigers: func(from, to time.Time, limit int) ([]*models.ActiveTiger, error) {
+			assert.Equal(t, 5, limit)
+			return active, nil
+		},
+	}
+	s := NewTigerService(mockRepo, nil).(service)
+	s.stats.now = func() time.Time { return now }
+
+	// Act
+	stats, err := s.GetPopulationStatsService(7, 5)
+	s.GetPopulationStatsService(7, 5)
+	s.stats.invalidateTiger(1)
+	s.GetPopulationStatsService(7, 5)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, days, stats.SightingsPerDay)
+	assert.Equal(t, active, stats.MostActiveTigers)
+	assert.Equal(t, 2, queries, "Stats should be cached until a sighting changes")
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"tigerhall-kittens-app/pkg/models"
+	"tigerhall-kittens-app/pkg/repository"
+	"tigerhall-kittens-app/pkg/utils"
+)
+
+// Codes of the ValidationErrors returned by the sighting rules.
+const (
+	CodeTimestampInFuture    = "timestamp_in_future"
+	CodeTimestampBeforeBirth = "timestamp_before_birth"
+	CodeDuplicateSighting    = "duplicate_sighting"
+	CodeImpossibleSpeed      = "impossible_travel_speed"
+)
+
+// ValidationError is returned when a new sighting is not plausible, Code tells the
+// clients which rule it failed.
+type ValidationError struct {
+	Code    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// SightingRule checks a new sighting of the tiger before it is saved and returns a
+// *ValidationError when it fails. Rules run in the transaction saving the sighting,
+// with the tiger locked, and may look up its other sightings in tx.
+type SightingRule func(tx repository.TigerRepository, tiger *models.Tiger, sighting *models.TigerSighting) error
+
+// SightingRulesConfig configures DefaultSightingRules, a zero value disables its rule.
+type SightingRulesConfig struct {
+	// MaxClockSkew is how far in the future a timestamp may be
+	MaxClockSkew time.Duration
+	// MaxSpeedKmh is the fastest a tiger is believed to travel between two sightings
+	MaxSpeedKmh float64
+	// Sightings within DuplicateRadiusKm and DuplicateWindow of another are duplicates
+	DuplicateRadiusKm float64
+	DuplicateWindow   time.Duration
+}
+
+// DefaultSightingRulesConfig is used by NewTigerService.
+var DefaultSightingRulesConfig = SightingRulesConfig{
+	MaxClockSkew:      5 * time.Minute,
+	MaxSpeedKmh:       60,
+	DuplicateRadiusKm: 5,
+	DuplicateWindow:   time.Hour,
+}
+
+// DefaultSightingRules returns the rules enabled by config. Timestamps before the date
+// of birth of the tiger are always rejected.
+func DefaultSightingRules(config SightingRulesConfig) []SightingRule {
+	rules := []SightingRule{NotBeforeBirth()}
+	if config.MaxClockSkew > 0 {
+		rules = append(rules, NotInFuture(config.MaxClockSkew))
+	}
+	if config.DuplicateRadiusKm > 0 && config.DuplicateWindow > 0 {
+		rules = append(rules, NoDuplicate(config.DuplicateRadiusKm, config.DuplicateWindow))
+	}
+	if config.MaxSpeedKmh > 0 {
+		rules = append(rules, MaxTravelSpeed(config.MaxSpeedKmh))
+	}
+	return rules
+}
+
+// NotInFuture rejects sightings more than skew after the current time.
+func NotInFuture(skew time.Duration) SightingRule {
+	return func(tx repository.TigerRepository, tiger *models.Tiger, sighting *models.TigerSighting) error {
+		if sighting.Timestamp.After(time.Now().Add(skew)) {
+			return &ValidationError{Code: CodeTimestampInFuture, Message: "timestamp is in the future"}
+		}
+		return nil
+	}
+}
+
+// NotBeforeBirth rejects sightings before the date of birth of the tiger.
+func NotBeforeBirth() SightingRule {
+	return func(tx repository.TigerRepository, tiger *models.Tiger, sighting *models.TigerSighting) error {
+		if !tiger.DateOfBirth.IsZero() && sighting.Timestamp.Before(tiger.DateOfBirth) {
+			return &ValidationError{Code: CodeTimestampBeforeBirth, Message: "timestamp is before the date of birth of the tiger"}
+		}
+		return nil
+	}
+}
+
+// NoDuplicate rejects sightings within radiusKm of another sighting of the tiger less
+// than window apart, so that the tiger can be reported again at the same place later.
+// Only approved sightings and the pending sightings of the same reporter count.
+func NoDuplicate(radiusKm float64, window time.Duration) SightingRule {
+	return func(tx repository.TigerRepository, tiger *models.Tiger, sighting *models.TigerSighting) error {
+		others, err := tx.GetTigerSightingsBetween(sighting.TigerID, sighting.ReporterEmail, sighting.Timestamp.Add(-window), sighting.Timestamp.Add(window))
+		if err != nil {
+			return errors.New("failed to retrieve previous sighting")
+		}
+
+		for _, other := range others {
+			if distanceKm(other, sighting) <= radiusKm {
+				return &ValidationError{
+					Code:    CodeDuplicateSighting,
+					Message: fmt.Sprintf("a sighting within %g kilometers and %s already exists", radiusKm, window),
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// MaxTravelSpeed rejects sightings the tiger could only have reached from the sighting
+// before it, or the sighting after it, by going faster than maxSpeedKmh. The sightings
+// are picked like in NoDuplicate.
+func MaxTravelSpeed(maxSpeedKmh float64) SightingRule {
+	return func(tx repository.TigerRepository, tiger *models.Tiger, sighting *models.TigerSighting) error {
+		before, after, err := tx.GetAdjacentTigerSightings(sighting.TigerID, sighting.ReporterEmail, sighting.Timestamp)
+		if err != nil {
+			return errors.New("failed to retrieve previous sighting")
+		}
+
+		for _, other := range []*models.TigerSighting{before, after} {
+			if other == nil {
+				continue
+			}
+			hours := sighting.Timestamp.Sub(other.Timestamp).Hours()
+			if hours < 0 {
+				hours = -hours
+			}
+			if distanceKm(other, sighting) > maxSpeedKmh*hours {
+				return &ValidationError{
+					Code:    CodeImpossibleSpeed,
+					Message: fmt.Sprintf("the tiger cannot have traveled faster than %g km/h from sighting %d", maxSpeedKmh, other.ID),
+				}
+			}
+		}
+		return nil
+	}
+}
+
+func distanceKm(a, b *models.TigerSighting) float64 {
+	return utils.CalculateDistance(models.Coordinates{Lat: a.Lat, Long: a.Long}, models.Coordinates{Lat: b.Lat, Long: b.Long})
+}
+
+// The photo of a sighting contradicts it when it was taken further than PhotoMaxDistanceKm
+// away or more than PhotoMaxTimeDifference apart. The time difference is generous since
+// the time zone of the camera clock is unknown.
+const (
+	PhotoMaxDistanceKm     = 2.0
+	PhotoMaxTimeDifference = 24 * time.Hour
+)
+
+// photoMismatch tells how the EXIF data of the photo contradicts the sighting, it is
+// empty when it does not or when the photo has no EXIF data.
+func photoMismatch(sighting *models.TigerSighting) string {
+	var mismatches []string
+	if sighting.PhotoLat != nil && sighting.PhotoLong != nil {
+		photo := &models.TigerSighting{Lat: *sighting.PhotoLat, Long: *sighting.PhotoLong}
+		if distance := distanceKm(photo, sighting); distance > PhotoMaxDistanceKm {
+			mismatches = append(mismatches, fmt.Sprintf("photo was taken %.1f km away", distance))
+		}
+	}
+	if sighting.PhotoTimestamp != nil {
+		difference := sighting.Timestamp.Sub(*sighting.PhotoTimestamp)
+		if difference < 0 {
+			difference = -difference
+		}
+		if difference > PhotoMaxTimeDifference {
+			mismatches = append(mismatches, fmt.Sprintf("photo was taken %s apart", difference.Round(time.Minute)))
+		}
+	}
+	return strings.Join(mismatches, ", ")
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"tigerhall-kittens-app/pkg/models"
+)
+
+func validationCode(err error) string {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Code
+	}
+	return ""
+}
+
+func TestNotInFuture(t *testing.T) {
+	// Arrange
+	rule := NotInFuture(5 * time.Minute)
+	tiger := &models.Tiger{ID: 1}
+
+	// Act & Assert
+	assert.NoError(t, rule(nil, tiger, &models.TigerSighting{Timestamp: time.Now().Add(time.Minute)}), "Small clock skew should be allowed")
+	err := rule(nil, tiger, &models.TigerSighting{Timestamp: time.Now().Add(time.Hour)})
+	assert.Equal(t, CodeTimestampInFuture, validationCode(err))
+}
+
+func TestNotBeforeBirth(t *testing.T) {
+	// Arrange
+	rule := NotBeforeBirth()
+	tiger := &models.Tiger{ID: 1, DateOfBirth: time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)}
+
+	// Act & Assert
+	assert.NoError(t, rule(nil, tiger, &models.TigerSighting{Timestamp: time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC)}))
+	err := rule(nil, tiger, &models.TigerSighting{Timestamp: time.Date(2019, time.July, 21, 12, 0, 0, 0, time.UTC)})
+	assert.Equal(t, CodeTimestampBeforeBirth, validationCode(err))
+	assert.NoError(t, rule(nil, &models.Tiger{ID: 2}, &models.TigerSighting{Timestamp: time.Date(2019, time.July, 21, 12, 0, 0, 0, time.UTC)}), "Tigers without date of birth should not be checked")
+}
+
+func TestNoDuplicate(t *testing.T) {
+	timestamp := time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		others   []*models.TigerSighting
+		expected string
+	}{
+		{name: "no other sightings", others: []*models.TigerSighting{}},
+		{name: "same place", others: []*models.TigerSighting{{ID: 1, Lat: 12.35, Long: 56.79}}, expected: CodeDuplicateSighting},
+		{name: "other place", others: []*models.TigerSighting{{ID: 1, Lat: 12.55, Long: 56.79}}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			mockRepo := &mockTigerRepo{
+				getTigerSightingsBetween: func(tigerID int, reporterEmail string, from, to time.Time) ([]*models.TigerSighting, error) {
+					assert.Equal(t, "reporter@example.com", reporterEmail, "Only the pending sightings of the reporter should count")
+					assert.Equal(t, timestamp.Add(-2*time.Hour), from)
+					assert.Equal(t, timestamp.Add(2*time.Hour), to)
+					return tt.others, nil
+				},
+			}
+			rule := NoDuplicate(5, 2*time.Hour)
+
+			// Act
+			err := rule(mockRepo, &models.Tiger{ID: 1}, &models.TigerSighting{TigerID: 1, Timestamp: timestamp, Lat: 12.34, Long: 56.78, ReporterEmail: "reporter@example.com"})
+
+			// Assert
+			assert.Equal(t, tt.expected, validationCode(err))
+			if tt.expected == "" {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestMaxTravelSpeed(t *testing.T) {
+	timestamp := time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC)
+	// 12.34, 56.78 and 12.79, 56.78 are about 50 km apart
+	tests := []struct {
+		name     string
+		before   *models.TigerSighting
+		after    *models.TigerSighting
+		expected string
+	}{
+		{name: "first sighting"},
+		{name: "slow enough", before: &models.TigerSighting{ID: 1, Timestamp: timestamp.Add(-2 * time.Hour), Lat: 12.79, Long: 56.78}},
+		{name: "too fast from before", before: &models.TigerSighting{ID: 1, Timestamp: timestamp.Add(-30 * time.Minute), Lat: 12.79, Long: 56.78}, expected: CodeImpossibleSpeed},
+		{name: "too fast to after", after: &models.TigerSighting{ID: 2, Timestamp: timestamp.Add(30 * time.Minute), Lat: 12.79, Long: 56.78}, expected: CodeImpossibleSpeed},
+		{name: "elsewhere at the same time", before: &models.TigerSighting{ID: 1, Timestamp: timestamp, Lat: 12.35, Long: 56.78}, expected: CodeImpossibleSpeed},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			mockRepo := &mockTigerRepo{
+				getAdjacentTigerSightings: func(tigerID int, reporterEmail string, at time.Time) (*models.TigerSighting, *models.TigerSighting, error) {
+					return tt.before, tt.after, nil
+				},
+			}
+			rule := MaxTravelSpeed(60)
+
+			// Act
+			err := rule(mockRepo, &models.Tiger{ID: 1}, &models.TigerSighting{TigerID: 1, Timestamp: timestamp, Lat: 12.34, Long: 56.78})
+
+			// Assert
+			assert.Equal(t, tt.expected, validationCode(err))
+			if tt.expected == "" {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDefaultSightingRules(t *testing.T) {
+	assert.Len(t, DefaultSightingRules(DefaultSightingRulesConfig), 4)
+	assert.Len(t, DefaultSightingRules(SightingRulesConfig{}), 1, "Only the date of birth check should be enabled without configuration")
+}
+
+func TestPhotoMismatch(t *testing.T) {
+	timestamp := time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC)
+	float := func(f float64) *float64 { return &f }
+	at := func(t time.Time) *time.Time { return &t }
+	tests := []struct {
+		name     string
+		sighting models.TigerSighting
+		expected string
+	}{
+		{name: "no exif", sighting: models.TigerSighting{Timestamp: timestamp, Lat: 12.34, Long: 56.78}},
+		{name: "matching photo", sighting: models.TigerSighting{Timestamp: timestamp, Lat: 12.34, Long: 56.78, PhotoLat: float(12.345), PhotoLong: float(56.78), PhotoTimestamp: at(timestamp.Add(-5 * time.Hour))}},
+		{name: "far away", sighting: models.TigerSighting{Timestamp: timestamp, Lat: 12.34, Long: 56.78, PhotoLat: float(12.84), PhotoLong: float(56.78)}, expected: "photo was taken 55.6 km away"},
+		{name: "days apart", sighting: models.TigerSighting{Timestamp: timestamp, Lat: 12.34, Long: 56.78, PhotoTimestamp: at(timestamp.Add(-72 * time.Hour))}, expected: "photo was taken 72h0m0s apart"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, photoMismatch(&tt.sighting))
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrNotFound is returned when no object is stored under the requested key.
+var ErrNotFound = errors.New("object not found")
+
+// ImageStore keeps sighting images outside of the database. Objects are
+// addressed by slash separated keys such as "sightings/1/3f2a.jpeg".
+type ImageStore interface {
+	Put(key string, data []byte, contentType string) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// NewImageKey returns a unique key for a new image of the tiger.
+func NewImageKey(tigerID int, extension string) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate image key: %v", err)
+	}
+	return fmt.Sprintf("sightings/%d/%s.%s", tigerID, hex.EncodeToString(random), extension), nil
+}
+
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return fmt.Errorf("invalid object key %q", key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("invalid object key %q", key)
+		}
+	}
+	return nil
+}
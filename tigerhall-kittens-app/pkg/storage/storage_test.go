@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 is a minimal stand-in for an S3 compatible service keeping objects in memory.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	t       *testing.T
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	// Check the request is signed for the configured credentials and payload
+	sum := sha256.Sum256(body)
+	assert.Equal(f.t, hex.EncodeToString(sum[:]), r.Header.Get("x-amz-content-sha256"))
+	assert.True(f.t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=test-access-key/20230801/eu-west-1/s3/aws4_request"))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[r.URL.Path] = body
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		object, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(object)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestFileSystemStore(t *testing.T) {
+	store, err := NewFileSystemStore(t.TempDir())
+	assert.NoError(t, err)
+
+	// Store and read back an image
+	err = store.Put("sightings/1/image.jpeg", []byte("image data"), "image/jpeg")
+	assert.NoError(t, err)
+
+	reader, err := store.Get("sightings/1/image.jpeg")
+	assert.NoError(t, err)
+	data, err := ioutil.ReadAll(reader)
+	reader.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("image data"), data)
+
+	// Delete the image
+	assert.NoError(t, store.Delete("sightings/1/image.jpeg"))
+	_, err = store.Get("sightings/1/image.jpeg")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestFileSystemStore_RejectsPathTraversal(t *testing.T) {
+	store, err := NewFileSystemStore(t.TempDir())
+	assert.NoError(t, err)
+
+	err = store.Put("../outside.jpeg", []byte("image data"), "image/jpeg")
+	assert.Error(t, err)
+}
+
+func TestS3Store(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}, t: t}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store := NewS3Store(S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "tiger-images",
+		Region:    "eu-west-1",
+		AccessKey: "test-access-key",
+		SecretKey: "test-secret-key",
+	}, server.Client())
+	store.now = func() time.Time { return time.Date(2023, 8, 1, 12, 0, 0, 0, time.UTC) }
+
+	// Store an image in the bucket
+	err := store.Put("sightings/1/image.jpeg", []byte("image data"), "image/jpeg")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("image data"), fake.objects["/tiger-images/sightings/1/image.jpeg"])
+
+	// Read it back
+	reader, err := store.Get("sightings/1/image.jpeg")
+	assert.NoError(t, err)
+	data, err := ioutil.ReadAll(reader)
+	reader.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("image data"), data)
+
+	// Delete it
+	assert.NoError(t, store.Delete("sightings/1/image.jpeg"))
+	_, err = store.Get("sightings/1/image.jpeg")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestNewImageKey(t *testing.T) {
+	first, err := NewImageKey(7, "jpeg")
+	assert.NoError(t, err)
+	second, err := NewImageKey(7, "jpeg")
+	assert.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(first, "sightings/7/"))
+	assert.True(t, strings.HasSuffix(first, ".jpeg"))
+	assert.NotEqual(t, first, second)
+	assert.NoError(t, validateKey(first))
+}
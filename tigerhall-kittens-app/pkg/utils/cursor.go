@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"tigerhall-kittens-app/pkg/models"
+)
+
+// ErrInvalidCursor is returned by DecodeCursor for cursors not made by EncodeCursor.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeCursor encodes the cursor as an opaque string clients pass back unchanged to
+// get the next page. A zero time is left out, as cursors of sorts by text do not have
+// one and it is out of the range of UnixNano.
+func EncodeCursor(cursor models.Cursor) string {
+	nanos := ""
+	if !cursor.Time.IsZero() {
+		nanos = strconv.FormatInt(cursor.Time.UnixNano(), 10)
+	}
+	encoded := fmt.Sprintf("%s:%d", nanos, cursor.ID)
+	if cursor.Text != "" {
+		encoded += ":" + cursor.Text
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(encoded))
+}
+
+// DecodeCursor decodes a cursor encoded by EncodeCursor.
+func DecodeCursor(encoded string) (*models.Cursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	nanos, id, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &models.Cursor{}
+	// The text comes last, it may contain colons itself
+	id, cursor.Text, _ = strings.Cut(id, ":")
+	if nanos != "" {
+		unixNano, err := strconv.ParseInt(nanos, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		cursor.Time = time.Unix(0, unixNano).UTC()
+	}
+	if cursor.ID, err = strconv.Atoi(id); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return cursor, nil
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"tigerhall-kittens-app/pkg/models"
+)
+
+func TestCursor(t *testing.T) {
+	// Arrange
+	cursor := models.Cursor{Time: time.Date(2023, time.July, 21, 12, 0, 0, 123, time.UTC), ID: 42}
+
+	// Act
+	decoded, err := DecodeCursor(EncodeCursor(cursor))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, &cursor, decoded, "Cursor should survive encoding to the nanosecond")
+
+	text := models.Cursor{Text: "Shere: Khan", ID: 7}
+	decoded, err = DecodeCursor(EncodeCursor(text))
+	assert.NoError(t, err)
+	assert.Equal(t, &text, decoded, "Text with colons should survive encoding, without a time")
+
+	for _, invalid := range []string{"", "not base64!", EncodeCursor(cursor)[1:], "MTIzNA"} {
+		_, err := DecodeCursor(invalid)
+		assert.ErrorIs(t, err, ErrInvalidCursor, "Cursor %q should be invalid", invalid)
+	}
+}
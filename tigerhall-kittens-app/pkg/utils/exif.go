@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// exifTimeLayout is the layout of the EXIF date and time tags.
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// PhotoMetadata is the position and capture time read from the EXIF data of a photo.
+type PhotoMetadata struct {
+	// HasLocation is set when the photo has a GPS position
+	HasLocation bool
+	Lat         float64
+	Long        float64
+	// Timestamp is zero when the capture time is unknown. It is taken from the GPS
+	// time when present, otherwise from the camera clock read as UTC, as EXIF does
+	// not record the time zone of the camera.
+	Timestamp time.Time
+}
+
+// ReadPhotoMetadata reads the EXIF data of a JPEG photo. Photos without EXIF data, or
+// with unreadable tags, have no metadata for those tags.
+func ReadPhotoMetadata(photo []byte) PhotoMetadata {
+	var metadata PhotoMetadata
+
+	// Decode returns the tags it could read along with the error of the others
+	x, _ := exif.Decode(bytes.NewReader(photo))
+	if x == nil {
+		return metadata
+	}
+
+	if lat, long, err := x.LatLong(); err == nil && validCoordinates(lat, long) {
+		metadata.HasLocation = true
+		metadata.Lat = lat
+		metadata.Long = long
+	}
+
+	if timestamp, ok := gpsTime(x); ok {
+		metadata.Timestamp = timestamp
+	} else if timestamp, ok := cameraTime(x); ok {
+		metadata.Timestamp = timestamp
+	}
+
+	return metadata
+}
+
+// validCoordinates rejects the 0, 0 position some phones write without a GPS fix.
+func validCoordinates(lat, long float64) bool {
+	if math.IsNaN(lat) || math.IsNaN(long) || (lat == 0 && long == 0) {
+		return false
+	}
+	return lat >= -90 && lat <= 90 && long >= -180 && long <= 180
+}
+
+// gpsTime returns the UTC time of the GPS fix of the photo.
+func gpsTime(x *exif.Exif) (time.Time, bool) {
+	dateTag, err := x.Get(exif.GPSDateStamp)
+	if err != nil {
+		return time.Time{}, false
+	}
+	date, err := dateTag.StringVal()
+	if err != nil {
+		return time.Time{}, false
+	}
+	day, err := time.Parse("2006:01:02", strings.TrimRight(date, "\x00"))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	timeTag, err := x.Get(exif.GPSTimeStamp)
+	if err != nil || timeTag.Count < 3 {
+		return time.Time{}, false
+	}
+	var seconds float64
+	for i, unit := range []float64{3600, 60, 1} {
+		num, den, err := timeTag.Rat2(i)
+		if err != nil || den == 0 {
+			return time.Time{}, false
+		}
+		seconds += float64(num) / float64(den) * unit
+	}
+
+	return day.Add(time.Duration(seconds * float64(time.Second))), true
+}
+
+// cameraTime returns the time the photo was taken by the camera clock, read as UTC.
+func cameraTime(x *exif.Exif) (time.Time, bool) {
+	tag, err := x.Get(exif.DateTimeOriginal)
+	if err != nil {
+		if tag, err = x.Get(exif.DateTime); err != nil {
+			return time.Time{}, false
+		}
+	}
+	value, err := tag.StringVal()
+	if err != nil {
+		return time.Time{}, false
+	}
+	timestamp, err := time.Parse(exifTimeLayout, strings.TrimRight(value, "\x00"))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return timestamp, true
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testdata/tiger_exif.jpeg is a 40x20 photo, red on the left and blue on the right, with
+// EXIF orientation 6 (rotate 90° clockwise), a GPS position, GPS time and camera time.
+func readTestPhoto(t *testing.T) []byte {
+	photo, err := ioutil.ReadFile("testdata/tiger_exif.jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return photo
+}
+
+func TestReadPhotoMetadata(t *testing.T) {
+	// Act
+	metadata := ReadPhotoMetadata(readTestPhoto(t))
+
+	// Assert
+	assert.True(t, metadata.HasLocation, "GPS position should be read")
+	assert.InDelta(t, 12.84, metadata.Lat, 0.0001)
+	assert.InDelta(t, 56.78, metadata.Long, 0.0001)
+	assert.Equal(t, time.Date(2023, time.July, 21, 11, 30, 0, 0, time.UTC), metadata.Timestamp, "GPS time should be preferred to the camera clock")
+}
+
+func TestReadPhotoMetadata_NoExif(t *testing.T) {
+	// Arrange
+	var photo bytes.Buffer
+	jpeg.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 10, 10)), nil)
+
+	// Act
+	metadata := ReadPhotoMetadata(photo.Bytes())
+
+	// Assert
+	assert.Equal(t, PhotoMetadata{}, metadata, "Photos without EXIF data should have no metadata")
+	assert.Equal(t, PhotoMetadata{}, ReadPhotoMetadata([]byte("not an image")))
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"math"
+	"sort"
+
+	"tigerhall-kittens-app/pkg/models"
+)
+
+// planePoint is a position projected onto a plane, in km.
+type planePoint struct {
+	x, y float64
+}
+
+// HomeRangeKm2 returns the area in km² of the convex hull of the points, the smallest
+// convex polygon holding them all. The points are projected onto a plane around their
+// mean latitude, which is accurate for the range of a tiger but not for areas spanning
+// the antimeridian or a pole. Fewer than three points, or points on a line, have no area.
+func HomeRangeKm2(points []models.Coordinates) float64 {
+	if len(points) < 3 {
+		return 0
+	}
+
+	meanLat := 0.0
+	for _, p := range points {
+		meanLat += p.Lat
+	}
+	meanLat /= float64(len(points))
+	cosLat := math.Cos(meanLat * math.Pi / 180)
+
+	projected := make([]planePoint, len(points))
+	for i, p := range points {
+		projected[i] = planePoint{
+			x: earthRadiusKm * p.Long * math.Pi / 180 * cosLat,
+			y: earthRadiusKm * p.Lat * math.Pi / 180,
+		}
+	}
+
+	return polygonArea(convexHull(projected))
+}
+
+// convexHull returns the corners of the convex hull of the points counterclockwise, using
+// Andrew's monotone chain algorithm.
+func convexHull(points []planePoint) []planePoint {
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].x != points[j].x {
+			return points[i].x < points[j].x
+		}
+		return points[i].y < points[j].y
+	})
+
+	// cross is positive when o, a and b turn counterclockwise
+	cross := func(o, a, b planePoint) float64 {
+		return (a.x-o.x)*(b.y-o.y) - (a.y-o.y)*(b.x-o.x)
+	}
+
+	hull := make([]planePoint, 0, 2*len(points))
+	// Lower hull from left to right, then upper hull from right to left
+	for _, p := range points {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	lower := len(hull) + 1
+	for i := len(points) - 2; i >= 0; i-- {
+		p := points[i]
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+
+	// The first point closes the upper hull
+	return hull[:len(hull)-1]
+}
+
+// polygonArea returns the area of the polygon with the shoelace formula.
+func polygonArea(corners []planePoint) float64 {
+	area := 0.0
+	for i, p := range corners {
+		next := corners[(i+1)%len(corners)]
+		area += p.x*next.y - next.x*p.y
+	}
+	return math.Abs(area) / 2
+}
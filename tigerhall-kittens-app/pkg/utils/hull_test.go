@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"tigerhall-kittens-app/pkg/models"
+)
+
+func TestHomeRangeKm2(t *testing.T) {
+	// A square of 0.1° at the equator, with points inside it and on its edges which are
+	// not corners of the hull
+	square := []models.Coordinates{
+		{Lat: 0, Long: 0},
+		{Lat: 0.05, Long: 0.05},
+		{Lat: 0, Long: 0.1},
+		{Lat: 0.02, Long: 0.07},
+		{Lat: 0.1, Long: 0.1},
+		{Lat: 0.05, Long: 0},
+		{Lat: 0.1, Long: 0},
+		{Lat: 0.1, Long: 0.1},
+	}
+	// 0.1° is 11.12 km along the equator and along a meridian
+	assert.InDelta(t, 123.6, HomeRangeKm2(square), 0.5)
+
+	// Triangles are half of their bounding square
+	triangle := []models.Coordinates{{Lat: 0, Long: 0}, {Lat: 0, Long: 0.1}, {Lat: 0.1, Long: 0}}
+	assert.InDelta(t, 61.8, HomeRangeKm2(triangle), 0.5)
+
+	// Degrees of longitude are shorter away from the equator
+	north := make([]models.Coordinates, len(square))
+	for i, p := range square {
+		north[i] = models.Coordinates{Lat: p.Lat + 60, Long: p.Long}
+	}
+	assert.InDelta(t, 61.8, HomeRangeKm2(north), 0.5)
+
+	assert.Equal(t, 0.0, HomeRangeKm2(square[:2]), "Two points should have no area")
+	assert.Equal(t, 0.0, HomeRangeKm2([]models.Coordinates{{Lat: 1, Long: 1}, {Lat: 2, Long: 2}, {Lat: 3, Long: 3}}), "Points on a line should have no area")
+	assert.Equal(t, 0.0, HomeRangeKm2([]models.Coordinates{{Lat: 1, Long: 1}, {Lat: 1, Long: 1}, {Lat: 1, Long: 1}}), "Repeated points should have no area")
+}
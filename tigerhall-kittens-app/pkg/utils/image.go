@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+
+	"github.com/disintegration/imaging"
+	// Register the WebP decoder, WebP photos are accepted but not encoded
+	_ "golang.org/x/image/webp"
+)
+
+// OriginalRendition names the photo at its uploaded size, next to its Renditions.
+const OriginalRendition = "original"
+
+// originalJPEGQuality is higher than the quality of the renditions, the original is
+// re-encoded only to drop the metadata of the upload.
+const originalJPEGQuality = 95
+
+// Formats renditions may be encoded in.
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+)
+
+// Errors returned by CheckImage and RenderPhoto.
+var (
+	ErrUnsupportedImage = errors.New("unsupported image format")
+	ErrImageTooLarge    = errors.New("image is too large")
+)
+
+// photoContentTypes are the content types photos may be uploaded in, as sniffed by
+// http.DetectContentType.
+var photoContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// Rendition is a resized copy of every sighting photo, encoded in each of Formats.
+type Rendition struct {
+	Name   string
+	Width  int
+	Height int
+	// Crop fills Width x Height cutting off the edges of the photo, otherwise the photo
+	// is made to fit within them keeping its aspect ratio. Photos are never enlarged to fit.
+	Crop    bool
+	Formats []string
+}
+
+// DefaultRenditions are used when no renditions are configured: a square thumbnail
+// for lists and a medium size preserving the aspect ratio, good enough to compare the
+// stripes of tigers.
+var DefaultRenditions = []Rendition{
+	{Name: "thumbnail", Width: 200, Height: 200, Crop: true, Formats: []string{FormatJPEG, FormatPNG}},
+	{Name: "medium", Width: 1024, Height: 1024, Formats: []string{FormatJPEG, FormatPNG}},
+}
+
+// RenderedImage is a photo in one rendition and format.
+type RenderedImage struct {
+	Rendition   string
+	Format      string
+	ContentType string
+	Width       int
+	Height      int
+	Data        []byte
+}
+
+// ValidateRenditions checks that every rendition has a unique name, a size and known formats.
+func ValidateRenditions(renditions []Rendition) error {
+	names := map[string]bool{OriginalRendition: true}
+	for _, rendition := range renditions {
+		if rendition.Name == "" || names[rendition.Name] {
+			return fmt.Errorf("rendition name %q is empty or used twice", rendition.Name)
+		}
+		names[rendition.Name] = true
+
+		if rendition.Width < 1 || rendition.Height < 1 {
+			return fmt.Errorf("rendition %s must have a width and a height", rendition.Name)
+		}
+		if len(rendition.Formats) == 0 {
+			return fmt.Errorf("rendition %s must have a format", rendition.Name)
+		}
+		for _, format := range rendition.Formats {
+			if format != FormatJPEG && format != FormatPNG {
+				return fmt.Errorf("rendition %s has unknown format %q", rendition.Name, format)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckImage checks that the photo is a JPEG, PNG or WebP image no wider or higher than
+// maxDimension with at most maxPixels pixels, and returns its format. Only the header of
+// the image is decoded, so that a small file claiming a huge size cannot exhaust memory.
+func CheckImage(photo []byte, maxDimension, maxPixels int) (string, error) {
+	if !photoContentTypes[http.DetectContentType(photo)] {
+		return "", ErrUnsupportedImage
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(photo))
+	if err != nil {
+		return "", ErrUnsupportedImage
+	}
+	if config.Width > maxDimension || config.Height > maxDimension || config.Width*config.Height > maxPixels {
+		return "", fmt.Errorf("%w: %dx%d pixels", ErrImageTooLarge, config.Width, config.Height)
+	}
+	return format, nil
+}
+
+// RenderPhoto returns the photo at its uploaded size followed by each of its renditions.
+// Photos are turned upright first as told by their EXIF orientation. Every image is
+// re-encoded, including the original, so that none keeps the EXIF data of the upload
+// such as the GPS position or the serial number of the camera. PNG originals stay PNG,
+// the others become JPEG. Only JPEG, PNG and WebP photos are rendered, their size is
+// checked by CheckImage.
+func RenderPhoto(photo []byte, renditions []Rendition) ([]RenderedImage, error) {
+	if !photoContentTypes[http.DetectContentType(photo)] {
+		return nil, ErrUnsupportedImage
+	}
+	_, format, err := image.DecodeConfig(bytes.NewReader(photo))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	img, err := imaging.Decode(bytes.NewReader(photo), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+
+	if format != FormatPNG {
+		format = FormatJPEG
+	}
+	original, err := encodeImage(img, format, originalJPEGQuality)
+	if err != nil {
+		return nil, err
+	}
+	images := []RenderedImage{{
+		Rendition:   OriginalRendition,
+		Format:      format,
+		ContentType: "image/" + format,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+		Data:        original,
+	}}
+
+	for _, rendition := range renditions {
+		var resized image.Image
+		if rendition.Crop {
+			resized = imaging.Fill(img, rendition.Width, rendition.Height, imaging.Center, imaging.Lanczos)
+		} else {
+			resized = imaging.Fit(img, rendition.Width, rendition.Height, imaging.Lanczos)
+		}
+
+		for _, format := range rendition.Formats {
+			data, err := encodeImage(resized, format, 85)
+			if err != nil {
+				return nil, err
+			}
+			images = append(images, RenderedImage{
+				Rendition:   rendition.Name,
+				Format:      format,
+				ContentType: "image/" + format,
+				Width:       resized.Bounds().Dx(),
+				Height:      resized.Bounds().Dy(),
+				Data:        data,
+			})
+		}
+	}
+
+	return images, nil
+}
+
+func encodeImage(img image.Image, format string, jpegQuality int) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case FormatJPEG:
+		err = imaging.Encode(&buf, img, imaging.JPEG, imaging.JPEGQuality(jpegQuality))
+	case FormatPNG:
+		err = imaging.Encode(&buf, img, imaging.PNG)
+	default:
+		err = fmt.Errorf("unknown image format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
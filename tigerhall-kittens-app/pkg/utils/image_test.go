@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/gif"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderPhoto(t *testing.T) {
+	// Arrange
+	renditions := []Rendition{
+		{Name: "thumbnail", Width: 10, Height: 10, Crop: true, Formats: []string{FormatJPEG}},
+		{Name: "medium", Width: 20, Height: 20, Formats: []string{FormatJPEG, FormatPNG}},
+	}
+	photo := readTestPhoto(t)
+
+	// Act
+	images, err := RenderPhoto(photo, renditions)
+
+	// Assert
+	assert.NoError(t, err)
+	if !assert.Len(t, images, 4) {
+		return
+	}
+
+	// The 40x20 photo is 20x40 once turned upright
+	expected := []struct {
+		rendition, format string
+		width, height     int
+	}{
+		{OriginalRendition, "jpeg", 20, 40},
+		{"thumbnail", "jpeg", 10, 10},
+		{"medium", "jpeg", 10, 20},
+		{"medium", "png", 10, 20},
+	}
+	for i, e := range expected {
+		rendered := images[i]
+		assert.Equal(t, PhotoMetadata{}, ReadPhotoMetadata(rendered.Data), "%s.%s should not keep the EXIF data of the upload", e.rendition, e.format)
+		assert.Equal(t, e.rendition, rendered.Rendition)
+		assert.Equal(t, e.format, rendered.Format)
+		assert.Equal(t, "image/"+e.format, rendered.ContentType)
+
+		img, format, err := image.Decode(bytes.NewReader(rendered.Data))
+		assert.NoError(t, err)
+		assert.Equal(t, e.format, format, "Rendition should be encoded in its format")
+		assert.Equal(t, e.width, img.Bounds().Dx(), "Width of %s.%s", e.rendition, e.format)
+		assert.Equal(t, e.height, img.Bounds().Dy(), "Height of %s.%s", e.rendition, e.format)
+		assert.Equal(t, e.width, rendered.Width)
+		assert.Equal(t, e.height, rendered.Height)
+	}
+}
+
+func TestRenderPhoto_Orientation(t *testing.T) {
+	// Act
+	images, err := RenderPhoto(readTestPhoto(t), []Rendition{{Name: "medium", Width: 20, Height: 40, Formats: []string{FormatPNG}}})
+
+	// Assert
+	assert.NoError(t, err)
+	img, _, err := image.Decode(bytes.NewReader(images[1].Data))
+	assert.NoError(t, err)
+
+	// Turned upright the red half of the photo is at the top
+	r, _, b, _ := img.At(15, 5).RGBA()
+	assert.Greater(t, r, b, "Top of the rendition should be red")
+	r, _, b, _ = img.At(15, 35).RGBA()
+	assert.Greater(t, b, r, "Bottom of the rendition should be blue")
+}
+
+func TestRenderPhoto_Unsupported(t *testing.T) {
+	_, err := RenderPhoto([]byte("not an image"), DefaultRenditions)
+	assert.ErrorIs(t, err, ErrUnsupportedImage)
+}
+
+// pngHeader returns the start of a PNG image of width x height, enough to decode its config.
+func pngHeader(width, height uint32) []byte {
+	var ihdr bytes.Buffer
+	ihdr.WriteString("IHDR")
+	binary.Write(&ihdr, binary.BigEndian, width)
+	binary.Write(&ihdr, binary.BigEndian, height)
+	// 8 bit RGBA, default compression, filter and no interlacing
+	ihdr.Write([]byte{8, 6, 0, 0, 0})
+
+	var header bytes.Buffer
+	header.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&header, binary.BigEndian, uint32(ihdr.Len()-4))
+	header.Write(ihdr.Bytes())
+	binary.Write(&header, binary.BigEndian, crc32.ChecksumIEEE(ihdr.Bytes()))
+	return header.Bytes()
+}
+
+func TestCheckImage(t *testing.T) {
+	webp, err := ioutil.ReadFile("testdata/tiger.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gifImage bytes.Buffer
+	gif.Encode(&gifImage, image.NewRGBA(image.Rect(0, 0, 10, 10)), nil)
+
+	tests := []struct {
+		name     string
+		photo    []byte
+		format   string
+		expected error
+	}{
+		{name: "jpeg", photo: readTestPhoto(t), format: "jpeg"},
+		{name: "webp", photo: webp, format: "webp"},
+		{name: "small png", photo: pngHeader(100, 100), format: "png"},
+		{name: "gif", photo: gifImage.Bytes(), expected: ErrUnsupportedImage},
+		{name: "not an image", photo: []byte("<html></html>"), expected: ErrUnsupportedImage},
+		{name: "jpeg header only", photo: []byte("\xff\xd8\xff"), expected: ErrUnsupportedImage},
+		{name: "too wide", photo: pngHeader(5000, 10), expected: ErrImageTooLarge},
+		{name: "too many pixels", photo: pngHeader(2000, 2000), expected: ErrImageTooLarge},
+		// A few bytes claiming to be 16 billion pixels must be rejected before being decoded
+		{name: "decompression bomb", photo: pngHeader(1<<17, 1<<17), expected: ErrImageTooLarge},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			format, err := CheckImage(tt.photo, 4096, 1000000)
+
+			// Assert
+			assert.ErrorIs(t, err, tt.expected)
+			assert.Equal(t, tt.format, format)
+		})
+	}
+}
+
+func TestRenderPhoto_WebP(t *testing.T) {
+	// Arrange
+	webp, err := ioutil.ReadFile("testdata/tiger.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	images, err := RenderPhoto(webp, []Rendition{{Name: "small", Width: 10, Height: 10, Formats: []string{FormatJPEG}}})
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.Len(t, images, 2) {
+		assert.Equal(t, "image/jpeg", images[0].ContentType, "WebP originals should be encoded as JPEG")
+		assert.Equal(t, "image/jpeg", images[1].ContentType)
+	}
+}
+
+func TestValidateRenditions(t *testing.T) {
+	assert.NoError(t, ValidateRenditions(DefaultRenditions))
+	assert.NoError(t, ValidateRenditions(nil), "Keeping only the original should be allowed")
+	assert.Error(t, ValidateRenditions([]Rendition{{Name: OriginalRendition, Width: 10, Height: 10, Formats: []string{FormatJPEG}}}))
+	assert.Error(t, ValidateRenditions([]Rendition{{Name: "small", Width: 10, Height: 10, Formats: []string{FormatJPEG}}, {Name: "small", Width: 20, Height: 20, Formats: []string{FormatJPEG}}}))
+	assert.Error(t, ValidateRenditions([]Rendition{{Name: "small", Formats: []string{FormatJPEG}}}))
+	assert.Error(t, ValidateRenditions([]Rendition{{Name: "small", Width: 10, Height: 10, Formats: []string{"webp"}}}))
+}
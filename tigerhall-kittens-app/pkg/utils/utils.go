@@ -1,39 +1,110 @@
 package utils
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"image"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/disintegration/imaging"
 	"github.com/umahmood/haversine"
 	"tigerhall-kittens-app/pkg/models"
 )
 
+// earthRadiusKm matches the radius used by haversine for CalculateDistance.
+const earthRadiusKm = 6371
+
+// EmailTemplate is one email of the message format published before SightingNotification.
+// Messages in this format may still be waiting in the queue.
 type EmailTemplate struct {
 	Sub       string `json:"subject"`
 	Body      string `json:"body"`
 	Recipient string `json:"recipient"`
 }
 
-func GetMails(previousSightings []*models.TigerSighting) []byte {
-	var emails []EmailTemplate
+// SightingNotification is the message published for a new sighting of a tiger.
+type SightingNotification struct {
+	TigerID    int       `json:"tigerID"`
+	TigerName  string    `json:"tigerName"`
+	SightingID int       `json:"sightingID"`
+	Lat        float64   `json:"lat"`
+	Long       float64   `json:"long"`
+	Timestamp  time.Time `json:"timestamp"`
+	// Recipients are the reporters of the previous sightings and the followers of the
+	// tiger, each listed once
+	Recipients []string `json:"recipients"`
+}
+
+// GetSightingNotification builds the notification about the new sighting of the tiger for
+// everyone who reported it before and its followers. Everyone is notified once, except the
+// reporter of the new sighting.
+func GetSightingNotification(tiger *models.Tiger, newSighting *models.TigerSighting, previousSightings []*models.TigerSighting, followers []string) ([]byte, error) {
+	notification := SightingNotification{
+		TigerID:    newSighting.TigerID,
+		TigerName:  tiger.Name,
+		SightingID: newSighting.ID,
+		Lat:        newSighting.Lat,
+		Long:       newSighting.Long,
+		Timestamp:  newSighting.Timestamp,
+		Recipients: []string{},
+	}
+
+	seen := map[string]bool{NormalizeEmail(newSighting.ReporterEmail): true}
+	addRecipient := func(email string) {
+		recipient := NormalizeEmail(email)
+		if recipient == "" || seen[recipient] {
+			return
+		}
+		seen[recipient] = true
+		notification.Recipients = append(notification.Recipients, recipient)
+	}
 	for _, pr := range previousSightings {
-		emails = append(emails, EmailTemplate{
-			Sub:       "Tiger Sights",
-			Body:      fmt.Sprintf(`Tiger_%v is found at {Lat: %v,Long: %v}`, pr.TigerID, pr.Lat, pr.Long),
-			Recipient: pr.ReporterEmail,
-		})
+		addRecipient(pr.ReporterEmail)
 	}
+	for _, follower := range followers {
+		addRecipient(follower)
+	}
+
+	notificationJSON, err := json.Marshal(notification)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode sighting notification: %v", err)
+	}
+	return notificationJSON, nil
+}
+
+// AccountEmail is the message published to mail a user a single use token, Type is the
+// purpose of the token, see models.TokenPurposeEmailVerification and models.TokenPurposePasswordReset.
+// The message only references the token by TokenID, the token is minted when the email
+// is sent so that it is never kept in the outbox or the message broker.
+type AccountEmail struct {
+	Type      string    `json:"type"`
+	Recipient string    `json:"recipient"`
+	Username  string    `json:"username"`
+	TokenID   int       `json:"tokenID"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
 
-	emailsJSON, err := json.Marshal(emails)
+// GetAccountEmail builds the message mailing the token for the purpose to the user.
+func GetAccountEmail(purpose string, user *models.User, tokenID int, expiresAt time.Time) ([]byte, error) {
+	emailJSON, err := json.Marshal(AccountEmail{
+		Type:      purpose,
+		Recipient: user.Email,
+		Username:  user.Username,
+		TokenID:   tokenID,
+		ExpiresAt: expiresAt,
+	})
 	if err != nil {
-		fmt.Println("Error marshaling to JSON:", err)
-		return nil
+		return nil, fmt.Errorf("failed to encode account email: %v", err)
 	}
-	return []byte(emailsJSON)
+	return emailJSON, nil
+}
+
+// NormalizeEmail lower cases the email address and trims surrounding spaces,
+// so that the same address is always stored and compared the same way.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
 
 func CalculateDistance(coord1, coord2 models.Coordinates) float64 {
@@ -45,24 +116,27 @@ func CalculateDistance(coord1, coord2 models.Coordinates) float64 {
 	return distanceKm
 }
 
-func ResizeImage(imageBytes []byte, width, height int) ([]byte, error) {
-	// Decode the imageBytes into an image.Image
-	img, _, err := image.Decode(bytes.NewReader(imageBytes))
-	if err != nil {
-		return nil, err
-	}
+// BoundingBoxAround returns the smallest bounding box holding every point within
+// radiusKm of the center. Boxes are clamped at the poles and at the antimeridian.
+func BoundingBoxAround(center models.Coordinates, radiusKm float64) models.BoundingBox {
+	deltaLat := radiusKm / earthRadiusKm * 180 / math.Pi
 
-	// Resize the image using the Lanczos filter
-	resizedImg := imaging.Resize(img, width, height, imaging.Lanczos)
+	box := models.BoundingBox{
+		MinLat:  math.Max(center.Lat-deltaLat, -90),
+		MaxLat:  math.Min(center.Lat+deltaLat, 90),
+		MinLong: -180,
+		MaxLong: 180,
+	}
 
-	// Encode the resized image back to bytes
-	var buf bytes.Buffer
-	err = imaging.Encode(&buf, resizedImg, imaging.JPEG)
-	if err != nil {
-		return nil, err
+	// Degrees of longitude shrink towards the poles, near them every longitude is in range
+	cosLat := math.Cos(center.Lat * math.Pi / 180)
+	if box.MinLat > -90 && box.MaxLat < 90 && cosLat > 0 {
+		deltaLong := deltaLat / cosLat
+		box.MinLong = math.Max(center.Long-deltaLong, -180)
+		box.MaxLong = math.Min(center.Long+deltaLong, 180)
 	}
 
-	return buf.Bytes(), nil
+	return box
 }
 
 func RespondWithError(w http.ResponseWriter, code int, message string) {
@@ -78,6 +152,22 @@ func RespondWithError(w http.ResponseWriter, code int, message string) {
 	w.Write(jsonResponse)
 }
 
+// RespondWithErrorCode responds with the error message and a code identifying the error.
+func RespondWithErrorCode(w http.ResponseWriter, status int, code, message string) {
+	RespondWithJSON(w, status, map[string]string{"error": message, "code": code})
+}
+
+// RespondWithTooManyRequests responds with 429 and a Retry-After header telling the
+// client how many seconds to wait before trying again.
+func RespondWithTooManyRequests(w http.ResponseWriter, retryAfter time.Duration, message string) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	RespondWithError(w, http.StatusTooManyRequests, message)
+}
+
 func RespondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	jsonResponse, err := json.Marshal(payload)
 	if err != nil {
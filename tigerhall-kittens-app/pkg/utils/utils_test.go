@@ -5,49 +5,49 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"tigerhall-kittens-app/pkg/models"
 )
 
-func TestGetMails(t *testing.T) {
-	// Create a slice of previousSightings to be used for testing
+func TestGetSightingNotification(t *testing.T) {
+	// Create the new sighting and a slice of previousSightings to be used for testing
+	tiger := &models.Tiger{ID: 1, Name: "Mufasa"}
+	newSighting := &models.TigerSighting{
+		ID:            3,
+		TigerID:       1,
+		Timestamp:     time.Date(2023, time.July, 21, 12, 0, 0, 0, time.UTC),
+		Lat:           40.7128,
+		Long:          -74.0060,
+		ReporterEmail: "reporter@example.com",
+	}
 	previousSightings := []*models.TigerSighting{
-		{
-			TigerID:       1,
-			Lat:           40.7128,
-			Long:          -74.0060,
-			ReporterEmail: "test1@example.com",
-		},
-		{
-			TigerID:       2,
-			Lat:           34.0522,
-			Long:          -118.2437,
-			ReporterEmail: "test2@example.com",
-		},
+		{TigerID: 1, Lat: 40.8128, Long: -74.1060, ReporterEmail: "test1@example.com"},
+		{TigerID: 1, Lat: 34.0522, Long: -118.2437, ReporterEmail: "test2@example.com"},
+		{TigerID: 1, Lat: 34.1522, Long: -118.3437, ReporterEmail: " Test1@Example.com"},
+		{TigerID: 1, Lat: 40.7128, Long: -74.0060, ReporterEmail: "reporter@example.com"},
 	}
 
-	expectedEmails := []EmailTemplate{
-		{
-			Sub:       "Tiger Sights",
-			Body:      "Tiger_1 is found at {Lat: 40.7128,Long: -74.006}",
-			Recipient: "test1@example.com",
-		},
-		{
-			Sub:       "Tiger Sights",
-			Body:      "Tiger_2 is found at {Lat: 34.0522,Long: -118.2437}",
-			Recipient: "test2@example.com",
-		},
+	expectedNotification := SightingNotification{
+		TigerID:    1,
+		TigerName:  "Mufasa",
+		SightingID: 3,
+		Lat:        40.7128,
+		Long:       -74.0060,
+		Timestamp:  newSighting.Timestamp,
+		Recipients: []string{"test1@example.com", "test2@example.com", "follower@example.com"},
 	}
+	followers := []string{"follower@example.com", "test2@example.com", "reporter@example.com"}
 
-	emailsJSON := GetMails(previousSightings)
-	assert.NotNil(t, emailsJSON)
+	notificationJSON, err := GetSightingNotification(tiger, newSighting, previousSightings, followers)
+	assert.NoError(t, err)
 
-	// Unmarshal the JSON to EmailTemplate slice for comparison
-	var actualEmails []EmailTemplate
-	err := json.Unmarshal(emailsJSON, &actualEmails)
+	// Unmarshal the JSON to SightingNotification for comparison
+	var actualNotification SightingNotification
+	err = json.Unmarshal(notificationJSON, &actualNotification)
 	assert.NoError(t, err)
-	assert.Equal(t, expectedEmails, actualEmails)
+	assert.Equal(t, expectedNotification, actualNotification)
 }
 
 func TestCalculateDistance(t *testing.T) {
@@ -63,6 +63,25 @@ func TestCalculateDistance(t *testing.T) {
 	assert.InDelta(t, expectedDistance, distance, 0.1)
 }
 
+func TestBoundingBoxAround(t *testing.T) {
+	center := models.Coordinates{Lat: 12.34, Long: 56.78}
+
+	box := BoundingBoxAround(center, 10)
+
+	// Every point 10 km north, south, east and west of the center lies in the box
+	assert.InDelta(t, 12.25, box.MinLat, 0.01)
+	assert.InDelta(t, 12.43, box.MaxLat, 0.01)
+	assert.InDelta(t, 10, CalculateDistance(center, models.Coordinates{Lat: center.Lat, Long: box.MaxLong}), 0.1)
+	assert.InDelta(t, 10, CalculateDistance(center, models.Coordinates{Lat: box.MinLat, Long: center.Long}), 0.1)
+	assert.True(t, box.Contains(center))
+
+	// Near the poles the box covers every longitude
+	polarBox := BoundingBoxAround(models.Coordinates{Lat: 89.99, Long: 10}, 10)
+	assert.Equal(t, 90.0, polarBox.MaxLat)
+	assert.Equal(t, -180.0, polarBox.MinLong)
+	assert.Equal(t, 180.0, polarBox.MaxLong)
+}
+
 //// Mock struct for image.Image
 //type mockImage struct{}
 //